@@ -23,6 +23,7 @@ var (
 	homePath          string
 	overlayPath       []string
 	scratchPath       []string
+	tmpfsMounts       []string
 	workdirPath       string
 	cwdPath           string
 	shellPath         string
@@ -40,6 +41,8 @@ var (
 	noMount           []string
 	dmtcpLaunch       string
 	dmtcpRestart      string
+	criuRestore       string
+	statsFile         string
 
 	isBoot          bool
 	isFakeroot      bool
@@ -49,6 +52,7 @@ var (
 	isContainAll    bool
 	isWritable      bool
 	isWritableTmpfs bool
+	readOnlyStrict  bool
 	nvidia          bool
 	nvCCLI          bool
 	rocm            bool
@@ -85,7 +89,14 @@ var (
 	memorySwap        string // bytes
 	oomKillDisable    bool
 	pidsLimit         int
+	memoryHigh        string // bytes
+	cpuWeight         int
+	ioMax             []string
+	hugepages         []string
+	rdma              []string
+	cgroupSet         []string
 	unsquash          bool
+	lazyPull          bool
 
 	ignoreSubuid      bool
 	ignoreFakerootCmd bool
@@ -117,7 +128,7 @@ var actionBindFlag = cmdline.Flag{
 	DefaultValue: cmdline.StringArray{}, // to allow commas in bind path
 	Name:         "bind",
 	ShortHand:    "B",
-	Usage:        "a user-bind path specification.  spec has the format src[:dest[:opts]], where src and dest are outside and inside paths.  If dest is not given, it is set equal to src.  Mount options ('opts') may be specified as 'ro' (read-only) or 'rw' (read/write, which is the default). Multiple bind paths can be given by a comma separated list.",
+	Usage:        "a user-bind path specification.  spec has the format src[:dest[:opts]], where src and dest are outside and inside paths.  If dest is not given, it is set equal to src.  Mount options ('opts') may be specified as 'ro' (read-only) or 'rw' (read/write, which is the default), and 'idmap[=UID]' with --fakeroot or --userns to make the files of the invoking user owned by the container user UID, or by the container user if omitted. Multiple bind paths can be given by a comma separated list.",
 	EnvKeys:      []string{"BIND", "BINDPATH"},
 	Tag:          "<spec>",
 	EnvHandler:   cmdline.EnvAppendValue,
@@ -171,6 +182,17 @@ var actionScratchFlag = cmdline.Flag{
 	Tag:          "<path>",
 }
 
+// --tmpfs
+var actionTmpfsFlag = cmdline.Flag{
+	ID:           "actionTmpfsFlag",
+	Value:        &tmpfsMounts,
+	DefaultValue: []string{},
+	Name:         "tmpfs",
+	Usage:        "mount a tmpfs filesystem within the container, with optional size, mode, uid, gid, nr_inodes, noexec and noatime options (e.g. /var/run:size=64M,mode=755)",
+	EnvKeys:      []string{"TMPFS"},
+	Tag:          "<path>[:<options>]",
+}
+
 // -W|--workdir
 var actionWorkdirFlag = cmdline.Flag{
 	ID:           "actionWorkdirFlag",
@@ -437,6 +459,16 @@ var actionWritableTmpfsFlag = cmdline.Flag{
 	EnvKeys:      []string{"WRITABLE_TMPFS"},
 }
 
+// --read-only-strict
+var actionReadOnlyStrictFlag = cmdline.Flag{
+	ID:           "actionReadOnlyStrictFlag",
+	Value:        &readOnlyStrict,
+	DefaultValue: false,
+	Name:         "read-only-strict",
+	Usage:        "mount the container and all binds read-only, only --tmpfs mounts are writable, and refuse any overlay",
+	EnvKeys:      []string{"READ_ONLY_STRICT"},
+}
+
 // --no-home
 var actionNoHomeFlag = cmdline.Flag{
 	ID:           "actionNoHomeFlag",
@@ -670,6 +702,26 @@ var actionDMTCPRestartFlag = cmdline.Flag{
 	EnvKeys:      []string{"DMTCP_RESTART"},
 }
 
+// --restore
+var actionCRIURestoreFlag = cmdline.Flag{
+	ID:           "actionCRIURestoreFlag",
+	Value:        &criuRestore,
+	DefaultValue: "",
+	Name:         "restore",
+	Usage:        "checkpoint for criu to restore instance processes from (experimental)",
+	EnvKeys:      []string{"RESTORE"},
+}
+
+// --stats-file
+var actionStatsFileFlag = cmdline.Flag{
+	ID:           "actionStatsFileFlag",
+	Value:        &statsFile,
+	DefaultValue: "",
+	Name:         "stats-file",
+	Usage:        "write container resource usage in JSON format to the given file when the container exits",
+	EnvKeys:      []string{"STATS_FILE"},
+}
+
 // --blkio-weight
 var actionBlkioWeightFlag = cmdline.Flag{
 	ID:           "actionBlkioWeight",
@@ -780,6 +832,66 @@ var actionPidsLimitFlag = cmdline.Flag{
 	EnvKeys:      []string{"PIDS_LIMIT"},
 }
 
+// --memory-high
+var actionMemoryHighFlag = cmdline.Flag{
+	ID:           "actionMemoryHigh",
+	Value:        &memoryHigh,
+	DefaultValue: "",
+	Name:         "memory-high",
+	Usage:        "Memory throttling threshold in bytes (cgroups v2)",
+	EnvKeys:      []string{"MEMORY_HIGH"},
+}
+
+// --cpu-weight
+var actionCPUWeightFlag = cmdline.Flag{
+	ID:           "actionCPUWeight",
+	Value:        &cpuWeight,
+	DefaultValue: 0,
+	Name:         "cpu-weight",
+	Usage:        "CPU relative weight in range 1-10000, 0 to disable (cgroups v2)",
+	EnvKeys:      []string{"CPU_WEIGHT"},
+}
+
+// --io-max
+var actionIOMaxFlag = cmdline.Flag{
+	ID:           "actionIOMax",
+	Value:        &ioMax,
+	DefaultValue: []string{},
+	Name:         "io-max",
+	Usage:        "Device specific IO limits in <device>:<rbps|wbps|riops|wiops>=<limit>[,...] format",
+	EnvKeys:      []string{"IO_MAX"},
+}
+
+// --hugepages
+var actionHugepagesFlag = cmdline.Flag{
+	ID:           "actionHugepages",
+	Value:        &hugepages,
+	DefaultValue: []string{},
+	Name:         "hugepages",
+	Usage:        "Hugepages usage limit in <pagesize>:<limit> format, e.g. 2MB:1G",
+	EnvKeys:      []string{"HUGEPAGES"},
+}
+
+// --rdma
+var actionRdmaFlag = cmdline.Flag{
+	ID:           "actionRdma",
+	Value:        &rdma,
+	DefaultValue: []string{},
+	Name:         "rdma",
+	Usage:        "RDMA device limits in <device>:hca_handle=<n>,hca_object=<n> format",
+	EnvKeys:      []string{"RDMA"},
+}
+
+// --cgroup-set
+var actionCgroupSetFlag = cmdline.Flag{
+	ID:           "actionCgroupSet",
+	Value:        &cgroupSet,
+	DefaultValue: []string{},
+	Name:         "cgroup-set",
+	Usage:        "Set a cgroups v2 interface file of the container cgroup, in <controller>.<file>=<value> format",
+	EnvKeys:      []string{"CGROUP_SET"},
+}
+
 // --unsquash
 var actionUnsquashFlag = cmdline.Flag{
 	ID:           "actionUnsquashFlag",
@@ -790,6 +902,16 @@ var actionUnsquashFlag = cmdline.Flag{
 	EnvKeys:      []string{"UNSQUASH"},
 }
 
+// --lazy
+var actionLazyFlag = cmdline.Flag{
+	ID:           "actionLazyFlag",
+	Value:        &lazyPull,
+	DefaultValue: false,
+	Name:         "lazy",
+	Usage:        "Run docker:// images without conversion to SIF, fetching files on demand",
+	EnvKeys:      []string{"LAZY"},
+}
+
 // --ignore-subuid
 var actionIgnoreSubuidFlag = cmdline.Flag{
 	ID:           "actionIgnoreSubuidFlag",
@@ -947,6 +1069,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionWorkdirFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableTmpfsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionReadOnlyStrictFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionTmpfsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonNoHTTPSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonOldNoHTTPSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, actionsInstanceCmd...)
@@ -969,11 +1093,19 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionMemorySwapFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionOomKillDisableFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionPidsLimitFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionMemoryHighFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionCPUWeightFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionIOMaxFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionHugepagesFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionRdmaFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionCgroupSetFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionUnsquashFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionLazyFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionIgnoreSubuidFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionIgnoreFakerootCommand, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionIgnoreUsernsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionUnderlayFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionStatsFileFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionShareNSFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionRunscriptTimeoutFlag, actionsRunscriptCmd...)
@@ -33,6 +33,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/util/env"
 	"github.com/apptainer/apptainer/internal/pkg/util/uri"
 	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/apptainerconf"
 	"github.com/apptainer/apptainer/pkg/util/fs/lock"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
@@ -95,6 +96,13 @@ func handleOCI(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command,
 		Platform:    getOCIPlatform(),
 	}
 
+	if (lazyPull || apptainerconf.GetCurrentConfig().LazyPull) && strings.HasPrefix(pullFrom, "docker://") {
+		if ociAuth == nil {
+			return oci.PullLazy(ctx, imgCache, pullFrom, pullOpts)
+		}
+		sylog.Warningf("Lazy pulling does not support --docker-login, --docker-username or --docker-password, pulling the full image")
+	}
+
 	return oci.Pull(ctx, imgCache, pullFrom, pullOpts)
 }
 
@@ -317,6 +325,8 @@ func launchContainer(cmd *cobra.Command, image string, args []string, instanceNa
 	opts := []launch.Option{
 		launch.OptWritable(isWritable),
 		launch.OptWritableTmpfs(isWritableTmpfs),
+		launch.OptReadOnlyStrict(readOnlyStrict),
+		launch.OptTmpfs(tmpfsMounts),
 		launch.OptOverlayPaths(overlayPath),
 		launch.OptScratchDirs(scratchPath),
 		launch.OptWorkDir(workdirPath),
@@ -349,6 +359,7 @@ func launchContainer(cmd *cobra.Command, image string, args []string, instanceNa
 		launch.OptSecurity(security),
 		launch.OptNoUmask(noUmask),
 		launch.OptCgroupsJSON(cgJSON),
+		launch.OptStatsFile(statsFile),
 		launch.OptConfigFile(configurationFile),
 		launch.OptShellPath(shellPath),
 		launch.OptCwdPath(cwdPath),
@@ -362,6 +373,7 @@ func launchContainer(cmd *cobra.Command, image string, args []string, instanceNa
 		launch.OptCacheDisabled(disableCache),
 		launch.OptDMTCPLaunch(dmtcpLaunch),
 		launch.OptDMTCPRestart(dmtcpRestart),
+		launch.OptCRIURestore(criuRestore),
 		launch.OptUnsquash(unsquash),
 		launch.OptIgnoreSubuid(ignoreSubuid),
 		launch.OptIgnoreFakerootCmd(ignoreFakerootCmd),
@@ -11,13 +11,11 @@
 package cli
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"math"
 	"os"
-	"os/exec"
 	"os/signal"
 	"os/user"
 	"path/filepath"
@@ -652,12 +650,21 @@ func RootCmd() *cobra.Command {
 	return apptainerCmd
 }
 
+// RuncCompatName is the name of the apptainer binary, usually a symlink,
+// running the runc compatible commands of 'apptainer oci runc'.
+const RuncCompatName = "apptainer-runc"
+
 // ExecuteApptainer adds all child commands to the root command and sets
 // flags appropriately. This is called by main.main(). It only needs to happen
 // once to the root command (apptainer).
 func ExecuteApptainer() {
 	loadPlugins := true
 
+	// when invoked as apptainer-runc, run the runc compatible commands
+	if filepath.Base(os.Args[0]) == RuncCompatName {
+		os.Args = append([]string{os.Args[0], "oci", "runc"}, os.Args[1:]...)
+	}
+
 	// we avoid to load installed plugins to not double load
 	// them during execution of plugin compile and plugin install
 	args := os.Args
@@ -799,37 +806,6 @@ func getRemote() (*endpoint.Config, error) {
 	return ep, err
 }
 
-func apptainerExec(image string, args []string) (string, error) {
-	// Record from stdout and store as a string to return as the contents of the file.
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-
-	abspath, err := filepath.Abs(image)
-	if err != nil {
-		return "", fmt.Errorf("while determining absolute path for %s: %v", image, err)
-	}
-
-	// reuse apptainer exec to grab image file content,
-	// we reduce binds to the bare minimum with options below
-	cmdArgs := []string{"exec", "--contain", "--no-home", "--no-nv", "--no-rocm", abspath}
-	cmdArgs = append(cmdArgs, args...)
-
-	apptainerCmd := filepath.Join(buildcfg.BINDIR, "apptainer")
-
-	cmd := exec.Command(apptainerCmd, cmdArgs...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	// move to the root to not bind the current working directory
-	// while inspecting the image
-	cmd.Dir = "/"
-
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("unable to process command: %s: error output:\n%s", err, stderr.String())
-	}
-
-	return stdout.String(), nil
-}
-
 // CheckRoot ensures that a command is executed with root privileges.
 func CheckRoot(cmd *cobra.Command, _ []string) {
 	if os.Geteuid() != 0 {
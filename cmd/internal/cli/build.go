@@ -59,6 +59,7 @@ var buildArgs struct {
 	buildVarArgs        []string // Variables passed to build procedure.
 	buildVarArgFile     string   // Variables file passed to build procedure.
 	buildArgsUnusedWarn bool     // Variables passed to build procedure to turn fatal error to warn.
+	lint                bool     // Check the definition file for common mistakes before building.
 }
 
 // -s|--sandbox
@@ -120,7 +121,7 @@ var buildArchFlag = cmdline.Flag{
 	Value:        &buildArgs.buildArch,
 	DefaultValue: runtime.GOARCH,
 	Name:         "arch",
-	Usage:        "architecture to build for",
+	Usage:        "architecture to build for, a comma separated list of architectures (arch or arch/variant) builds a multi-architecture SIF image",
 	EnvKeys:      []string{"BUILD_ARCH"},
 }
 
@@ -341,6 +342,16 @@ var buildVarArgFileFlag = cmdline.Flag{
 	Usage:        "specifies a file containing variable=value lines to replace '{{ variable }}' with value in build definition files",
 }
 
+// --lint
+var buildLintFlag = cmdline.Flag{
+	ID:           "buildLintFlag",
+	Value:        &buildArgs.lint,
+	DefaultValue: false,
+	Name:         "lint",
+	Usage:        "check the definition file for common mistakes before building, and abort the build on errors (see 'apptainer def lint')",
+	EnvKeys:      []string{"BUILD_LINT"},
+}
+
 // --warn-unused-build-args
 var buildArgUnusedWarn = cmdline.Flag{
 	ID:           "buildArgUnusedWarnFlag",
@@ -396,6 +407,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&buildVarArgsFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildVarArgFileFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildArgUnusedWarn, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildLintFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonAuthFileFlag, buildCmd)
 	})
 }
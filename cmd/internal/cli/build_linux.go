@@ -16,12 +16,14 @@ import (
 	"fmt"
 	"os"
 	osExec "os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/apptainer/apptainer/internal/pkg/build"
 	"github.com/apptainer/apptainer/internal/pkg/build/args"
+	"github.com/apptainer/apptainer/internal/pkg/build/lint"
 	"github.com/apptainer/apptainer/internal/pkg/build/oci"
 	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
 	"github.com/apptainer/apptainer/internal/pkg/cache"
@@ -29,6 +31,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/ociplatform"
 	"github.com/apptainer/apptainer/internal/pkg/remote/endpoint"
 	fakerootConfig "github.com/apptainer/apptainer/internal/pkg/runtime/engine/fakeroot/config"
+	"github.com/apptainer/apptainer/internal/pkg/sifindex"
 	"github.com/apptainer/apptainer/internal/pkg/util/env"
 	"github.com/apptainer/apptainer/internal/pkg/util/fs"
 	"github.com/apptainer/apptainer/internal/pkg/util/interactive"
@@ -280,6 +283,10 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string, fa
 		sylog.Fatalf("While creating Docker credentials: %v", err)
 	}
 
+	if buildArgs.lint && fs.IsFile(spec) && !isImage(spec) {
+		lintDefinition(spec)
+	}
+
 	// parse definition to determine build source
 	buildArgsMap, err := args.ReadBuildArgs(buildArgs.buildVarArgs, buildArgs.buildVarArgFile)
 	if err != nil {
@@ -356,7 +363,80 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string, fa
 
 	}
 
-	arch, err := oci.ConvertArch(buildArgs.buildArch, buildArgs.buildArchVariant)
+	opts := types.Options{
+		ImgCache:          imgCache,
+		TmpDir:            tmpDir,
+		NoCache:           disableCache,
+		Update:            buildArgs.update,
+		Force:             forceOverwrite,
+		Sections:          buildArgs.sections,
+		NoTest:            buildArgs.noTest,
+		NoHTTPS:           noHTTPS,
+		LibraryURL:        buildArgs.libraryURL,
+		LibraryAuthToken:  authToken,
+		FakerootPath:      fakerootPath,
+		KeyServerOpts:     ko,
+		OCIAuthConfig:     authConf,
+		DockerDaemonHost:  dockerHost,
+		EncryptionKeyInfo: keyInfo,
+		FixPerms:          buildArgs.fixPerms,
+		SandboxTarget:     sandboxTarget,
+		MksquashfsArgs:    buildArgs.mksquashfsArgs,
+		Binds:             buildArgs.bindPaths,
+		Unprivilege:       unprivilege,
+		ReqAuthFile:       reqAuthFile,
+	}
+
+	archs := strings.Split(buildArgs.buildArch, ",")
+	if len(archs) == 1 {
+		runBuildArch(ctx, defs, dst, buildFormat, buildArgs.buildArch, buildArgs.buildArchVariant, opts)
+		return
+	}
+
+	// multi-architecture build, each architecture is built into
+	// its own SIF image then they are all gathered in the destination
+	if sandboxTarget {
+		sylog.Fatalf("Multiple architectures can only be built into a SIF image")
+	}
+	if buildArgs.buildArchVariant != "" {
+		sylog.Fatalf("--arch-variant can't be used with multiple architectures, use --arch arch/variant instead")
+	}
+	if buildArgs.update {
+		sylog.Fatalf("--update can't be used with multiple architectures")
+	}
+
+	tmp, err := os.MkdirTemp(tmpDir, "build-index-")
+	if err != nil {
+		sylog.Fatalf("While creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	srcs := make([]sifindex.Source, 0, len(archs))
+	for i, a := range archs {
+		arch, variant, _ := strings.Cut(strings.TrimSpace(a), "/")
+		platform, err := ociplatform.PlatformFromString("linux/" + strings.TrimSpace(a))
+		if err != nil {
+			sylog.Fatalf("While processing architecture %s: %v", a, err)
+		}
+		path := filepath.Join(tmp, fmt.Sprintf("image-%d.sif", i))
+		sylog.Infof("Building image for platform %s", platform)
+		runBuildArch(ctx, defs, path, buildFormat, arch, variant, opts)
+		srcs = append(srcs, sifindex.Source{Path: path, Platform: platform})
+	}
+
+	sylog.Infof("Creating multi-architecture SIF file...")
+	if forceOverwrite {
+		os.RemoveAll(dst)
+	}
+	if err := sifindex.Create(dst, srcs); err != nil {
+		sylog.Fatalf("While creating multi-architecture image: %v", err)
+	}
+}
+
+// runBuildArch builds the definitions for the architecture arch and its
+// variant into the destination dst.
+func runBuildArch(ctx context.Context, defs []types.Definition, dst, format, arch, variant string, opts types.Options) {
+	arch, err := oci.ConvertArch(arch, variant)
 	if err != nil {
 		sylog.Fatalf("While processing the arch and arch variant: %v", err)
 		return
@@ -365,45 +445,23 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string, fa
 	if err != nil {
 		sylog.Fatalf("%v", err)
 	}
+	opts.Arch = arch
+	opts.Platform = *dp
 
 	b, err := build.New(
 		defs,
 		build.Config{
 			Dest:      dst,
-			Format:    buildFormat,
+			Format:    format,
 			NoCleanUp: buildArgs.noCleanUp,
-			Opts: types.Options{
-				ImgCache:          imgCache,
-				TmpDir:            tmpDir,
-				NoCache:           disableCache,
-				Update:            buildArgs.update,
-				Force:             forceOverwrite,
-				Sections:          buildArgs.sections,
-				NoTest:            buildArgs.noTest,
-				NoHTTPS:           noHTTPS,
-				LibraryURL:        buildArgs.libraryURL,
-				LibraryAuthToken:  authToken,
-				FakerootPath:      fakerootPath,
-				KeyServerOpts:     ko,
-				OCIAuthConfig:     authConf,
-				DockerDaemonHost:  dockerHost,
-				EncryptionKeyInfo: keyInfo,
-				FixPerms:          buildArgs.fixPerms,
-				SandboxTarget:     sandboxTarget,
-				MksquashfsArgs:    buildArgs.mksquashfsArgs,
-				Binds:             buildArgs.bindPaths,
-				Unprivilege:       unprivilege,
-				ReqAuthFile:       reqAuthFile,
-				Arch:              arch,
-				Platform:          *dp,
-			},
+			Opts:      opts,
 		})
 	if err != nil {
 		sylog.Fatalf("Unable to create build: %v", err)
 	}
 
 	if err = b.Full(ctx); err != nil {
-		if fakerootPath != "" && strings.Contains(err.Error(), " %post section") && os.Getuid() == 0 {
+		if opts.FakerootPath != "" && strings.Contains(err.Error(), " %post section") && os.Getuid() == 0 {
 			sylog.Infof("If error was from fakeroot, try --ignore-fakeroot-command and")
 			sylog.Infof("  maybe use fakeroot inside the %%post section as described at")
 			sylog.Infof("  https://apptainer.org/docs/user/latest/fakeroot.html#fakeroot-inside-def")
@@ -412,6 +470,28 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string, fa
 	}
 }
 
+// lintDefinition reports the findings of the definition file linter on
+// spec, and aborts the build if any error is found.
+func lintDefinition(spec string) {
+	findings, err := lint.LintFile(spec)
+	if err != nil {
+		sylog.Fatalf("While checking definition file: %v", err)
+	}
+	for _, f := range findings {
+		switch f.Severity {
+		case lint.SeverityError:
+			sylog.Errorf("%s", f)
+		case lint.SeverityWarning:
+			sylog.Warningf("%s", f)
+		default:
+			sylog.Infof("%s", f)
+		}
+	}
+	if lint.HasErrors(findings) {
+		sylog.Fatalf("Definition file %s has errors, see 'apptainer def lint %s'", spec, spec)
+	}
+}
+
 func checkSections() error {
 	var all, none bool
 	for _, section := range buildArgs.sections {
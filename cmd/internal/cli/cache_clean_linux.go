@@ -45,7 +45,7 @@ var (
 		DefaultValue: []string{"all"},
 		Name:         "type",
 		ShortHand:    "T",
-		Usage:        "a list of cache types to clean (possible values: library, oci, shub, blob, net, oras, all)",
+		Usage:        "a list of cache types to clean (possible values: library, oci, oci-lazy, shub, blob, net, oras, all)",
 	}
 
 	// -D|--days
@@ -32,7 +32,7 @@ var cacheListTypesFlag = cmdline.Flag{
 	DefaultValue: []string{"all"},
 	Name:         "type",
 	ShortHand:    "T",
-	Usage:        "a list of cache types to display, possible entries: library, oci, shub, blob(s), all",
+	Usage:        "a list of cache types to display, possible entries: library, oci, oci-lazy, shub, blob(s), all",
 }
 
 // -s|--summary
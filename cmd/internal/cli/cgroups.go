@@ -12,6 +12,7 @@ package cli
 import (
 	"fmt"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/cgroups"
 	"github.com/ccoveille/go-safecast"
 	"github.com/docker/go-units"
+	lccgroups "github.com/opencontainers/cgroups"
 	"github.com/shopspring/decimal"
 	"golang.org/x/sys/unix"
 )
@@ -90,6 +92,33 @@ func getFlagLimits() (*cgroups.Config, error) {
 		configured = true
 	}
 
+	hugepageLimits, err := getHugepageLimits()
+	if err != nil {
+		return nil, err
+	}
+	if hugepageLimits != nil {
+		config.HugepageLimits = hugepageLimits
+		configured = true
+	}
+
+	rdmaLimits, err := getRdmaLimits()
+	if err != nil {
+		return nil, err
+	}
+	if rdmaLimits != nil {
+		config.Rdma = rdmaLimits
+		configured = true
+	}
+
+	unified, err := getUnifiedLimits()
+	if err != nil {
+		return nil, err
+	}
+	if unified != nil {
+		config.Unified = unified
+		configured = true
+	}
+
 	if configured {
 		return &config, nil
 	}
@@ -146,6 +175,55 @@ func getBlkioLimits() (*cgroups.LinuxBlockIO, error) {
 		configured = true
 	}
 
+	// Format of --io-max CLI values is...
+	//  <device>:<key>=<limit>[,<key>=<limit>...]
+	//  /dev/sda:rbps=1M,wiops=100
+	for _, val := range ioMax {
+		path, limits, ok := strings.Cut(val, ":")
+		if !ok || limits == "" {
+			return nil, fmt.Errorf("io-max specifications must be in <device>:<key>=<limit>[,...] format")
+		}
+
+		major, minor, err := deviceMajorMinor(path)
+		if err != nil {
+			return nil, fmt.Errorf("while examining device: %w", err)
+		}
+
+		for _, limit := range strings.Split(limits, ",") {
+			key, value, _ := strings.Cut(limit, "=")
+
+			var rate uint64
+			switch key {
+			case "rbps", "wbps":
+				r, err := units.RAMInBytes(value)
+				if err != nil || r <= 0 {
+					return nil, fmt.Errorf("%s is not a valid %s limit", value, key)
+				}
+				rate = uint64(r)
+			case "riops", "wiops":
+				rate, err = strconv.ParseUint(value, 10, 64)
+				if err != nil || rate == 0 {
+					return nil, fmt.Errorf("%s is not a valid %s limit", value, key)
+				}
+			default:
+				return nil, fmt.Errorf("unknown io-max key %q, must be one of rbps, wbps, riops, wiops", key)
+			}
+
+			td := cgroups.LinuxThrottleDevice{Major: major, Minor: minor, Rate: rate}
+			switch key {
+			case "rbps":
+				blkio.ThrottleReadBpsDevice = append(blkio.ThrottleReadBpsDevice, td)
+			case "wbps":
+				blkio.ThrottleWriteBpsDevice = append(blkio.ThrottleWriteBpsDevice, td)
+			case "riops":
+				blkio.ThrottleReadIOPSDevice = append(blkio.ThrottleReadIOPSDevice, td)
+			case "wiops":
+				blkio.ThrottleWriteIOPSDevice = append(blkio.ThrottleWriteIOPSDevice, td)
+			}
+		}
+		configured = true
+	}
+
 	if configured {
 		return &blkio, nil
 	}
@@ -295,6 +373,118 @@ func getPidsLimits() (*cgroups.LinuxPids, error) {
 	return nil, nil
 }
 
+// getHugepageLimits handles --hugepages flags, converting values into a list
+// of LinuxHugepageLimit structures
+func getHugepageLimits() ([]cgroups.LinuxHugepageLimit, error) {
+	if len(hugepages) == 0 {
+		return nil, nil
+	}
+
+	sizes := lccgroups.HugePageSizes()
+	limits := make([]cgroups.LinuxHugepageLimit, 0, len(hugepages))
+	for _, val := range hugepages {
+		size, value, ok := strings.Cut(val, ":")
+		if !ok {
+			return nil, fmt.Errorf("hugepages specifications must be in <pagesize>:<limit> format")
+		}
+		if !slices.Contains(sizes, size) {
+			return nil, fmt.Errorf("hugepage size %s is not supported, must be one of: %s", size, strings.Join(sizes, ", "))
+		}
+		limit, err := units.RAMInBytes(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid hugepages limit %s", value)
+		}
+		limits = append(limits, cgroups.LinuxHugepageLimit{
+			Pagesize: size,
+			Limit:    uint64(limit),
+		})
+	}
+	return limits, nil
+}
+
+// getRdmaLimits handles --rdma flags, converting values into LinuxRdma
+// structures by device
+func getRdmaLimits() (map[string]cgroups.LinuxRdma, error) {
+	if len(rdma) == 0 {
+		return nil, nil
+	}
+
+	limits := make(map[string]cgroups.LinuxRdma)
+	for _, val := range rdma {
+		device, values, ok := strings.Cut(val, ":")
+		if !ok || device == "" || values == "" {
+			return nil, fmt.Errorf("rdma specifications must be in <device>:hca_handle=<n>,hca_object=<n> format")
+		}
+
+		limit := limits[device]
+		for _, v := range strings.Split(values, ",") {
+			key, value, _ := strings.Cut(v, "=")
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid rdma %s limit", value, key)
+			}
+			count := uint32(n)
+			switch key {
+			case "hca_handle":
+				limit.HcaHandles = &count
+			case "hca_object":
+				limit.HcaObjects = &count
+			default:
+				return nil, fmt.Errorf("unknown rdma key %q, must be one of hca_handle, hca_object", key)
+			}
+		}
+		limits[device] = limit
+	}
+	return limits, nil
+}
+
+// getUnifiedLimits handles --memory-high, --cpu-weight and --cgroup-set
+// flags, converting values into cgroups v2 unified resources
+func getUnifiedLimits() (map[string]string, error) {
+	unified := make(map[string]string)
+
+	if memoryHigh != "" {
+		if memoryHigh == "max" {
+			unified["memory.high"] = memoryHigh
+		} else {
+			mh, err := units.RAMInBytes(memoryHigh)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory-high value: %w", err)
+			}
+			unified["memory.high"] = strconv.FormatInt(mh, 10)
+		}
+	}
+
+	if cpuWeight > 0 {
+		if cpuWeight > 10000 {
+			return nil, fmt.Errorf("cpu-weight must be in range 1-10000")
+		}
+		if cpuShares > 0 {
+			return nil, fmt.Errorf("cpu-weight and cpu-shares are mutually exclusive")
+		}
+		unified["cpu.weight"] = strconv.Itoa(cpuWeight)
+	}
+
+	// Format of --cgroup-set CLI values is...
+	//  <controller>.<file>=<value>
+	//  io.max=8:0 rbps=1048576
+	for _, val := range cgroupSet {
+		key, value, ok := strings.Cut(val, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("cgroup-set specifications must be in <controller>.<file>=<value> format")
+		}
+		if _, ok := unified[key]; ok {
+			return nil, fmt.Errorf("cgroup setting %s is set more than once", key)
+		}
+		unified[key] = value
+	}
+
+	if len(unified) == 0 {
+		return nil, nil
+	}
+	return unified, nil
+}
+
 // deviceMajorMinor returns major and minor numbers for the device at path
 func deviceMajorMinor(path string) (major, minor int64, err error) {
 	var stat unix.Stat_t
@@ -10,6 +10,7 @@
 package cli
 
 import (
+	"reflect"
 	"runtime"
 	"strconv"
 	"testing"
@@ -522,3 +523,263 @@ func Test_getPidsLimits(t *testing.T) {
 		})
 	}
 }
+
+func Test_getIOMaxLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		ioMax      []string
+		wantBlkio  bool
+		wantError  bool
+		blkioCheck func(t *testing.T, b *cgroups.LinuxBlockIO)
+	}{
+		{
+			name:      "None",
+			wantBlkio: false,
+			wantError: false,
+		},
+		{
+			name:      "GoodIOMax",
+			ioMax:     []string{"/dev/zero:rbps=1M,wiops=100"},
+			wantBlkio: true,
+			wantError: false,
+			blkioCheck: func(t *testing.T, b *cgroups.LinuxBlockIO) {
+				if len(b.ThrottleReadBpsDevice) != 1 || len(b.ThrottleWriteIOPSDevice) != 1 {
+					t.Fatalf("expected 1 rbps and 1 wiops entry, got %v", b)
+				}
+				if rbps := b.ThrottleReadBpsDevice[0]; rbps.Major != 1 || rbps.Minor != 5 || rbps.Rate != 1048576 {
+					t.Errorf("expected 1:5 rbps 1048576, got %v", rbps)
+				}
+				if wiops := b.ThrottleWriteIOPSDevice[0]; wiops.Rate != 100 {
+					t.Errorf("expected wiops 100, got %d", wiops.Rate)
+				}
+			},
+		},
+		{
+			name:      "BadFormat",
+			ioMax:     []string{"/dev/zero"},
+			wantBlkio: false,
+			wantError: true,
+		},
+		{
+			name:      "BadKey",
+			ioMax:     []string{"/dev/zero:rios=1"},
+			wantBlkio: false,
+			wantError: true,
+		},
+		{
+			name:      "BadValue",
+			ioMax:     []string{"/dev/zero:riops=0"},
+			wantBlkio: false,
+			wantError: true,
+		},
+		{
+			name:      "NotDevice",
+			ioMax:     []string{"/etc/hosts:rbps=1M"},
+			wantBlkio: false,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blkioWeight = 0
+			blkioWeightDevice = []string{}
+			ioMax = tt.ioMax
+
+			blkio, err := getBlkioLimits()
+
+			if err != nil && !tt.wantError {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if err == nil && tt.wantError {
+				t.Errorf("unexpected success: %s", err)
+			}
+
+			if tt.wantBlkio && blkio == nil {
+				t.Errorf("expected blkio struct, got nil")
+			}
+
+			if !tt.wantBlkio && blkio != nil {
+				t.Errorf("expected nil, got %v", blkio)
+			}
+
+			if tt.blkioCheck != nil && blkio != nil {
+				tt.blkioCheck(t, blkio)
+			}
+		})
+	}
+	ioMax = []string{}
+}
+
+func Test_getRdmaLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		rdma      []string
+		wantRdma  bool
+		wantError bool
+		rdmaCheck func(t *testing.T, r map[string]cgroups.LinuxRdma)
+	}{
+		{
+			name:      "None",
+			wantRdma:  false,
+			wantError: false,
+		},
+		{
+			name:      "GoodRdma",
+			rdma:      []string{"mlx5_0:hca_handle=2,hca_object=2000", "mlx5_1:hca_handle=1"},
+			wantRdma:  true,
+			wantError: false,
+			rdmaCheck: func(t *testing.T, r map[string]cgroups.LinuxRdma) {
+				l := r["mlx5_0"]
+				if l.HcaHandles == nil || *l.HcaHandles != 2 || l.HcaObjects == nil || *l.HcaObjects != 2000 {
+					t.Errorf("unexpected mlx5_0 limits: %v", l)
+				}
+				l = r["mlx5_1"]
+				if l.HcaHandles == nil || *l.HcaHandles != 1 || l.HcaObjects != nil {
+					t.Errorf("unexpected mlx5_1 limits: %v", l)
+				}
+			},
+		},
+		{
+			name:      "BadKey",
+			rdma:      []string{"mlx5_0:handles=2"},
+			wantRdma:  false,
+			wantError: true,
+		},
+		{
+			name:      "BadValue",
+			rdma:      []string{"mlx5_0:hca_handle=-1"},
+			wantRdma:  false,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdma = tt.rdma
+
+			limits, err := getRdmaLimits()
+
+			if err != nil && !tt.wantError {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if err == nil && tt.wantError {
+				t.Errorf("unexpected success: %s", err)
+			}
+
+			if tt.wantRdma && limits == nil {
+				t.Errorf("expected rdma limits, got nil")
+			}
+
+			if !tt.wantRdma && limits != nil {
+				t.Errorf("expected nil, got %v", limits)
+			}
+
+			if tt.rdmaCheck != nil && limits != nil {
+				tt.rdmaCheck(t, limits)
+			}
+		})
+	}
+	rdma = []string{}
+}
+
+func Test_getUnifiedLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		memoryHigh  string
+		cpuWeight   int
+		cpuShares   int
+		cgroupSet   []string
+		wantUnified map[string]string
+		wantError   bool
+	}{
+		{
+			name:      "None",
+			cpuShares: -1,
+		},
+		{
+			name:        "MemoryHigh",
+			memoryHigh:  "6G",
+			cpuShares:   -1,
+			wantUnified: map[string]string{"memory.high": "6442450944"},
+		},
+		{
+			name:        "MemoryHighMax",
+			memoryHigh:  "max",
+			cpuShares:   -1,
+			wantUnified: map[string]string{"memory.high": "max"},
+		},
+		{
+			name:       "BadMemoryHigh",
+			memoryHigh: "lots",
+			cpuShares:  -1,
+			wantError:  true,
+		},
+		{
+			name:        "CPUWeight",
+			cpuWeight:   500,
+			cpuShares:   -1,
+			wantUnified: map[string]string{"cpu.weight": "500"},
+		},
+		{
+			name:      "CPUWeightTooHigh",
+			cpuWeight: 10001,
+			cpuShares: -1,
+			wantError: true,
+		},
+		{
+			name:      "CPUWeightAndShares",
+			cpuWeight: 500,
+			cpuShares: 512,
+			wantError: true,
+		},
+		{
+			name:        "CgroupSet",
+			cpuShares:   -1,
+			cgroupSet:   []string{"memory.high=6G", "io.max=8:0 rbps=1048576"},
+			wantUnified: map[string]string{"memory.high": "6G", "io.max": "8:0 rbps=1048576"},
+		},
+		{
+			name:      "CgroupSetBadFormat",
+			cpuShares: -1,
+			cgroupSet: []string{"memory.high"},
+			wantError: true,
+		},
+		{
+			name:       "CgroupSetDuplicate",
+			memoryHigh: "6G",
+			cpuShares:  -1,
+			cgroupSet:  []string{"memory.high=4G"},
+			wantError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memoryHigh = tt.memoryHigh
+			cpuWeight = tt.cpuWeight
+			cpuShares = tt.cpuShares
+			cgroupSet = tt.cgroupSet
+
+			unified, err := getUnifiedLimits()
+
+			if err != nil && !tt.wantError {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if err == nil && tt.wantError {
+				t.Errorf("unexpected success: %s", err)
+			}
+
+			if !reflect.DeepEqual(unified, tt.wantUnified) {
+				t.Errorf("expected %v, got %v", tt.wantUnified, unified)
+			}
+		})
+	}
+	memoryHigh = ""
+	cpuWeight = 0
+	cpuShares = -1
+	cgroupSet = []string{}
+}
@@ -15,6 +15,9 @@ import (
 	"text/tabwriter"
 
 	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint/criu"
 	"github.com/apptainer/apptainer/internal/pkg/checkpoint/dmtcp"
 	"github.com/apptainer/apptainer/internal/pkg/instance"
 	"github.com/apptainer/apptainer/pkg/cmdline"
@@ -31,13 +34,54 @@ func init() {
 		cmdManager.RegisterSubCmd(CheckpointCmd, CheckpointInstanceCmd)
 		cmdManager.RegisterSubCmd(CheckpointCmd, CheckpointCreateCmd)
 		cmdManager.RegisterSubCmd(CheckpointCmd, CheckpointDeleteCmd)
+		cmdManager.RegisterSubCmd(CheckpointCmd, CheckpointExportCmd)
+		cmdManager.RegisterSubCmd(CheckpointCmd, CheckpointImportCmd)
 
 		cmdManager.RegisterFlagForCmd(&actionHomeFlag, CheckpointInstanceCmd)
+		cmdManager.RegisterFlagForCmd(&checkpointBackendFlag, CheckpointListCmd, CheckpointInstanceCmd, CheckpointDeleteCmd, CheckpointExportCmd)
 	})
 }
 
+// --backend
+var checkpointBackend string
+
+var checkpointBackendFlag = cmdline.Flag{
+	ID:           "checkpointBackendFlag",
+	Value:        &checkpointBackend,
+	DefaultValue: checkpoint.DMTCPBackend,
+	Name:         "backend",
+	Usage:        "checkpoint backend to use, dmtcp or criu (experimental)",
+	EnvKeys:      []string{"CHECKPOINT_BACKEND"},
+}
+
 func checkpointPreRun(_ *cobra.Command, _ []string) {
-	dmtcp.QuickInstallationCheck()
+	switch checkpointBackend {
+	case checkpoint.DMTCPBackend:
+		dmtcp.QuickInstallationCheck()
+	case checkpoint.CRIUBackend:
+		criu.QuickInstallationCheck()
+	default:
+		sylog.Fatalf("Unknown checkpoint backend %q, must be one of %s or %s", checkpointBackend, checkpoint.DMTCPBackend, checkpoint.CRIUBackend)
+	}
+}
+
+// checkpointPaths returns the paths of the checkpoints of the selected backend.
+func checkpointPaths() ([]string, error) {
+	var paths []string
+
+	if checkpointBackend == checkpoint.CRIUBackend {
+		entries, err := criu.NewManager().List()
+		for _, e := range entries {
+			paths = append(paths, e.Path())
+		}
+		return paths, err
+	}
+
+	entries, err := dmtcp.NewManager().List()
+	for _, e := range entries {
+		paths = append(paths, e.Path())
+	}
+	return paths, err
 }
 
 // CheckpointCmd represents the checkpoint command.
@@ -56,9 +100,7 @@ var CheckpointListCmd = &cobra.Command{
 	Args:   cobra.ExactArgs(0),
 	PreRun: checkpointPreRun,
 	Run: func(_ *cobra.Command, _ []string) {
-		m := dmtcp.NewManager()
-
-		entries, err := m.List()
+		paths, err := checkpointPaths()
 		if err != nil {
 			sylog.Fatalf("Failed to get checkpoint entries: %v", err)
 		}
@@ -66,8 +108,8 @@ var CheckpointListCmd = &cobra.Command{
 		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintf(tw, listLine, "NAME")
 
-		for _, e := range entries {
-			fmt.Fprintf(tw, listLine, filepath.Base(e.Path()))
+		for _, p := range paths {
+			fmt.Fprintf(tw, listLine, filepath.Base(p))
 		}
 
 		tw.Flush()
@@ -116,9 +158,13 @@ var CheckpointDeleteCmd = &cobra.Command{
 	PreRun: checkpointPreRun,
 	Run: func(_ *cobra.Command, args []string) {
 		name := args[0]
-		m := dmtcp.NewManager()
 
-		err := m.Delete(name)
+		var err error
+		if checkpointBackend == checkpoint.CRIUBackend {
+			err = criu.NewManager().Delete(name)
+		} else {
+			err = dmtcp.NewManager().Delete(name)
+		}
 		if err != nil {
 			sylog.Fatalf("Failed to delete checkpoint entries: %v", err)
 		}
@@ -134,15 +180,72 @@ var CheckpointDeleteCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 }
 
+// CheckpointExportCmd apptainer checkpoint export
+var CheckpointExportCmd = &cobra.Command{
+	Args:   cobra.ExactArgs(2),
+	PreRun: checkpointPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		name := args[0]
+
+		if err := apptainer.CheckpointExport(checkpointBackend, name, args[1]); err != nil {
+			sylog.Fatalf("Failed to export checkpoint: %s", err)
+		}
+
+		sylog.Infof("Checkpoint %q exported to %s.", name, args[1])
+	},
+
+	Use:     docs.CheckpointExportUse,
+	Short:   docs.CheckpointExportShort,
+	Long:    docs.CheckpointExportLong,
+	Example: docs.CheckpointExportExample,
+
+	DisableFlagsInUseLine: true,
+}
+
+// CheckpointImportCmd apptainer checkpoint import
+var CheckpointImportCmd = &cobra.Command{
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+
+		m, err := apptainer.CheckpointImport(args[0], name)
+		if err != nil {
+			sylog.Fatalf("Failed to import checkpoint: %s", err)
+		}
+
+		sylog.Infof("Checkpoint %q imported for the %s backend.", m.Name, m.Backend)
+	},
+
+	Use:     docs.CheckpointImportUse,
+	Short:   docs.CheckpointImportShort,
+	Long:    docs.CheckpointImportLong,
+	Example: docs.CheckpointImportExample,
+
+	DisableFlagsInUseLine: true,
+}
+
 var CheckpointInstanceCmd = &cobra.Command{
 	Args: cobra.ExactArgs(1),
 	PreRun: func(cmd *cobra.Command, args []string) {
 		checkpointPreRun(cmd, args)
-		actionPreRun(cmd, args)
+		if checkpointBackend == checkpoint.DMTCPBackend {
+			actionPreRun(cmd, args)
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		instanceName := args[0]
 
+		if checkpointBackend == checkpoint.CRIUBackend {
+			if err := apptainer.CheckpointInstanceCRIU(cmd.Context(), instanceName); err != nil {
+				sylog.Fatalf("Failed to checkpoint instance: %s", err)
+			}
+			sylog.Infof("Checkpoint %q created, instance %s stopped.", instanceName, instanceName)
+			return
+		}
+
 		file, err := instance.Get(instanceName, instance.AppSubDir)
 		if err != nil {
 			sylog.Fatalf("Could not retrieve instance file: %s", err)
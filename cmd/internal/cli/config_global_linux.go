@@ -11,9 +11,11 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/apptainer/apptainer/docs"
 	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/internal/pkg/util/user"
 	"github.com/apptainer/apptainer/pkg/cmdline"
 	"github.com/apptainer/apptainer/pkg/sylog"
 	"github.com/spf13/cobra"
@@ -79,14 +81,69 @@ var globalConfigDryRunFlag = cmdline.Flag{
 	Usage:        "dump resulting configuration on stdout but doesn't write it to apptainer.conf",
 }
 
+// --show-origin
+var globalConfigShowOrigin bool
+
+var globalConfigShowOriginFlag = cmdline.Flag{
+	ID:           "globalConfigShowOriginFlag",
+	Value:        &globalConfigShowOrigin,
+	DefaultValue: false,
+	Name:         "show-origin",
+	Usage:        "show the effective value of the configuration directive, or of all directives, and the file it comes from",
+}
+
+// --effective
+var globalConfigEffective bool
+
+var globalConfigEffectiveFlag = cmdline.Flag{
+	ID:           "globalConfigEffectiveFlag",
+	Value:        &globalConfigEffective,
+	DefaultValue: false,
+	Name:         "effective",
+	Usage:        "show the effective value of the configuration directive, or of all directives, for the current user once per-user and per-group overrides are applied",
+}
+
 // configGlobalCmd apptainer config global
 var configGlobalCmd = &cobra.Command{
-	Args:                  cobra.RangeArgs(1, 2),
+	Args:                  cobra.RangeArgs(0, 2),
 	DisableFlagsInUseLine: true,
-	PreRun:                CheckRootOrUnpriv,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// any user can display its own effective configuration
+		if !globalConfigEffective {
+			CheckRootOrUnpriv(cmd, args)
+		}
+	},
 	RunE: func(_ *cobra.Command, args []string) error {
 		var op apptainer.GlobalConfigOp
 
+		if globalConfigEffective {
+			if len(args) > 1 {
+				return fmt.Errorf("--effective accepts at most one configuration directive")
+			}
+			u, err := user.CurrentOriginal()
+			if err != nil {
+				return fmt.Errorf("while getting current user: %s", err)
+			}
+			if err := apptainer.GlobalConfigEffective(os.Stdout, args, configurationFile, int(u.UID), globalConfigShowOrigin); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			return nil
+		}
+
+		if globalConfigShowOrigin {
+			if len(args) > 1 {
+				return fmt.Errorf("--show-origin accepts at most one configuration directive")
+			}
+			if err := apptainer.GlobalConfigShowOrigin(os.Stdout, args, configurationFile); err != nil {
+				sylog.Fatalf("%s", err)
+			}
+			return nil
+		}
+
+		if len(args) == 0 {
+			return fmt.Errorf("you must specify a configuration directive")
+		}
+
 		if globalConfigSet {
 			op = apptainer.GlobalConfigSet
 		} else if globalConfigUnset {
@@ -119,5 +176,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&globalConfigGetFlag, configGlobalCmd)
 		cmdManager.RegisterFlagForCmd(&globalConfigResetFlag, configGlobalCmd)
 		cmdManager.RegisterFlagForCmd(&globalConfigDryRunFlag, configGlobalCmd)
+		cmdManager.RegisterFlagForCmd(&globalConfigShowOriginFlag, configGlobalCmd)
+		cmdManager.RegisterFlagForCmd(&globalConfigEffectiveFlag, configGlobalCmd)
 	})
 }
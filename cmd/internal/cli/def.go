@@ -0,0 +1,257 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/build/dockerfile"
+	"github.com/apptainer/apptainer/internal/pkg/build/lint"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/build/types/parser"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	defLintFormat string
+	defFmtWrite   bool
+	defFmtCheck   bool
+	defConvertTo  string
+)
+
+// --format
+var defLintFormatFlag = cmdline.Flag{
+	ID:           "defLintFormatFlag",
+	Value:        &defLintFormat,
+	DefaultValue: lint.FormatText,
+	Name:         "format",
+	Usage:        "output format of findings: text, json or sarif",
+}
+
+// -w|--write
+var defFmtWriteFlag = cmdline.Flag{
+	ID:           "defFmtWriteFlag",
+	Value:        &defFmtWrite,
+	DefaultValue: false,
+	Name:         "write",
+	ShortHand:    "w",
+	Usage:        "write the formatted definition file in place instead of stdout",
+}
+
+// --check
+var defFmtCheckFlag = cmdline.Flag{
+	ID:           "defFmtCheckFlag",
+	Value:        &defFmtCheck,
+	DefaultValue: false,
+	Name:         "check",
+	Usage:        "exit with an error status if the definition file is not formatted, without writing it",
+}
+
+// --to
+var defConvertToFlag = cmdline.Flag{
+	ID:           "defConvertToFlag",
+	Value:        &defConvertTo,
+	DefaultValue: "",
+	Name:         "to",
+	Usage:        "format to convert the definition to: json, yaml or def",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(DefCmd)
+		cmdManager.RegisterSubCmd(DefCmd, DefLintCmd)
+		cmdManager.RegisterSubCmd(DefCmd, DefFmtCmd)
+		cmdManager.RegisterSubCmd(DefCmd, DefConvertCmd)
+		cmdManager.RegisterSubCmd(DefCmd, DefSchemaCmd)
+		cmdManager.RegisterSubCmd(DefCmd, DefFromDockerfileCmd)
+
+		cmdManager.RegisterFlagForCmd(&defLintFormatFlag, DefLintCmd)
+		cmdManager.RegisterFlagForCmd(&defFmtWriteFlag, DefFmtCmd)
+		cmdManager.RegisterFlagForCmd(&defFmtCheckFlag, DefFmtCmd)
+		cmdManager.RegisterFlagForCmd(&defConvertToFlag, DefConvertCmd)
+	})
+}
+
+// DefCmd is the 'def' command that allows to check and format definition files.
+var DefCmd = &cobra.Command{
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.DefUse,
+	Short:   docs.DefShort,
+	Long:    docs.DefLong,
+	Example: docs.DefExample,
+}
+
+// DefLintCmd is 'apptainer def lint' and checks definition files for common mistakes.
+var DefLintCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		var findings []lint.Finding
+		for _, path := range args {
+			f, err := lint.LintFile(path)
+			if err != nil {
+				sylog.Fatalf("While reading definition file: %v", err)
+			}
+			findings = append(findings, f...)
+		}
+
+		if err := lint.Write(os.Stdout, defLintFormat, findings); err != nil {
+			sylog.Fatalf("%v", err)
+		}
+		if lint.HasErrors(findings) {
+			os.Exit(1)
+		}
+	},
+
+	Use:     docs.DefLintUse,
+	Short:   docs.DefLintShort,
+	Long:    docs.DefLintLong,
+	Example: docs.DefLintExample,
+}
+
+// DefFmtCmd is 'apptainer def fmt' and formats a definition file.
+var DefFmtCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		path := args[0]
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			sylog.Fatalf("While reading definition file: %v", err)
+		}
+		defs, err := parser.All(bytes.NewReader(raw))
+		if err != nil {
+			sylog.Fatalf("While parsing definition file %s: %v", path, err)
+		}
+
+		var buf bytes.Buffer
+		if err := types.WriteDefinitions(&buf, defs...); err != nil {
+			sylog.Fatalf("While formatting definition file: %v", err)
+		}
+
+		switch {
+		case defFmtCheck:
+			if !bytes.Equal(raw, buf.Bytes()) {
+				sylog.Fatalf("%s is not formatted, run 'apptainer def fmt --write %s'", path, path)
+			}
+		case defFmtWrite:
+			fi, err := os.Stat(path)
+			if err != nil {
+				sylog.Fatalf("While formatting definition file: %v", err)
+			}
+			if err := os.WriteFile(path, buf.Bytes(), fi.Mode().Perm()); err != nil {
+				sylog.Fatalf("While writing definition file: %v", err)
+			}
+		default:
+			os.Stdout.Write(buf.Bytes())
+		}
+	},
+
+	Use:     docs.DefFmtUse,
+	Short:   docs.DefFmtShort,
+	Long:    docs.DefFmtLong,
+	Example: docs.DefFmtExample,
+}
+
+// DefConvertCmd is 'apptainer def convert' and converts a definition between
+// the definition file, JSON and YAML formats.
+var DefConvertCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		path := args[0]
+
+		f, err := os.Open(path)
+		if err != nil {
+			sylog.Fatalf("While reading definition: %v", err)
+		}
+		defer f.Close()
+
+		defs, err := parser.AllFormat(f, parser.FormatFromPath(path))
+		if err != nil {
+			sylog.Fatalf("While parsing definition %s: %v", path, err)
+		}
+
+		switch defConvertTo {
+		case parser.FormatDef:
+			err = types.WriteDefinitions(os.Stdout, defs...)
+		case parser.FormatJSON:
+			err = types.WriteDefinitionsJSON(os.Stdout, defs...)
+		case parser.FormatYAML:
+			err = types.WriteDefinitionsYAML(os.Stdout, defs...)
+		default:
+			sylog.Fatalf("--to must be one of %s, %s or %s", parser.FormatJSON, parser.FormatYAML, parser.FormatDef)
+		}
+		if err != nil {
+			sylog.Fatalf("While converting definition: %v", err)
+		}
+	},
+
+	Use:     docs.DefConvertUse,
+	Short:   docs.DefConvertShort,
+	Long:    docs.DefConvertLong,
+	Example: docs.DefConvertExample,
+}
+
+// DefSchemaCmd is 'apptainer def schema' and prints the JSON Schema of definitions.
+var DefSchemaCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		os.Stdout.Write(types.DefinitionSchema)
+	},
+
+	Use:     docs.DefSchemaUse,
+	Short:   docs.DefSchemaShort,
+	Long:    docs.DefSchemaLong,
+	Example: docs.DefSchemaExample,
+}
+
+// DefFromDockerfileCmd is 'apptainer def from-dockerfile' and converts a
+// Dockerfile into a definition file.
+var DefFromDockerfileCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		path := args[0]
+
+		f, err := os.Open(path)
+		if err != nil {
+			sylog.Fatalf("While reading Dockerfile: %v", err)
+		}
+		defer f.Close()
+
+		defs, warnings, err := dockerfile.Convert(f)
+		if err != nil {
+			sylog.Fatalf("While converting %s: %v", path, err)
+		}
+		for _, w := range warnings {
+			sylog.Warningf("%s: %s", path, w)
+		}
+
+		if err := types.WriteDefinitions(os.Stdout, defs...); err != nil {
+			sylog.Fatalf("While writing definition: %v", err)
+		}
+	},
+
+	Use:     docs.DefFromDockerfileUse,
+	Short:   docs.DefFromDockerfileShort,
+	Long:    docs.DefFromDockerfileLong,
+	Example: docs.DefFromDockerfileExample,
+}
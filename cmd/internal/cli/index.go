@@ -0,0 +1,70 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+var indexPlatforms []string
+
+// --platform
+var indexPlatformFlag = cmdline.Flag{
+	ID:           "indexPlatformFlag",
+	Value:        &indexPlatforms,
+	DefaultValue: []string{},
+	Name:         "platform",
+	Usage:        "platform (os/arch[/variant]) of each image, in the same order as images",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(IndexCmd)
+		cmdManager.RegisterSubCmd(IndexCmd, IndexCreateCmd)
+
+		cmdManager.RegisterFlagForCmd(&commonForceFlag, IndexCreateCmd)
+		cmdManager.RegisterFlagForCmd(&indexPlatformFlag, IndexCreateCmd)
+	})
+}
+
+// IndexCmd is the 'index' command that allows to manage multi-architecture images.
+var IndexCmd = &cobra.Command{
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.IndexUse,
+	Short:   docs.IndexShort,
+	Long:    docs.IndexLong,
+	Example: docs.IndexExample,
+}
+
+// IndexCreateCmd is the 'index create' command that allows to create a multi-architecture image.
+var IndexCreateCmd = &cobra.Command{
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(_ *cobra.Command, args []string) error {
+		if err := apptainer.IndexCreate(args[0], args[1:], indexPlatforms, forceOverwrite); err != nil {
+			sylog.Fatalf("%v", err.Error())
+		}
+		return nil
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.IndexCreateUse,
+	Short:   docs.IndexCreateShort,
+	Long:    docs.IndexCreateLong,
+	Example: docs.IndexCreateExample,
+}
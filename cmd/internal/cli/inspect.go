@@ -10,20 +10,19 @@
 package cli
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
+	"path"
 	"sort"
 	"strings"
 
 	"github.com/apptainer/apptainer/docs"
-	"github.com/apptainer/apptainer/internal/pkg/util/env"
+	"github.com/apptainer/apptainer/internal/pkg/image/imagefs"
+	"github.com/apptainer/apptainer/internal/pkg/sifindex"
 	"github.com/apptainer/apptainer/pkg/cmdline"
 	"github.com/apptainer/apptainer/pkg/image"
 	"github.com/apptainer/apptainer/pkg/inspect"
@@ -48,6 +47,7 @@ var (
 	labels      bool
 	deffile     bool
 	jsonfmt     bool
+	platforms   bool
 )
 
 // -l|--labels
@@ -158,6 +158,15 @@ var inspectAllFlag = cmdline.Flag{
 	Usage:        "show all available data (imply --json option)",
 }
 
+// --platforms
+var inspectPlatformsFlag = cmdline.Flag{
+	ID:           "inspectPlatformsFlag",
+	Value:        &platforms,
+	DefaultValue: false,
+	Name:         "platforms",
+	Usage:        "list the platforms contained in a SIF image",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(InspectCmd)
@@ -173,33 +182,37 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&inspectTestFlag, InspectCmd)
 		cmdManager.RegisterFlagForCmd(&inspectAppsListFlag, InspectCmd)
 		cmdManager.RegisterFlagForCmd(&inspectAllFlag, InspectCmd)
+		cmdManager.RegisterFlagForCmd(&inspectPlatformsFlag, InspectCmd)
 	})
 }
 
-const (
-	sectionDelim = "~~##@@> "
-	metadataJSON = "inspect-metadata.json"
-)
+const metadataJSON = "inspect-metadata.json"
+
+// inspectFile is a metadata file to read from each of the metadata
+// directories of the container.
+type inspectFile struct {
+	name    string
+	section string
+}
 
 type command struct {
-	script      string
+	files       []inspectFile
+	environment bool
+	allData     bool
 	appName     string
 	metadata    *inspect.Metadata
 	sifMetadata *inspect.Metadata
 	img         *image.Image
 }
 
-//nolint:dupword
 func newCommand(allData bool, appName string, img *image.Image) *command {
 	command := new(command)
 	command.img = img
 	command.metadata = inspect.NewMetadata()
 	command.appName = appName
+	command.allData = allData
 
-	prefix := ""
-	if img.Type == image.SANDBOX {
-		prefix = img.Path
-	} else if img.Type == image.SIF {
+	if img.Type == image.SIF {
 		metadata, err := getInspectMetadataFromSIF(img)
 		if err == nil {
 			sylog.Debugf("Using %s SIF descriptor", metadataJSON)
@@ -211,58 +224,10 @@ func newCommand(allData bool, appName string, img *image.Image) *command {
 		} else if err != image.ErrNoSection {
 			sylog.Warningf("Unable to read %s SIF descriptor: %s", metadataJSON, err)
 		} else {
-			if runtime.GOOS != "linux" {
-				sylog.Fatalf("Could not inspect %s: %s SIF descriptor not found", img.Path, metadataJSON)
-			}
 			sylog.Debugf("No %s SIF descriptor found", metadataJSON)
 		}
-	} else if runtime.GOOS != "linux" {
-		sylog.Fatalf("Could not inspect image %s on this platform, only SIF and sandbox images are supported", img.Path)
 	}
 
-	pathPrefix := filepath.Join(prefix, "/.singularity.d")
-	if appName != "" && !allData {
-		pathPrefix = fmt.Sprintf("%s/scif/apps/%s/scif", prefix, appName)
-	}
-	allVar := ""
-	if allData {
-		allVar = "ALL_DATA=1"
-	}
-
-	snippet := `%[1]s
-	for app in %[2]s/scif/apps/*; do
-	if [ -d "$app/scif" ]; then
-		echo "%[3]s apps"
-		echo "${app##*/}"
-		if [ ! -z "${ALL_DATA}" ]; then
-			if [ -z "${ALL_PATH}" ]; then
-				ALL_PATH="$app/scif"
-			else
-				ALL_PATH="${ALL_PATH}:$app/scif"
-			fi
-		fi
-	fi
-	done
-
-	cat_file() {
-		echo "%[3]s $1:$2"
-
-		local IFS="
-"
-		while read -r content; do
-			printf "%%s\n" "$content"
-		done < "$2"
-		if [ ! -z "$content" ]; then
-			printf "%%s\n" "$content"
-		fi
-	}
-
-	ALL_PATH="%[4]s:${ALL_PATH}"
-
-	IFS=":"
-	`
-
-	command.script = fmt.Sprintf(snippet, allVar, prefix, sectionDelim, pathPrefix)
 	return command
 }
 
@@ -331,86 +296,92 @@ func (c *command) setAttribute(section, value, file string) error {
 	return nil
 }
 
+// readFile sets the attribute section with the content of the file
+// name of the container filesystem if it's a regular file.
+func (c *command) readFile(fsys fs.FS, name, section string) error {
+	fi, err := fs.Stat(fsys, name)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("while reading %s: %w", name, err)
+	}
+	return c.setAttribute(section, string(b), "/"+name)
+}
+
+// readGlob sets the attribute section with the content of the regular
+// files of the container filesystem matching pattern.
+func (c *command) readGlob(fsys fs.FS, pattern, section string) error {
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := c.readFile(fsys, m, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *command) getMetadata() (*inspect.Metadata, error) {
-	// we got metadata from SIF, no need to run script
+	// we got metadata from SIF, no need to read the container filesystem
 	if c.sifMetadata != nil {
 		return c.metadata, nil
 	}
 
-	args := []string{"/bin/sh", "-c", c.script}
-	prefix := ""
-	outBuf := new(bytes.Buffer)
+	// the container filesystem is read directly from the image, which
+	// doesn't require to run the container, images that can't be parsed
+	// are limited to the metadata directories extraction
+	fsys, cleanup, err := imagefs.Open(c.img, ".singularity.d", "scif")
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect container: %v", err)
+	}
+	defer cleanup()
 
-	// Execute the compound script.
-	if c.img.Type == image.SANDBOX {
-		os.Setenv("PATH", env.DefaultPath)
+	// metadata directories of the container and of its apps
+	paths := []string{".singularity.d"}
+	if c.appName != "" && !c.allData {
+		paths = []string{path.Join("scif/apps", c.appName, "scif")}
+	}
 
-		// look for sh
-		shell, err := exec.LookPath("sh")
-		if err != nil {
-			return nil, fmt.Errorf("could not inspect container: sh command not found in %q", env.DefaultPath)
+	apps, err := fs.ReadDir(fsys, "scif/apps")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not inspect container: %v", err)
+	}
+	for _, app := range apps {
+		scif := path.Join("scif/apps", app.Name(), "scif")
+		if fi, err := fs.Stat(fsys, scif); err != nil || !fi.IsDir() {
+			continue
 		}
-		args[0] = shell
-
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Env = []string{"PATH=" + env.DefaultPath}
-		out, err := cmd.Output()
-		if err != nil {
-			return nil, fmt.Errorf("could not inspect container: %v", err)
+		if err := c.setAttribute("apps", app.Name(), ""); err != nil {
+			return nil, err
 		}
-		outBuf.Write(out)
-		prefix = c.img.Path
-	} else {
-		// single file image, run apptainer exec with the compound script
-		out, err := apptainerExec(c.img.Path, args)
-		if err != nil {
-			return nil, fmt.Errorf("could not inspect container: %v", err)
+		if c.allData {
+			paths = append(paths, scif)
 		}
-		outBuf.WriteString(out)
 	}
 
-	prevSection := ""
-	prevFile := ""
-	buf := new(bytes.Buffer)
-
-	// Parse the command output string into sections.
-	for {
-		section, err := outBuf.ReadBytes('\n')
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, fmt.Errorf("while reading formatted content: %s", err)
+	for _, f := range c.files {
+		for _, p := range paths {
+			if err := c.readFile(fsys, path.Join(p, f.name), f.section); err != nil {
+				return nil, fmt.Errorf("could not inspect container: %v", err)
+			}
 		}
-		sectionStr := strings.TrimSpace(string(section))
+	}
 
-		if strings.HasPrefix(sectionStr, sectionDelim) {
-			sectionStr = strings.TrimSpace(strings.TrimPrefix(sectionStr, sectionDelim))
-			parts := strings.SplitN(sectionStr, ":", 2)
-			if len(parts) < 1 {
-				return nil, fmt.Errorf("badly formatted content, can't recover: %v", parts)
+	if c.environment {
+		for _, p := range paths {
+			patterns := []string{path.Join(p, "env/9*-environment.sh")}
+			if path.Base(p) == ".singularity.d" {
+				patterns = append([]string{path.Join(p, "env/10-docker*.sh")}, patterns...)
 			}
-			if prevSection != "" {
-				err := c.setAttribute(prevSection, buf.String(), strings.TrimPrefix(prevFile, prefix))
-				if err != nil {
-					return nil, err
+			for _, pattern := range patterns {
+				if err := c.readGlob(fsys, pattern, "environment"); err != nil {
+					return nil, fmt.Errorf("could not inspect container: %v", err)
 				}
 			}
-			buf.Reset()
-			prevSection = parts[0]
-			prevFile = ""
-			if len(parts) == 2 {
-				prevFile = parts[1]
-			}
-		} else {
-			buf.Write(section)
-		}
-	}
-
-	// write the remaining section if any
-	if prevSection != "" {
-		err := c.setAttribute(prevSection, buf.String(), strings.TrimPrefix(prevFile, prefix))
-		if err != nil {
-			return nil, err
 		}
 	}
 
@@ -418,15 +389,7 @@ func (c *command) getMetadata() (*inspect.Metadata, error) {
 }
 
 func (c *command) addSingleFileCommand(file string, label string) {
-	snippet := `
-	for prefix in ${ALL_PATH}; do
-		file="$prefix/%[1]s"
-		if [ -f "$file" ]; then
-			cat_file "%[2]s" "$file"
-		fi
-	done
-	`
-	c.script += fmt.Sprintf(snippet, file, label)
+	c.files = append(c.files, inspectFile{name: file, section: label})
 }
 
 func (c *command) addLabelsCommand() {
@@ -511,23 +474,7 @@ func (c *command) addHelpCommand() {
 
 func (c *command) addEnvironmentCommand() {
 	if c.sifMetadata == nil {
-		c.script += `
-		for prefix in ${ALL_PATH}; do
-			if [ "${prefix##*/}" = ".singularity.d" ]; then
-				for env in $prefix/env/10-docker*.sh; do
-					if [ -f "$env" ]; then
-						cat_file "environment" "$env"
-					fi
-				done
-			fi
-
-			for env in $prefix/env/9*-environment.sh; do
-				if [ -f "$env" ]; then
-					cat_file "environment" "$env"
-				fi
-			done
-		done
-		`
+		c.environment = true
 		return
 	}
 
@@ -602,6 +549,23 @@ func inspectDeffilePartition(img *image.Image) (string, error) {
 	return string(data), nil
 }
 
+func inspectPlatforms(img *image.Image) ([]sifindex.Platform, error) {
+	if img.Type != image.SIF {
+		return nil, errNoSIF
+	}
+
+	f, err := sif.LoadContainer(img.File,
+		sif.OptLoadWithFlag(os.O_RDONLY),
+		sif.OptLoadWithCloseOnUnload(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer f.UnloadContainer()
+
+	return sifindex.Platforms(f)
+}
+
 func printSortedApp(m map[string]*inspect.AppAttributes) {
 	sorted := make([]string, 0, len(m))
 	for k := range m {
@@ -646,6 +610,29 @@ var InspectCmd = &cobra.Command{
 			sylog.Fatalf("Failed to open image %s: %s", args[0], err)
 		}
 
+		if platforms {
+			platforms, err := inspectPlatforms(img)
+			if err != nil {
+				sylog.Fatalf("Could not list platforms of %s: %s", args[0], err)
+			}
+			if jsonfmt {
+				jsonObj, err := json.MarshalIndent(platforms, "", "\t")
+				if err != nil {
+					sylog.Fatalf("Could not format platforms as JSON")
+				}
+				fmt.Printf("%s\n", string(jsonObj))
+				return
+			}
+			for _, p := range platforms {
+				if p.Primary {
+					fmt.Printf("%s (default)\n", p.String())
+				} else {
+					fmt.Printf("%s\n", p.String())
+				}
+			}
+			return
+		}
+
 		if allData {
 			// display all data in JSON format only
 			jsonfmt = true
@@ -654,11 +641,9 @@ var InspectCmd = &cobra.Command{
 
 		inspectCmd := newCommand(allData, appName, img)
 
-		// Try to inspect the label partition, if not, then exec/shell
-		// the container to get the data.
+		// Try to inspect the label partition, if not, then read the
+		// data from the container filesystem.
 		if labels || defaultToLabels() || allData {
-			// If '--app' is specified, then we need to shell/exec the
-			// container.
 			sylog.Debugf("Inspection of labels selected.")
 			inspectCmd.addLabelsCommand()
 		}
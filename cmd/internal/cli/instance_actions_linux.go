@@ -22,6 +22,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&instanceStartPidFileFlag, instanceStartCmd, instanceRunCmd)
 		cmdManager.RegisterFlagForCmd(&actionDMTCPLaunchFlag, instanceStartCmd, instanceRunCmd)
 		cmdManager.RegisterFlagForCmd(&actionDMTCPRestartFlag, instanceStartCmd, instanceRunCmd)
+		cmdManager.RegisterFlagForCmd(&actionCRIURestoreFlag, instanceStartCmd, instanceRunCmd)
 	})
 }
 
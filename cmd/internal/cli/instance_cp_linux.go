@@ -0,0 +1,33 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/spf13/cobra"
+)
+
+// Basic Design
+// apptainer instance cp <name>:<path> <local path>
+// apptainer instance cp <local path> <name>:<path>
+
+// apptainer instance cp
+var instanceCpCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return apptainer.InstanceCopy(cmd.Context(), args[0], args[1])
+	},
+
+	Use:     docs.InstanceCpUse,
+	Short:   docs.InstanceCpShort,
+	Long:    docs.InstanceCpLong,
+	Example: docs.InstanceCpExample,
+}
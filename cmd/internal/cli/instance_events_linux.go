@@ -0,0 +1,55 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/spf13/cobra"
+)
+
+// Basic Design
+// apptainer instance events <name>
+// apptainer instance events --follow <name>
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&instanceEventsFollowFlag, instanceEventsCmd)
+	})
+}
+
+// -f|--follow
+var instanceEventsFollow bool
+
+var instanceEventsFollowFlag = cmdline.Flag{
+	ID:           "instanceEventsFollowFlag",
+	Value:        &instanceEventsFollow,
+	DefaultValue: false,
+	Name:         "follow",
+	ShortHand:    "f",
+	Usage:        "wait for new events until the instance exits",
+}
+
+// apptainer instance events
+var instanceEventsCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return apptainer.InstanceEvents(cmd.Context(), os.Stdout, instance.ExtractName(args[0]), instanceEventsFollow)
+	},
+
+	Use:     docs.InstanceEventsUse,
+	Short:   docs.InstanceEventsShort,
+	Long:    docs.InstanceEventsLong,
+	Example: docs.InstanceEventsExample,
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+// Basic Design
+// apptainer instance top <name>
+// apptainer instance top --json <name>
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&instanceTopUserFlag, instanceTopCmd)
+		cmdManager.RegisterFlagForCmd(&instanceTopJSONFlag, instanceTopCmd)
+	})
+}
+
+// -u|--user
+var instanceTopUser string
+
+var instanceTopUserFlag = cmdline.Flag{
+	ID:           "instanceTopUserFlag",
+	Value:        &instanceTopUser,
+	DefaultValue: "",
+	Name:         "user",
+	ShortHand:    "u",
+	Usage:        "view processes of an instance belonging to a user (root only)",
+	Tag:          "<username>",
+	EnvKeys:      []string{"USER"},
+}
+
+// -j|--json
+var instanceTopJSON bool
+
+var instanceTopJSONFlag = cmdline.Flag{
+	ID:           "instanceTopJSONFlag",
+	Value:        &instanceTopJSON,
+	DefaultValue: false,
+	Name:         "json",
+	ShortHand:    "j",
+	Usage:        "output processes in json",
+}
+
+// apptainer instance top
+var instanceTopCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		// Root is required to look at processes of another user
+		if instanceTopUser != "" && os.Getuid() != 0 {
+			sylog.Fatalf("Only the root user can look at processes of a user's instance")
+		}
+		return apptainer.InstanceTop(os.Stdout, args[0], instanceTopUser, instanceTopJSON)
+	},
+
+	Use:     docs.InstanceTopUse,
+	Short:   docs.InstanceTopShort,
+	Long:    docs.InstanceTopLong,
+	Example: docs.InstanceTopExample,
+}
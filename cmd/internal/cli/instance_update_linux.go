@@ -0,0 +1,127 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+// Basic Design
+// apptainer instance update [limit flags] <name>
+// apptainer instance pause <name>
+// apptainer instance resume <name>
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterSubCmd(instanceCmd, instanceUpdateCmd)
+		cmdManager.RegisterSubCmd(instanceCmd, instancePauseCmd)
+		cmdManager.RegisterSubCmd(instanceCmd, instanceResumeCmd)
+
+		cmdManager.RegisterFlagForCmd(&instanceUpdateUserFlag, instanceUpdateCmd, instancePauseCmd, instanceResumeCmd)
+		cmdManager.RegisterFlagForCmd(&actionApplyCgroupsFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionBlkioWeightFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionBlkioWeightDeviceFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCPUSharesFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCPUsFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCPUsetCPUsFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCPUsetMemsFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionMemoryFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionMemoryReservationFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionMemorySwapFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionOomKillDisableFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionPidsLimitFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionMemoryHighFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCPUWeightFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionIOMaxFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionHugepagesFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionRdmaFlag, instanceUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&actionCgroupSetFlag, instanceUpdateCmd)
+
+		// the instance name is the only argument, limits can be given
+		// after it
+		instanceUpdateCmd.Flags().SetInterspersed(true)
+	})
+}
+
+// -u|--user
+var instanceUpdateUser string
+
+var instanceUpdateUserFlag = cmdline.Flag{
+	ID:           "instanceUpdateUserFlag",
+	Value:        &instanceUpdateUser,
+	DefaultValue: "",
+	Name:         "user",
+	ShortHand:    "u",
+	Usage:        "manage an instance belonging to a user (root only)",
+	Tag:          "<username>",
+	EnvKeys:      []string{"USER"},
+}
+
+func checkInstanceUser() {
+	// Root is required to manage another user's instance
+	if instanceUpdateUser != "" && os.Getuid() != 0 {
+		sylog.Fatalf("Only the root user can manage a user's instance")
+	}
+}
+
+// apptainer instance update
+var instanceUpdateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		checkInstanceUser()
+
+		cgJSON, err := getCgroupsJSON()
+		if err != nil {
+			sylog.Fatalf("While parsing cgroups limits: %s", err)
+		}
+		return apptainer.InstanceUpdate(args[0], instanceUpdateUser, cgJSON)
+	},
+
+	Use:     docs.InstanceUpdateUse,
+	Short:   docs.InstanceUpdateShort,
+	Long:    docs.InstanceUpdateLong,
+	Example: docs.InstanceUpdateExample,
+}
+
+// apptainer instance pause
+var instancePauseCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		checkInstanceUser()
+		return apptainer.InstancePauseResume(args[0], instanceUpdateUser, true)
+	},
+
+	Use:     docs.InstancePauseUse,
+	Short:   docs.InstancePauseShort,
+	Long:    docs.InstancePauseLong,
+	Example: docs.InstancePauseExample,
+}
+
+// apptainer instance resume
+var instanceResumeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		checkInstanceUser()
+		return apptainer.InstancePauseResume(args[0], instanceUpdateUser, false)
+	},
+
+	Use:     docs.InstanceResumeUse,
+	Short:   docs.InstanceResumeShort,
+	Long:    docs.InstanceResumeLong,
+	Example: docs.InstanceResumeExample,
+}
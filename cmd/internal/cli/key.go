@@ -32,6 +32,9 @@ var (
 	keyRemovePrivate    bool   //--private option to remove only private keys
 	keyRemoveBoth       bool   //--both option to remove both public and private keys
 	keyLocalDir         string //--keysdir option for local key dir path
+	keyRevokePush       bool   //--push option to push a revoked key
+	keyRevokeReason     string //--reason option for key and subkey revocations
+	keyLifetime         string //--in option for key and subkey expiration
 )
 
 // -u|--url
@@ -115,6 +118,34 @@ var keyLocalDirKeyFlag = cmdline.Flag{
 	Usage:        "set local keyring dir path, an alternative way is to set environment variable 'APPTAINER_KEYSDIR'",
 }
 
+// --push
+var keyRevokePushFlag = cmdline.Flag{
+	ID:           "keyRevokePushFlag",
+	Value:        &keyRevokePush,
+	DefaultValue: false,
+	Name:         "push",
+	ShortHand:    "U",
+	Usage:        "push the revoked public key to the key server",
+}
+
+// --reason
+var keyRevokeReasonFlag = cmdline.Flag{
+	ID:           "keyRevokeReasonFlag",
+	Value:        &keyRevokeReason,
+	DefaultValue: "compromised",
+	Name:         "reason",
+	Usage:        "revocation reason (compromised|superseded|retired|unspecified), signatures made before a revocation other than compromised remain valid",
+}
+
+// --in
+var keyLifetimeFlag = cmdline.Flag{
+	ID:           "keyLifetimeFlag",
+	Value:        &keyLifetime,
+	DefaultValue: "never",
+	Name:         "in",
+	Usage:        "expire the key after the given lifetime (e.g. 30d, 6m, 1y), or never",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(KeyCmd)
@@ -133,11 +164,19 @@ func init() {
 		cmdManager.RegisterSubCmd(KeyCmd, KeyImportCmd)
 		cmdManager.RegisterSubCmd(KeyCmd, KeyRemoveCmd)
 		cmdManager.RegisterSubCmd(KeyCmd, KeyExportCmd)
+		cmdManager.RegisterSubCmd(KeyCmd, KeyRevokeCmd)
+		cmdManager.RegisterSubCmd(KeyCmd, KeyExpireCmd)
+		cmdManager.RegisterSubCmd(KeyCmd, KeySubkeyCmd)
+		cmdManager.RegisterSubCmd(KeySubkeyCmd, KeySubkeyAddCmd)
+		cmdManager.RegisterSubCmd(KeySubkeyCmd, KeySubkeyRemoveCmd)
 
-		cmdManager.RegisterFlagForCmd(&keyServerURIFlag, KeySearchCmd, KeyPushCmd, KeyPullCmd)
+		cmdManager.RegisterFlagForCmd(&keyServerURIFlag, KeySearchCmd, KeyPushCmd, KeyPullCmd, KeyRevokeCmd)
 		cmdManager.RegisterFlagForCmd(&keySearchLongListFlag, KeySearchCmd)
 		cmdManager.RegisterFlagForCmd(&keyNewpairBitLengthFlag, KeyNewPairCmd)
 		cmdManager.RegisterFlagForCmd(&keyImportWithNewPasswordFlag, KeyImportCmd)
+		cmdManager.RegisterFlagForCmd(&keyRevokePushFlag, KeyRevokeCmd)
+		cmdManager.RegisterFlagForCmd(&keyRevokeReasonFlag, KeyRevokeCmd, KeySubkeyRemoveCmd)
+		cmdManager.RegisterFlagForCmd(&keyLifetimeFlag, KeyExpireCmd, KeySubkeyAddCmd)
 
 		cmdManager.SetCmdGroup("key_group_cmd", KeyImportCmd, KeyExportCmd, KeyListCmd, KeyPullCmd, KeyPushCmd, KeyRemoveCmd)
 
@@ -148,7 +187,7 @@ func init() {
 
 		cmdManager.RegisterFlagForCmd(
 			&keyLocalDirKeyFlag,
-			append(cmdManager.GetCmdGroup("key_group_cmd"), KeyNewPairCmd)...,
+			append(cmdManager.GetCmdGroup("key_group_cmd"), KeyNewPairCmd, KeyRevokeCmd, KeyExpireCmd, KeySubkeyAddCmd, KeySubkeyRemoveCmd)...,
 		)
 
 		// register public/private/both flags for KeyRemoveCmd only
@@ -0,0 +1,48 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/sypgp"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+// KeyExpireCmd is `apptainer key expire <fingerprint>' and sets the expiration time of a local key pair
+var KeyExpireCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		d, err := sypgp.ParseLifetime(keyLifetime)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
+		keyring := sypgp.NewHandle(keyLocalDir)
+		e, err := keyring.SetKeyExpiry(args[0], d, passphraseInteractive)
+		if err != nil {
+			sylog.Fatalf("Unable to set key expiration time: %s", err)
+		}
+
+		sig, _ := e.PrimarySelfSignature()
+		if t, ok := sypgp.ExpirationTime(e.PrimaryKey, sig); ok {
+			fmt.Printf("Key %X expires on %s\n", e.PrimaryKey.Fingerprint, t)
+		} else {
+			fmt.Printf("Key %X does not expire\n", e.PrimaryKey.Fingerprint)
+		}
+	},
+
+	Use:     docs.KeyExpireUse,
+	Short:   docs.KeyExpireShort,
+	Long:    docs.KeyExpireLong,
+	Example: docs.KeyExpireExample,
+}
@@ -0,0 +1,56 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/remote/endpoint"
+	"github.com/apptainer/apptainer/internal/pkg/sypgp"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+// KeyRevokeCmd is `apptainer key revoke <fingerprint>' and revokes a local key pair
+var KeyRevokeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		reason, err := sypgp.ParseRevocationReason(keyRevokeReason)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
+		keyring := sypgp.NewHandle(keyLocalDir)
+		e, err := keyring.RevokeKey(args[0], reason, passphraseInteractive)
+		if err != nil {
+			sylog.Fatalf("Unable to revoke key: %s", err)
+		}
+		fmt.Printf("Key %X revoked\n", e.PrimaryKey.Fingerprint)
+
+		if !keyRevokePush {
+			return
+		}
+
+		co, err := getKeyserverClientOpts(keyServerURI, endpoint.KeyserverPushOp)
+		if err != nil {
+			sylog.Fatalf("Keyserver client failed: %s", err)
+		}
+		if err := sypgp.PushPubkey(cmd.Context(), e, co...); err != nil {
+			sylog.Fatalf("Unable to push revoked key: %s", err)
+		}
+		fmt.Printf("Revoked public key %X pushed to server successfully\n", e.PrimaryKey.Fingerprint)
+	},
+
+	Use:     docs.KeyRevokeUse,
+	Short:   docs.KeyRevokeShort,
+	Long:    docs.KeyRevokeLong,
+	Example: docs.KeyRevokeExample,
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/sypgp"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+// KeySubkeyCmd is `apptainer key subkey' and manages signing subkeys of local key pairs
+var KeySubkeyCmd = &cobra.Command{
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return errors.New("invalid command")
+	},
+	DisableFlagsInUseLine: true,
+
+	Use:           docs.KeySubkeyUse,
+	Short:         docs.KeySubkeyShort,
+	Long:          docs.KeySubkeyLong,
+	Example:       docs.KeySubkeyExample,
+	SilenceErrors: true,
+}
+
+// KeySubkeyAddCmd is `apptainer key subkey add <fingerprint>' and adds a signing subkey
+var KeySubkeyAddCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		d, err := sypgp.ParseLifetime(keyLifetime)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
+		keyring := sypgp.NewHandle(keyLocalDir)
+		e, err := keyring.AddSigningSubkey(args[0], d, passphraseInteractive)
+		if err != nil {
+			sylog.Fatalf("Unable to add signing subkey: %s", err)
+		}
+
+		sk := e.Subkeys[len(e.Subkeys)-1]
+		fmt.Printf("Signing subkey %016X added to key %X\n", sk.PublicKey.KeyId, e.PrimaryKey.Fingerprint)
+	},
+
+	Use:     docs.KeySubkeyAddUse,
+	Short:   docs.KeySubkeyAddShort,
+	Long:    docs.KeySubkeyAddLong,
+	Example: docs.KeySubkeyAddExample,
+}
+
+// KeySubkeyRemoveCmd is `apptainer key subkey remove <fingerprint> <subkey ID>' and revokes a signing subkey
+var KeySubkeyRemoveCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		reason, err := sypgp.ParseRevocationReason(keyRevokeReason)
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+
+		keyring := sypgp.NewHandle(keyLocalDir)
+		e, err := keyring.RevokeSubkey(args[0], args[1], reason, passphraseInteractive)
+		if err != nil {
+			sylog.Fatalf("Unable to revoke signing subkey: %s", err)
+		}
+		fmt.Printf("Signing subkey %s of key %X revoked\n", args[1], e.PrimaryKey.Fingerprint)
+	},
+
+	Use:     docs.KeySubkeyRemoveUse,
+	Short:   docs.KeySubkeyRemoveShort,
+	Long:    docs.KeySubkeyRemoveLong,
+	Example: docs.KeySubkeyRemoveExample,
+}
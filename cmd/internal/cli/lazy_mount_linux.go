@@ -0,0 +1,38 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/apptainer/apptainer/internal/pkg/ocilazy"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterCmd(LazyMountCmd)
+	})
+}
+
+// LazyMountCmd serves the root filesystem of a lazily pulled OCI image,
+// it's executed by the lazy image driver.
+var LazyMountCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ocilazy.Serve(cmd.Context(), args[0], args[1]); err != nil {
+			sylog.Fatalf("%v", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+
+	Hidden: true,
+	Args:   cobra.ExactArgs(2),
+	Use:    "lazy-mount descriptor target",
+	Short:  "Serve the root filesystem of a lazily pulled image",
+}
@@ -19,6 +19,12 @@ import (
 
 var ociArgs apptainer.OciArgs
 
+var (
+	ociSpecBundle   string
+	ociSpecImage    string
+	ociSpecRootless bool
+)
+
 // -b|--bundle
 var ociBundleFlag = cmdline.Flag{
 	ID:           "ociBundleFlag",
@@ -132,6 +138,36 @@ var ociUpdateFromFileFlag = cmdline.Flag{
 	EnvKeys:      []string{"FROM_FILE"},
 }
 
+// -b|--bundle
+var ociSpecBundleFlag = cmdline.Flag{
+	ID:           "ociSpecBundleFlag",
+	Value:        &ociSpecBundle,
+	DefaultValue: ".",
+	Name:         "bundle",
+	ShortHand:    "b",
+	Usage:        "path to the OCI bundle directory where config.json is written",
+	Tag:          "<path>",
+}
+
+// --from
+var ociSpecFromFlag = cmdline.Flag{
+	ID:           "ociSpecFromFlag",
+	Value:        &ociSpecImage,
+	DefaultValue: "",
+	Name:         "from",
+	Usage:        "SIF image providing the process, environment and working directory of the configuration",
+	Tag:          "<image>",
+}
+
+// --rootless
+var ociSpecRootlessFlag = cmdline.Flag{
+	ID:           "ociSpecRootlessFlag",
+	Value:        &ociSpecRootless,
+	DefaultValue: false,
+	Name:         "rootless",
+	Usage:        "generate a configuration for a container run by an unprivileged user",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(OciCmd)
@@ -148,6 +184,8 @@ func init() {
 		cmdManager.RegisterSubCmd(OciCmd, OciResumeCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciMountCmd)
 		cmdManager.RegisterSubCmd(OciCmd, OciUmountCmd)
+		cmdManager.RegisterSubCmd(OciCmd, OciSpecCmd)
+		cmdManager.RegisterSubCmd(OciCmd, OciBundleCmd)
 
 		cmdManager.SetCmdGroup("create_run", OciCreateCmd, OciRunCmd)
 		createRunCmd := cmdManager.GetCmdGroup("create_run")
@@ -163,6 +201,9 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&ociKillTimeoutFlag, OciKillCmd)
 		cmdManager.RegisterFlagForCmd(&ociUpdateFromFileFlag, OciUpdateCmd)
 		cmdManager.RegisterFlagForCmd(&ociSyncSocketFlag, OciStateCmd)
+		cmdManager.RegisterFlagForCmd(&ociSpecBundleFlag, OciSpecCmd)
+		cmdManager.RegisterFlagForCmd(&ociSpecFromFlag, OciSpecCmd)
+		cmdManager.RegisterFlagForCmd(&ociSpecRootlessFlag, OciSpecCmd, OciBundleCmd)
 	})
 }
 
@@ -384,6 +425,36 @@ var OciUmountCmd = &cobra.Command{
 	Example: docs.OciUmountExample,
 }
 
+// OciSpecCmd represents oci spec command.
+var OciSpecCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := apptainer.OciSpec(ociSpecBundle, ociSpecImage, ociSpecRootless); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciSpecUse,
+	Short:   docs.OciSpecShort,
+	Long:    docs.OciSpecLong,
+	Example: docs.OciSpecExample,
+}
+
+// OciBundleCmd represents oci bundle command.
+var OciBundleCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciBundle(args[0], args[1], ociSpecRootless); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciBundleUse,
+	Short:   docs.OciBundleShort,
+	Long:    docs.OciBundleLong,
+	Example: docs.OciBundleExample,
+}
+
 // OciCmd apptainer oci runtime.
 var OciCmd = &cobra.Command{
 	Run:                   nil,
@@ -0,0 +1,593 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/app/apptainer"
+	"github.com/apptainer/apptainer/pkg/cmdline"
+	"github.com/apptainer/apptainer/pkg/ociruntime"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runcArgs         apptainer.OciArgs
+	runcRoot         string
+	runcLog          string
+	runcLogFormat    string
+	runcDebug        bool
+	runcRootless     string
+	runcCriu         string
+	runcKillAll      bool
+	runcForceDelete  bool
+	runcFormat       string
+	runcQuiet        bool
+	runcTTY          bool
+	runcNoPivot      bool
+	runcNoNewKeyring bool
+	runcPreserveFds  int
+)
+
+// --root
+var ociRuncRootFlag = cmdline.Flag{
+	ID:           "ociRuncRootFlag",
+	Value:        &runcRoot,
+	DefaultValue: "",
+	Name:         "root",
+	Usage:        "directory storing the state of containers (default: apptainer configuration directory)",
+	Tag:          "<path>",
+}
+
+// --log
+var ociRuncLogFlag = cmdline.Flag{
+	ID:           "ociRuncLogFlag",
+	Value:        &runcLog,
+	DefaultValue: "",
+	Name:         "log",
+	Usage:        "file where runtime messages are written, in addition to stderr",
+	Tag:          "<path>",
+}
+
+// --log-format
+var ociRuncLogFormatFlag = cmdline.Flag{
+	ID:           "ociRuncLogFormatFlag",
+	Value:        &runcLogFormat,
+	DefaultValue: "text",
+	Name:         "log-format",
+	Usage:        "format of the runtime log file: text or json",
+	Tag:          "<format>",
+}
+
+// --debug
+var ociRuncDebugFlag = cmdline.Flag{
+	ID:           "ociRuncDebugFlag",
+	Value:        &runcDebug,
+	DefaultValue: false,
+	Name:         "debug",
+	Usage:        "enable debug messages",
+}
+
+// --systemd-cgroup
+var ociRuncSystemdCgroupFlag = cmdline.Flag{
+	ID:           "ociRuncSystemdCgroupFlag",
+	Value:        &runcArgs.SystemdCgroups,
+	DefaultValue: false,
+	Name:         "systemd-cgroup",
+	Usage:        "manage cgroups with systemd, cgroupsPath must be slice:prefix:name",
+}
+
+// --rootless
+var ociRuncRootlessFlag = cmdline.Flag{
+	ID:           "ociRuncRootlessFlag",
+	Value:        &runcRootless,
+	DefaultValue: "auto",
+	Name:         "rootless",
+	Usage:        "accepted for compatibility, ignored",
+	Hidden:       true,
+}
+
+// --criu
+var ociRuncCriuFlag = cmdline.Flag{
+	ID:           "ociRuncCriuFlag",
+	Value:        &runcCriu,
+	DefaultValue: "criu",
+	Name:         "criu",
+	Usage:        "accepted for compatibility, ignored",
+	Hidden:       true,
+}
+
+// -b|--bundle
+var ociRuncBundleFlag = cmdline.Flag{
+	ID:           "ociRuncBundleFlag",
+	Value:        &runcArgs.BundlePath,
+	DefaultValue: ".",
+	Name:         "bundle",
+	ShortHand:    "b",
+	Usage:        "path to the OCI bundle",
+	Tag:          "<path>",
+}
+
+// --console-socket
+var ociRuncConsoleSocketFlag = cmdline.Flag{
+	ID:           "ociRuncConsoleSocketFlag",
+	Value:        &runcArgs.ConsoleSocket,
+	DefaultValue: "",
+	Name:         "console-socket",
+	Usage:        "unix socket receiving the master side of the terminal of the container process",
+	Tag:          "<path>",
+}
+
+// --pid-file
+var ociRuncPidFileFlag = cmdline.Flag{
+	ID:           "ociRuncPidFileFlag",
+	Value:        &runcArgs.PidFile,
+	DefaultValue: "",
+	Name:         "pid-file",
+	Usage:        "file where the PID of the container process is written",
+	Tag:          "<path>",
+}
+
+// --no-pivot
+var ociRuncNoPivotFlag = cmdline.Flag{
+	ID:           "ociRuncNoPivotFlag",
+	Value:        &runcNoPivot,
+	DefaultValue: false,
+	Name:         "no-pivot",
+	Usage:        "accepted for compatibility, ignored",
+	Hidden:       true,
+}
+
+// --no-new-keyring
+var ociRuncNoNewKeyringFlag = cmdline.Flag{
+	ID:           "ociRuncNoNewKeyringFlag",
+	Value:        &runcNoNewKeyring,
+	DefaultValue: false,
+	Name:         "no-new-keyring",
+	Usage:        "accepted for compatibility, ignored",
+	Hidden:       true,
+}
+
+// --preserve-fds
+var ociRuncPreserveFdsFlag = cmdline.Flag{
+	ID:           "ociRuncPreserveFdsFlag",
+	Value:        &runcPreserveFds,
+	DefaultValue: 0,
+	Name:         "preserve-fds",
+	Usage:        "accepted for compatibility, ignored",
+	Hidden:       true,
+}
+
+// -a|--all
+var ociRuncKillAllFlag = cmdline.Flag{
+	ID:           "ociRuncKillAllFlag",
+	Value:        &runcKillAll,
+	DefaultValue: false,
+	Name:         "all",
+	ShortHand:    "a",
+	Usage:        "send the signal to all processes of the container",
+}
+
+// -f|--force
+var ociRuncForceDeleteFlag = cmdline.Flag{
+	ID:           "ociRuncForceDeleteFlag",
+	Value:        &runcForceDelete,
+	DefaultValue: false,
+	Name:         "force",
+	ShortHand:    "f",
+	Usage:        "kill the container with SIGKILL if it is running",
+}
+
+// -r|--resources
+var ociRuncResourcesFlag = cmdline.Flag{
+	ID:           "ociRuncResourcesFlag",
+	Value:        &runcArgs.FromFile,
+	DefaultValue: "",
+	Name:         "resources",
+	ShortHand:    "r",
+	Usage:        "path to the OCI JSON cgroups resources file ('-' to read from STDIN)",
+	Tag:          "<path>",
+}
+
+// -p|--process
+var ociRuncProcessFlag = cmdline.Flag{
+	ID:           "ociRuncProcessFlag",
+	Value:        &runcArgs.ProcessFile,
+	DefaultValue: "",
+	Name:         "process",
+	ShortHand:    "p",
+	Usage:        "path to the process.json file, in the format of the process of the OCI runtime specification",
+	Tag:          "<path>",
+}
+
+// -d|--detach
+var ociRuncDetachFlag = cmdline.Flag{
+	ID:           "ociRuncDetachFlag",
+	Value:        &runcArgs.Detach,
+	DefaultValue: false,
+	Name:         "detach",
+	ShortHand:    "d",
+	Usage:        "run the process in the background",
+}
+
+// -t|--tty
+var ociRuncTTYFlag = cmdline.Flag{
+	ID:           "ociRuncTTYFlag",
+	Value:        &runcTTY,
+	DefaultValue: false,
+	Name:         "tty",
+	ShortHand:    "t",
+	Usage:        "allocate a terminal for the process, sent to --console-socket",
+}
+
+// -f|--format
+var ociRuncFormatFlag = cmdline.Flag{
+	ID:           "ociRuncFormatFlag",
+	Value:        &runcFormat,
+	DefaultValue: apptainer.OciFormatTable,
+	Name:         "format",
+	ShortHand:    "f",
+	Usage:        "output format: table or json",
+	Tag:          "<format>",
+}
+
+// -q|--quiet
+var ociRuncQuietFlag = cmdline.Flag{
+	ID:           "ociRuncQuietFlag",
+	Value:        &runcQuiet,
+	DefaultValue: false,
+	Name:         "quiet",
+	ShortHand:    "q",
+	Usage:        "only display container IDs",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterSubCmd(OciCmd, OciRuncCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncCreateCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncStartCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncStateCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncKillCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncDeleteCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncExecCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncPauseCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncResumeCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncUpdateCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncPsCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncListCmd)
+		cmdManager.RegisterSubCmd(OciRuncCmd, OciRuncFeaturesCmd)
+
+		cmdManager.RegisterFlagForCmd(&ociRuncRootFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncLogFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncLogFormatFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncDebugFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncSystemdCgroupFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncRootlessFlag, OciRuncCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncCriuFlag, OciRuncCmd)
+
+		cmdManager.RegisterFlagForCmd(&ociRuncBundleFlag, OciRuncCreateCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncConsoleSocketFlag, OciRuncCreateCmd, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncPidFileFlag, OciRuncCreateCmd, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncNoPivotFlag, OciRuncCreateCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncNoNewKeyringFlag, OciRuncCreateCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncPreserveFdsFlag, OciRuncCreateCmd, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncKillAllFlag, OciRuncKillCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncForceDeleteFlag, OciRuncDeleteCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncResourcesFlag, OciRuncUpdateCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncProcessFlag, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncDetachFlag, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncTTYFlag, OciRuncExecCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncFormatFlag, OciRuncPsCmd, OciRuncListCmd)
+		cmdManager.RegisterFlagForCmd(&ociRuncQuietFlag, OciRuncListCmd)
+	})
+}
+
+// runcLogWriter writes runtime messages to the --log file, as JSON
+// objects with the json log format.
+type runcLogWriter struct {
+	sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (l *runcLogWriter) Write(p []byte) (int, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.json {
+		return l.w.Write(p)
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		level, msg, _ := strings.Cut(line, " ")
+		entry := map[string]string{
+			"level": strings.ToLower(strings.TrimSuffix(level, ":")),
+			"msg":   strings.TrimSpace(msg),
+			"time":  time.Now().Format(time.RFC3339Nano),
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := l.w.Write(append(data, '\n')); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// runcPreRun checks for root privileges and applies the global options of
+// the runc compatible commands.
+func runcPreRun(cmd *cobra.Command, args []string) {
+	CheckRoot(cmd, args)
+
+	if runcDebug {
+		sylog.SetLevel(int(sylog.DebugLevel), false)
+	}
+	if runcLog != "" {
+		if runcLogFormat != "text" && runcLogFormat != "json" {
+			sylog.Fatalf("--log-format must be text or json")
+		}
+		f, err := os.OpenFile(runcLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			sylog.Fatalf("While opening log file: %s", err)
+		}
+		// colors are not written to the log file
+		sylog.SetLevel(sylog.GetLevel(), false)
+		sylog.SetWriter(io.MultiWriter(os.Stderr, &runcLogWriter{w: f, json: runcLogFormat == "json"}))
+	}
+	if runcRoot != "" {
+		if err := apptainer.OciSetRoot(runcRoot); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	}
+}
+
+// OciRuncCmd is 'apptainer oci runc', the runc compatible commands.
+var OciRuncCmd = &cobra.Command{
+	Run:                   nil,
+	DisableFlagsInUseLine: true,
+
+	Use:     docs.OciRuncUse,
+	Short:   docs.OciRuncShort,
+	Long:    docs.OciRuncLong,
+	Example: docs.OciRuncExample,
+}
+
+// OciRuncCreateCmd creates a container from an OCI bundle.
+var OciRuncCreateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciCreate(args[0], &runcArgs); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncCreateUse,
+	Short:   docs.OciRuncCreateShort,
+	Long:    docs.OciRuncCreateLong,
+	Example: docs.OciRuncCreateExample,
+}
+
+// OciRuncStartCmd starts the process of a created container.
+var OciRuncStartCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciStart(args[0]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncStartUse,
+	Short:   docs.OciRuncStartShort,
+	Long:    docs.OciRuncStartLong,
+	Example: docs.OciRuncStartExample,
+}
+
+// OciRuncStateCmd displays the state of a container.
+var OciRuncStateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciState(args[0], &apptainer.OciArgs{}); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncStateUse,
+	Short:   docs.OciRuncStateShort,
+	Long:    docs.OciRuncStateLong,
+	Example: docs.OciRuncStateExample,
+}
+
+// OciRuncKillCmd sends a signal to the process of a container.
+var OciRuncKillCmd = &cobra.Command{
+	Args:                  cobra.RangeArgs(1, 2),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		killSignal := "SIGTERM"
+		if len(args) > 1 {
+			killSignal = args[1]
+		}
+
+		var err error
+		if runcKillAll {
+			err = apptainer.OciKillAll(args[0], killSignal)
+		} else {
+			err = apptainer.OciKill(args[0], killSignal, 0)
+		}
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncKillUse,
+	Short:   docs.OciRuncKillShort,
+	Long:    docs.OciRuncKillLong,
+	Example: docs.OciRuncKillExample,
+}
+
+// OciRuncDeleteCmd deletes the resources of a container.
+var OciRuncDeleteCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		containerID := args[0]
+
+		if runcForceDelete {
+			state, err := apptainer.OciGetState(containerID)
+			if err != nil {
+				// deleting a missing container is not an error
+				return
+			}
+			if state.Status == ociruntime.Running || state.Status == ociruntime.Paused {
+				if state.Status == ociruntime.Paused {
+					if err := apptainer.OciPauseResume(containerID, false); err != nil {
+						sylog.Fatalf("%s", err)
+					}
+				}
+				if err := apptainer.OciKill(containerID, "SIGKILL", 5); err != nil {
+					sylog.Fatalf("%s", err)
+				}
+			}
+		}
+
+		if err := apptainer.OciDelete(cmd.Context(), containerID); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncDeleteUse,
+	Short:   docs.OciRuncDeleteShort,
+	Long:    docs.OciRuncDeleteLong,
+	Example: docs.OciRuncDeleteExample,
+}
+
+// OciRuncExecCmd executes a process in a running container.
+var OciRuncExecCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciExecProcess(args[0], args[1:], &runcArgs, runcTTY); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncExecUse,
+	Short:   docs.OciRuncExecShort,
+	Long:    docs.OciRuncExecLong,
+	Example: docs.OciRuncExecExample,
+}
+
+// OciRuncPauseCmd pauses the processes of a container.
+var OciRuncPauseCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciPauseResume(args[0], true); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncPauseUse,
+	Short:   docs.OciRuncPauseShort,
+	Long:    docs.OciRuncPauseLong,
+	Example: docs.OciRuncPauseExample,
+}
+
+// OciRuncResumeCmd resumes the processes of a paused container.
+var OciRuncResumeCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciPauseResume(args[0], false); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncResumeUse,
+	Short:   docs.OciRuncResumeShort,
+	Long:    docs.OciRuncResumeLong,
+	Example: docs.OciRuncResumeExample,
+}
+
+// OciRuncUpdateCmd updates the cgroups resources of a container.
+var OciRuncUpdateCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciUpdate(args[0], &runcArgs); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncUpdateUse,
+	Short:   docs.OciRuncUpdateShort,
+	Long:    docs.OciRuncUpdateLong,
+	Example: docs.OciRuncUpdateExample,
+}
+
+// OciRuncPsCmd displays the processes of a container.
+var OciRuncPsCmd = &cobra.Command{
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, args []string) {
+		if err := apptainer.OciPs(os.Stdout, args[0], runcFormat, args[1:]); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncPsUse,
+	Short:   docs.OciRuncPsShort,
+	Long:    docs.OciRuncPsLong,
+	Example: docs.OciRuncPsExample,
+}
+
+// OciRuncListCmd lists the containers.
+var OciRuncListCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	PreRun:                runcPreRun,
+	Run: func(_ *cobra.Command, _ []string) {
+		if err := apptainer.OciList(os.Stdout, runcFormat, runcQuiet); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+	},
+	Use:     docs.OciRuncListUse,
+	Short:   docs.OciRuncListShort,
+	Long:    docs.OciRuncListLong,
+	Example: docs.OciRuncListExample,
+}
+
+// OciRuncFeaturesCmd displays the features supported by the runtime.
+var OciRuncFeaturesCmd = &cobra.Command{
+	Args:                  cobra.ExactArgs(0),
+	DisableFlagsInUseLine: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		var buf bytes.Buffer
+		if err := apptainer.OciFeatures(&buf); err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		os.Stdout.Write(buf.Bytes())
+	},
+	Use:     docs.OciRuncFeaturesUse,
+	Short:   docs.OciRuncFeaturesShort,
+	Long:    docs.OciRuncFeaturesLong,
+	Example: docs.OciRuncFeaturesExample,
+}
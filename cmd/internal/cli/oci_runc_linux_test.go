@@ -0,0 +1,64 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestRuncLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := &runcLogWriter{w: &buf, json: true}
+	msg := "WARNING: first message\nERROR:   second message\n"
+	n, err := w.Write([]byte(msg))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != len(msg) {
+		t.Errorf("wrote %d bytes instead of %d", n, len(msg))
+	}
+
+	want := []struct{ level, msg string }{
+		{"warning", "first message"},
+		{"error", "second message"},
+	}
+	scanner := bufio.NewScanner(&buf)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		entry := make(map[string]string)
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("while decoding %q: %s", scanner.Text(), err)
+		}
+		if i >= len(want) {
+			continue
+		}
+		if entry["level"] != want[i].level || entry["msg"] != want[i].msg {
+			t.Errorf("got level %q msg %q, want level %q msg %q", entry["level"], entry["msg"], want[i].level, want[i].msg)
+		}
+		if entry["time"] == "" {
+			t.Errorf("missing time in %q", scanner.Text())
+		}
+	}
+	if i != len(want) {
+		t.Errorf("got %d log entries instead of %d", i, len(want))
+	}
+
+	buf.Reset()
+	w.json = false
+	if _, err := w.Write([]byte(msg)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.String() != msg {
+		t.Errorf("got %q with text format, want %q", buf.String(), msg)
+	}
+}
@@ -100,14 +100,25 @@ func decryptSelectedEntityInteractive(f sypgp.EntitySelector) sypgp.EntitySelect
 	}
 }
 
-// decryptPrivateKeyInteractive decrypts the private key in e, prompting the user for a passphrase.
+// decryptPrivateKeyInteractive decrypts the private keys in e, including signing subkeys,
+// prompting the user for a passphrase.
 func decryptPrivateKeyInteractive(e *openpgp.Entity) error {
-	passphrase, err := interactive.AskQuestionNoEcho("Enter key passphrase : ")
+	passphrase, err := passphraseInteractive(e)
 	if err != nil {
 		return err
 	}
 
-	return e.PrivateKey.Decrypt([]byte(passphrase))
+	return e.DecryptPrivateKeys(passphrase)
+}
+
+// passphraseInteractive prompts the user for the passphrase of the private key in e.
+func passphraseInteractive(*openpgp.Entity) ([]byte, error) {
+	passphrase, err := interactive.AskQuestionNoEcho("Enter key passphrase : ")
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(passphrase), nil
 }
 
 // primaryIdentity returns the Identity marked as primary, or the first identity if none are so
@@ -148,31 +159,42 @@ func isGlobal(e *openpgp.Entity) bool {
 	return len(keys) > 0
 }
 
-// outputVerify outputs a textual representation of r to stdout.
-func outputVerify(_ *sif.FileImage, r integrity.VerifyResult) bool {
+// outputSigner outputs a textual representation of the signing entity of r to stdout.
+func outputSigner(r sifsignature.Result) {
 	e := r.Entity()
 
-	// Print signing entity info.
-	if e != nil {
-		prefix := color.New(color.FgYellow).Sprint("[REMOTE]")
-
-		if isGlobal(e) {
-			prefix = color.New(color.FgCyan).Sprint("[GLOBAL]")
-		} else if isLocal(e) {
-			prefix = color.New(color.FgGreen).Sprint("[LOCAL]")
+	// Print key fingerprint(s) when the signing entity is not a PGP entity.
+	if e == nil {
+		for _, fp := range sifsignature.Fingerprints(r) {
+			fmt.Printf("%-18v Fingerprint: %v\n", "[KEY]", fp)
 		}
+		return
+	}
 
-		// Print identity, if possible.
-		if id := primaryIdentity(e); id != nil {
-			fmt.Printf("%-18v Signing entity: %v\n", prefix, id.Name)
-		} else {
-			sylog.Warningf("Primary identity unknown")
-		}
+	// Print signing entity info.
+	prefix := color.New(color.FgYellow).Sprint("[REMOTE]")
 
-		// Always print fingerprint.
-		fmt.Printf("%-18v Fingerprint: %X\n", prefix, e.PrimaryKey.Fingerprint)
+	if isGlobal(e) {
+		prefix = color.New(color.FgCyan).Sprint("[GLOBAL]")
+	} else if isLocal(e) {
+		prefix = color.New(color.FgGreen).Sprint("[LOCAL]")
 	}
 
+	// Print identity, if possible.
+	if id := primaryIdentity(e); id != nil {
+		fmt.Printf("%-18v Signing entity: %v\n", prefix, id.Name)
+	} else {
+		sylog.Warningf("Primary identity unknown")
+	}
+
+	// Always print fingerprint.
+	fmt.Printf("%-18v Fingerprint: %X\n", prefix, e.PrimaryKey.Fingerprint)
+}
+
+// outputVerify outputs a textual representation of r to stdout.
+func outputVerify(_ *sif.FileImage, r integrity.VerifyResult) bool {
+	outputSigner(r)
+
 	// Print table of signed objects.
 	if len(r.Verified()) > 0 {
 		fmt.Printf("Objects verified:\n")
@@ -204,6 +226,19 @@ func outputVerify(_ *sif.FileImage, r integrity.VerifyResult) bool {
 	return false
 }
 
+// outputCountersign outputs a textual representation of the countersignature result r to stdout.
+func outputCountersign(_ *sif.FileImage, r sifsignature.CountersignResult) bool {
+	outputSigner(r)
+
+	fmt.Printf("Countersignature %d verified over signature %d\n", r.Signature().ID(), r.Target().ID())
+
+	if err := r.Error(); err != nil {
+		fmt.Printf("\nError encountered during countersignature verification: %v\n", err)
+	}
+
+	return false
+}
+
 type key struct {
 	Signer keyEntity
 }
@@ -218,10 +253,29 @@ type keyEntity struct {
 	DataCheck   bool
 }
 
+// chainEntry describes a signature or countersignature, used for json output.
+type chainEntry struct {
+	ID           uint32
+	Fingerprints []string
+	Countersigns uint32 `json:",omitempty"`
+	Valid        bool
+}
+
 // keyList is a list of one or more keys.
 type keyList struct {
 	Signatures int
 	SignerKeys []*key
+	Chain      []*chainEntry
+}
+
+// addChainEntry appends the signature or countersignature described by r to kl.
+func (kl *keyList) addChainEntry(r sifsignature.Result, countersigns uint32) {
+	kl.Chain = append(kl.Chain, &chainEntry{
+		ID:           r.Signature().ID(),
+		Fingerprints: sifsignature.Fingerprints(r),
+		Countersigns: countersigns,
+		Valid:        r.Error() == nil,
+	})
 }
 
 // getJSONCallback returns a signature.VerifyCallback that appends to kl.
@@ -232,6 +286,7 @@ func getJSONCallback(kl *keyList) sifsignature.VerifyCallback {
 
 		// Increment signature count.
 		kl.Signatures++
+		kl.addChainEntry(r, 0)
 
 		// If entity is determined, note a few values.
 		if e := r.Entity(); e != nil {
@@ -279,6 +334,15 @@ func getJSONCallback(kl *keyList) sifsignature.VerifyCallback {
 	}
 }
 
+// getJSONCountersignCallback returns a signature.CountersignCallback that appends to kl.
+func getJSONCountersignCallback(kl *keyList) sifsignature.CountersignCallback {
+	return func(_ *sif.FileImage, r sifsignature.CountersignResult) bool {
+		kl.Signatures++
+		kl.addChainEntry(r, r.Target().ID())
+		return false
+	}
+}
+
 // outputJSON outputs a JSON representation of kl to w.
 func outputJSON(w io.Writer, kl keyList) error {
 	e := json.NewEncoder(w)
@@ -12,6 +12,7 @@ package cli
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -28,9 +29,11 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/ociimage"
 	"github.com/apptainer/apptainer/internal/pkg/ociplatform"
 	"github.com/apptainer/apptainer/internal/pkg/remote/endpoint"
+	"github.com/apptainer/apptainer/internal/pkg/sifindex"
 	"github.com/apptainer/apptainer/internal/pkg/util/uri"
 	"github.com/apptainer/apptainer/pkg/cmdline"
 	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/sif/v2/pkg/sif"
 	"github.com/spf13/cobra"
 )
 
@@ -283,6 +286,9 @@ func pullRun(cmd *cobra.Command, args []string) {
 		if err == library.ErrLibraryPullUnsigned {
 			sylog.Warningf("Skipping container verification")
 		}
+		if err := selectPullPlatform(cmd, pullTo); err != nil {
+			sylog.Fatalf("While selecting image platform: %v", err)
+		}
 	case ShubProtocol:
 		_, err := shub.PullToFile(ctx, imgCache, pullTo, pullFrom, noHTTPS, pullSandbox)
 		if err != nil {
@@ -300,6 +306,9 @@ func pullRun(cmd *cobra.Command, args []string) {
 		if err != nil {
 			sylog.Fatalf("While pulling image from oci registry: %v", err)
 		}
+		if err := selectPullPlatform(cmd, pullTo); err != nil {
+			sylog.Fatalf("While selecting image platform: %v", err)
+		}
 	case IPFSProtocol:
 		_, err := ipfs.PullToFile(ctx, imgCache, pullTo, pullFrom, pullSandbox)
 		if err != nil {
@@ -347,3 +356,44 @@ func pullRun(cmd *cobra.Command, args []string) {
 		sylog.Fatalf("Unsupported transport type: %s", transport)
 	}
 }
+
+// selectPullPlatform keeps only the system partition matching the platform
+// requested with --arch and --arch-variant when the pulled image is a
+// multi-architecture SIF image. Without these flags, the image is kept as is
+// and the platform is selected when running it.
+func selectPullPlatform(cmd *cobra.Command, pullTo string) error {
+	if !cmd.Flags().Changed(pullArchFlag.Name) && !cmd.Flags().Changed(pullArchVariantFlag.Name) {
+		return nil
+	}
+	if pullSandbox {
+		sylog.Warningf("Multi-architecture SIF images pulled as sandbox use the host platform")
+		return nil
+	}
+
+	f, err := sif.LoadContainerFromPath(pullTo, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading %s: %w", pullTo, err)
+	}
+	isIndex := sifindex.IsIndex(f)
+	f.UnloadContainer()
+	if !isIndex {
+		return nil
+	}
+
+	arch, err := build_oci.ConvertArch(pullArch, pullArchVariant)
+	if err != nil {
+		return err
+	}
+	platform, err := ociplatform.PlatformFromArch(arch)
+	if err != nil {
+		return err
+	}
+
+	tmp := pullTo + ".platform"
+	if err := sifindex.Extract(tmp, pullTo, *platform); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	sylog.Infof("Keeping %s system partition of multi-architecture image", platform)
+	return os.Rename(tmp, pullTo)
+}
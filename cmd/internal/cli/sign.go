@@ -13,8 +13,10 @@ import (
 	"crypto"
 
 	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/pkcs11"
 	sifsignature "github.com/apptainer/apptainer/internal/pkg/signature"
 	"github.com/apptainer/apptainer/internal/pkg/sypgp"
+	"github.com/apptainer/apptainer/internal/pkg/util/interactive"
 	"github.com/apptainer/apptainer/pkg/cmdline"
 	"github.com/apptainer/apptainer/pkg/sylog"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
@@ -24,8 +26,10 @@ import (
 
 var (
 	priKeyPath string
+	priKeyURI  string
 	priKeyIdx  int
 	signAll    bool
+	countersig bool
 )
 
 // -g|--group-id
@@ -78,6 +82,16 @@ var signPrivateKeyFlag = cmdline.Flag{
 	EnvKeys:      []string{"SIGN_KEY"},
 }
 
+// --pkcs11-uri
+var signPKCS11URIFlag = cmdline.Flag{
+	ID:           "signPKCS11URIFlag",
+	Value:        &priKeyURI,
+	DefaultValue: "",
+	Name:         "pkcs11-uri",
+	Usage:        "PKCS#11 URI of the private key stored in a token",
+	EnvKeys:      []string{"SIGN_PKCS11_URI"},
+}
+
 // -k|--keyidx
 var signKeyIdxFlag = cmdline.Flag{
 	ID:           "signKeyIdxFlag",
@@ -99,6 +113,15 @@ var signAllFlag = cmdline.Flag{
 	Deprecated:   "now the default behavior",
 }
 
+// --countersign
+var signCountersignFlag = cmdline.Flag{
+	ID:           "signCountersignFlag",
+	Value:        &countersig,
+	DefaultValue: false,
+	Name:         "countersign",
+	Usage:        "countersign existing signature(s) instead of signing data objects",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(SignCmd)
@@ -108,8 +131,10 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&signSifDescSifIDFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signSifDescIDFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signPrivateKeyFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&signPKCS11URIFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signKeyIdxFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signAllFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&signCountersignFlag, SignCmd)
 	})
 }
 
@@ -143,6 +168,16 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 		}
 		opts = append(opts, sifsignature.OptSignWithSigner(s))
 
+	case cmd.Flag(signPKCS11URIFlag.Name).Changed:
+		sylog.Infof("Signing image with PKCS#11 key '%v'", priKeyURI)
+
+		k, err := pkcs11.OpenPrivateKey(priKeyURI, pkcs11PinInteractive)
+		if err != nil {
+			sylog.Fatalf("Failed to load PKCS#11 key: %v", err)
+		}
+		defer k.Close()
+		opts = append(opts, sifsignature.OptSignWithSigner(k.Signer()))
+
 	default:
 		sylog.Infof("Signing image with PGP key material")
 
@@ -157,13 +192,34 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 		opts = append(opts, sifsignature.OptSignEntitySelector(f))
 	}
 
+	groupSet := cmd.Flag(signSifGroupIDFlag.Name).Changed || cmd.Flag(signOldSifGroupIDFlag.Name).Changed
+	objectSet := cmd.Flag(signSifDescSifIDFlag.Name).Changed || cmd.Flag(signSifDescIDFlag.Name).Changed
+
+	// Countersign the selected signature, or all signatures not yet countersigned.
+	if countersig {
+		if groupSet {
+			sylog.Fatalf("--countersign can't be used with --group-id, use --sif-id to select a signature")
+		}
+		if objectSet {
+			opts = append(opts, sifsignature.OptSignCountersign(sifDescID))
+		} else {
+			opts = append(opts, sifsignature.OptSignCountersign())
+		}
+
+		if err := sifsignature.Sign(cmd.Context(), cpath, opts...); err != nil {
+			sylog.Fatalf("Failed to countersign container: %v", err)
+		}
+		sylog.Infof("Countersignature created and applied to image '%v'", cpath)
+		return
+	}
+
 	// Set group option, if applicable.
-	if cmd.Flag(signSifGroupIDFlag.Name).Changed || cmd.Flag(signOldSifGroupIDFlag.Name).Changed {
+	if groupSet {
 		opts = append(opts, sifsignature.OptSignGroup(sifGroupID))
 	}
 
 	// Set object option, if applicable.
-	if cmd.Flag(signSifDescSifIDFlag.Name).Changed || cmd.Flag(signSifDescIDFlag.Name).Changed {
+	if objectSet {
 		opts = append(opts, sifsignature.OptSignObjects(sifDescID))
 	}
 
@@ -173,3 +229,8 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 	}
 	sylog.Infof("Signature created and applied to image '%v'", cpath)
 }
+
+// pkcs11PinInteractive asks the PIN of a PKCS#11 token.
+func pkcs11PinInteractive() (string, error) {
+	return interactive.AskQuestionNoEcho("Enter PIN for PKCS#11 token: ")
+}
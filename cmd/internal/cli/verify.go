@@ -15,6 +15,7 @@ import (
 	"os"
 
 	"github.com/apptainer/apptainer/docs"
+	"github.com/apptainer/apptainer/internal/pkg/pkcs11"
 	"github.com/apptainer/apptainer/internal/pkg/remote/endpoint"
 	sifsignature "github.com/apptainer/apptainer/internal/pkg/signature"
 	"github.com/apptainer/apptainer/pkg/cmdline"
@@ -24,17 +25,21 @@ import (
 )
 
 var (
-	sifGroupID                   uint32 // -g groupid specification
-	sifDescID                    uint32 // -i id specification
-	certificatePath              string // --certificate flag
-	certificateIntermediatesPath string // --certificate-intermediates flag
-	certificateRootsPath         string // --certificate-roots flag
-	ocspVerify                   bool   // --ocsp-verify flag
-	pubKeyPath                   string // --key flag
-	localVerify                  bool   // -l flag
-	jsonVerify                   bool   // -j flag
+	sifGroupID                   uint32   // -g groupid specification
+	sifDescID                    uint32   // -i id specification
+	certificatePath              string   // --certificate flag
+	certificateIntermediatesPath string   // --certificate-intermediates flag
+	certificateRootsPath         string   // --certificate-roots flag
+	ocspVerify                   bool     // --ocsp-verify flag
+	pubKeyPaths                  []string // --key flag
+	pubKeyURI                    string   // --pkcs11-uri flag
+	localVerify                  bool     // -l flag
+	jsonVerify                   bool     // -j flag
 	verifyAll                    bool
 	verifyLegacy                 bool
+	requireSigners               int      // --require-signers flag
+	requireFingerprints          []string // --require-fingerprint flag
+	requireCountersign           bool     // --require-countersign flag
 )
 
 // -u|--url
@@ -131,13 +136,23 @@ var verifyOCSPFlag = cmdline.Flag{
 // --key
 var verifyPublicKeyFlag = cmdline.Flag{
 	ID:           "publicKeyFlag",
-	Value:        &pubKeyPath,
-	DefaultValue: "",
+	Value:        &pubKeyPaths,
+	DefaultValue: cmdline.StringArray{},
 	Name:         "key",
-	Usage:        "path to the public key file",
+	Usage:        "path to the public key file (can be specified multiple times)",
 	EnvKeys:      []string{"VERIFY_KEY"},
 }
 
+// --pkcs11-uri
+var verifyPKCS11URIFlag = cmdline.Flag{
+	ID:           "verifyPKCS11URIFlag",
+	Value:        &pubKeyURI,
+	DefaultValue: "",
+	Name:         "pkcs11-uri",
+	Usage:        "PKCS#11 URI of the public key or certificate stored in a token",
+	EnvKeys:      []string{"VERIFY_PKCS11_URI"},
+}
+
 // -l|--local
 var verifyLocalFlag = cmdline.Flag{
 	ID:           "verifyLocalFlag",
@@ -178,6 +193,33 @@ var verifyLegacyFlag = cmdline.Flag{
 	Usage:        "enable verification of (insecure) legacy signatures",
 }
 
+// --require-signers
+var verifyRequireSignersFlag = cmdline.Flag{
+	ID:           "verifyRequireSignersFlag",
+	Value:        &requireSigners,
+	DefaultValue: 0,
+	Name:         "require-signers",
+	Usage:        "require valid signatures from at least this number of entities",
+}
+
+// --require-fingerprint
+var verifyRequireFingerprintFlag = cmdline.Flag{
+	ID:           "verifyRequireFingerprintFlag",
+	Value:        &requireFingerprints,
+	DefaultValue: []string{},
+	Name:         "require-fingerprint",
+	Usage:        "require a valid signature from the entity with this fingerprint (can be specified multiple times)",
+}
+
+// --require-countersign
+var verifyRequireCountersignFlag = cmdline.Flag{
+	ID:           "verifyRequireCountersignFlag",
+	Value:        &requireCountersign,
+	DefaultValue: false,
+	Name:         "require-countersign",
+	Usage:        "require signatures to be countersigned by another entity, in the order of --require-fingerprint if specified multiple times",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(VerifyCmd)
@@ -192,10 +234,14 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&verifyCertificateRootsFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyOCSPFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyPublicKeyFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&verifyPKCS11URIFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyLocalFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyJSONFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyAllFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyLegacyFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&verifyRequireSignersFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&verifyRequireFingerprintFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&verifyRequireCountersignFlag, VerifyCmd)
 	})
 }
 
@@ -249,11 +295,27 @@ func doVerifyCmd(cmd *cobra.Command, cpath string) {
 		}
 
 	case cmd.Flag(verifyPublicKeyFlag.Name).Changed:
-		sylog.Infof("Verifying image with key material from '%v'", pubKeyPath)
+		for _, path := range pubKeyPaths {
+			sylog.Infof("Verifying image with key material from '%v'", path)
 
-		v, err := signature.LoadVerifierFromPEMFile(pubKeyPath, crypto.SHA256)
+			v, err := signature.LoadVerifierFromPEMFile(path, crypto.SHA256)
+			if err != nil {
+				sylog.Fatalf("Failed to load key material: %v", err)
+			}
+			opts = append(opts, sifsignature.OptVerifyWithVerifier(v))
+		}
+
+	case cmd.Flag(verifyPKCS11URIFlag.Name).Changed:
+		sylog.Infof("Verifying image with PKCS#11 key '%v'", pubKeyURI)
+
+		k, err := pkcs11.OpenPublicKey(pubKeyURI, pkcs11PinInteractive)
 		if err != nil {
-			sylog.Fatalf("Failed to load key material: %v", err)
+			sylog.Fatalf("Failed to load PKCS#11 key: %v", err)
+		}
+		v, err := k.Verifier()
+		k.Close()
+		if err != nil {
+			sylog.Fatalf("Failed to load PKCS#11 key: %v", err)
 		}
 		opts = append(opts, sifsignature.OptVerifyWithVerifier(v))
 
@@ -292,11 +354,25 @@ func doVerifyCmd(cmd *cobra.Command, cpath string) {
 		opts = append(opts, sifsignature.OptVerifyLegacy())
 	}
 
+	// Set policy options, if applicable.
+	if cmd.Flag(verifyRequireSignersFlag.Name).Changed {
+		opts = append(opts, sifsignature.OptVerifyRequireSigners(requireSigners))
+	}
+	for _, fp := range requireFingerprints {
+		opts = append(opts, sifsignature.OptVerifyRequireFingerprint(fp))
+	}
+	if requireCountersign {
+		opts = append(opts, sifsignature.OptVerifyRequireCountersign())
+	}
+
 	// Set callback option.
 	if jsonVerify {
 		var kl keyList
 
-		opts = append(opts, sifsignature.OptVerifyCallback(getJSONCallback(&kl)))
+		opts = append(opts,
+			sifsignature.OptVerifyCallback(getJSONCallback(&kl)),
+			sifsignature.OptVerifyCountersignCallback(getJSONCountersignCallback(&kl)),
+		)
 
 		verifyErr := sifsignature.Verify(cmd.Context(), cpath, opts...)
 
@@ -309,7 +385,10 @@ func doVerifyCmd(cmd *cobra.Command, cpath string) {
 			sylog.Fatalf("Failed to verify container: %v", verifyErr)
 		}
 	} else {
-		opts = append(opts, sifsignature.OptVerifyCallback(outputVerify))
+		opts = append(opts,
+			sifsignature.OptVerifyCallback(outputVerify),
+			sifsignature.OptVerifyCountersignCallback(outputCountersign),
+		)
 
 		if err := sifsignature.Verify(cmd.Context(), cpath, opts...); err != nil {
 			sylog.Fatalf("Failed to verify container: %v", err)
@@ -112,11 +112,24 @@ Enterprise Performance Computing (EPC)`
           MirrorURL: http://download.opensuse.org/distribution/openSUSE-stable/repo/oss
           Include: zypper
 
+      Alpine:
+          Bootstrap: apk
+          OSVersion: v3.20
+          MirrorURL: https://dl-cdn.alpinelinux.org/alpine/%{OSVERSION}
+          Keys: https://alpinelinux.org/keys/alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub
+
       Debian/Ubuntu:
           Bootstrap: debootstrap
           OSVersion: trusty
           MirrorURL: http://us.archive.ubuntu.com/ubuntu/
 
+      Debian/Ubuntu (rootless):
+          Bootstrap: mmdebstrap
+          OSVersion: bookworm
+          Suites: bookworm-updates
+          Components: main contrib
+          MirrorURL: http://deb.debian.org/debian
+
       Local Image:
           Bootstrap: localimage
           From: /home/dave/starter.img
@@ -364,6 +377,83 @@ Enterprise Performance Computing (EPC)`
 	KeyRemoveExample string = `
   $ apptainer key remove D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key revoke
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeyRevokeUse   string = `revoke [revoke options...] <fingerprint>`
+	KeyRevokeShort string = `Revoke a key pair from your local keyring`
+	KeyRevokeLong  string = `
+  The 'key revoke' command adds a revocation signature to a key pair of your
+  local keyring, and optionally pushes the revoked public key to a key server.
+  The key can't be used to sign images anymore.
+
+  By default, the key is revoked as compromised, and 'apptainer verify' rejects
+  all the signatures it made, including the older ones, as it does for the
+  'unspecified' reason. A key revoked with '--reason superseded' or
+  '--reason retired' keeps validating the signatures countersigned before its
+  revocation, by an entity required with 'apptainer verify --require-fingerprint'.`
+	KeyRevokeExample string = `
+  $ apptainer key revoke --push D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934
+
+  # Retire a key, keeping the images it already signed valid
+  $ apptainer key revoke --reason retired D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key expire
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeyExpireUse   string = `expire [expire options...] <fingerprint>`
+	KeyExpireShort string = `Set the expiration time of a key pair from your local keyring`
+	KeyExpireLong  string = `
+  The 'key expire' command sets the expiration time of a key pair of your local
+  keyring, relative to now, or removes it with '--in never'. Lifetimes are given
+  in days, weeks, months or years (e.g. 30d, 2w, 6m, 1y).
+
+  Expiration is checked at the time a signature was made, so images signed
+  before a key expired remain valid. Push the public key again to publish the
+  new expiration time.`
+	KeyExpireExample string = `
+  $ apptainer key expire --in 1y D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934
+  $ apptainer key expire --in never D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key subkey
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeySubkeyUse   string = `subkey`
+	KeySubkeyShort string = `Manage signing subkeys of a key pair from your local keyring`
+	KeySubkeyLong  string = `
+  Signing subkeys allow you to keep your primary key offline, or to rotate
+  signing keys without changing the fingerprint identifying you. When a key
+  pair has valid signing subkeys, 'apptainer sign' uses the newest one.`
+	KeySubkeyExample string = `
+  All group commands have their own help output:
+
+  $ apptainer help key subkey add
+  $ apptainer key subkey remove --help`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key subkey add
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeySubkeyAddUse   string = `add [add options...] <fingerprint>`
+	KeySubkeyAddShort string = `Add a signing subkey to a key pair`
+	KeySubkeyAddLong  string = `
+  The 'key subkey add' command adds a new signing subkey to a key pair of your
+  local keyring, optionally expiring after the given lifetime.`
+	KeySubkeyAddExample string = `
+  $ apptainer key subkey add --in 1y D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// key subkey remove
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	KeySubkeyRemoveUse   string = `remove [remove options...] <fingerprint> <subkey ID>`
+	KeySubkeyRemoveShort string = `Revoke a signing subkey of a key pair`
+	KeySubkeyRemoveLong  string = `
+  The 'key subkey remove' command revokes a signing subkey of a key pair of your
+  local keyring, identified by its key ID as shown by 'apptainer key list'. The
+  subkey is kept, so that the revocation can be published with 'apptainer key
+  push'.`
+	KeySubkeyRemoveExample string = `
+  $ apptainer key subkey remove D87FE3AF5C1F063FCBCC9B02F812842B5EEE5934 4F1A2B3C4D5E6F70`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// delete
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -657,6 +747,108 @@ Enterprise Performance Computing (EPC)`
   $ apptainer instance stop -s TERM mysql1
   $ apptainer instance stop -s 15 mysql1`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance update
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceUpdateUse   string = `update [update options...] <instance name>`
+	InstanceUpdateShort string = `Update the cgroups limits of a named instance`
+	InstanceUpdateLong  string = `
+  The instance update command allows you to change the cgroups limits of a
+  running instance, with the same limit flags as instance start, or with a
+  cgroups TOML file given with --apply-cgroups. Only the given limits are
+  changed, and the resulting limits are shown by instance list --json.
+  Settings of cgroups v2 interface files, given with --cgroup-set, and
+  --memory-high or --cpu-weight, are also reported by instance stats. The
+  instance must have been started with cgroups limits.`
+	InstanceUpdateExample string = `
+  $ apptainer instance start --memory 1G my-sql.sif mysql
+  $ apptainer instance update mysql --memory 8G --cpus 2 --pids-limit 500
+  $ apptainer instance update mysql --memory-high 6G --cgroup-set io.max="8:0 rbps=1048576"
+  $ sudo apptainer instance update --user <username> --apply-cgroups limits.toml user-mysql`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance pause
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstancePauseUse   string = `pause [pause options...] <instance name>`
+	InstancePauseShort string = `Pause all processes of a named instance`
+	InstancePauseLong  string = `
+  The instance pause command allows you to suspend all processes of a running
+  instance with the cgroups freezer, until the instance is resumed. The
+  instance must have been started with cgroups limits.`
+	InstancePauseExample string = `
+  $ apptainer instance pause mysql`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance resume
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceResumeUse   string = `resume [resume options...] <instance name>`
+	InstanceResumeShort string = `Resume all processes of a paused instance`
+	InstanceResumeLong  string = `
+  The instance resume command allows you to resume all processes of an
+  instance paused by instance pause.`
+	InstanceResumeExample string = `
+  $ apptainer instance resume mysql`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance top
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceTopUse   string = `top [top options...] <instance name>`
+	InstanceTopShort string = `Display the processes of a named instance`
+	InstanceTopLong  string = `
+  The instance top command allows you to list the processes running in a named
+  instance, with their PID and user as seen inside the instance, their PID on
+  the host, and their CPU and memory usage. If you are root, you can optionally
+  list the processes of an instance belonging to a specific user.`
+	InstanceTopExample string = `
+  $ apptainer instance top mysql
+  PID  HOST PID  USER     %CPU  %MEM  RSS      STAT  TIME  COMMAND
+  1    23845     mibauer  0.0   0.0   1.2MiB   S     0s    appinit
+  2    23858     mibauer  1.2   2.4   180MiB   S     42s   mysqld
+
+  $ apptainer instance top --json mysql`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance cp
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceCpUse   string = `cp <instance name>:<path> <local path> | <local path> <instance name>:<path>`
+	InstanceCpShort string = `Copy files between a named instance and the host`
+	InstanceCpLong  string = `
+  The instance cp command allows you to copy files and directories from a
+  running instance to the host, or from the host to a running instance. Paths
+  in the instance are given in the <instance name>:<path> form and must be
+  absolute. Directories are copied recursively.
+
+  Files are read and written in the instance like with
+  'apptainer exec instance://<instance name>', so they get the ownership of
+  the instance user, or of root for an instance started with --fakeroot. The
+  tar and /bin/sh commands must be available in the instance, so that files
+  can't be copied with instances of distroless images.`
+	InstanceCpExample string = `
+  $ apptainer instance cp mysql:/var/log/mysql ./logs
+  $ apptainer instance cp ./my.cnf mysql:/etc/mysql/my.cnf`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// instance events
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	InstanceEventsUse   string = `events [events options...] <instance name>`
+	InstanceEventsShort string = `Show the events of a named instance`
+	InstanceEventsLong  string = `
+  The instance events command shows the events of an instance, one JSON object
+  per line. Events are recorded when processes of the instance are killed by
+  the OOM killer (oom), when the instance fails to create processes because of
+  its process limit (pids-max), and when the instance process exits (exit).
+  Memory and process events are watched in the cgroup of the instance, or
+  in the cgroup of the instance process when apptainer didn't create one.
+
+  Events are kept after the instance exits, until an instance with the same
+  name is started. With --follow, new events are shown as they occur, until
+  the instance exits.`
+	InstanceEventsExample string = `
+  $ apptainer instance events mysql
+  {"time":"2025-01-01T12:00:00Z","type":"oom","message":"1 process(es) killed by the OOM killer, container exceeded memory limit of 4GiB, peak 4GiB","count":1}
+
+  $ apptainer instance events --follow mysql`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// pull
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -810,14 +1002,26 @@ Enterprise Performance Computing (EPC)`
   image. By default, one digital signature is added for each object group in
   the file.
 
-  Key material can be provided via PEM-encoded file, or an entity in the PGP
-  keyring. To manage the PGP keyring, see 'apptainer help key'.`
+  Key material can be provided via PEM-encoded file, a PKCS#11 URI identifying
+  a key stored in a hardware security module or token, or an entity in the PGP
+  keyring. To manage the PGP keyring, see 'apptainer help key'.
+
+  With --countersign, a countersignature is added over existing signature(s)
+  rather than over the data objects, recording that the image was approved
+  after it was signed. By default, every signature not yet countersigned is
+  countersigned; use --sif-id to select a specific signature object.`
 	SignExample string = `
   Sign with a private key:
   $ apptainer sign --key private.pem container.sif
 
+  Sign with a private key stored in a PKCS#11 token:
+  $ apptainer sign --pkcs11-uri 'pkcs11:token=signing;object=sif?module-name=softhsm2' container.sif
+
   Sign with PGP:
-  $ apptainer sign container.sif`
+  $ apptainer sign container.sif
+
+  Countersign the existing signature(s) with a private key:
+  $ apptainer sign --countersign --key security.pem container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// verify
@@ -828,14 +1032,35 @@ Enterprise Performance Computing (EPC)`
   The verify command allows a user to verify one or more digital signatures
   within a SIF image.
 
-  Key material can be provided via PEM-encoded file, or via the PGP keyring. To
-  manage the PGP keyring, see 'apptainer help key'.`
+  Key material can be provided via PEM-encoded file, via a PKCS#11 URI
+  identifying a key stored in a hardware security module or token, or via the
+  PGP keyring. To manage the PGP keyring, see 'apptainer help key'.
+
+  Countersignatures added with 'apptainer sign --countersign' are verified
+  along with the signatures they cover. Policies can require valid signatures
+  from a number of entities (--require-signers), from given entities
+  (--require-fingerprint), and that signatures are countersigned by another
+  entity (--require-countersign). When --require-countersign is combined with
+  several --require-fingerprint, the entities must have signed in that order.
+  PGP keys are checked for revocation and expiration at the time a signature
+  was countersigned by another entity given with --require-fingerprint, and
+  as they are now otherwise.
+  PGP entities are identified by their key fingerprint, other keys by the
+  SHA-256 digest of their DER-encoded public key, as displayed by verify.`
 	VerifyExample string = `
   Verify with a public key:
   $ apptainer verify --key public.pem container.sif
 
+  Verify with a public key stored in a PKCS#11 token:
+  $ apptainer verify --pkcs11-uri 'pkcs11:token=signing;object=sif?module-name=softhsm2' container.sif
+
   Verify with PGP:
-  $ apptainer verify container.sif`
+  $ apptainer verify container.sif
+
+  Verify that the image was signed by CI, then countersigned by security:
+  $ apptainer verify --key ci.pem --key security.pem --require-countersign \
+      --require-fingerprint <ci fingerprint> \
+      --require-fingerprint <security fingerprint> container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Run-help
@@ -878,6 +1103,8 @@ Enterprise Performance Computing (EPC)`
   Inspect will show you labels, environment variables, apps and scripts associated 
   with the image determined by the flags you pass. By default, they will be shown in 
   plain text. If you would like to list them in json format, you should use the --json flag.
+  The image content is read directly without running the container, encrypted
+  images are not supported.
   `
 	InspectExample string = `
   $ apptainer inspect ubuntu.sif
@@ -928,7 +1155,11 @@ Enterprise Performance Computing (EPC)`
 
   To verify you own a single application on your container image, use the --app <appname> flag:
 
-  $ apptainer inspect --app <appname> ubuntu.sif`
+  $ apptainer inspect --app <appname> ubuntu.sif
+
+  To list the platforms contained in a multi-architecture image:
+
+  $ apptainer inspect --platforms multi.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Test
@@ -967,6 +1198,9 @@ Enterprise Performance Computing (EPC)`
 	OciLong  string = `
   Allow you to manage containers from OCI bundle directories.
 
+  The 'oci runc' command group provides a runc compatible command line
+  interface, to use Apptainer as the OCI runtime of other container engines.
+
   NOTE: all oci commands requires to run as root`
 	OciExample string = `
   All group commands have their own help output:
@@ -1087,6 +1321,165 @@ Enterprise Performance Computing (EPC)`
 	OciUmountExample string = `
   $ apptainer oci umount /var/lib/apptainer/bundles/example`
 
+	OciSpecUse   string = `spec [spec options...]`
+	OciSpecShort string = `Generate a default OCI configuration`
+	OciSpecLong  string = `
+  Spec writes a default OCI configuration in the config.json file of the
+  bundle directory given with --bundle, the current directory by default.
+  The configuration sets the usual mounts, namespaces, capabilities, masked
+  and read-only paths, and runs the container runscript from the rootfs
+  directory of the bundle.
+
+  With --from, the process of the configuration is taken from a SIF image:
+  the image entrypoint, environment and working directory when the image
+  was built from an OCI image, its runscript and environment otherwise.
+  With --rootless, the configuration can be used by an unprivileged user:
+  the user is mapped to root in a user namespace, the host network is used
+  and no cgroups resources are set.`
+	OciSpecExample string = `
+  $ apptainer oci spec --bundle ~/bundle --from image.sif
+  $ apptainer oci spec --rootless`
+
+	OciBundleUse   string = `bundle [bundle options...] <sif_image> <bundle_path>`
+	OciBundleShort string = `Create a standalone OCI bundle from a SIF image`
+	OciBundleLong  string = `
+  Bundle creates an OCI bundle directory from a SIF image, with the image
+  root filesystem extracted in the rootfs directory and the configuration
+  generated as with the spec command --from the image. Contrary to mount,
+  the bundle doesn't depend on the image and can be used by any OCI
+  runtime.`
+	OciBundleExample string = `
+  $ apptainer oci bundle image.sif ~/bundle
+  $ apptainer oci create -b ~/bundle mycontainer`
+
+	OciRuncUse   string = `runc <command> [options...]`
+	OciRuncShort string = `runc compatible OCI runtime commands (root user only)`
+	OciRuncLong  string = `
+  The runc command group provides the command line interface of runc, so
+  that container engines like containerd or Podman can use Apptainer as
+  their OCI runtime. When the apptainer binary is invoked through the
+  apptainer-runc symlink, installed next to it, the runc commands are
+  available directly, without the 'oci runc' prefix.
+
+  The global options --root, --log, --log-format, --debug and
+  --systemd-cgroup are supported and must be placed before the command.
+  --rootless and --criu are accepted and ignored.
+
+  When the master side of the terminal of a container is sent to
+  --console-socket, the container can't be attached and its output is not
+  logged by Apptainer.`
+	OciRuncExample string = `
+  $ apptainer oci runc --root /run/apptainer create -b ~/bundle mycontainer
+  $ apptainer oci runc --root /run/apptainer start mycontainer
+  $ apptainer oci runc --root /run/apptainer list
+
+  To use apptainer-runc as an additional runtime of Podman:
+
+  $ podman --runtime /usr/local/bin/apptainer-runc run -it alpine`
+
+	OciRuncCreateUse   string = `create [create options...] <container_ID>`
+	OciRuncCreateShort string = `Create a container from an OCI bundle`
+	OciRuncCreateLong  string = `
+  Create a container from the OCI bundle given with --bundle, the current
+  directory by default. With a terminal, the master side of the terminal is
+  sent to --console-socket.`
+	OciRuncCreateExample string = `
+  $ apptainer oci runc create --bundle ~/bundle --pid-file /run/mycontainer.pid mycontainer`
+
+	OciRuncStartUse   string = `start <container_ID>`
+	OciRuncStartShort string = `Start the process of a created container`
+	OciRuncStartLong  string = `
+  Start the user process of a container created with the create command.`
+	OciRuncStartExample string = `
+  $ apptainer oci runc start mycontainer`
+
+	OciRuncStateUse   string = `state <container_ID>`
+	OciRuncStateShort string = `Display the state of a container`
+	OciRuncStateLong  string = `
+  Display the state of a container, in the JSON format of the OCI runtime
+  specification.`
+	OciRuncStateExample string = `
+  $ apptainer oci runc state mycontainer`
+
+	OciRuncKillUse   string = `kill [kill options...] <container_ID> [signal]`
+	OciRuncKillShort string = `Send a signal to the process of a container`
+	OciRuncKillLong  string = `
+  Send a signal, SIGTERM by default, to the process of a container. The
+  signal can be given by name or by number. With --all, the signal is sent
+  to all processes of the container.`
+	OciRuncKillExample string = `
+  $ apptainer oci runc kill mycontainer KILL
+  $ apptainer oci runc kill --all mycontainer 9`
+
+	OciRuncDeleteUse   string = `delete [delete options...] <container_ID>`
+	OciRuncDeleteShort string = `Delete the resources of a container`
+	OciRuncDeleteLong  string = `
+  Delete the resources of a created or stopped container. With --force, a
+  running or paused container is killed first, and deleting a container
+  which doesn't exist is not an error.`
+	OciRuncDeleteExample string = `
+  $ apptainer oci runc delete --force mycontainer`
+
+	OciRuncExecUse   string = `exec [exec options...] <container_ID> [command [args...]]`
+	OciRuncExecShort string = `Execute a process in a running container`
+	OciRuncExecLong  string = `
+  Execute a process in a running container. The process is read from
+  --process, a JSON file with the format of the process of the OCI runtime
+  specification, or given on the command line. With --detach, the process
+  runs in the background and its PID is written to --pid-file.`
+	OciRuncExecExample string = `
+  $ apptainer oci runc exec mycontainer ps
+  $ apptainer oci runc exec --detach --pid-file /run/exec.pid --process process.json mycontainer`
+
+	OciRuncPauseUse   string = `pause <container_ID>`
+	OciRuncPauseShort string = `Pause the processes of a container`
+	OciRuncPauseLong  string = `
+  Pause all processes of a running container with the cgroups freezer.`
+	OciRuncPauseExample string = `
+  $ apptainer oci runc pause mycontainer`
+
+	OciRuncResumeUse   string = `resume <container_ID>`
+	OciRuncResumeShort string = `Resume the processes of a paused container`
+	OciRuncResumeLong  string = `
+  Resume all processes of a container paused with the pause command.`
+	OciRuncResumeExample string = `
+  $ apptainer oci runc resume mycontainer`
+
+	OciRuncUpdateUse   string = `update --resources <path> <container_ID>`
+	OciRuncUpdateShort string = `Update the cgroups resources of a container`
+	OciRuncUpdateLong  string = `
+  Update the cgroups resources of a container from a JSON file, in the
+  format of the linux resources of the OCI runtime specification. Use '-'
+  to read the resources from standard input.`
+	OciRuncUpdateExample string = `
+  $ apptainer oci runc update --resources resources.json mycontainer`
+
+	OciRuncPsUse   string = `ps [ps options...] <container_ID> [ps arguments...]`
+	OciRuncPsShort string = `Display the processes of a container`
+	OciRuncPsLong  string = `
+  Display the processes of a container, with the ps command, or as a JSON
+  list of PIDs with --format json. Additional arguments are passed to ps,
+  -ef by default.`
+	OciRuncPsExample string = `
+  $ apptainer oci runc ps --format json mycontainer
+  $ apptainer oci runc ps mycontainer -o pid,comm`
+
+	OciRuncListUse   string = `list [list options...]`
+	OciRuncListShort string = `List the containers`
+	OciRuncListLong  string = `
+  List the containers stored in the state directory, as a table or as JSON
+  with --format json. With --quiet, only the container IDs are displayed.`
+	OciRuncListExample string = `
+  $ apptainer oci runc list --format json`
+
+	OciRuncFeaturesUse   string = `features`
+	OciRuncFeaturesShort string = `Display the features supported by the runtime`
+	OciRuncFeaturesLong  string = `
+  Display the features supported by the runtime, in the JSON format of the
+  OCI runtime specification features document.`
+	OciRuncFeaturesExample string = `
+  $ apptainer oci runc features`
+
 	ConfigUse   string = `config`
 	ConfigShort string = `Manage various apptainer configuration (root user only)`
 	ConfigLong  string = `
@@ -1120,7 +1513,23 @@ Enterprise Performance Computing (EPC)`
 	ConfigGlobalShort string = `Edit apptainer.conf from command line (root user only or unprivileged installation)`
 	ConfigGlobalLong  string = `
   The config global command allow administrators to set/unset/get/reset configuration
-  directives of apptainer.conf from command line.`
+  directives of apptainer.conf from command line.
+
+  Configuration can also be split into drop-in files, named *.conf, in the
+  apptainer.conf.d directory next to apptainer.conf. Drop-in files are read after
+  apptainer.conf in lexical order: values of multi-value directives like "bind path"
+  are appended, values of other directives override the previous ones. The
+  --set/--unset/--get/--reset options only operate on apptainer.conf, use
+  --show-origin to display the effective values and the file they come from.
+
+  Administrators can override directives for some Unix users or groups in the
+  apptainer-overrides.conf policy file next to apptainer.conf, which must be owned
+  by root. Directives follow a [user NAME] or [group NAME] section header, NAME
+  being a name or a numeric ID. Group sections are applied first, then user
+  sections, and overridden values replace the existing ones, including those of
+  multi-value directives. Apptainer fails to run when the user or its groups
+  can't be looked up to determine if a section applies. Any user can display the configuration applying to them
+  with --effective, combined with --show-origin to display where values come from.`
 	ConfigGlobalExample string = `
   To add a path to "bind path" directive:
   $ apptainer config global --set "bind path" /etc/resolv.conf
@@ -1135,7 +1544,23 @@ Enterprise Performance Computing (EPC)`
   $ apptainer config global --get "bind path"
 
   To display the resulting configuration instead of writing it to file:
-  $ apptainer config global --dry-run --set "bind path" /etc/resolv.conf`
+  $ apptainer config global --dry-run --set "bind path" /etc/resolv.conf
+
+  To display the effective configuration and the file each value comes from:
+  $ apptainer config global --show-origin
+  $ apptainer config global --show-origin "bind path"
+
+  With the following apptainer-overrides.conf, members of the gpu-users group
+  always get the --nv option, and members of the students group can only run
+  containers from /shared/images:
+    [group gpu-users]
+    always use nv = yes
+
+    [group students]
+    limit container paths = /shared/images
+
+  To display the configuration applying to the current user and where it comes from:
+  $ apptainer config global --effective --show-origin`
 
 	OverlayUse   string = `overlay`
 	OverlayShort string = `Manage an EXT3 writable overlay image`
@@ -1165,6 +1590,41 @@ Enterprise Performance Computing (EPC)`
   To create an EXT3 writable overlay image for use with --fakeroot actions:
   $ apptainer overlay create --fakeroot --size 1024 /tmp/my_overlay.img`
 
+	IndexUse   string = `index`
+	IndexShort string = `Manage multi-architecture SIF images`
+	IndexLong  string = `
+  The index command allows management of multi-architecture SIF images. A
+  multi-architecture SIF image holds one root filesystem per platform, the
+  one matching the host platform (including the architecture variant) is
+  automatically selected by actions. The first platform is the default one,
+  it is used by older versions and when no platform matches the host.`
+	IndexExample string = `
+  All index commands have their own help output:
+
+  $ apptainer help index create
+  $ apptainer index create --help`
+
+	IndexCreateUse   string = `create [create options...] <index path> <image path> <image path> [image path...]`
+	IndexCreateShort string = `Create a multi-architecture SIF image`
+	IndexCreateLong  string = `
+  The index create command gathers single-architecture SIF images into a
+  multi-architecture SIF image. The platform of each image is taken from its
+  root filesystem partition unless specified with --platform. Signatures are
+  not copied, the resulting image must be signed again.`
+	IndexCreateExample string = `
+  To create a multi-architecture image from an amd64 and an arm64 image:
+  $ apptainer index create multi.sif image_amd64.sif image_arm64.sif
+
+  To specify the platform of each image:
+  $ apptainer index create --platform linux/arm/v6 --platform linux/arm/v7 \
+      multi.sif image_armv6.sif image_armv7.sif
+
+  The same result can be achieved with a multi-architecture build:
+  $ apptainer build --arch amd64,arm64 multi.sif docker://alpine
+
+  To list the platforms of a multi-architecture image:
+  $ apptainer inspect --platforms multi.sif`
+
 	CheckpointUse   string = `checkpoint`
 	CheckpointShort string = `Manage container checkpoint state (experimental)`
 	CheckpointLong  string = `
@@ -1179,10 +1639,14 @@ Enterprise Performance Computing (EPC)`
 	CheckpointListShort string = `List local checkpoints (experimental)`
 	CheckpointListLong  string = `
   The checkpoint list command will list the checkpoints stored at $HOME/.apptainer/checkpoints
-  for use with container instances.`
+  for use with container instances. The --backend option selects the checkpoints of the dmtcp
+  (default) or criu backend.`
 	CheckpointListExample string = `
   To list checkpoints:
-  $ apptainer checkpoint list`
+  $ apptainer checkpoint list
+
+  To list CRIU checkpoints:
+  $ apptainer checkpoint list --backend criu`
 
 	CheckpointCreateUse   string = `create <name>`
 	CheckpointCreateShort string = `Create empty checkpoint storage (experimental)`
@@ -1199,16 +1663,176 @@ Enterprise Performance Computing (EPC)`
   The checkpoint delete command will remove all state for the given checkpoint.`
 	CheckpointDeleteExample string = `
   To delete a checkpoint:
-  $ apptainer checkpoint delete example-checkpoint`
+  $ apptainer checkpoint delete example-checkpoint
+
+  To delete a CRIU checkpoint:
+  $ apptainer checkpoint delete --backend criu example-instance`
+
+	CheckpointExportUse   string = `export [export options...] <name> <file>`
+	CheckpointExportShort string = `Export a checkpoint to a file (experimental)`
+	CheckpointExportLong  string = `
+  The checkpoint export command bundles all state of the given checkpoint, with a manifest
+  recording the digest of the container image it was created from, into a file that can be
+  moved to another host or archived. The file is a zstd compressed tar archive when its name
+  ends with .tar.zst, or a SIF image holding the archive as a data object when its name ends
+  with .sif.`
+	CheckpointExportExample string = `
+  To export a checkpoint to a compressed archive:
+  $ apptainer checkpoint export example-checkpoint example-checkpoint.tar.zst
+
+  To export a CRIU checkpoint to a SIF image:
+  $ apptainer checkpoint export --backend criu example-instance example-instance.sif`
+
+	CheckpointImportUse   string = `import <file> [name]`
+	CheckpointImportShort string = `Import a checkpoint from a file (experimental)`
+	CheckpointImportLong  string = `
+  The checkpoint import command imports a checkpoint exported with checkpoint export, for the
+  backend it was created with, under its original name or under the given name. When
+  restoring the checkpoint, the container image must be the one the checkpoint was created
+  from, as recorded by its digest in the checkpoint manifest. The digest of a SIF image is
+  computed from its header, and the descriptors and data of its objects other than
+  signatures, images in other formats are not verified.`
+	CheckpointImportExample string = `
+  To import a checkpoint:
+  $ apptainer checkpoint import example-checkpoint.tar.zst
+
+  To import a checkpoint under another name:
+  $ apptainer checkpoint import example-instance.sif other-instance`
 
 	CheckpointInstanceUse   string = `instance <instance-name>`
 	CheckpointInstanceShort string = `Checkpoint the state of a running instance (experimental)`
 	CheckpointInstanceLong  string = `
-  The checkpoint instance command checkpoints an active instance by name. The instance must
-  have been started with either --dmtcp-launch or --dmtcp-restart.`
+  The checkpoint instance command checkpoints an active instance by name. With the default
+  dmtcp backend, the instance must have been started with either --dmtcp-launch or
+  --dmtcp-restart.
+
+  With the criu backend, the whole process tree of any instance is dumped by CRIU into a
+  checkpoint named after the instance, along with the upper directory of its writable overlay,
+  and the instance is stopped. The instance can then be started again from this checkpoint,
+  possibly on another host, with 'instance start --restore'. The overlay of the instance must
+  be an overlay directory, --writable-tmpfs and overlay images are not supported. The criu
+  backend requires root privileges.`
 	CheckpointInstanceExample string = `
   To checkpoint an instance:
-  $ apptainer checkpoint instance example-instance`
+  $ apptainer checkpoint instance example-instance
+
+  To checkpoint an instance with CRIU and restore it:
+  $ sudo apptainer checkpoint instance --backend criu example-instance
+  $ sudo apptainer instance start --restore example-instance image.sif example-instance`
+
+	DefUse   string = `def`
+	DefShort string = `Check and format definition files`
+	DefLong  string = `
+  The def command allows checking definition files for common mistakes,
+  formatting them in a canonical form, converting them between the definition
+  file, JSON and YAML formats, and converting Dockerfiles into definition
+  files.`
+	DefExample string = `
+  All def commands have their own help output:
+
+  $ apptainer help def lint
+  $ apptainer def lint --help`
+
+	DefLintUse   string = `lint [lint options...] <definition file> [definition file...]`
+	DefLintShort string = `Check definition files for common mistakes`
+	DefLintLong  string = `
+  The def lint command checks definition files for mistakes accepted by the
+  parser, but likely to make a build fail, behave unexpectedly or produce larger
+  images:
+    - headers ignored by the Bootstrap agent of their stage, unknown Bootstrap
+      agents and missing required headers
+    - build arguments defined in %arguments but never used, used without default
+      value, or used unquoted in scripts
+    - %post sections run with a custom shell without "set -e"
+    - package manager commands which may prompt for confirmation, and package
+      installations without cache cleanup
+
+  Findings are reported as text, JSON or SARIF with --format. The command exits
+  with an error status if any error is found.`
+	DefLintExample string = `
+  To check a definition file:
+  $ apptainer def lint my.def
+
+  To report findings in SARIF format for code scanning tools:
+  $ apptainer def lint --format sarif my.def > lint.sarif`
+
+	DefFmtUse   string = `fmt [fmt options...] <definition file>`
+	DefFmtShort string = `Format a definition file`
+	DefFmtLong  string = `
+  The def fmt command formats a definition file in a canonical form: header
+  keywords first, in a fixed order, then sections in the order %arguments, %pre,
+  %setup, %files, %environment, %post, %runscript, %startscript, %test,
+  %labels, %help and the app sections. Leading and trailing blank lines of
+  sections are removed, other lines of script sections are kept verbatim.
+  Header comments are kept before the keyword they precede or are on the line
+  of, other comments outside of script sections are not preserved.
+
+  The formatted definition file is written on stdout, unless --write or --check
+  is specified.`
+	DefFmtExample string = `
+  To format a definition file in place:
+  $ apptainer def fmt --write my.def
+
+  To check if a definition file is formatted:
+  $ apptainer def fmt --check my.def`
+
+	DefConvertUse   string = `convert --to <json|yaml|def> <definition>`
+	DefConvertShort string = `Convert a definition between definition file, JSON and YAML formats`
+	DefConvertLong  string = `
+  The def convert command converts a definition to the definition file, JSON or
+  YAML format, and writes it on stdout. The format of the input is determined by
+  its extension: .json for JSON, .yaml or .yml for YAML, a definition file
+  otherwise. JSON and YAML definitions follow the JSON Schema displayed by
+  'apptainer def schema', with a single object for a single stage build, or an
+  array of objects for a multi-stage build. They are validated against the
+  schema, invalid fields being reported with their path.
+
+  JSON and YAML definitions can be built directly, e.g.
+  'apptainer build image.sif recipe.yaml'.`
+	DefConvertExample string = `
+  To convert a definition file to YAML:
+  $ apptainer def convert --to yaml my.def > my.yaml
+
+  To convert it back to a definition file:
+  $ apptainer def convert --to def my.yaml`
+
+	DefSchemaUse   string = `schema`
+	DefSchemaShort string = `Display the JSON Schema of JSON and YAML definitions`
+	DefSchemaLong  string = `
+  The def schema command displays the JSON Schema that JSON and YAML
+  definitions are validated against.`
+	DefSchemaExample string = `
+  $ apptainer def schema > definition.schema.json`
+
+	DefFromDockerfileUse   string = `from-dockerfile <Dockerfile>`
+	DefFromDockerfileShort string = `Convert a Dockerfile into a definition file`
+	DefFromDockerfileLong  string = `
+  The def from-dockerfile command converts a Dockerfile into a definition file,
+  written on stdout, so that it can be built by 'apptainer build' without the
+  BuildKit daemon needed by the buildkit bootstrap agent. Each Dockerfile stage
+  becomes a build stage of the definition:
+
+    FROM            docker or scratch bootstrap agent, or the instructions of
+                    the previous stage it refers to
+    ARG             %arguments, with references replaced by {{ NAME }} build
+                    arguments, and exported to %post
+    RUN, WORKDIR    %post
+    ENV             %environment, and exported to %post
+    LABEL           %labels
+    COPY, ADD       %files, or '%files from stage' with --from. A stage
+                    bootstrapped from the image is added when --from refers
+                    to an image
+    ENTRYPOINT, CMD %runscript
+
+  Sources of COPY and ADD are relative to the directory 'apptainer build' is
+  run from, and %files are copied before %post runs, which is reported as a
+  warning for COPY and ADD following RUN. The ENTRYPOINT of the base image is
+  not kept, which is reported for CMD without ENTRYPOINT. Instructions or flags
+  which cannot be converted, e.g. USER, VOLUME, HEALTHCHECK, ONBUILD or ADD from
+  a URL, are reported as warnings.`
+	DefFromDockerfileExample string = `
+  $ apptainer def from-dockerfile Dockerfile > app.def
+  $ apptainer build --build-arg VERSION=1.2 app.sif app.def`
 )
 
 // Documentation for sif/siftool command.
@@ -173,6 +173,22 @@ func (c imgBuildTests) buildFrom(t *testing.T) {
 				require.Arch(t, "arm64")
 			},
 		},
+		{
+			name:      "Mmdebstrap",
+			buildSpec: "../examples/debian/Mmdebstrap",
+			requirements: func(t *testing.T) {
+				require.Command(t, "mmdebstrap")
+				require.ArchIn(t, []string{"amd64", "arm64"})
+			},
+		},
+		{
+			name:      "Apk",
+			buildSpec: "../examples/alpine/Apptainer",
+			requirements: func(t *testing.T) {
+				require.Command(t, "apk.static")
+				require.ArchIn(t, []string{"amd64", "arm64"})
+			},
+		},
 	}
 
 	profiles := []e2e.Profile{e2e.RootProfile, e2e.FakerootProfile}
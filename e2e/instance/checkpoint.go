@@ -12,6 +12,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -188,3 +189,100 @@ func (c *ctx) testCheckpointInstance(t *testing.T) {
 		e2e.ExpectExit(0),
 	)
 }
+
+// testCheckpointInstanceCRIU runs through the checkpointing scenario of
+// testCheckpointInstance with the CRIU backend: the process tree of the
+// instance is dumped from the host, and restored by criu inside the new
+// instance.
+func (c *ctx) testCheckpointInstanceCRIU(t *testing.T) {
+	if !c.profile.Privileged() {
+		t.Skip("CRIU checkpoints require root privileges")
+	}
+	require.CRIU(t)
+
+	imageDir, cleanup := e2e.MakeTempDir(t, c.env.TestDir, "checkpoint-", "")
+	defer e2e.Privileged(cleanup)(t)
+
+	imagePath := filepath.Join(imageDir, "state-server.sif")
+	overlayDir := filepath.Join(imageDir, "overlay")
+	instanceName := randomName(t)
+	instanceAddress := "http://" + net.JoinHostPort("localhost", strconv.Itoa(checkpointStateServerPort))
+
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(e2e.RootProfile),
+		e2e.WithCommand("build"),
+		e2e.WithArgs("--force", imagePath, "testdata/state-server.def"),
+		e2e.ExpectExit(0),
+	)
+
+	if err := os.Mkdir(overlayDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance"),
+		e2e.WithArgs("start", "--overlay", overlayDir, imagePath, instanceName, strconv.Itoa(checkpointStateServerPort)),
+		e2e.ExpectExit(0),
+	)
+
+	pollServer(t, instanceAddress)
+	getServerState(t, instanceAddress, "0")
+	setServerState(t, instanceAddress, "1")
+
+	// Write a file in the overlay directory, saved with the checkpoint
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("instance://"+instanceName, "/bin/sh", "-c", "echo 1 > /app/state"),
+		e2e.ExpectExit(0),
+	)
+
+	// Dump the instance into a checkpoint named after the instance, which
+	// stops the instance
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("checkpoint"),
+		e2e.WithArgs("instance", "--backend", "criu", instanceName),
+		e2e.ExpectExit(0),
+	)
+
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance"),
+		e2e.WithArgs("start", "--restore", instanceName, imagePath, instanceName),
+		e2e.ExpectExit(0),
+	)
+
+	// The server state and the overlay content are restored
+	pollServer(t, instanceAddress)
+	getServerState(t, instanceAddress, "1")
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("exec"),
+		e2e.WithArgs("instance://"+instanceName, "cat", "/app/state"),
+		e2e.ExpectExit(0, e2e.ExpectOutput(e2e.ExactMatch, "1")),
+	)
+
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("instance"),
+		e2e.WithArgs("stop", instanceName),
+		e2e.ExpectExit(0),
+	)
+
+	c.env.RunApptainer(
+		t,
+		e2e.WithProfile(c.profile),
+		e2e.WithCommand("checkpoint"),
+		e2e.WithArgs("delete", "--backend", "criu", instanceName),
+		e2e.ExpectExit(0),
+	)
+}
@@ -597,6 +597,7 @@ func E2ETests(env e2e.TestEnv) testhelper.Tests {
 				{"StopAll", c.testStopAll},
 				{"GhostInstance", c.testGhostInstance},
 				{"CheckpointInstance", c.testCheckpointInstance},
+				{"CheckpointInstanceCRIU", c.testCheckpointInstanceCRIU},
 				{"InstanceWithConfigDir", c.testInstanceWithConfigDir},
 				{"ShareNSMode", c.testShareNSMode},
 				{"issue 2189", c.issue2189},
@@ -0,0 +1,223 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint/criu"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint/dmtcp"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/internal/pkg/util/fs"
+	apptainerConfig "github.com/apptainer/apptainer/pkg/runtime/engine/apptainer/config"
+	"github.com/apptainer/apptainer/pkg/runtime/engine/config"
+	"github.com/apptainer/apptainer/pkg/sylog"
+)
+
+// instanceUpperDir returns the overlay upper directory of an instance,
+// or an empty path if the instance has no writable overlay.
+func instanceUpperDir(file *instance.File) (string, error) {
+	engineConfig := apptainerConfig.NewConfig()
+	instanceConfig := &config.Common{
+		EngineConfig: engineConfig,
+	}
+	if err := json.Unmarshal(file.Config, instanceConfig); err != nil {
+		return "", fmt.Errorf("while reading instance configuration: %s", err)
+	}
+
+	// the tmpfs holding the upper directory is only mounted in
+	// the instance mount namespace, detached from its root
+	if engineConfig.GetWritableTmpfs() {
+		return "", fmt.Errorf("the --writable-tmpfs overlay of instance %s can't be saved, use an overlay directory instead", file.Name)
+	}
+
+	for _, overlay := range engineConfig.GetOverlayImage() {
+		path, mode, _ := strings.Cut(overlay, ":")
+		if mode == "ro" {
+			continue
+		}
+		if !fs.IsDir(path) {
+			return "", fmt.Errorf("the writable overlay image %s of instance %s can't be saved, use an overlay directory instead", path, file.Name)
+		}
+		return filepath.Join(path, "upper"), nil
+	}
+	return "", nil
+}
+
+// CheckpointInstanceCRIU dumps the process tree of a running instance with
+// CRIU into a checkpoint named after the instance, along with the upper
+// directory of its writable overlay. The instance processes are terminated
+// once dumped, the instance can then be started again from the checkpoint
+// with instance start --restore.
+func CheckpointInstanceCRIU(ctx context.Context, name string) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("checkpointing an instance with CRIU requires root privileges")
+	}
+	criuPath, err := exec.LookPath("criu")
+	if err != nil {
+		return fmt.Errorf("while looking for criu: %s", err)
+	}
+
+	file, err := instance.Get(name, instance.AppSubDir)
+	if err != nil {
+		return fmt.Errorf("could not retrieve instance file: %s", err)
+	}
+	if file.Checkpoint != "" {
+		return fmt.Errorf("instance %s was started with DMTCP checkpointing", name)
+	}
+
+	upperDir, err := instanceUpperDir(file)
+	if err != nil {
+		return err
+	}
+
+	m := criu.NewManager()
+	if _, err := m.Get(name); err == nil {
+		return fmt.Errorf("checkpoint %q already exists", name)
+	}
+	e, err := m.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %s", err)
+	}
+
+	manifest, err := checkpoint.NewManifest(checkpoint.CRIUBackend, name, file.Image)
+	if err != nil {
+		return fmt.Errorf("while creating checkpoint manifest: %s", err)
+	}
+	if err := checkpoint.WriteManifest(e.Path(), manifest); err != nil {
+		return fmt.Errorf("while writing checkpoint manifest: %s", err)
+	}
+
+	sylog.Debugf("Dumping instance %s process tree from PID %d", name, file.Pid)
+	cmd := exec.CommandContext(ctx, criuPath, criu.DumpArgs(file.Pid, e)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("criu dump failed, see %s for details: %s", e.DumpLog(), err)
+	}
+
+	// processes are dumped and terminated, the upper directory is now
+	// consistent with the dumped state
+	if upperDir != "" {
+		sylog.Debugf("Saving overlay upper directory %s", upperDir)
+		if err := e.SaveUpper(upperDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointPath returns the directory of the checkpoint name of backend,
+// creating it when create is true.
+func checkpointPath(backend, name string, create bool) (string, error) {
+	switch backend {
+	case checkpoint.CRIUBackend:
+		m := criu.NewManager()
+		e, err := m.Get(name)
+		if create {
+			if err == nil {
+				return "", fmt.Errorf("checkpoint %q already exists", name)
+			}
+			e, err = m.Create(name)
+		}
+		if err != nil {
+			return "", err
+		}
+		return e.Path(), nil
+	case checkpoint.DMTCPBackend:
+		m := dmtcp.NewManager()
+		e, err := m.Get(name)
+		if create {
+			if err == nil {
+				return "", fmt.Errorf("checkpoint %q already exists", name)
+			}
+			e, err = m.Create(name)
+		}
+		if err != nil {
+			return "", err
+		}
+		return e.Path(), nil
+	}
+	return "", fmt.Errorf("unknown checkpoint backend %q", backend)
+}
+
+// CheckpointExport exports the checkpoint name of backend to out, a
+// .tar.zst archive or a .sif image, along with its manifest.
+func CheckpointExport(backend, name, out string) error {
+	dir, err := checkpointPath(backend, name, false)
+	if err != nil {
+		return fmt.Errorf("failed to get checkpoint entry: %s", err)
+	}
+
+	// checkpoints created before manifests were introduced
+	if _, err := checkpoint.ReadManifest(dir); os.IsNotExist(err) {
+		sylog.Warningf("Checkpoint %q has no manifest, the container image won't be verified on restore", name)
+		manifest, err := checkpoint.NewManifest(backend, name, "")
+		if err != nil {
+			return err
+		}
+		if err := checkpoint.WriteManifest(dir, manifest); err != nil {
+			return fmt.Errorf("while writing checkpoint manifest: %s", err)
+		}
+	}
+
+	var excludes []string
+	if backend == checkpoint.CRIUBackend {
+		excludes = criu.ExportExcludes()
+	}
+	return checkpoint.Export(dir, out, excludes)
+}
+
+// CheckpointImport imports the checkpoint exported to in, under its
+// original name or under name if not empty, and returns its manifest.
+func CheckpointImport(in, name string) (*checkpoint.Manifest, error) {
+	if err := os.MkdirAll(checkpoint.StatePath(), 0o700); err != nil {
+		return nil, err
+	}
+	// the checkpoint backend and name are only known once extracted
+	tmpDir, err := os.MkdirTemp(checkpoint.StatePath(), ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := checkpoint.Import(in, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" && name != manifest.Name {
+		manifest.Name = name
+		if err := checkpoint.WriteManifest(tmpDir, manifest); err != nil {
+			return nil, fmt.Errorf("while writing checkpoint manifest: %s", err)
+		}
+	}
+	if manifest.Name == "" || manifest.Name == "." || manifest.Name == ".." || strings.ContainsRune(manifest.Name, '/') {
+		return nil, fmt.Errorf("invalid checkpoint name %q", manifest.Name)
+	}
+
+	dir, err := checkpointPath(manifest.Backend, manifest.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	// replace the empty checkpoint directory by the extracted one
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return nil, fmt.Errorf("while moving imported checkpoint: %s", err)
+	}
+	return manifest, nil
+}
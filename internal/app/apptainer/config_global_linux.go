@@ -15,6 +15,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/apptainer/apptainer/pkg/util/apptainerconf"
 	"golang.org/x/sys/unix"
@@ -160,3 +161,71 @@ func GlobalConfig(args []string, configFile string, dry bool, op GlobalConfigOp)
 
 	return generateConfig(configFile, directives, dry)
 }
+
+// GlobalConfigShowOrigin prints the effective value of a configuration
+// directive, or of all directives if args is empty, along with the file
+// it comes from, apptainer.conf or one of its drop-in files.
+func GlobalConfigShowOrigin(w io.Writer, args []string, configFile string) error {
+	directive, err := globalConfigDirective(args)
+	if err != nil {
+		return err
+	}
+
+	directives, origins, err := apptainerconf.LoadDirectives(configFile)
+	if err != nil {
+		return fmt.Errorf("while loading configuration file %s: %s", configFile, err)
+	}
+
+	return printEffectiveValues(w, directive, directives, origins, true)
+}
+
+// GlobalConfigEffective prints the effective value of a configuration
+// directive, or of all directives if args is empty, for the user with uid,
+// once the overrides of the policy file applying to the user have been
+// applied. If showOrigin is true, the file and the override section each
+// value comes from are printed too.
+func GlobalConfigEffective(w io.Writer, args []string, configFile string, uid int, showOrigin bool) error {
+	directive, err := globalConfigDirective(args)
+	if err != nil {
+		return err
+	}
+
+	directives, origins, err := apptainerconf.LoadUserDirectives(configFile, uid)
+	if err != nil {
+		return fmt.Errorf("while loading configuration file %s: %s", configFile, err)
+	}
+
+	return printEffectiveValues(w, directive, directives, origins, showOrigin)
+}
+
+// globalConfigDirective returns the configuration directive in args, if any.
+func globalConfigDirective(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if !apptainerconf.HasDirective(args[0]) {
+		return "", fmt.Errorf("%q is not a valid configuration directive", args[0])
+	}
+	return args[0], nil
+}
+
+// printEffectiveValues prints the effective values of directive, or of all
+// directives if directive is empty, optionally with their origin.
+func printEffectiveValues(w io.Writer, directive string, directives apptainerconf.Directives, origins apptainerconf.Origins, showOrigin bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, v := range apptainerconf.EffectiveValues(directives, origins) {
+		if directive != "" && v.Directive != directive {
+			continue
+		}
+		if !showOrigin {
+			fmt.Fprintf(tw, "%s = %s\n", v.Directive, v.Value)
+			continue
+		}
+		origin := v.Origin
+		if origin != apptainerconf.DefaultOrigin {
+			origin = "file:" + origin
+		}
+		fmt.Fprintf(tw, "%s\t%s = %s\n", origin, v.Directive, v.Value)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apptainer/apptainer/internal/pkg/ociplatform"
+	"github.com/apptainer/apptainer/internal/pkg/sifindex"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// IndexCreate creates the multi-architecture SIF image dst from the single
+// architecture SIF images srcs. platforms optionally overrides the platform
+// of each source image, in the same order.
+func IndexCreate(dst string, srcs []string, platforms []string, force bool) error {
+	if len(srcs) < 2 {
+		return fmt.Errorf("at least two images are required")
+	}
+	if len(platforms) > 0 && len(platforms) != len(srcs) {
+		return fmt.Errorf("%d platforms specified for %d images", len(platforms), len(srcs))
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		if !force {
+			return fmt.Errorf("image file already exists: %s - will not overwrite", dst)
+		}
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("while removing %s: %s", dst, err)
+		}
+	}
+
+	sources := make([]sifindex.Source, 0, len(srcs))
+	for i, src := range srcs {
+		var platform *ggcrv1.Platform
+		if len(platforms) > 0 {
+			p, err := ociplatform.PlatformFromString(platforms[i])
+			if err != nil {
+				return fmt.Errorf("while parsing platform %s: %s", platforms[i], err)
+			}
+			platform = p
+		}
+		sources = append(sources, sifindex.Source{Path: src, Platform: platform})
+	}
+
+	if err := sifindex.Create(dst, sources); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	sylog.Infof("Multi-architecture image created: %s", dst)
+	return nil
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/moby/go-archive"
+)
+
+// copyDestScript prints the type of the destination path given as first
+// argument, inside the instance.
+const copyDestScript = `if [ -d "$1" ]; then echo dir; elif [ -e "$1" ]; then echo file; ` +
+	`elif [ -d "$(dirname "$1")" ]; then echo none; else echo noparent; fi`
+
+// splitInstancePath splits a NAME:/path argument of instance cp. It returns
+// an empty instance name for a local path.
+func splitInstancePath(arg string) (name, p string, err error) {
+	// explicit local paths may contain a colon
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") {
+		return "", arg, nil
+	}
+	name, p, found := strings.Cut(instance.ExtractName(arg), ":")
+	if !found {
+		return "", arg, nil
+	}
+	if err := instance.CheckName(name); err != nil {
+		return "", "", err
+	}
+	if !path.IsAbs(p) {
+		return "", "", fmt.Errorf("path %q in instance %s must be absolute", p, name)
+	}
+	return name, p, nil
+}
+
+// instanceExecCommand returns a command executing args in the named
+// instance, joining its namespaces as with exec instance://NAME.
+func instanceExecCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	execArgs := append([]string{"-q", "exec", "instance://" + name}, args...)
+	cmd := exec.CommandContext(ctx, filepath.Join(buildcfg.BINDIR, "apptainer"), execArgs...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// instanceCommandError returns err, the error of a command executed in
+// the named instance, mentioning the commands required in the instance
+// when it could not be executed, which apptainer exec reports with the
+// exit code 255.
+func instanceCommandError(err error, name string) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 255 {
+		return fmt.Errorf("%w (tar and /bin/sh must be available in instance %s)", err, name)
+	}
+	return err
+}
+
+// InstanceCopy copies files between a running instance and the host. One
+// of src or dst must be in the NAME:/path form, designating a path in the
+// instance. Copies follow the semantics of docker cp, directories are
+// copied recursively. Files are read and written in the instance by the
+// instance user, through tar and /bin/sh which must be available in the
+// instance, so they get the ownership of the instance user, or root for a
+// fakeroot instance.
+func InstanceCopy(ctx context.Context, src, dst string) error {
+	srcInstance, srcPath, err := splitInstancePath(src)
+	if err != nil {
+		return err
+	}
+	dstInstance, dstPath, err := splitInstancePath(dst)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case srcInstance != "" && dstInstance != "":
+		return fmt.Errorf("copying between instances is not supported")
+	case srcInstance == "" && dstInstance == "":
+		return fmt.Errorf("source or destination must be an instance path in the NAME:/path form")
+	case srcInstance != "":
+		if _, err := instance.Get(srcInstance, instance.AppSubDir); err != nil {
+			return fmt.Errorf("no instance found with name %s", srcInstance)
+		}
+		return copyFromInstance(ctx, srcInstance, srcPath, dstPath)
+	default:
+		if _, err := instance.Get(dstInstance, instance.AppSubDir); err != nil {
+			return fmt.Errorf("no instance found with name %s", dstInstance)
+		}
+		return copyToInstance(ctx, srcPath, dstInstance, dstPath)
+	}
+}
+
+// copyFromInstance copies srcPath from the named instance to dstPath.
+func copyFromInstance(ctx context.Context, name, srcPath, dstPath string) error {
+	srcDir, srcBase := path.Split(path.Clean(srcPath))
+	if srcBase == "" {
+		return fmt.Errorf("copying the root directory of instance %s is not supported", name)
+	}
+
+	cmd := instanceExecCommand(ctx, name, "tar", "-C", srcDir, "-cf", "-", srcBase)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("while executing tar in instance %s: %s", name, err)
+	}
+
+	// the first entry of the archive tells whether the source is a directory
+	content := bufio.NewReaderSize(stdout, 64*1024)
+	header, _ := content.Peek(64 * 1024)
+	hdr, err := tar.NewReader(bytes.NewReader(header)).Next()
+	if err != nil {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("while archiving %s in instance %s: %w", srcPath, name, instanceCommandError(err, name))
+		}
+		return fmt.Errorf("could not read %s in instance %s", srcPath, name)
+	}
+
+	srcInfo := archive.CopyInfo{
+		Path:   srcPath,
+		Exists: true,
+		IsDir:  hdr.Typeflag == tar.TypeDir,
+	}
+	copyErr := archive.CopyTo(content, srcInfo, dstPath)
+	// drain the archive to let tar terminate when the copy failed
+	io.Copy(io.Discard, content)
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("while archiving %s in instance %s: %w", srcPath, name, instanceCommandError(err, name))
+	}
+	return copyErr
+}
+
+// copyToInstance copies srcPath to dstPath in the named instance.
+func copyToInstance(ctx context.Context, srcPath, name, dstPath string) error {
+	srcInfo, err := archive.CopyInfoSourcePath(srcPath, false)
+	if err != nil {
+		return err
+	}
+	content, err := archive.TarResource(srcInfo)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	var out bytes.Buffer
+	cmd := instanceExecCommand(ctx, name, "/bin/sh", "-c", copyDestScript, "sh", dstPath)
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("while checking %s in instance %s: %w", dstPath, name, instanceCommandError(err, name))
+	}
+
+	dstInfo := archive.CopyInfo{Path: dstPath}
+	switch strings.TrimSpace(out.String()) {
+	case "dir":
+		dstInfo.Exists = true
+		dstInfo.IsDir = true
+	case "file":
+		dstInfo.Exists = true
+	case "none":
+	default:
+		return fmt.Errorf("parent directory of %s doesn't exist in instance %s", dstPath, name)
+	}
+
+	dstDir, copyArchive, err := archive.PrepareArchiveCopy(content, srcInfo, dstInfo)
+	if err != nil {
+		if errors.Is(err, archive.ErrCannotCopyDir) {
+			return fmt.Errorf("cannot copy directory %s to file %s", srcPath, dstPath)
+		}
+		return err
+	}
+	defer copyArchive.Close()
+
+	// files are owned by the user extracting them in the instance
+	cmd = instanceExecCommand(ctx, name, "tar", "-C", dstDir, "-xof", "-")
+	cmd.Stdin = copyArchive
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("while extracting %s in instance %s: %w", dstPath, name, instanceCommandError(err, name))
+	}
+	sylog.Debugf("Copied %s to %s in instance %s", srcPath, dstPath, name)
+	return nil
+}
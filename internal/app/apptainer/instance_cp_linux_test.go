@@ -0,0 +1,39 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestInstanceCommandError(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode string
+		wantHint bool
+	}{
+		{name: "NotExecuted", exitCode: "255", wantHint: true},
+		{name: "CommandFailed", exitCode: "2", wantHint: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := exec.Command("/bin/sh", "-c", "exit "+tt.exitCode).Run()
+			if err == nil {
+				t.Fatalf("unexpected success")
+			}
+			err = instanceCommandError(err, "test")
+			if hint := strings.Contains(err.Error(), "must be available in instance test"); hint != tt.wantHint {
+				t.Errorf("unexpected error %q", err)
+			}
+		})
+	}
+}
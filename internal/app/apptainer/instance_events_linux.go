@@ -0,0 +1,96 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+)
+
+// eventsFollowInterval is the interval between two reads of the events log
+// of an instance when following it.
+const eventsFollowInterval = 500 * time.Millisecond
+
+// InstanceEvents writes the events of the named instance to w, one JSON
+// object per line. Events are kept after the instance exits, until an
+// instance with the same name is started. With follow, new events are
+// written as they occur, until the instance exits.
+func InstanceEvents(ctx context.Context, w io.Writer, name string, follow bool) error {
+	if err := instance.CheckName(name); err != nil {
+		return err
+	}
+	path, err := instance.GetEventsFilePath(name, instance.LogSubDir)
+	if err != nil {
+		return fmt.Errorf("could not find events path: %s", err)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no events found for instance %s", name)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var line []byte
+	for {
+		data, err := r.ReadBytes('\n')
+		line = append(line, data...)
+		if err == nil {
+			exited, err := writeEvent(w, line)
+			if err != nil {
+				return err
+			}
+			line = line[:0]
+			if exited && follow {
+				return nil
+			}
+			continue
+		} else if !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		// end of the events log, wait for new events while the
+		// instance is running
+		if !follow {
+			return nil
+		}
+		if _, err := instance.Get(name, instance.AppSubDir); err != nil {
+			// the instance exited, read the remaining events
+			follow = false
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(eventsFollowInterval):
+		}
+	}
+}
+
+// writeEvent writes the event log entry line to w, and returns whether it
+// is the exit event of the instance.
+func writeEvent(w io.Writer, line []byte) (bool, error) {
+	var ev instance.Event
+	if err := json.Unmarshal(line, &ev); err != nil {
+		return false, fmt.Errorf("while decoding instance event: %s", err)
+	}
+	if _, err := w.Write(line); err != nil {
+		return false, err
+	}
+	return ev.Type == instance.ExitEvent, nil
+}
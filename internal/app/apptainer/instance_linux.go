@@ -19,6 +19,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"sort"
 	"strings"
 	"syscall"
 	"text/tabwriter"
@@ -32,6 +33,7 @@ import (
 	"github.com/ccoveille/go-safecast"
 	units "github.com/docker/go-units"
 	libcgroups "github.com/opencontainers/cgroups"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
 type instanceInfo struct {
@@ -41,6 +43,9 @@ type instanceInfo struct {
 	IP         string `json:"ip"`
 	LogErrPath string `json:"logErrPath"`
 	LogOutPath string `json:"logOutPath"`
+	Paused     bool   `json:"paused,omitempty"`
+
+	Resources *specs.LinuxResources `json:"resources,omitempty"`
 }
 
 // PrintInstanceList fetches instance list, applying name and
@@ -98,6 +103,8 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool, showLogs
 		instances[i].IP = ii[i].IP
 		instances[i].LogErrPath = ii[i].LogErrPath
 		instances[i].LogOutPath = ii[i].LogOutPath
+		instances[i].Paused = ii[i].Paused
+		instances[i].Resources = ii[i].Resources
 	}
 
 	enc := json.NewEncoder(w)
@@ -150,6 +157,30 @@ func instanceListOrError(instanceUser, name string) ([]*instance.File, error) {
 	return ii, err
 }
 
+// instanceStats holds the cgroup stats of an instance, and the current
+// values of its cgroups v2 settings.
+type instanceStats struct {
+	*libcgroups.Stats
+	Unified map[string]string `json:"unified,omitempty"`
+}
+
+// writeUnified writes the current values of the cgroups v2 settings of an
+// instance after its stats.
+func writeUnified(w io.Writer, keys []string, unified map[string]string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "\nCGROUP SETTING\tVALUE"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", k, unified[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // calculate BlockIO counts up read/write totals
 func calculateBlockIO(stats *libcgroups.BlkioStats) (float64, float64) {
 	var read, write float64
@@ -233,6 +264,16 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 		return fmt.Errorf("while getting cgroup manager for pid: %v", err)
 	}
 
+	// cgroups v2 settings of the instance, set with --cgroup-set or similar
+	// flags, are reported with their current value
+	var unifiedKeys []string
+	if i.Resources != nil {
+		for k := range i.Resources.Unified {
+			unifiedKeys = append(unifiedKeys, k)
+		}
+		sort.Strings(unifiedKeys)
+	}
+
 	// Otherwise print shortened table
 	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 8, 4, ' ', 0)
 	defer tabWriter.Flush()
@@ -269,11 +310,17 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 				return fmt.Errorf("while getting stats for pid: %v", err)
 			}
 
+			// Current values of the cgroups v2 settings of the instance
+			unified, err := manager.GetUnified(unifiedKeys)
+			if err != nil {
+				return fmt.Errorf("while getting cgroup settings for pid: %v", err)
+			}
+
 			// Do we want json?
 			if formatJSON {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "\t")
-				err = enc.Encode(stats)
+				err = enc.Encode(instanceStats{Stats: stats, Unified: unified})
 				return err
 			}
 
@@ -296,10 +343,13 @@ func InstanceStats(ctx context.Context, name, instanceUser string, formatJSON bo
 				cpuPercent, units.BytesSize(memUsage), units.BytesSize(memLimit),
 				memPercent, "%", units.BytesSize(blockRead), units.BytesSize(blockWrite),
 				stats.PidsStats.Current)
-			tabWriter.Flush()
 			if err != nil {
 				return fmt.Errorf("could not write instance stats: %v", err)
 			}
+			if err := writeUnified(tabWriter, unifiedKeys, unified); err != nil {
+				return fmt.Errorf("could not write instance cgroup settings: %v", err)
+			}
+			tabWriter.Flush()
 
 			// We don't want a stream, return after just one record
 			if noStream {
@@ -353,6 +403,15 @@ func killInstance(i *instance.File, sig syscall.Signal, stoppedPID chan<- int) {
 	sylog.Infof("Stopping %s instance of %s (PID=%d)\n", i.Name, i.Image, i.Pid)
 	syscall.Kill(i.Pid, sig)
 
+	// signals are not handled by the processes of a paused instance
+	if i.Paused {
+		if manager, err := cgroups.GetManagerForPid(i.Pid); err == nil {
+			if err := manager.Thaw(); err != nil {
+				sylog.Warningf("Could not resume %s instance: %v", i.Name, err)
+			}
+		}
+	}
+
 	for {
 		if err := syscall.Kill(i.PPid, 0); err == syscall.ESRCH {
 			stoppedPID <- i.Pid
@@ -0,0 +1,373 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/cgroups"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	units "github.com/docker/go-units"
+)
+
+// clockTicks is the number of clock ticks per second used by the kernel
+// for process times in /proc, fixed to 100 on Linux.
+const clockTicks = 100
+
+// instanceProcess holds information about a process of an instance.
+type instanceProcess struct {
+	Pid        int      `json:"pid"`
+	HostPid    int      `json:"hostPid"`
+	User       string   `json:"user"`
+	CPUPercent float64  `json:"cpuPercent"`
+	MemPercent float64  `json:"memPercent"`
+	RSS        uint64   `json:"rss"`
+	CPUTime    float64  `json:"cpuTime"`
+	State      string   `json:"state"`
+	Command    []string `json:"command"`
+}
+
+// procStat holds the fields of /proc/<pid>/stat used by instance top.
+type procStat struct {
+	comm      string
+	state     string
+	ppid      int
+	utime     uint64
+	stime     uint64
+	starttime uint64
+	rss       uint64
+}
+
+// parseProcStat parses the content of a /proc/<pid>/stat file.
+func parseProcStat(data string) (*procStat, error) {
+	// the command name is enclosed in parentheses and may contain spaces
+	start := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("malformed stat data")
+	}
+	// fields start at the process state, field 3 in proc(5)
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat data: %d fields", len(fields))
+	}
+	field := func(n int) string {
+		return fields[n-3]
+	}
+
+	var err error
+	s := &procStat{
+		comm:  data[start+1 : end],
+		state: field(3),
+	}
+	if s.ppid, err = strconv.Atoi(field(4)); err != nil {
+		return nil, err
+	}
+	for n, v := range map[int]*uint64{14: &s.utime, 15: &s.stime, 22: &s.starttime, 24: &s.rss} {
+		if *v, err = strconv.ParseUint(field(n), 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// idMap is an entry of a /proc/<pid>/uid_map file.
+type idMap struct {
+	inside  uint64
+	outside uint64
+	count   uint64
+}
+
+// readIDMaps reads the ID mappings of the user namespace of pid.
+func readIDMaps(path string) ([]idMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var maps []idMap
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m idMap
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d %d", &m.inside, &m.outside, &m.count); err != nil {
+			return nil, fmt.Errorf("while parsing %s: %s", path, err)
+		}
+		maps = append(maps, m)
+	}
+	return maps, scanner.Err()
+}
+
+// containerID returns the ID inside the user namespace corresponding to
+// the host ID id, or -1 if id is not mapped.
+func containerID(maps []idMap, id uint64) int64 {
+	for _, m := range maps {
+		if id >= m.outside && id < m.outside+m.count {
+			return int64(m.inside + id - m.outside) //nolint:gosec
+		}
+	}
+	return -1
+}
+
+// readPasswd returns the user names by UID of a passwd file.
+func readPasswd(path string) map[int64]string {
+	names := make(map[int64]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return names
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			if _, ok := names[uid]; !ok {
+				names[uid] = fields[0]
+			}
+		}
+	}
+	return names
+}
+
+// procStatus returns the effective UID and the PID in the innermost PID
+// namespace of a process, from /proc/<pid>/status.
+func procStatus(pid int) (uid uint64, nspid int, err error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	nspid = pid
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), ":")
+		fields := strings.Fields(value)
+		switch {
+		case key == "Uid" && len(fields) > 1:
+			if uid, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				return 0, 0, err
+			}
+		case key == "NSpid" && len(fields) > 0:
+			if nspid, err = strconv.Atoi(fields[len(fields)-1]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return uid, nspid, scanner.Err()
+}
+
+// listPids returns the PIDs of all processes.
+func listPids() ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// instancePids returns the host PIDs of the processes of an instance:
+// the processes of its cgroup when started with cgroups limits, the
+// processes of its PID namespace otherwise, or the descendants of the
+// instance process if the instance shares the host PID namespace.
+func instancePids(i *instance.File) ([]int, error) {
+	if i.Cgroup {
+		manager, err := cgroups.GetManagerForPid(i.Pid)
+		if err != nil {
+			return nil, fmt.Errorf("while getting cgroup manager for pid: %v", err)
+		}
+		return manager.GetPids()
+	}
+
+	pids, err := listPids()
+	if err != nil {
+		return nil, err
+	}
+
+	instanceNs, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", i.Pid))
+	if err != nil {
+		return nil, fmt.Errorf("while reading instance PID namespace: %s", err)
+	}
+	selfNs, err := os.Readlink("/proc/self/ns/pid")
+	if err != nil {
+		return nil, err
+	}
+
+	if instanceNs != selfNs {
+		var nsPids []int
+		for _, pid := range pids {
+			if ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", pid)); err == nil && ns == instanceNs {
+				nsPids = append(nsPids, pid)
+			}
+		}
+		return nsPids, nil
+	}
+
+	// no PID namespace, collect the instance process tree
+	children := make(map[int][]int)
+	for _, pid := range pids {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		if s, err := parseProcStat(string(data)); err == nil {
+			children[s.ppid] = append(children[s.ppid], pid)
+		}
+	}
+	tree := []int{i.Pid}
+	for n := 0; n < len(tree); n++ {
+		tree = append(tree, children[tree[n]]...)
+	}
+	return tree, nil
+}
+
+// InstanceTop displays the processes of a named instance, with PIDs and
+// users as seen from inside the instance.
+func InstanceTop(w io.Writer, name, instanceUser string, formatJSON bool) error {
+	ii, err := instanceListOrError(instanceUser, name)
+	if err != nil {
+		return err
+	}
+	if len(ii) != 1 {
+		return fmt.Errorf("query returned more than one instance (%d)", len(ii))
+	}
+	i := ii[0]
+
+	pids, err := instancePids(i)
+	if err != nil {
+		return err
+	}
+
+	uidMaps, err := readIDMaps(fmt.Sprintf("/proc/%d/uid_map", i.Pid))
+	if err != nil {
+		return fmt.Errorf("while reading instance user namespace mappings: %s", err)
+	}
+	// user names are resolved with the instance passwd file when readable
+	userNames := readPasswd(filepath.Join(fmt.Sprintf("/proc/%d/root", i.Pid), "etc", "passwd"))
+
+	uptimeData, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return err
+	}
+	uptime, err := strconv.ParseFloat(strings.Fields(string(uptimeData))[0], 64)
+	if err != nil {
+		return fmt.Errorf("while parsing uptime: %s", err)
+	}
+
+	var memTotal uint64
+	info := &syscall.Sysinfo_t{}
+	if err := syscall.Sysinfo(info); err == nil {
+		memTotal = info.Totalram * uint64(info.Unit)
+	}
+	pageSize := uint64(os.Getpagesize()) //nolint:gosec
+
+	processes := make([]instanceProcess, 0, len(pids))
+	for _, pid := range pids {
+		// processes may exit while being listed
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		stat, err := parseProcStat(string(data))
+		if err != nil {
+			continue
+		}
+		uid, nspid, err := procStatus(pid)
+		if err != nil {
+			continue
+		}
+
+		p := instanceProcess{
+			Pid:     nspid,
+			HostPid: pid,
+			RSS:     stat.rss * pageSize,
+			CPUTime: float64(stat.utime+stat.stime) / clockTicks,
+			State:   stat.state,
+		}
+
+		if elapsed := uptime - float64(stat.starttime)/clockTicks; elapsed > 0 {
+			p.CPUPercent = p.CPUTime / elapsed * 100
+		}
+		if memTotal > 0 {
+			p.MemPercent = float64(p.RSS) / float64(memTotal) * 100
+		}
+
+		cuid := containerID(uidMaps, uid)
+		if n, ok := userNames[cuid]; ok {
+			p.User = n
+		} else {
+			p.User = strconv.FormatInt(cuid, 10)
+		}
+
+		if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil && len(cmdline) > 0 {
+			p.Command = strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		} else {
+			p.Command = []string{"[" + stat.comm + "]"}
+		}
+
+		processes = append(processes, p)
+	}
+
+	sort.Slice(processes, func(a, b int) bool {
+		return processes[a].Pid < processes[b].Pid
+	})
+
+	if formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		err := enc.Encode(
+			map[string][]instanceProcess{
+				"processes": processes,
+			})
+		if err != nil {
+			return fmt.Errorf("could not encode process list: %v", err)
+		}
+		return nil
+	}
+
+	tabWriter := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tabWriter.Flush()
+
+	_, err = fmt.Fprintln(tabWriter, "PID\tHOST PID\tUSER\t%CPU\t%MEM\tRSS\tSTAT\tTIME\tCOMMAND")
+	if err != nil {
+		return fmt.Errorf("could not write process list header: %v", err)
+	}
+	for _, p := range processes {
+		cpuTime := time.Duration(p.CPUTime * float64(time.Second)).Truncate(time.Second)
+		_, err := fmt.Fprintf(tabWriter, "%d\t%d\t%s\t%.1f\t%.1f\t%s\t%s\t%s\t%s\n",
+			p.Pid, p.HostPid, p.User, p.CPUPercent, p.MemPercent,
+			units.BytesSize(float64(p.RSS)), p.State, cpuTime, strings.Join(p.Command, " "))
+		if err != nil {
+			return fmt.Errorf("could not write process info: %v", err)
+		}
+	}
+	return nil
+}
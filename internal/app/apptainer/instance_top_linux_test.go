@@ -0,0 +1,89 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	data := "4242 (my (odd) cmd) S 4200 4242 4242 0 -1 4194560 1234 0 0 0 " +
+		"150 50 0 0 20 0 1 0 98765 12345678 321 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0"
+
+	s, err := parseProcStat(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.comm != "my (odd) cmd" {
+		t.Errorf("unexpected command name %q", s.comm)
+	}
+	if s.state != "S" || s.ppid != 4200 {
+		t.Errorf("unexpected state %q or parent PID %d", s.state, s.ppid)
+	}
+	if s.utime != 150 || s.stime != 50 || s.starttime != 98765 || s.rss != 321 {
+		t.Errorf("unexpected times or RSS: %+v", s)
+	}
+
+	if _, err := parseProcStat("4242 (truncated) S 1 2"); err == nil {
+		t.Errorf("unexpected success for truncated stat data")
+	}
+}
+
+func TestContainerID(t *testing.T) {
+	maps := []idMap{
+		{inside: 0, outside: 1000, count: 1},
+		{inside: 1, outside: 100000, count: 65536},
+	}
+	tests := []struct {
+		id   uint64
+		want int64
+	}{
+		{id: 1000, want: 0},
+		{id: 100000, want: 1},
+		{id: 100999, want: 1000},
+		{id: 0, want: -1},
+		{id: 165536, want: -1},
+	}
+	for _, tt := range tests {
+		if got := containerID(maps, tt.id); got != tt.want {
+			t.Errorf("containerID(%d) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSplitInstancePath(t *testing.T) {
+	tests := []struct {
+		arg      string
+		name     string
+		path     string
+		hasError bool
+	}{
+		{arg: "myinst:/etc/hosts", name: "myinst", path: "/etc/hosts"},
+		{arg: "instance://myinst:/tmp", name: "myinst", path: "/tmp"},
+		{arg: "local/file", path: "local/file"},
+		{arg: "./dir:with:colons", path: "./dir:with:colons"},
+		{arg: "/abs/dir:with:colons", path: "/abs/dir:with:colons"},
+		{arg: "myinst:relative", hasError: true},
+		{arg: "my inst:/tmp", hasError: true},
+	}
+	for _, tt := range tests {
+		name, path, err := splitInstancePath(tt.arg)
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("unexpected success for %q", tt.arg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.arg, err)
+		} else if name != tt.name || path != tt.path {
+			t.Errorf("splitInstancePath(%q) = (%q, %q), want (%q, %q)", tt.arg, name, path, tt.name, tt.path)
+		}
+	}
+}
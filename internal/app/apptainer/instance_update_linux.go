@@ -0,0 +1,103 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"fmt"
+
+	"github.com/apptainer/apptainer/internal/pkg/cgroups"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/pkg/sylog"
+)
+
+// instanceCgroupManager returns the named instance, and the manager of its
+// cgroup. The instance must have been started with cgroups limits, so its
+// processes are in a dedicated cgroup.
+func instanceCgroupManager(name, instanceUser, action string) (*instance.File, *cgroups.Manager, error) {
+	ii, err := instanceListOrError(instanceUser, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ii) != 1 {
+		return nil, nil, fmt.Errorf("query returned more than one instance (%d)", len(ii))
+	}
+	i := ii[0]
+
+	if !i.Cgroup {
+		return nil, nil, fmt.Errorf("instance %s can't be %s, it was not started with cgroups limits", i.Name, action)
+	}
+
+	manager, err := cgroups.GetManagerForPid(i.Pid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while getting cgroup manager for pid: %v", err)
+	}
+	return i, manager, nil
+}
+
+// InstanceUpdate updates the cgroups limits of a named instance with the
+// resources in the cgroups JSON configuration cgroupsJSON. The updated
+// limits are stored in the instance file.
+func InstanceUpdate(name, instanceUser, cgroupsJSON string) error {
+	if cgroupsJSON == "" {
+		return fmt.Errorf("no cgroups limits to update")
+	}
+	resources, err := cgroups.UnmarshalJSONResources(cgroupsJSON)
+	if err != nil {
+		return fmt.Errorf("while decoding cgroups limits: %v", err)
+	}
+
+	i, manager, err := instanceCgroupManager(name, instanceUser, "updated")
+	if err != nil {
+		return err
+	}
+
+	if err := manager.UpdateFromSpec(resources); err != nil {
+		return fmt.Errorf("while updating cgroups limits: %v", err)
+	}
+
+	i.Resources, err = cgroups.MergeResources(i.Resources, resources)
+	if err != nil {
+		return fmt.Errorf("while merging cgroups limits: %v", err)
+	}
+	if err := i.Update(); err != nil {
+		return fmt.Errorf("while storing instance limits: %v", err)
+	}
+
+	sylog.Infof("Updated cgroups limits of %s instance of %s (PID=%d)\n", i.Name, i.Image, i.Pid)
+	return nil
+}
+
+// InstancePauseResume pauses, or resumes, all processes of a named
+// instance with the cgroups freezer.
+func InstancePauseResume(name, instanceUser string, pause bool) error {
+	action := "paused"
+	if !pause {
+		action = "resumed"
+	}
+
+	i, manager, err := instanceCgroupManager(name, instanceUser, action)
+	if err != nil {
+		return err
+	}
+
+	if pause {
+		err = manager.Freeze()
+	} else {
+		err = manager.Thaw()
+	}
+	if err != nil {
+		return fmt.Errorf("instance %s could not be %s: %v", i.Name, action, err)
+	}
+
+	i.Paused = pause
+	if err := i.Update(); err != nil {
+		return fmt.Errorf("while storing instance state: %v", err)
+	}
+	return nil
+}
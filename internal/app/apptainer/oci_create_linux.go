@@ -29,13 +29,26 @@ func OciCreate(containerID string, args *OciArgs) error {
 		return fmt.Errorf("%s already exists", containerID)
 	}
 
+	// the configuration directory holds the state of containers, and is
+	// set by the --root option of the runc compatible commands
+	configDir, hasConfigDir := os.LookupEnv(configDirEnv)
 	os.Clearenv()
+	if hasConfigDir {
+		os.Setenv(configDirEnv, configDir)
+	}
 
 	absBundle, err := filepath.Abs(args.BundlePath)
 	if err != nil {
 		return fmt.Errorf("failed to determine bundle absolute path: %s", err)
 	}
 
+	consoleSocket := args.ConsoleSocket
+	if consoleSocket != "" {
+		if consoleSocket, err = filepath.Abs(consoleSocket); err != nil {
+			return fmt.Errorf("failed to determine console socket absolute path: %s", err)
+		}
+	}
+
 	if err := os.Chdir(absBundle); err != nil {
 		return fmt.Errorf("failed to change directory to %s: %s", absBundle, err)
 	}
@@ -46,6 +59,8 @@ func OciCreate(containerID string, args *OciArgs) error {
 	engineConfig.SetLogPath(args.LogPath)
 	engineConfig.SetLogFormat(args.LogFormat)
 	engineConfig.SetPidFile(args.PidFile)
+	engineConfig.SetConsoleSocket(consoleSocket)
+	engineConfig.SetSystemdCgroups(args.SystemdCgroups)
 
 	// load config.json from bundle path
 	configJSON := filepath.Join(absBundle, "config.json")
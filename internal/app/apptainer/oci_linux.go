@@ -22,18 +22,26 @@ import (
 	"github.com/apptainer/apptainer/pkg/sylog"
 )
 
+// configDirEnv is the environment variable setting the apptainer
+// configuration directory, where the state of containers is stored.
+const configDirEnv = "APPTAINER_CONFIGDIR"
+
 // OciArgs contains CLI arguments
 type OciArgs struct {
 	BundlePath     string
 	LogPath        string
 	LogFormat      string
 	SyncSocketPath string
+	ConsoleSocket  string
 	PidFile        string
 	FromFile       string
+	ProcessFile    string
 	KillSignal     string
 	KillTimeout    uint32
 	EmptyProcess   bool
 	ForceKill      bool
+	SystemdCgroups bool
+	Detach         bool
 }
 
 func getCommonConfig(containerID string) (*config.Common, error) {
@@ -0,0 +1,472 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
+	"github.com/apptainer/apptainer/internal/pkg/cgroups"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/internal/pkg/runtime/engine/oci"
+	"github.com/apptainer/apptainer/internal/pkg/security/apparmor"
+	"github.com/apptainer/apptainer/internal/pkg/security/seccomp"
+	"github.com/apptainer/apptainer/internal/pkg/security/selinux"
+	"github.com/apptainer/apptainer/internal/pkg/util/signal"
+	"github.com/apptainer/apptainer/internal/pkg/util/starter"
+	"github.com/apptainer/apptainer/pkg/ociruntime"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/capabilities"
+	"github.com/apptainer/apptainer/pkg/util/unix"
+	"github.com/ccoveille/go-safecast"
+	"github.com/creack/pty"
+	lccgroups "github.com/opencontainers/cgroups"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+// Output formats of the list and ps runc compatible commands.
+const (
+	OciFormatTable = "table"
+	OciFormatJSON  = "json"
+)
+
+// ociExecPidSocketEnv marks the process started by detachExec, and holds
+// the file descriptor of the socket to which the PID of the container
+// process is sent.
+const ociExecPidSocketEnv = "APPTAINER_OCI_EXEC_PID_SOCKET"
+
+// OciSetRoot sets the directory where the state of containers is stored,
+// as the --root option of runc.
+func OciSetRoot(root string) error {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return fmt.Errorf("while creating state directory %s: %s", root, err)
+	}
+	return os.Setenv(configDirEnv, root)
+}
+
+// OciFeatures writes the features supported by the OCI runtime to w, in
+// the JSON format of the OCI runtime specification.
+func OciFeatures(w io.Writer) error {
+	enabled := func(b bool) *bool {
+		return &b
+	}
+
+	caps := make([]string, 0, len(capabilities.Map))
+	for name := range capabilities.Map {
+		caps = append(caps, name)
+	}
+	sort.Strings(caps)
+
+	cgroupV2 := lccgroups.IsCgroup2UnifiedMode()
+	f := features.Features{
+		OCIVersionMin: "1.0.0",
+		OCIVersionMax: specs.Version,
+		Hooks:         []string{"createRuntime", "createContainer", "startContainer", "poststart", "poststop"},
+		Linux: &features.Linux{
+			Namespaces: []string{
+				string(specs.CgroupNamespace),
+				string(specs.IPCNamespace),
+				string(specs.MountNamespace),
+				string(specs.NetworkNamespace),
+				string(specs.PIDNamespace),
+				string(specs.UserNamespace),
+				string(specs.UTSNamespace),
+			},
+			Capabilities: caps,
+			Cgroup: &features.Cgroup{
+				V1:      enabled(!cgroupV2),
+				V2:      enabled(cgroupV2),
+				Systemd: enabled(true),
+			},
+			Seccomp:  &features.Seccomp{Enabled: enabled(seccomp.Enabled())},
+			Apparmor: &features.Apparmor{Enabled: enabled(apparmor.Enabled())},
+			Selinux:  &features.Selinux{Enabled: enabled(selinux.Enabled())},
+		},
+		Annotations: map[string]string{
+			"org.apptainer.version": buildcfg.PACKAGE_VERSION,
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(f)
+}
+
+// ociContainer is the state of a container listed by OciList, as listed
+// by runc.
+type ociContainer struct {
+	OCIVersion  string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Pid         int               `json:"pid"`
+	Status      string            `json:"status"`
+	Bundle      string            `json:"bundle"`
+	Rootfs      string            `json:"rootfs"`
+	Created     time.Time         `json:"created"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Owner       string            `json:"owner"`
+}
+
+// OciList writes the list of containers to w, in format OciFormatTable or
+// OciFormatJSON, or only their IDs if quiet is true.
+func OciList(w io.Writer, format string, quiet bool) error {
+	files, err := instance.List("", "*", instance.OciSubDir, true)
+	if err != nil {
+		return fmt.Errorf("while listing containers: %s", err)
+	}
+
+	containers := make([]ociContainer, 0, len(files))
+	for _, file := range files {
+		engineConfig, err := getEngineConfig(file.Name)
+		if err != nil {
+			continue
+		}
+		state := engineConfig.State
+		c := ociContainer{
+			OCIVersion:  state.Version,
+			ID:          state.ID,
+			Pid:         state.Pid,
+			Status:      string(state.Status),
+			Bundle:      state.Bundle,
+			Annotations: state.Annotations,
+			Owner:       file.User,
+		}
+		if root := engineConfig.OciConfig.Root; root != nil {
+			c.Rootfs = root.Path
+		}
+		if state.CreatedAt != nil {
+			c.Created = time.Unix(0, *state.CreatedAt)
+		}
+		containers = append(containers, c)
+	}
+
+	switch {
+	case quiet:
+		for _, c := range containers {
+			fmt.Fprintln(w, c.ID)
+		}
+	case format == OciFormatJSON:
+		return json.NewEncoder(w).Encode(containers)
+	case format == OciFormatTable:
+		tw := tabwriter.NewWriter(w, 12, 1, 3, ' ', 0)
+		fmt.Fprint(tw, "ID\tPID\tSTATUS\tBUNDLE\tCREATED\tOWNER\n")
+		for _, c := range containers {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", c.ID, c.Pid, c.Status, c.Bundle, c.Created.Format(time.RFC3339Nano), c.Owner)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("invalid format %q, must be %s or %s", format, OciFormatTable, OciFormatJSON)
+	}
+	return nil
+}
+
+// containerPids returns the PIDs of the processes of a container.
+func containerPids(containerID string) ([]int, error) {
+	state, err := getState(containerID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status != ociruntime.Created && state.Status != ociruntime.Running && state.Status != ociruntime.Paused {
+		return nil, nil
+	}
+
+	manager, err := cgroups.GetManagerForPid(state.Pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cgroups manager: %v", err)
+	}
+	return manager.GetPids()
+}
+
+// OciPs writes the processes of a container to w. With OciFormatJSON, the
+// PIDs are written as a JSON array. With OciFormatTable, ps is run with
+// psArgs, -ef by default, and its output filtered to the processes of the
+// container.
+func OciPs(w io.Writer, containerID, format string, psArgs []string) error {
+	pids, err := containerPids(containerID)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case OciFormatJSON:
+		if pids == nil {
+			pids = []int{}
+		}
+		return json.NewEncoder(w).Encode(pids)
+	case OciFormatTable:
+	default:
+		return fmt.Errorf("invalid format %q, must be %s or %s", format, OciFormatTable, OciFormatJSON)
+	}
+
+	if len(psArgs) == 0 {
+		psArgs = []string{"-ef"}
+	}
+	out, err := osexec.Command("ps", psArgs...).Output()
+	if err != nil {
+		return fmt.Errorf("while running ps: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	pidIndex := -1
+	for i, field := range strings.Fields(lines[0]) {
+		if field == "PID" {
+			pidIndex = i
+		}
+	}
+	if pidIndex == -1 {
+		return fmt.Errorf("couldn't find PID field in ps output")
+	}
+
+	inContainer := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		inContainer[pid] = true
+	}
+	fmt.Fprintln(w, lines[0])
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) <= pidIndex {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[pidIndex]); err == nil && inContainer[pid] {
+			fmt.Fprintln(w, line)
+		}
+	}
+	return nil
+}
+
+// OciGetState returns the state of a container.
+func OciGetState(containerID string) (*ociruntime.State, error) {
+	return getState(containerID)
+}
+
+// OciKillAll sends killSignal to all processes of a container.
+func OciKillAll(containerID, killSignal string) error {
+	sig, err := signal.Convert(killSignal)
+	if err != nil {
+		return err
+	}
+	pids, err := containerPids(containerID)
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("while sending signal to process %d: %s", pid, err)
+		}
+	}
+	return nil
+}
+
+// OciExecProcess executes the process described in args.ProcessFile, in
+// the OCI runtime specification format, or the command cmdArgs in a
+// container. When the process has a terminal, and args.ConsoleSocket is
+// set, the master side of the terminal is sent to the console socket.
+// With args.Detach, the process runs in the background, and the PID of
+// the process is written to args.PidFile.
+func OciExecProcess(containerID string, cmdArgs []string, args *OciArgs, terminal bool) error {
+	commonConfig, err := getCommonConfig(containerID)
+	if err != nil {
+		return fmt.Errorf("%s doesn't exist", containerID)
+	}
+	engineConfig := commonConfig.EngineConfig.(*oci.EngineConfig)
+
+	switch engineConfig.GetState().Status {
+	case ociruntime.Running, ociruntime.Paused:
+	default:
+		return fmt.Errorf("cannot execute a process, container '%s' is not running", containerID)
+	}
+
+	if args.ProcessFile != "" {
+		data, err := os.ReadFile(args.ProcessFile)
+		if err != nil {
+			return fmt.Errorf("failed to read process file: %s", err)
+		}
+		process := &specs.Process{}
+		if err := json.Unmarshal(data, process); err != nil {
+			return fmt.Errorf("failed to parse process file %s: %s", args.ProcessFile, err)
+		}
+		engineConfig.OciConfig.Process = process
+	} else {
+		if len(cmdArgs) == 0 {
+			return fmt.Errorf("a command or --process is required")
+		}
+		engineConfig.OciConfig.SetProcessArgs(cmdArgs)
+		engineConfig.OciConfig.SetProcessTerminal(terminal)
+	}
+	if len(engineConfig.OciConfig.Process.Args) == 0 {
+		return fmt.Errorf("process args are empty")
+	}
+
+	pidSocket := os.Getenv(ociExecPidSocketEnv)
+	if args.Detach && pidSocket == "" {
+		return detachExec(args.PidFile)
+	}
+
+	if engineConfig.OciConfig.Process.Terminal {
+		if err := setupExecTerminal(engineConfig.OciConfig.Process.ConsoleSize, args.ConsoleSocket); err != nil {
+			return err
+		}
+	}
+	if pidSocket != "" {
+		// the PID file is written by the detaching process
+		fd, err := strconv.Atoi(pidSocket)
+		if err != nil {
+			return fmt.Errorf("bad %s value %q: %s", ociExecPidSocketEnv, pidSocket, err)
+		}
+		engineConfig.PidSocket = fd
+	} else if args.PidFile != "" {
+		// the starter replaces the current process
+		if err := os.WriteFile(args.PidFile, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+			return err
+		}
+	}
+
+	engineConfig.Exec = true
+
+	os.Clearenv()
+
+	procName := fmt.Sprintf("Apptainer OCI %s", containerID)
+	return starter.Exec(procName, commonConfig)
+}
+
+// detachExec runs the current command in the background, in a new
+// session, and writes the PID of the container process to pidFile once it
+// is started.
+func detachExec(pidFile string) error {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("while creating PID socket: %s", err)
+	}
+	parent := os.NewFile(uintptr(fds[0]), "pid-socket")
+	defer parent.Close()
+	child := os.NewFile(uintptr(fds[1]), "pid-socket")
+	defer child.Close()
+
+	// receive the credentials of the container process
+	if err := syscall.SetsockoptInt(fds[0], syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1); err != nil {
+		return fmt.Errorf("while setting PID socket options: %s", err)
+	}
+
+	cmd := osexec.Command("/proc/self/exe", os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// the socket is the first extra file, with descriptor 3
+	cmd.ExtraFiles = []*os.File{child}
+	cmd.Env = append(os.Environ(), ociExecPidSocketEnv+"=3")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("while starting detached process: %s", err)
+	}
+	child.Close()
+
+	pid, err := receivePid(fds[0])
+	if err != nil {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("detached process failed: %s", err)
+		}
+		return err
+	}
+
+	if pidFile != "" {
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0o644); err != nil {
+			return err
+		}
+	}
+	return cmd.Process.Release()
+}
+
+// receivePid returns the PID of the process sending its credentials over
+// the socket fd.
+func receivePid(fd int) (int, error) {
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred))
+	n, oobn, _, _, err := syscall.Recvmsg(fd, buf, oob, 0)
+	if err != nil {
+		return 0, fmt.Errorf("while receiving container process PID: %s", err)
+	} else if n == 0 {
+		return 0, fmt.Errorf("container process exited before being started")
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range msgs {
+		if cred, err := syscall.ParseUnixCredentials(&msg); err == nil {
+			return int(cred.Pid), nil
+		}
+	}
+	return 0, fmt.Errorf("no credentials received for container process")
+}
+
+// setupExecTerminal makes a new terminal the controlling terminal and the
+// standard streams of the current process, and sends its master side to
+// consoleSocket if set.
+func setupExecTerminal(size *specs.Box, consoleSocket string) error {
+	if consoleSocket == "" {
+		return fmt.Errorf("a console socket is required for a process with a terminal")
+	}
+
+	master, slave, err := pty.Open()
+	if err != nil {
+		return fmt.Errorf("while allocating terminal: %s", err)
+	}
+	defer slave.Close()
+
+	if size != nil {
+		var ws pty.Winsize
+		if ws.Cols, err = safecast.Convert[uint16](size.Width); err != nil {
+			return err
+		}
+		if ws.Rows, err = safecast.Convert[uint16](size.Height); err != nil {
+			return err
+		}
+		if err := pty.Setsize(slave, &ws); err != nil {
+			return err
+		}
+	}
+
+	err = unix.SendFile(consoleSocket, master)
+	master.Close()
+	if err != nil {
+		return fmt.Errorf("while sending terminal to console socket: %s", err)
+	}
+
+	for _, fd := range []int{0, 1, 2} {
+		if err := syscall.Dup3(int(slave.Fd()), fd, 0); err != nil {
+			return err
+		}
+	}
+	// setsid fails for process group leaders, like the process started
+	// by detachExec which is already the leader of a new session without
+	// controlling terminal
+	if _, err := syscall.Setsid(); errors.Is(err, syscall.EPERM) {
+		if sid, _, _ := syscall.RawSyscall(syscall.SYS_GETSID, 0, 0, 0); int(sid) != os.Getpid() {
+			sylog.Warningf("Terminal not set as controlling terminal: process is a process group leader")
+			return nil
+		}
+	} else if err != nil {
+		return fmt.Errorf("while creating session: %s", err)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, 0, uintptr(syscall.TIOCSCTTY), 0); errno != 0 {
+		return fmt.Errorf("failed to set controlling terminal: %s", errno)
+	}
+	return nil
+}
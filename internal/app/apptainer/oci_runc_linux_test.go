@@ -0,0 +1,109 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/apptainer/apptainer/pkg/util/unix"
+)
+
+const execTerminalTestEnv = "APPTAINER_TEST_EXEC_TERMINAL_SOCKET"
+
+func TestReceivePid(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_SEQPACKET|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("while creating socket pair: %s", err)
+	}
+	defer syscall.Close(fds[0])
+	if err := syscall.SetsockoptInt(fds[0], syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1); err != nil {
+		t.Fatalf("while setting socket options: %s", err)
+	}
+
+	if err := syscall.Sendmsg(fds[1], []byte{0}, nil, nil, 0); err != nil {
+		t.Fatalf("while sending message: %s", err)
+	}
+	pid, err := receivePid(fds[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("got PID %d, want %d", pid, os.Getpid())
+	}
+
+	// the sender exited without sending its credentials
+	syscall.Close(fds[1])
+	if _, err := receivePid(fds[0]); err == nil {
+		t.Errorf("unexpected success with closed socket")
+	}
+}
+
+// ttyNr returns the tty_nr field of /proc/self/stat.
+func ttyNr() (string, error) {
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return "", err
+	}
+	// fields following the command name: state ppid pgrp session tty_nr
+	i := strings.LastIndexByte(string(b), ')')
+	fields := strings.Fields(string(b[i+1:]))
+	if i < 0 || len(fields) < 5 {
+		return "", errors.New("malformed /proc/self/stat")
+	}
+	return fields[4], nil
+}
+
+func TestSetupExecTerminal(t *testing.T) {
+	// the detached process started by detachExec is a session leader
+	// which must get the terminal as controlling terminal
+	if socket := os.Getenv(execTerminalTestEnv); socket != "" {
+		if err := setupExecTerminal(nil, socket); err != nil {
+			os.Exit(2)
+		}
+		if tty, err := ttyNr(); err != nil || tty == "0" {
+			os.Exit(3)
+		}
+		os.Exit(0)
+	}
+
+	socket := filepath.Join(t.TempDir(), "console.sock")
+	ln, err := unix.CreateSocket(socket)
+	if err != nil {
+		t.Fatalf("while creating console socket: %s", err)
+	}
+	defer ln.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetupExecTerminal$")
+	cmd.Env = append(os.Environ(), execTerminalTestEnv+"="+socket)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("while starting process: %s", err)
+	}
+
+	// keep the terminal master side open until the process exits
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("while accepting console socket connection: %s", err)
+	}
+	defer conn.Close()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+			t.Fatalf("no controlling terminal set for session leader")
+		}
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
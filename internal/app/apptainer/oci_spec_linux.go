@@ -0,0 +1,39 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"fmt"
+	"os"
+
+	ocibundle "github.com/apptainer/apptainer/pkg/ocibundle/sif"
+	"github.com/apptainer/apptainer/pkg/ocibundle/tools"
+)
+
+// OciSpec writes a default OCI configuration in the config.json file of
+// the bundle directory. If image is set, the configuration runs the SIF
+// image entrypoint or runscript.
+func OciSpec(bundle, image string, rootless bool) error {
+	configPath := tools.Config(bundle).Path()
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("%s already exists, remove it first", configPath)
+	}
+
+	g, err := ocibundle.Spec(image, rootless)
+	if err != nil {
+		return err
+	}
+	return tools.SaveBundleConfig(bundle, g)
+}
+
+// OciBundle creates a standalone OCI bundle in the bundle directory from
+// the SIF image, with the root filesystem extracted from the image.
+func OciBundle(image, bundle string, rootless bool) error {
+	return ocibundle.Export(image, bundle, rootless)
+}
@@ -214,12 +214,17 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 
 	flags = append(flags, extraArgs...)
 
+	buildarch, hasBuildArch := oci.ArchMap[b.Opts.Arch]
 	arch := machine.ArchFromContainer(b.RootfsPath)
-	if arch == "" {
+	if arch == "" && hasBuildArch {
+		// cross-architecture builds must not be stamped with the host arch
+		sylog.Infof("Architecture not recognized, use build arch %s", buildarch.Arch)
+		arch = buildarch.Arch
+	} else if arch == "" {
 		sylog.Infof("Architecture not recognized, use native")
 		arch = runtime.GOARCH
 	}
-	if buildarch, ok := oci.ArchMap[b.Opts.Arch]; ok {
+	if hasBuildArch {
 		if arch != buildarch.Arch {
 			// the container arch overrides the build arch (!), for backwards compatibility
 			sylog.Warningf("Architecture %s does not match build arch %s", arch, b.Opts.Arch)
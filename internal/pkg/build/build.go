@@ -219,6 +219,14 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 
 // cleanUp removes remnants of build from file system unless NoCleanUp is specified.
 func (b Build) cleanUp() {
+	for _, s := range b.stages {
+		if s.emulator != nil {
+			if err := s.emulator.Unregister(); err != nil {
+				sylog.Errorf("Could not unregister emulator: %v", err)
+			}
+		}
+	}
+
 	if b.Conf.NoCleanUp {
 		var bundlePaths []string
 		for _, s := range b.stages {
@@ -371,6 +379,13 @@ func (b *Build) Full(ctx context.Context) error {
 			}
 		}
 
+		// set up emulation if the stage rootfs is for a foreign architecture
+		stage.emulator, err = stage.setupEmulation()
+		if err != nil {
+			return err
+		}
+		b.stages[i].emulator = stage.emulator
+
 		if stage.b.Recipe.BuildData.Post.Script != "" {
 			if err := stage.runPostScript(sessionResolv, sessionHosts); err != nil {
 				return fmt.Errorf("while running engine: %v", err)
@@ -447,12 +462,21 @@ func MakeAllDefs(spec string, buildArgsMap map[string]string) ([]types.Definitio
 	}
 	defer defFile.Close()
 
-	defsPreBuildArgs, err := parser.All(defFile)
-	nDefs := len(defsPreBuildArgs)
+	defsPreBuildArgs, err := parser.AllFormat(defFile, parser.FormatFromPath(spec))
 	if err != nil {
 		return nil, nil, fmt.Errorf("while parsing definition: %s: %w", spec, err)
 	}
 
+	return ApplyBuildArgs(defsPreBuildArgs, buildArgsMap)
+}
+
+// ApplyBuildArgs replaces the {{ NAME }} build arguments of the definitions
+// of a multi-stage build with their value from buildArgsMap, or their
+// default value from the %arguments section of their stage. The returned
+// definitions can be passed to New, along with the build arguments of
+// buildArgsMap which are not used by any stage.
+func ApplyBuildArgs(defsPreBuildArgs []types.Definition, buildArgsMap map[string]string) ([]types.Definition, []string, error) {
+	nDefs := len(defsPreBuildArgs)
 	revisedDefs := make([]types.Definition, 0, nDefs)
 	var overallConsumedArgs []string
 	for _, def := range defsPreBuildArgs {
@@ -54,6 +54,8 @@ func conveyorPacker(def types.Definition) (ConveyorPacker, error) {
 		return &sources.BusyBoxConveyorPacker{}, nil
 	case "debootstrap":
 		return &sources.DebootstrapConveyorPacker{}, nil
+	case "mmdebstrap":
+		return &sources.MmdebstrapConveyorPacker{}, nil
 	case "arch":
 		return &sources.ArchConveyorPacker{}, nil
 	case "localimage":
@@ -62,6 +64,8 @@ func conveyorPacker(def types.Definition) (ConveyorPacker, error) {
 		return &sources.YumConveyorPacker{}, nil
 	case "zypper":
 		return &sources.ZypperConveyorPacker{}, nil
+	case "apk":
+		return &sources.ApkConveyorPacker{}, nil
 	case "scratch":
 		return &sources.ScratchConveyorPacker{}, nil
 	case "buildkit", "dockerfile":
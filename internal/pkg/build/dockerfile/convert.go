@@ -0,0 +1,631 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package dockerfile converts Dockerfiles into Apptainer definitions, so
+// that they can be built without a BuildKit daemon.
+package dockerfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/build/types/parser"
+)
+
+// Warning reports a Dockerfile instruction, or a part of it, which could
+// not be converted.
+type Warning struct {
+	Line        int
+	Instruction string
+	Message     string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.Line, w.Instruction, w.Message)
+}
+
+var (
+	varReg     = regexp.MustCompile(`\\?\$(?:\{([a-zA-Z_][a-zA-Z0-9_]*)\}|([a-zA-Z_][a-zA-Z0-9_]*))`)
+	stageReg   = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+	archiveReg = regexp.MustCompile(`\.(tar|tar\.gz|tgz|tar\.bz2|tbz2|tar\.xz|txz)$`)
+)
+
+// stage holds the converted instructions of a build stage.
+type stage struct {
+	name   string
+	header map[string]string
+	// args are the build arguments of the %arguments section, with their
+	// default value in argDefaults
+	args        []string
+	argDefaults map[string]string
+	// declared are the build arguments available to the instructions of
+	// the stage, which must be declared by an ARG instruction
+	declared    map[string]bool
+	env         map[string]string
+	environment []string
+	post        []string
+	files       []types.Files
+	labels      map[string]string
+	workdir     string
+	entrypoint  []string
+	cmd         []string
+	shellEntry  bool
+	shellCmd    bool
+	// cmdInst is the CMD instruction setting cmd
+	cmdInst Instruction
+	// ran is true when a RUN instruction was converted to %post, which is
+	// run after the %files section
+	ran bool
+	// cdInPost is true when the last RUN instruction may have changed the
+	// current directory of %post.
+	cdInPost bool
+}
+
+func newStage(name string) *stage {
+	return &stage{
+		name:        name,
+		header:      make(map[string]string),
+		argDefaults: make(map[string]string),
+		declared:    make(map[string]bool),
+		env:         make(map[string]string),
+		labels:      make(map[string]string),
+		workdir:     "/",
+	}
+}
+
+// clone returns a copy of s named name, for stages based on a previous one.
+func (s *stage) clone(name string) *stage {
+	c := newStage(name)
+	for k, v := range s.header {
+		if k != "stage" {
+			c.header[k] = v
+		}
+	}
+	c.args = append(c.args, s.args...)
+	for k, v := range s.argDefaults {
+		c.argDefaults[k] = v
+	}
+	for k, v := range s.declared {
+		c.declared[k] = v
+	}
+	for k, v := range s.env {
+		c.env[k] = v
+	}
+	for k, v := range s.labels {
+		c.labels[k] = v
+	}
+	c.environment = append(c.environment, s.environment...)
+	c.post = append(c.post, s.post...)
+	for _, f := range s.files {
+		c.files = append(c.files, types.Files{Args: f.Args, Files: append([]types.FileTransport(nil), f.Files...)})
+	}
+	c.workdir = s.workdir
+	c.entrypoint, c.cmd = s.entrypoint, s.cmd
+	c.shellEntry, c.shellCmd = s.shellEntry, s.shellCmd
+	c.cmdInst = s.cmdInst
+	c.ran = s.ran
+	c.cdInPost = s.cdInPost
+	return c
+}
+
+// converter holds the state of a Dockerfile conversion.
+type converter struct {
+	stages     []*stage
+	globalArgs map[string]string
+	// extra stages bootstrapped from images referenced by COPY --from
+	images   map[string]*stage
+	warnings []Warning
+}
+
+func (c *converter) warn(inst Instruction, format string, a ...interface{}) {
+	c.warnings = append(c.warnings, Warning{
+		Line:        inst.Line,
+		Instruction: inst.Command,
+		Message:     fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *converter) current() *stage {
+	return c.stages[len(c.stages)-1]
+}
+
+// findStage returns the previous stage named name, or with index name
+// when index is true.
+func (c *converter) findStage(name string, index bool) *stage {
+	for _, s := range c.stages {
+		if strings.EqualFold(s.name, name) {
+			return s
+		}
+	}
+	if i, err := strconv.Atoi(name); index && err == nil && i >= 0 && i < len(c.stages)-1 {
+		return c.stages[i]
+	}
+	return nil
+}
+
+// expand substitutes the $NAME and ${NAME} references of s to build
+// arguments with a {{ NAME }} template, and to environment variables with
+// their value when env is true.
+func (s *stage) expand(str string, env bool) string {
+	return varReg.ReplaceAllStringFunc(str, func(ref string) string {
+		if strings.HasPrefix(ref, `\`) {
+			return ref[1:]
+		}
+		name := strings.Trim(ref, "${}")
+		if v, ok := s.env[name]; ok && env {
+			return v
+		}
+		if s.declared[name] {
+			return "{{ " + name + " }}"
+		}
+		return ref
+	})
+}
+
+// useArg adds the build argument name to the %arguments section of s,
+// with value as default.
+func (s *stage) useArg(name, value string) {
+	if _, ok := s.argDefaults[name]; !ok {
+		s.args = append(s.args, name)
+	}
+	s.argDefaults[name] = value
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`;&|<>()*?[]#~=%{}!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin quotes and joins the words of an exec form command.
+func shellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// doubleQuote double quotes s for a POSIX shell, keeping variable
+// references.
+func doubleQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+// keyValues parses the key=value pairs of ENV and LABEL instructions, or
+// their legacy "key value" form.
+func keyValues(args string) ([][2]string, error) {
+	if key, value, found := strings.Cut(args, " "); found && !strings.Contains(key, "=") {
+		words, err := splitWords(value)
+		if err != nil {
+			return nil, err
+		}
+		return [][2]string{{key, strings.Join(words, " ")}}, nil
+	}
+
+	words, err := splitWords(args)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([][2]string, 0, len(words))
+	for _, w := range words {
+		key, value, found := strings.Cut(w, "=")
+		if !found {
+			return nil, fmt.Errorf("%q is not a key=value pair", w)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// Convert converts the Dockerfile read from r into the definitions of a
+// multi-stage build, in the same form as those parsed by parser.All, so
+// that they can be built once their build arguments are applied. Each
+// Dockerfile stage becomes a build stage, using:
+//
+//   - the docker or scratch bootstrap agents for FROM, and the
+//     instructions of a previous stage when FROM refers to it
+//   - %arguments for ARG, and {{ NAME }} templates for references to
+//     build arguments
+//   - %post for RUN, with WORKDIR and build arguments and environment
+//     variables set by ARG and ENV
+//   - %environment for ENV and %labels for LABEL
+//   - %files for COPY and ADD, and %files from stage for COPY --from
+//   - %runscript for ENTRYPOINT and CMD
+//
+// Instructions which cannot be converted are reported as warnings, as well
+// as COPY and ADD instructions following a RUN instruction, since %files
+// is processed before %post, and a CMD without ENTRYPOINT, since the
+// ENTRYPOINT of the base image is not kept.
+func Convert(r io.Reader) ([]types.Definition, []Warning, error) {
+	instructions, err := Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &converter{
+		globalArgs: make(map[string]string),
+		images:     make(map[string]*stage),
+	}
+	var imageStages []*stage
+
+	for _, inst := range instructions {
+		if len(c.stages) == 0 && inst.Command != "FROM" && inst.Command != "ARG" {
+			return nil, nil, fmt.Errorf("line %d: %s instruction before FROM", inst.Line, inst.Command)
+		}
+
+		switch inst.Command {
+		case "FROM":
+			err = c.from(inst)
+		case "ARG":
+			err = c.arg(inst)
+		case "RUN":
+			c.run(inst)
+		case "ENV":
+			err = c.envInstruction(inst)
+		case "LABEL":
+			err = c.label(inst)
+		case "MAINTAINER":
+			c.current().labels["maintainer"] = inst.Args
+		case "WORKDIR":
+			c.workdir(inst)
+		case "COPY", "ADD":
+			var extra *stage
+			extra, err = c.copy(inst)
+			if extra != nil {
+				imageStages = append(imageStages, extra)
+			}
+		case "ENTRYPOINT", "CMD":
+			c.runscript(inst)
+		case "USER":
+			c.warn(inst, "ignored, RUN instructions are run as root and containers as the calling user")
+		case "EXPOSE":
+			c.warn(inst, "ignored, containers share the network of the host by default")
+		case "VOLUME":
+			c.warn(inst, "not supported, use bind mounts at runtime")
+		case "HEALTHCHECK", "ONBUILD", "STOPSIGNAL", "SHELL":
+			c.warn(inst, "not supported, ignored")
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown instruction %s", inst.Line, inst.Command)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s: %v", inst.Line, inst.Command, err)
+		}
+	}
+	if len(c.stages) == 0 {
+		return nil, nil, fmt.Errorf("no FROM instruction found")
+	}
+	if s := c.current(); len(s.cmd) > 0 && len(s.entrypoint) == 0 && s.header["bootstrap"] == "docker" {
+		c.warn(s.cmdInst, "ENTRYPOINT of the base image, if any, not kept, CMD is run as the runscript")
+	}
+
+	// stages bootstrapped from images referenced by COPY --from are built
+	// first
+	stages := append(imageStages, c.stages...)
+	defs := make([]types.Definition, 0, len(stages))
+	for _, s := range stages {
+		if len(stages) == 1 {
+			delete(s.header, "stage")
+		}
+		defs = append(defs, s.definition())
+	}
+
+	var buf bytes.Buffer
+	if err := types.WriteDefinitions(&buf, defs...); err != nil {
+		return nil, nil, err
+	}
+	defs, err = parser.All(&buf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while parsing converted definition: %v", err)
+	}
+	return defs, c.warnings, nil
+}
+
+func (c *converter) from(inst Instruction) error {
+	words := strings.Fields(inst.Args)
+	if len(words) != 1 && (len(words) != 3 || !strings.EqualFold(words[1], "AS")) {
+		return fmt.Errorf("expected FROM image [AS name]")
+	}
+	if p, ok := inst.Flags["platform"]; ok {
+		c.warn(inst, "--platform=%s ignored, use apptainer build --arch", p)
+	}
+
+	name := fmt.Sprintf("stage%d", len(c.stages))
+	if len(words) == 3 {
+		name = words[2]
+	}
+	name = stageReg.ReplaceAllString(name, "-")
+
+	if base := c.findStage(words[0], false); base != nil {
+		s := base.clone(name)
+		s.header["stage"] = name
+		c.stages = append(c.stages, s)
+		return nil
+	}
+
+	s := newStage(name)
+	// global build arguments are only available to FROM
+	image := varReg.ReplaceAllStringFunc(words[0], func(ref string) string {
+		argName := strings.Trim(ref, "${}")
+		if v, ok := c.globalArgs[argName]; ok {
+			s.useArg(argName, v)
+			return "{{ " + argName + " }}"
+		}
+		return ref
+	})
+	if image == "scratch" {
+		s.header["bootstrap"] = "scratch"
+	} else {
+		s.header["bootstrap"] = "docker"
+		s.header["from"] = image
+	}
+	s.header["stage"] = name
+	c.stages = append(c.stages, s)
+	return nil
+}
+
+func (c *converter) arg(inst Instruction) error {
+	words, err := splitWords(inst.Args)
+	if err != nil {
+		return err
+	}
+	for _, w := range words {
+		name, value, found := strings.Cut(w, "=")
+		if !found {
+			// redeclared global build arguments keep their default
+			value = c.globalArgs[name]
+		}
+		if len(c.stages) == 0 {
+			c.globalArgs[name] = value
+			continue
+		}
+		s := c.current()
+		s.useArg(name, s.expand(value, true))
+		s.declared[name] = true
+		s.post = append(s.post, fmt.Sprintf("export %s=%s", name, doubleQuote("{{ "+name+" }}")))
+	}
+	return nil
+}
+
+func (c *converter) run(inst Instruction) {
+	s := c.current()
+	for flag := range inst.Flags {
+		c.warn(inst, "--%s ignored", flag)
+	}
+
+	if s.cdInPost {
+		s.post = append(s.post, "cd "+shellQuote(s.workdir))
+		s.cdInPost = false
+	}
+	s.ran = true
+
+	if words, ok := inst.ExecForm(); ok {
+		s.post = append(s.post, shellJoin(words))
+		return
+	}
+
+	script := inst.Raw
+	if strings.HasPrefix(script, "<<") {
+		script = "/bin/sh " + script
+	}
+	s.post = append(s.post, script)
+	for _, w := range strings.Fields(inst.Args) {
+		if w == "cd" || w == "pushd" {
+			s.cdInPost = true
+		}
+	}
+}
+
+func (c *converter) envInstruction(inst Instruction) error {
+	pairs, err := keyValues(inst.Args)
+	if err != nil {
+		return err
+	}
+	s := c.current()
+	for _, p := range pairs {
+		value := s.expand(p[1], true)
+		s.env[p[0]] = value
+		line := fmt.Sprintf("export %s=%s", p[0], doubleQuote(value))
+		s.environment = append(s.environment, line)
+		s.post = append(s.post, line)
+	}
+	return nil
+}
+
+func (c *converter) label(inst Instruction) error {
+	pairs, err := keyValues(inst.Args)
+	if err != nil {
+		return err
+	}
+	s := c.current()
+	for _, p := range pairs {
+		s.labels[p[0]] = s.expand(p[1], true)
+	}
+	return nil
+}
+
+func (c *converter) workdir(inst Instruction) {
+	s := c.current()
+	dir := s.expand(strings.TrimSpace(inst.Args), true)
+	if !path.IsAbs(dir) {
+		dir = path.Join(s.workdir, dir)
+	}
+	s.workdir = path.Clean(dir)
+	s.post = append(s.post, fmt.Sprintf("mkdir -p %s", shellQuote(s.workdir)), "cd "+shellQuote(s.workdir))
+	s.cdInPost = false
+}
+
+// copy converts COPY and ADD instructions to %files entries. A stage
+// bootstrapped from the image referenced by COPY --from is returned when
+// it is not a stage of the Dockerfile.
+func (c *converter) copy(inst Instruction) (*stage, error) {
+	s := c.current()
+	if len(inst.Heredocs) > 0 {
+		c.warn(inst, "here-documents not supported, ignored")
+		return nil, nil
+	}
+	for _, flag := range []string{"chown", "chmod", "link", "parents", "exclude", "checksum", "keep-git-dir"} {
+		if _, ok := inst.Flags[flag]; ok {
+			c.warn(inst, "--%s ignored", flag)
+		}
+	}
+
+	words, ok := inst.ExecForm()
+	if !ok {
+		var err error
+		if words, err = splitWords(inst.Args); err != nil {
+			return nil, err
+		}
+	}
+	if len(words) < 2 {
+		return nil, fmt.Errorf("expected at least a source and a destination")
+	}
+	for i := range words {
+		words[i] = s.expand(words[i], true)
+	}
+
+	if s.ran {
+		c.warn(inst, "files copied before the previous RUN instructions are run, %%files is processed before %%post")
+	}
+
+	srcs, dst := words[:len(words)-1], words[len(words)-1]
+	if !path.IsAbs(dst) {
+		dst = path.Join(s.workdir, dst)
+		if strings.HasSuffix(words[len(words)-1], "/") || words[len(words)-1] == "." {
+			dst += "/"
+		}
+	}
+	if len(srcs) > 1 && !strings.HasSuffix(dst, "/") {
+		dst += "/"
+	}
+
+	var extra *stage
+	args := ""
+	if from, ok := inst.Flags["from"]; ok {
+		from = s.expand(from, false)
+		if base := c.findStage(from, true); base != nil && base != s {
+			args = "from " + base.name
+		} else {
+			image, ok := c.images[from]
+			if !ok {
+				image = newStage(stageReg.ReplaceAllString("image-"+from, "-"))
+				image.header["bootstrap"] = "docker"
+				image.header["from"] = from
+				image.header["stage"] = image.name
+				c.images[from] = image
+				extra = image
+			}
+			args = "from " + image.name
+		}
+	}
+
+	for _, src := range srcs {
+		if inst.Command == "ADD" && args == "" {
+			if strings.Contains(src, "://") || strings.HasPrefix(src, "git@") {
+				c.warn(inst, "remote source %s not supported, ignored", src)
+				continue
+			}
+			if archiveReg.MatchString(src) {
+				c.warn(inst, "archive %s copied without extraction", src)
+			}
+		}
+		// a directory source copies its content
+		if strings.HasSuffix(src, "/") || src == "." {
+			src = strings.TrimSuffix(src, "/") + "/."
+			if !strings.HasSuffix(dst, "/") {
+				dst += "/"
+			}
+		}
+		s.addFile(args, types.FileTransport{Src: src, Dst: dst})
+	}
+	return extra, nil
+}
+
+func (s *stage) addFile(args string, ft types.FileTransport) {
+	for i := range s.files {
+		if s.files[i].Args == args {
+			s.files[i].Files = append(s.files[i].Files, ft)
+			return
+		}
+	}
+	s.files = append(s.files, types.Files{Args: args, Files: []types.FileTransport{ft}})
+}
+
+func (c *converter) runscript(inst Instruction) {
+	s := c.current()
+	words, exec := inst.ExecForm()
+	if !exec {
+		words = []string{inst.Args}
+	}
+	if inst.Command == "ENTRYPOINT" {
+		s.entrypoint, s.shellEntry = words, !exec
+		// ENTRYPOINT resets the CMD of the base image
+		s.cmd, s.shellCmd = nil, false
+	} else {
+		s.cmd, s.shellCmd = words, !exec
+		s.cmdInst = inst
+	}
+}
+
+// runscript returns the %runscript of s, with the semantics of ENTRYPOINT
+// and CMD: command line arguments replace CMD, and are passed to the exec
+// form of ENTRYPOINT.
+func (s *stage) runscript() string {
+	shellForm := func(cmd string) string {
+		return "exec /bin/sh -c " + shellQuote(cmd)
+	}
+
+	switch {
+	case len(s.entrypoint) > 0 && s.shellEntry:
+		return shellForm(s.entrypoint[0])
+	case len(s.entrypoint) > 0:
+		script := ""
+		if len(s.cmd) > 0 {
+			cmd := shellJoin(s.cmd)
+			if s.shellCmd {
+				cmd = "/bin/sh -c " + shellQuote(s.cmd[0])
+			}
+			script = fmt.Sprintf("if [ $# -eq 0 ]; then\n    set -- %s\nfi\n", cmd)
+		}
+		return script + "exec " + shellJoin(s.entrypoint) + ` "$@"`
+	case len(s.cmd) > 0 && s.shellCmd:
+		return fmt.Sprintf("if [ $# -eq 0 ]; then\n    %s\nfi\nexec \"$@\"", shellForm(s.cmd[0]))
+	case len(s.cmd) > 0:
+		return fmt.Sprintf("if [ $# -eq 0 ]; then\n    set -- %s\nfi\nexec \"$@\"", shellJoin(s.cmd))
+	}
+	return ""
+}
+
+// definition returns the definition of the stage.
+func (s *stage) definition() types.Definition {
+	d := types.Definition{Header: s.header}
+
+	var args []string
+	for _, name := range s.args {
+		args = append(args, name+"="+s.argDefaults[name])
+	}
+	d.BuildData.Arguments.Script = strings.Join(args, "\n")
+	d.BuildData.Post.Script = strings.Join(s.post, "\n")
+	d.BuildData.Files = s.files
+	d.Environment.Script = strings.Join(s.environment, "\n")
+	d.Runscript.Script = s.runscript()
+
+	if len(s.labels) > 0 {
+		d.Labels = s.labels
+	}
+	return d
+}
@@ -0,0 +1,222 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package dockerfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apptainer/apptainer/pkg/build/types"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       string
+		warnings   []string
+		wantErr    bool
+	}{
+		{
+			name: "SingleStage",
+			dockerfile: `FROM ubuntu:22.04
+ARG DEBIAN_FRONTEND=noninteractive
+LABEL version="1.0" description="my app"
+ENV APP_HOME=/opt/app PATH=/opt/app/bin:$PATH
+WORKDIR $APP_HOME
+COPY ["src dir/", "bin/"]
+COPY run.sh .
+RUN apt-get update && apt-get install -y curl
+RUN ["chmod", "+x", "run.sh"]
+USER nobody
+CMD ./run.sh --port 8080
+`,
+			want: `Bootstrap: docker
+From: ubuntu:22.04
+
+%arguments
+DEBIAN_FRONTEND=noninteractive
+
+%files
+	"src dir/." /opt/app/bin/
+	run.sh /opt/app/
+
+%environment
+export APP_HOME="/opt/app"
+export PATH="/opt/app/bin:$PATH"
+
+%post
+export DEBIAN_FRONTEND="{{ DEBIAN_FRONTEND }}"
+export APP_HOME="/opt/app"
+export PATH="/opt/app/bin:$PATH"
+mkdir -p /opt/app
+cd /opt/app
+apt-get update && apt-get install -y curl
+chmod +x run.sh
+
+%runscript
+if [ $# -eq 0 ]; then
+    exec /bin/sh -c './run.sh --port 8080'
+fi
+exec "$@"
+
+%labels
+	description my app
+	version 1.0
+`,
+			warnings: []string{
+				"line 10: USER: ignored, RUN instructions are run as root and containers as the calling user",
+				"line 11: CMD: ENTRYPOINT of the base image, if any, not kept, CMD is run as the runscript",
+			},
+		},
+		{
+			name: "MultiStage",
+			dockerfile: `ARG BASE=alpine:3.19
+FROM golang:1.22 AS build
+RUN cd /src && go build -o /app
+RUN go vet ./...
+
+FROM build AS test
+RUN go test ./...
+
+FROM ${BASE}
+COPY --from=0 /app /usr/bin/app
+COPY --from=busybox:latest /bin/busybox /bin/
+VOLUME /data
+ENTRYPOINT ["/usr/bin/app"]
+`,
+			want: `Bootstrap: docker
+From: busybox:latest
+Stage: image-busybox-latest
+
+Bootstrap: docker
+From: golang:1.22
+Stage: build
+
+%post
+cd /src && go build -o /app
+cd /
+go vet ./...
+
+Bootstrap: docker
+From: golang:1.22
+Stage: test
+
+%post
+cd /src && go build -o /app
+cd /
+go vet ./...
+go test ./...
+
+Bootstrap: docker
+From: {{ BASE }}
+Stage: stage2
+
+%arguments
+BASE=alpine:3.19
+
+%files from build
+	/app /usr/bin/app
+
+%files from image-busybox-latest
+	/bin/busybox /bin/
+
+%runscript
+exec /usr/bin/app "$@"
+`,
+			warnings: []string{"line 12: VOLUME: not supported, use bind mounts at runtime"},
+		},
+		{
+			name:       "Scratch",
+			dockerfile: "FROM scratch\nADD rootfs.tar.gz /\nADD https://example.com/a /a\n",
+			want:       "Bootstrap: scratch\n\n%files\n\trootfs.tar.gz /\n",
+			warnings: []string{
+				"line 2: ADD: archive rootfs.tar.gz copied without extraction",
+				"line 3: ADD: remote source https://example.com/a not supported, ignored",
+			},
+		},
+		{
+			name:       "CopyAfterRun",
+			dockerfile: "FROM alpine AS build\nRUN make\nCOPY config /etc/\n\nFROM build\nCOPY data /data\n",
+			want: `Bootstrap: docker
+From: alpine
+Stage: build
+
+%files
+	config /etc/
+
+%post
+make
+
+Bootstrap: docker
+From: alpine
+Stage: stage1
+
+%files
+	config /etc/
+	data /data
+
+%post
+make
+`,
+			warnings: []string{
+				"line 3: COPY: files copied before the previous RUN instructions are run, %files is processed before %post",
+				"line 6: COPY: files copied before the previous RUN instructions are run, %files is processed before %post",
+			},
+		},
+		{
+			name:       "NoFrom",
+			dockerfile: "RUN true\n",
+			wantErr:    true,
+		},
+		{
+			name:       "UnknownInstruction",
+			dockerfile: "FROM alpine\nFOO bar\n",
+			wantErr:    true,
+		},
+		{
+			name:       "MissingDestination",
+			dockerfile: "FROM alpine\nCOPY file\n",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, warnings, err := Convert(strings.NewReader(tt.dockerfile))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := types.WriteDefinitions(&buf, defs...); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got definition:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+			if !bytes.Equal(defs[0].FullRaw, buf.Bytes()) {
+				t.Errorf("unexpected raw definition:\n%s", defs[0].FullRaw)
+			}
+
+			var got []string
+			for _, w := range warnings {
+				got = append(got, w.String())
+			}
+			if !reflect.DeepEqual(got, tt.warnings) {
+				t.Errorf("got warnings %q, want %q", got, tt.warnings)
+			}
+		})
+	}
+}
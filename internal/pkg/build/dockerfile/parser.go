@@ -0,0 +1,268 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package dockerfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	directiveReg = regexp.MustCompile(`^#\s*([a-zA-Z]+)\s*=\s*(\S+)\s*$`)
+	heredocReg   = regexp.MustCompile(`<<(-?)\s*(["']?)([a-zA-Z_][a-zA-Z0-9_]*)(["']?)`)
+)
+
+// Heredoc is a here-document of an instruction, e.g. RUN <<EOF.
+type Heredoc struct {
+	Name string
+	Body string
+}
+
+// Instruction is a Dockerfile instruction.
+type Instruction struct {
+	// Line is the line number of the instruction in the Dockerfile.
+	Line int
+	// Command is the upper-cased instruction name, e.g. RUN.
+	Command string
+	// Flags are the --name[=value] flags of the instruction, e.g. --from
+	// for COPY, with the value of valueless flags set to "true".
+	Flags map[string]string
+	// Args are the arguments of the instruction following its flags, with
+	// line continuations joined.
+	Args string
+	// Raw are the arguments of the instruction following its flags, with
+	// line continuations and here-documents preserved.
+	Raw string
+	// Heredocs are the here-documents of the instruction.
+	Heredocs []Heredoc
+}
+
+// ExecForm returns the arguments of the instruction if they are written
+// in exec (JSON array) form, e.g. CMD ["echo", "hello"].
+func (i Instruction) ExecForm() ([]string, bool) {
+	args := strings.TrimSpace(i.Args)
+	if !strings.HasPrefix(args, "[") {
+		return nil, false
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(args), &list); err != nil {
+		return nil, false
+	}
+	return list, true
+}
+
+// instructions accepting flags, which come first in their arguments.
+var flagCommands = map[string]bool{
+	"FROM":        true,
+	"RUN":         true,
+	"COPY":        true,
+	"ADD":         true,
+	"HEALTHCHECK": true,
+}
+
+// instructions accepting here-documents.
+var heredocCommands = map[string]bool{
+	"RUN":  true,
+	"COPY": true,
+	"ADD":  true,
+}
+
+// Parse parses the instructions of the Dockerfile read from r. The escape
+// parser directive is honored, comments and empty lines are skipped.
+func Parse(r io.Reader) ([]Instruction, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("while reading Dockerfile: %v", err)
+	}
+
+	escape := `\`
+	n := 0
+	// parser directives are only recognized at the top of the Dockerfile
+	for ; n < len(lines); n++ {
+		match := directiveReg.FindStringSubmatch(lines[n])
+		if match == nil {
+			break
+		}
+		if strings.EqualFold(match[1], "escape") {
+			if match[2] != `\` && match[2] != "`" {
+				return nil, fmt.Errorf("line %d: invalid escape character %q", n+1, match[2])
+			}
+			escape = match[2]
+		}
+	}
+
+	var instructions []Instruction
+	for ; n < len(lines); n++ {
+		trimmed := strings.TrimSpace(lines[n])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		start := n
+		var joined, raw []string
+		for {
+			line := strings.TrimRight(lines[n], " \t")
+			continued := strings.HasSuffix(line, escape)
+			if continued {
+				joined = append(joined, strings.TrimSuffix(line, escape))
+			} else {
+				joined = append(joined, line)
+			}
+			if escape == `\` {
+				raw = append(raw, line)
+			} else {
+				raw = append(raw, strings.TrimSuffix(line, escape))
+			}
+			if !continued || n+1 == len(lines) {
+				break
+			}
+			n++
+			// comment and empty lines within continuations are skipped
+			for n+1 < len(lines) {
+				if t := strings.TrimSpace(lines[n]); t != "" && !strings.HasPrefix(t, "#") {
+					break
+				}
+				n++
+			}
+		}
+
+		inst, err := newInstruction(start+1, strings.Join(joined, ""), strings.Join(raw, "\n"))
+		if err != nil {
+			return nil, err
+		}
+
+		if heredocCommands[inst.Command] {
+			for _, match := range heredocReg.FindAllStringSubmatch(inst.Args, -1) {
+				strip := match[1] == "-"
+				var body []string
+				found := false
+				for n++; n < len(lines); n++ {
+					line := lines[n]
+					if strip {
+						line = strings.TrimLeft(line, "\t")
+					}
+					if line == match[3] {
+						found = true
+						break
+					}
+					body = append(body, line)
+				}
+				if !found {
+					return nil, fmt.Errorf("line %d: here-document %s is not terminated", start+1, match[3])
+				}
+				inst.Heredocs = append(inst.Heredocs, Heredoc{Name: match[3], Body: strings.Join(body, "\n") + "\n"})
+				inst.Raw += "\n" + strings.Join(body, "\n") + "\n" + match[3]
+			}
+		}
+
+		instructions = append(instructions, inst)
+	}
+
+	return instructions, nil
+}
+
+// newInstruction splits an instruction line into its command, flags and
+// arguments.
+func newInstruction(line int, joined, raw string) (Instruction, error) {
+	inst := Instruction{Line: line, Flags: make(map[string]string)}
+
+	command, args, _ := strings.Cut(strings.TrimLeft(joined, " \t"), " ")
+	if cmd, rest, found := strings.Cut(command, "\t"); found {
+		command, args = cmd, rest+" "+args
+	}
+	inst.Command = strings.ToUpper(command)
+	if !isWord(inst.Command) {
+		return inst, fmt.Errorf("line %d: invalid instruction %q", line, command)
+	}
+
+	raw = strings.TrimLeft(raw, " \t")
+	raw = strings.TrimLeft(raw[len(command):], " \t")
+	args = strings.TrimLeft(args, " \t")
+
+	if flagCommands[inst.Command] {
+		for strings.HasPrefix(args, "--") {
+			flag, rest, _ := strings.Cut(args, " ")
+			name, value, found := strings.Cut(strings.TrimPrefix(flag, "--"), "=")
+			if !found {
+				value = "true"
+			}
+			inst.Flags[strings.ToLower(name)] = value
+			args = strings.TrimLeft(rest, " \t")
+
+			// flags are on the first lines, skip them in raw arguments
+			raw = strings.TrimLeft(raw[strings.Index(raw, flag)+len(flag):], " \t")
+			raw = strings.TrimLeft(strings.TrimPrefix(raw, "\\\n"), " \t")
+		}
+	}
+
+	inst.Args = strings.TrimSpace(args)
+	inst.Raw = strings.TrimSpace(raw)
+	return inst, nil
+}
+
+func isWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitWords splits s into whitespace separated words, honoring single and
+// double quotes and backslash escapes, which are removed.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == 0 && (c == ' ' || c == '\t' || c == '\n'):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\\' && quote != '\'' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == quote:
+			quote = 0
+		default:
+			word.WriteRune(c)
+		}
+		inWord = true
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Instruction
+		wantErr bool
+	}{
+		{
+			name: "Instructions",
+			data: "# comment\nfrom alpine AS base\n\nRUN --mount=type=cache,target=/var/cache/apk --network=none \\\n    apk add \\\n    # skipped\n    curl\nCMD [\"sh\"]\n",
+			want: []Instruction{
+				{Line: 2, Command: "FROM", Flags: map[string]string{}, Args: "alpine AS base", Raw: "alpine AS base"},
+				{
+					Line:    4,
+					Command: "RUN",
+					Flags:   map[string]string{"mount": "type=cache,target=/var/cache/apk", "network": "none"},
+					Args:    "apk add     curl",
+					Raw:     "apk add \\\n    curl",
+				},
+				{Line: 8, Command: "CMD", Flags: map[string]string{}, Args: `["sh"]`, Raw: `["sh"]`},
+			},
+		},
+		{
+			name: "EscapeDirective",
+			data: "# escape=`\nFROM scratch\nCOPY a `\n  b /\n",
+			want: []Instruction{
+				{Line: 2, Command: "FROM", Flags: map[string]string{}, Args: "scratch", Raw: "scratch"},
+				{Line: 3, Command: "COPY", Flags: map[string]string{}, Args: "a   b /", Raw: "a \n  b /"},
+			},
+		},
+		{
+			name: "Heredoc",
+			data: "FROM alpine\nRUN <<-EOF\n\techo $HOME\nEOF\n",
+			want: []Instruction{
+				{Line: 1, Command: "FROM", Flags: map[string]string{}, Args: "alpine", Raw: "alpine"},
+				{
+					Line:     2,
+					Command:  "RUN",
+					Flags:    map[string]string{},
+					Args:     "<<-EOF",
+					Raw:      "<<-EOF\necho $HOME\nEOF",
+					Heredocs: []Heredoc{{Name: "EOF", Body: "echo $HOME\n"}},
+				},
+			},
+		},
+		{
+			name:    "UnterminatedHeredoc",
+			data:    "FROM alpine\nRUN <<EOF\necho\n",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidInstruction",
+			data:    "FROM alpine\nR-UN true\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got instructions %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	got, err := splitWords(`a "b c" 'd \e' f\ g h="i j"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"a", "b c", `d \e`, "f g", "h=i j"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got words %q, want %q", got, want)
+	}
+
+	if _, err := splitWords(`a "b`); err == nil {
+		t.Errorf("unexpected success with unterminated quote")
+	}
+}
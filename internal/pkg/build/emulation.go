@@ -0,0 +1,100 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+
+	"github.com/apptainer/apptainer/internal/pkg/util/bin"
+	"github.com/apptainer/apptainer/internal/pkg/util/machine"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/namespaces"
+)
+
+// setupEmulation checks the architecture of the stage rootfs and, when
+// it's not native, sets up QEMU user emulation so the %post and %test
+// sections can run. An interpreter already registered in binfmt_misc is
+// used first, otherwise a static qemu-<arch> interpreter found in PATH is
+// registered with the F flag, which requires privileges.
+func (s *stage) setupEmulation() (*machine.Emulator, error) {
+	if s.b.Recipe.BuildData.Post.Script == "" && (s.b.Opts.NoTest || s.b.Recipe.BuildData.Test.Script == "") {
+		return nil, nil
+	}
+
+	arch := machine.ArchFromContainer(s.b.RootfsPath)
+	if arch == "" || machine.IsNative(arch) {
+		return nil, nil
+	}
+
+	if e := machine.FindEmulator(arch); e != nil {
+		if e.NeedsBind {
+			if err := checkStaticInterpreter(e.Interpreter); err != nil {
+				return nil, fmt.Errorf("binfmt_misc interpreter for %s can't be used in container: %v", arch, err)
+			}
+		}
+		sylog.Infof("Running %s scriptlets through %s emulation", arch, e.Interpreter)
+		return e, nil
+	}
+
+	qarch, err := machine.QemuArch(arch)
+	if err != nil {
+		return nil, fmt.Errorf("no emulation available for %s container: %v", arch, err)
+	}
+
+	insideUserNs, _ := namespaces.IsInsideUserNamespace(os.Getpid())
+	if os.Geteuid() != 0 || insideUserNs {
+		return nil, fmt.Errorf("container architecture %s requires emulation: register a static qemu-%s interpreter in binfmt_misc with the F flag, or run the build as root", arch, qarch)
+	}
+
+	interpreter, err := bin.FindBin("qemu-" + qarch + "-static")
+	if err != nil {
+		interpreter, err = bin.FindBin("qemu-" + qarch)
+		if err != nil {
+			return nil, fmt.Errorf("container architecture %s requires emulation but no qemu-%s-static interpreter found in PATH", arch, qarch)
+		}
+	}
+	if err := checkStaticInterpreter(interpreter); err != nil {
+		return nil, err
+	}
+
+	e, err := machine.RegisterEmulator(arch, interpreter)
+	if err != nil {
+		return nil, err
+	}
+	sylog.Infof("Registered %s in binfmt_misc to run %s scriptlets", interpreter, arch)
+
+	return e, nil
+}
+
+// emulationBinds returns the bind mounts required by the stage emulator.
+func (s *stage) emulationBinds() []string {
+	if s.emulator == nil || !s.emulator.NeedsBind {
+		return nil
+	}
+	return []string{s.emulator.Interpreter + ":" + s.emulator.Interpreter}
+}
+
+// checkStaticInterpreter ensures the interpreter at path is statically
+// linked, as it's executed within the container filesystem.
+func checkStaticInterpreter(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("while reading interpreter %s: %v", path, err)
+	}
+	defer f.Close()
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			return fmt.Errorf("interpreter %s is not statically linked", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,481 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package lint checks definition files for mistakes which the parser
+// accepts, but which are likely to make a build fail, behave differently
+// than expected, or produce larger images.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/build/args"
+	"github.com/apptainer/apptainer/internal/pkg/ociimage"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/build/types/parser"
+)
+
+// Severity is the severity of a finding.
+type Severity string
+
+const (
+	// SeverityError is the severity of findings which make a build fail.
+	SeverityError Severity = "error"
+	// SeverityWarning is the severity of likely mistakes.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is the severity of suggestions.
+	SeverityInfo Severity = "info"
+)
+
+// Rule describes a check performed on definition files.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+}
+
+// Rule IDs.
+const (
+	RuleParseError            = "parse-error"
+	RuleMissingBootstrap      = "missing-bootstrap"
+	RuleUnknownBootstrap      = "unknown-bootstrap"
+	RuleMissingHeader         = "missing-header"
+	RuleIrrelevantHeader      = "irrelevant-header"
+	RuleUnusedArgument        = "unused-argument"
+	RuleUndefinedArgument     = "undefined-argument"
+	RuleUnquotedArgument      = "unquoted-argument"
+	RulePostErrexit           = "post-errexit"
+	RuleNonInteractiveInstall = "noninteractive-install"
+	RulePackageCache          = "package-cache"
+)
+
+// Rules lists the checks performed by Lint.
+var Rules = []Rule{
+	{RuleParseError, SeverityError, "The definition file can't be parsed."},
+	{RuleMissingBootstrap, SeverityError, "A build stage has no Bootstrap header."},
+	{RuleUnknownBootstrap, SeverityError, "The Bootstrap agent is not supported."},
+	{RuleMissingHeader, SeverityError, "A header required by the Bootstrap agent is missing."},
+	{RuleIrrelevantHeader, SeverityWarning, "A header is ignored by the Bootstrap agent of its stage."},
+	{RuleUnusedArgument, SeverityWarning, "A build argument defined in %arguments is never used."},
+	{RuleUndefinedArgument, SeverityInfo, "A build argument has no default value in %arguments and must be given with --build-arg."},
+	{RuleUnquotedArgument, SeverityWarning, "A build argument is used unquoted in a script, its value is subject to word splitting and globbing."},
+	{RulePostErrexit, SeverityWarning, "%post runs with a custom shell without exiting on errors."},
+	{RuleNonInteractiveInstall, SeverityWarning, "A package manager command may prompt for confirmation and fail the build."},
+	{RulePackageCache, SeverityInfo, "Packages are installed without cleaning the package manager cache, increasing the image size."},
+}
+
+// ruleSeverity returns the severity of the rule with id.
+func ruleSeverity(id string) Severity {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r.Severity
+		}
+	}
+	return SeverityWarning
+}
+
+// Finding is a problem found in a definition file.
+type Finding struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// HasErrors returns true if any of findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	buildArgsRegexp  = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+	bootstrapRegexp  = regexp.MustCompile(`(?i)^bootstrap:`)
+	errexitRegexp    = regexp.MustCompile(`^\s*set\s+(-[a-zA-Z]*e|-o\s+errexit)`)
+	shellErrexitArgs = regexp.MustCompile(`(^|\s)-[a-zA-Z]*e`)
+	otherURLRegexp   = regexp.MustCompile(`^otherurl\d+$`)
+)
+
+// commonHeaders are the headers relevant to any Bootstrap agent.
+var commonHeaders = []string{"bootstrap", "stage"}
+
+// bootstrapHeaders maps Bootstrap agents to the headers they use. OCI
+// transports are handled as "oci".
+var bootstrapHeaders = map[string][]string{
+	"library":     {"from", "library"},
+	"oras":        {"from"},
+	"shub":        {"from"},
+	"oci":         {"from", "namespace", "registry"},
+	"busybox":     {"mirrorurl"},
+	"debootstrap": {"include", "mirrorurl", "osversion"},
+	"mmdebstrap":  {"components", "include", "keyring", "mirrorurl", "osversion", "suites"},
+	"arch":        {"confurl", "include"},
+	"localimage":  {"fingerprints", "from"},
+	"yum":         {"include", "mirrorurl", "osversion", "setopt", "updateurl"},
+	"dnf":         {"include", "mirrorurl", "osversion", "setopt", "updateurl"},
+	"zypper":      {"include", "mirrorurl", "modules", "osversion", "otherurl&n", "product", "productpgp", "regcode", "registerurl", "updateurl", "user"},
+	"apk":         {"include", "keys", "mirrorurl", "osversion"},
+	"scratch":     {},
+	"buildkit":    {"buildargs", "filename", "from", "frontend", "target"},
+	"dockerfile":  {"buildargs", "filename", "from", "frontend", "target"},
+}
+
+// requiredHeaders maps Bootstrap agents to the headers they require.
+var requiredHeaders = map[string][]string{
+	"library":    {"from"},
+	"oras":       {"from"},
+	"shub":       {"from"},
+	"oci":        {"from"},
+	"localimage": {"from"},
+}
+
+// scriptSections are the sections holding shell scripts.
+var scriptSections = map[string]bool{
+	"setup":       true,
+	"pre":         true,
+	"post":        true,
+	"environment": true,
+	"runscript":   true,
+	"startscript": true,
+	"test":        true,
+	"appinstall":  true,
+	"appenv":      true,
+	"apprun":      true,
+	"appstart":    true,
+	"apptest":     true,
+}
+
+// line is a line of a definition file, with its line number.
+type line struct {
+	n    int
+	text string
+}
+
+// section is a section of a definition file stage.
+type section struct {
+	name  string
+	args  string
+	line  int
+	lines []line
+}
+
+// stage is a build stage of a definition file.
+type stage struct {
+	line     int
+	raw      []byte
+	header   []line
+	sections []section
+}
+
+// splitStages splits a definition file into build stages, the same way
+// parser.All does, and each stage into its header and sections.
+func splitStages(raw []byte) []*stage {
+	var stages []*stage
+	var cur *stage
+
+	for i, text := range strings.Split(string(raw), "\n") {
+		l := line{n: i + 1, text: text}
+		if cur == nil || bootstrapRegexp.MatchString(text) {
+			cur = &stage{line: l.n}
+			stages = append(stages, cur)
+		}
+		cur.raw = append(cur.raw, text+"\n"...)
+
+		fields := strings.Fields(text)
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "%") {
+			s := section{
+				name: strings.ToLower(strings.TrimPrefix(fields[0], "%")),
+				line: l.n,
+			}
+			if rest := strings.SplitN(strings.TrimSpace(text), " ", 2); len(rest) == 2 {
+				s.args = strings.TrimSpace(rest[1])
+			}
+			cur.sections = append(cur.sections, s)
+			continue
+		}
+		if len(cur.sections) == 0 {
+			cur.header = append(cur.header, l)
+		} else {
+			s := &cur.sections[len(cur.sections)-1]
+			s.lines = append(s.lines, l)
+		}
+	}
+
+	return stages
+}
+
+// empty returns true if a stage has no section, and only blank or comment
+// lines in its header, like text above the first Bootstrap header.
+func (st *stage) empty() bool {
+	if len(st.sections) > 0 {
+		return false
+	}
+	for _, l := range st.header {
+		if strings.TrimSpace(l.text) != "" && !isComment(l.text) {
+			return false
+		}
+	}
+	return true
+}
+
+// isComment returns true if text is a comment line. Shebangs are not
+// comments.
+func isComment(text string) bool {
+	t := strings.TrimSpace(text)
+	return strings.HasPrefix(t, "#") && !strings.HasPrefix(t, "#!")
+}
+
+// Lint checks the definition file content raw and returns the problems
+// found, ordered by line.
+func Lint(raw []byte) []Finding {
+	var findings []Finding
+	report := func(n int, rule, format string, a ...interface{}) {
+		findings = append(findings, Finding{
+			Line:     n,
+			Rule:     rule,
+			Severity: ruleSeverity(rule),
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	for _, st := range splitStages(raw) {
+		if st.empty() {
+			continue
+		}
+		def, err := parser.ParseDefinitionFile(bytes.NewReader(st.raw))
+		if err != nil {
+			report(st.line, RuleParseError, "%s", err)
+			continue
+		}
+
+		lintHeader(st, def.Header, report)
+		lintArguments(st, args.ReadDefaults(def), report)
+		for _, s := range st.sections {
+			if !scriptSections[s.name] {
+				continue
+			}
+			lintUnquoted(s, report)
+			if s.name == "post" || s.name == "appinstall" {
+				lintPost(s, report)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// LintFile checks the definition file at path, see Lint. JSON and YAML
+// definitions are checked in their definition file form, findings are then
+// reported without line number.
+func LintFile(path string) ([]Finding, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format := parser.FormatFromPath(path)
+	if format != parser.FormatDef {
+		raw, err = convertDefinition(raw, format)
+		if err != nil {
+			return []Finding{{
+				File:     path,
+				Rule:     RuleParseError,
+				Severity: ruleSeverity(RuleParseError),
+				Message:  err.Error(),
+			}}, nil
+		}
+	}
+
+	findings := Lint(raw)
+	for i := range findings {
+		findings[i].File = path
+		if format != parser.FormatDef {
+			findings[i].Line = 0
+		}
+	}
+	return findings, nil
+}
+
+// convertDefinition converts JSON or YAML definitions to a definition file.
+func convertDefinition(raw []byte, format string) ([]byte, error) {
+	defs, err := parser.AllFormat(bytes.NewReader(raw), format)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := types.WriteDefinitions(&buf, defs...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type reportFunc func(n int, rule, format string, a ...interface{})
+
+// lintHeader checks that the header of a stage has a supported Bootstrap
+// agent, the headers it requires, and no headers it ignores.
+func lintHeader(st *stage, header map[string]string, report reportFunc) {
+	bs, ok := header["bootstrap"]
+	if !ok {
+		report(st.line, RuleMissingBootstrap, "build stage has no Bootstrap header")
+		return
+	}
+
+	agent := strings.ToLower(bs)
+	if ociimage.SupportedTransport(agent) != "" {
+		agent = "oci"
+	}
+	relevant, ok := bootstrapHeaders[agent]
+	if !ok {
+		report(headerLine(st, "bootstrap"), RuleUnknownBootstrap, "unknown Bootstrap agent %q", bs)
+		return
+	}
+
+	for _, h := range requiredHeaders[agent] {
+		if header[h] == "" {
+			report(headerLine(st, "bootstrap"), RuleMissingHeader, "Bootstrap agent %q requires a %q header", bs, h)
+		}
+	}
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if isRelevantHeader(k, relevant) {
+			continue
+		}
+		report(headerLine(st, k), RuleIrrelevantHeader, "header %q is ignored by Bootstrap agent %q", k, bs)
+	}
+}
+
+// isRelevantHeader returns true if header key is in relevant, or common to
+// all Bootstrap agents.
+func isRelevantHeader(key string, relevant []string) bool {
+	for _, h := range append(relevant, commonHeaders...) {
+		if h == key || (h == "otherurl&n" && otherURLRegexp.MatchString(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerLine returns the line number of header key in a stage, or the
+// first line of the stage if it can't be found.
+func headerLine(st *stage, key string) int {
+	for _, l := range st.header {
+		k, _, ok := strings.Cut(l.text, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return l.n
+		}
+	}
+	return st.line
+}
+
+// lintArguments checks that build arguments defined with a default value
+// in %arguments are used, and reports those used without default value.
+func lintArguments(st *stage, defaults map[string]string, report reportFunc) {
+	used := make(map[string]bool)
+	defined := make(map[string]int)
+	undefined := make(map[string]bool)
+
+	useArgs := func(l line, text string) {
+		if isComment(l.text) {
+			return
+		}
+		for _, m := range buildArgsRegexp.FindAllStringSubmatch(text, -1) {
+			used[m[1]] = true
+			if _, ok := defaults[m[1]]; !ok && !undefined[m[1]] {
+				undefined[m[1]] = true
+				report(l.n, RuleUndefinedArgument, "build argument %q has no default value in %%arguments", m[1])
+			}
+		}
+	}
+
+	for _, l := range st.header {
+		useArgs(l, l.text)
+	}
+	for _, s := range st.sections {
+		useArgs(line{n: s.line, text: s.args}, s.args)
+		for _, l := range s.lines {
+			if s.name != "arguments" {
+				useArgs(l, l.text)
+				continue
+			}
+			// arguments may reference other arguments in their values
+			k, v, ok := strings.Cut(l.text, "=")
+			if !ok || isComment(l.text) {
+				continue
+			}
+			if _, seen := defined[strings.TrimSpace(k)]; !seen {
+				defined[strings.TrimSpace(k)] = l.n
+			}
+			useArgs(l, v)
+		}
+	}
+
+	names := make([]string, 0, len(defined))
+	for name := range defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !used[name] {
+			report(defined[name], RuleUnusedArgument, "build argument %q is never used", name)
+		}
+	}
+}
+
+// lintUnquoted reports build arguments used outside of quotes in script
+// section s.
+func lintUnquoted(s section, report reportFunc) {
+	for _, l := range s.lines {
+		if isComment(l.text) {
+			continue
+		}
+		for _, m := range buildArgsRegexp.FindAllStringSubmatchIndex(l.text, -1) {
+			if !quotedAt(l.text, m[0]) {
+				report(l.n, RuleUnquotedArgument, "build argument %q is not quoted in %%%s", l.text[m[2]:m[3]], s.name)
+			}
+		}
+	}
+}
+
+// quotedAt returns true if position pos of a shell line is within single
+// or double quotes.
+func quotedAt(text string, pos int) bool {
+	var single, double, escaped bool
+	for i := 0; i < pos; i++ {
+		c := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && !single:
+			escaped = true
+		case c == '\'' && !double:
+			single = !single
+		case c == '"' && !single:
+			double = !double
+		}
+	}
+	return single || double
+}
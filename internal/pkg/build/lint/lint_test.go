@@ -0,0 +1,205 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// ruleLine is a rule reported on a line.
+type ruleLine struct {
+	rule string
+	line int
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want []ruleLine
+	}{
+		{
+			name: "Clean",
+			def: `Bootstrap: docker
+From: alpine:{{ VERSION }}
+
+%arguments
+    VERSION=3.20
+
+%post
+    apk add --no-cache curl
+    echo "{{ VERSION }}" > /version
+`,
+		},
+		{
+			name: "Headers",
+			def: `# comment before the first stage
+Bootstrap: docker
+MirrorURL: http://example.com
+
+Bootstrap: yum
+OSVersion: 9
+From: centos
+
+Bootstrap: unknown
+`,
+			want: []ruleLine{
+				{RuleMissingHeader, 2},
+				{RuleIrrelevantHeader, 3},
+				{RuleIrrelevantHeader, 7},
+				{RuleUnknownBootstrap, 9},
+			},
+		},
+		{
+			name: "MissingBootstrap",
+			def: `%post
+    true
+`,
+			want: []ruleLine{{RuleMissingBootstrap, 1}},
+		},
+		{
+			name: "ParseError",
+			def: `Bootstrap: docker
+From: alpine
+Unknown: value
+`,
+			want: []ruleLine{{RuleParseError, 1}},
+		},
+		{
+			name: "Arguments",
+			def: `Bootstrap: docker
+From: alpine
+
+%arguments
+    VERSION=1
+    PREFIX=/opt/{{ NAME }}
+    NAME=app
+    UNUSED=1
+
+%post
+    # {{ COMMENTED }}
+    echo {{ VERSION }} '{{ NAME }}' > "{{ PREFIX }}/version"
+    touch /{{ MISSING }}
+`,
+			want: []ruleLine{
+				{RuleUnusedArgument, 8},
+				{RuleUnquotedArgument, 12},
+				{RuleUndefinedArgument, 13},
+				{RuleUnquotedArgument, 13},
+			},
+		},
+		{
+			name: "Post",
+			def: `Bootstrap: docker
+From: ubuntu
+
+%post -c /bin/bash
+    apt-get update && \
+        apt-get install curl
+    yes | apt-get remove vim
+    apt-get -qq install git
+    DEBIAN_FRONTEND=noninteractive apt-get install -y make
+    dnf install gcc
+    dnf clean all
+
+%appinstall app
+    apt-get -y install foo
+    rm -rf /var/lib/apt/lists/*
+`,
+			want: []ruleLine{
+				{RulePostErrexit, 4},
+				{RuleNonInteractiveInstall, 5},
+				{RulePackageCache, 5},
+				{RuleNonInteractiveInstall, 10},
+			},
+		},
+		{
+			name: "PostErrexit",
+			def: `Bootstrap: scratch
+
+%post -c /bin/bash
+    set -euo pipefail
+
+%test -c /bin/bash
+    true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []ruleLine
+			for _, f := range Lint([]byte(tt.def)) {
+				got = append(got, ruleLine{f.Rule, f.Line})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got findings %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got findings %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	findings := []Finding{
+		{File: "a.def", Line: 3, Rule: RuleUnusedArgument, Severity: SeverityWarning, Message: "unused"},
+		{File: "a.def", Rule: RulePackageCache, Severity: SeverityInfo, Message: "cache"},
+	}
+
+	var text bytes.Buffer
+	if err := Write(&text, FormatText, findings); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.def:3: warning: unused [unused-argument]\na.def: info: cache [package-cache]\n"
+	if text.String() != want {
+		t.Errorf("got text %q, want %q", text.String(), want)
+	}
+
+	var js bytes.Buffer
+	if err := Write(&js, FormatJSON, findings); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Finding
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0] != findings[0] {
+		t.Errorf("unexpected JSON findings: %s", js.String())
+	}
+
+	var sarif bytes.Buffer
+	if err := Write(&sarif, FormatSARIF, findings); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	results := log.Runs[0].Results
+	if log.Version != "2.1.0" || len(results) != 2 || len(log.Runs[0].Tool.Driver.Rules) != len(Rules) {
+		t.Fatalf("unexpected SARIF log: %s", sarif.String())
+	}
+	if results[0].Level != "warning" || results[0].Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("unexpected SARIF result: %+v", results[0])
+	}
+	if results[1].Level != "note" || results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("unexpected SARIF result: %+v", results[1])
+	}
+
+	if err := Write(&text, "xml", findings); err == nil {
+		t.Errorf("unexpected success with unknown format")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package lint
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var commandSeparator = regexp.MustCompile(`&&|\|\||;`)
+
+// packageManager describes the commands of a package manager checked in
+// %post sections.
+type packageManager struct {
+	// prompting lists the subcommands asking for confirmation.
+	prompting []string
+	// yes matches the options answering yes to confirmations.
+	yes *regexp.Regexp
+	// install lists the subcommands downloading packages in the cache.
+	install []string
+	// noCache matches the options disabling the cache.
+	noCache *regexp.Regexp
+	// clean matches the commands cleaning the cache.
+	clean *regexp.Regexp
+}
+
+var (
+	aptManager = &packageManager{
+		prompting: []string{"install", "upgrade", "dist-upgrade", "full-upgrade", "remove", "purge", "autoremove", "reinstall"},
+		yes:       regexp.MustCompile(`^(-[a-zA-Z]*y[a-zA-Z]*|--yes|--assume-yes|-qq)$`),
+		install:   []string{"install", "upgrade", "dist-upgrade", "full-upgrade", "reinstall"},
+		clean:     regexp.MustCompile(`\bapt(-get)?\s+(.*\s)?(clean|distclean)\b|/var/lib/apt/lists`),
+	}
+	yumManager = &packageManager{
+		prompting: []string{"install", "update", "upgrade", "remove", "erase", "groupinstall", "reinstall", "downgrade", "autoremove"},
+		yes:       regexp.MustCompile(`^(-[a-zA-Z]*y[a-zA-Z]*|--assumeyes)$`),
+		install:   []string{"install", "update", "upgrade", "groupinstall", "reinstall", "downgrade"},
+		clean:     regexp.MustCompile(`\b(yum|dnf|microdnf)\s+(.*\s)?clean\b|/var/cache/(yum|dnf)`),
+	}
+	apkManager = &packageManager{
+		install: []string{"add", "upgrade"},
+		noCache: regexp.MustCompile(`^--no-cache$`),
+		clean:   regexp.MustCompile(`\bapk\s+(.*\s)?cache\s+clean\b|/var/cache/apk`),
+	}
+)
+
+// packageManagers maps package manager commands to their description.
+var packageManagers = map[string]*packageManager{
+	"apt-get":  aptManager,
+	"apt":      aptManager,
+	"yum":      yumManager,
+	"dnf":      yumManager,
+	"microdnf": yumManager,
+	"apk":      apkManager,
+}
+
+// logicalLines joins the lines of a section continued with a trailing
+// backslash, keeping the line number of their first line.
+func logicalLines(lines []line) []line {
+	var joined []line
+	var cur *line
+
+	for _, l := range lines {
+		if cur == nil {
+			joined = append(joined, line{n: l.n})
+			cur = &joined[len(joined)-1]
+		}
+		text := strings.TrimRight(l.text, " \t")
+		if strings.HasSuffix(text, "\\") && !isComment(text) {
+			cur.text += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		cur.text += text
+		cur = nil
+	}
+
+	return joined
+}
+
+// packageCommand returns the package manager invoked by the simple command
+// fields, skipping sudo, env and variable assignments, and its arguments.
+func packageCommand(fields []string) (*packageManager, []string) {
+	for i, f := range fields {
+		if f == "sudo" || f == "env" || f == "command" || strings.Contains(f, "=") {
+			continue
+		}
+		pm := packageManagers[filepath.Base(f)]
+		return pm, fields[i+1:]
+	}
+	return nil, nil
+}
+
+// subcommand returns the first argument which is not an option.
+func subcommand(args []string) string {
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			return a
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func anyMatch(re *regexp.Regexp, args []string) bool {
+	if re == nil {
+		return false
+	}
+	for _, a := range args {
+		if re.MatchString(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintPost checks that %post and %appinstall scripts exit on errors, and
+// run package managers non-interactively, cleaning their cache.
+func lintPost(s section, report reportFunc) {
+	if s.name == "post" && strings.Contains(" "+s.args+" ", " -c ") {
+		lintErrexit(s, report)
+	}
+
+	cacheUsed := make(map[*packageManager]int)
+	cleaned := make(map[*packageManager]bool)
+
+	for _, l := range logicalLines(s.lines) {
+		if isComment(l.text) {
+			continue
+		}
+		for pm, clean := range map[*packageManager]*regexp.Regexp{aptManager: aptManager.clean, yumManager: yumManager.clean, apkManager: apkManager.clean} {
+			if clean.MatchString(l.text) {
+				cleaned[pm] = true
+			}
+		}
+
+		for _, cmd := range commandSeparator.Split(l.text, -1) {
+			yesPiped := false
+			for _, simple := range strings.Split(cmd, "|") {
+				fields := strings.Fields(simple)
+				if len(fields) > 0 && fields[0] == "yes" {
+					yesPiped = true
+					continue
+				}
+				pm, args := packageCommand(fields)
+				if pm == nil {
+					continue
+				}
+				sub := subcommand(args)
+
+				if contains(pm.prompting, sub) && !yesPiped && !anyMatch(pm.yes, args) {
+					report(l.n, RuleNonInteractiveInstall, "%q may prompt for confirmation, use -y", strings.TrimSpace(simple))
+				}
+				if contains(pm.install, sub) && !anyMatch(pm.noCache, args) {
+					if _, ok := cacheUsed[pm]; !ok {
+						cacheUsed[pm] = l.n
+					}
+				}
+			}
+		}
+	}
+
+	for _, pm := range []*packageManager{aptManager, yumManager, apkManager} {
+		n, ok := cacheUsed[pm]
+		if !ok || cleaned[pm] {
+			continue
+		}
+		msg := "packages are installed in %%%s without cleaning the package cache"
+		switch pm {
+		case aptManager:
+			msg += ", add \"apt-get clean && rm -rf /var/lib/apt/lists/*\""
+		case yumManager:
+			msg += ", add \"yum clean all\" or \"dnf clean all\""
+		case apkManager:
+			msg += ", use \"apk add --no-cache\""
+		}
+		report(n, RulePackageCache, msg, s.name)
+	}
+}
+
+// lintErrexit checks that a %post section run with a custom shell, which
+// unlike the default shell doesn't exit on errors, enables errexit.
+func lintErrexit(s section, report reportFunc) {
+	_, shell, _ := strings.Cut(" "+s.args+" ", " -c ")
+	if shellErrexitArgs.MatchString(shell) {
+		return
+	}
+	for _, l := range s.lines {
+		if errexitRegexp.MatchString(l.text) {
+			return
+		}
+	}
+	report(s.line, RulePostErrexit, "%%post runs with %q which doesn't exit on errors, add \"set -e\"", strings.TrimSpace(shell))
+}
@@ -0,0 +1,171 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output formats supported by Write.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// Write writes findings to w in format, one of FormatText, FormatJSON or
+// FormatSARIF.
+func Write(w io.Writer, format string, findings []Finding) error {
+	switch format {
+	case FormatText:
+		return writeText(w, findings)
+	case FormatJSON:
+		return writeJSON(w, findings)
+	case FormatSARIF:
+		return writeSARIF(w, findings)
+	default:
+		return fmt.Errorf("unknown output format %q, must be one of %s, %s or %s", format, FormatText, FormatJSON, FormatSARIF)
+	}
+}
+
+// String returns the finding as file:line: severity: message [rule].
+func (f Finding) String() string {
+	loc := f.File
+	if f.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, f.Line)
+	}
+	if loc != "" {
+		loc += ": "
+	}
+	return fmt.Sprintf("%s%s: %s [%s]", loc, f.Severity, f.Message, f.Rule)
+}
+
+func writeText(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		if _, err := fmt.Fprintln(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// SARIF 2.1.0 log, limited to the properties used to report findings, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevel returns the SARIF level corresponding to severity s.
+func sarifLevel(s Severity) string {
+	if s == SeverityInfo {
+		return "note"
+	}
+	return string(s)
+}
+
+func writeSARIF(w io.Writer, findings []Finding) error {
+	driver := sarifDriver{
+		Name:           "apptainer",
+		InformationURI: "https://apptainer.org",
+	}
+	for _, r := range Rules {
+		sr := sarifRule{ID: r.ID, ShortDescription: sarifMessage{Text: r.Description}}
+		sr.DefaultConfiguration.Level = sarifLevel(r.Severity)
+		driver.Rules = append(driver.Rules, sr)
+	}
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: driver},
+		Results: []sarifResult{},
+	}
+	for _, f := range findings {
+		res := sarifResult{
+			RuleID:  f.Rule,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+		}
+		if f.File != "" {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = f.File
+			if f.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+			}
+			res.Locations = append(res.Locations, loc)
+		}
+		run.Results = append(run.Results, res)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,373 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/apptainer/apptainer/internal/pkg/util/bin"
+	"github.com/apptainer/apptainer/internal/pkg/util/fs"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/namespaces"
+)
+
+const (
+	apkReposFile = "/etc/apk/repositories"
+	apkKeysDir   = "/etc/apk/keys"
+	apkCacheDir  = "/var/cache/apk"
+)
+
+// apkArchs is a map of GO Archs to Alpine Linux architectures
+// https://wiki.alpinelinux.org/wiki/Architecture
+var apkArchs = map[string]string{
+	"386":     "x86",
+	"amd64":   "x86_64",
+	"arm":     "armv7",
+	"arm64":   "aarch64",
+	"ppc64le": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// apkRepos are the repositories of an Alpine Linux release which
+// are enabled below the MirrorURL
+var apkRepos = []string{"main", "community"}
+
+// ApkConveyor holds stuff that needs to be packed into the bundle
+type ApkConveyor struct {
+	b         *types.Bundle
+	mirrorurl string
+	osversion string
+	include   string
+	keys      []string
+}
+
+// ApkConveyorPacker only needs to hold the conveyor to have the needed data to pack
+type ApkConveyorPacker struct {
+	ApkConveyor
+}
+
+// Get downloads container information from the specified source
+func (c *ApkConveyor) Get(ctx context.Context, b *types.Bundle) (err error) {
+	c.b = b
+
+	// check for a static apk, or a regular apk, on the system
+	var apkPath string
+	if apkPath, err = bin.FindBin("apk.static"); err == nil {
+		sylog.Debugf("Found apk.static at: %v", apkPath)
+	} else if apkPath, err = bin.FindBin("apk"); err == nil {
+		sylog.Debugf("Found apk at: %v", apkPath)
+	} else {
+		return fmt.Errorf("neither apk.static nor apk in path")
+	}
+
+	apkArch, ok := apkArchs[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("alpine arch not known for GOARCH %s", runtime.GOARCH)
+	}
+
+	err = c.getBootstrapOptions()
+	if err != nil {
+		return fmt.Errorf("while getting bootstrap options: %v", err)
+	}
+
+	err = c.genApkConfig()
+	if err != nil {
+		return fmt.Errorf("while generating apk config: %v", err)
+	}
+
+	insideUserNs, setgroupsAllowed := namespaces.IsInsideUserNamespace(os.Getpid())
+	if insideUserNs {
+		umountFn, err := c.prepareFakerootEnv()
+		if umountFn != nil {
+			defer umountFn()
+		}
+		if err != nil {
+			return fmt.Errorf("while preparing fakeroot build environment: %s", err)
+		}
+	} else {
+		err = c.makePseudoDevices()
+		if err != nil {
+			return fmt.Errorf("while copying pseudo devices: %v", err)
+		}
+	}
+
+	args := []string{
+		`--root`, c.b.RootfsPath,
+		`--initdb`,
+		`--no-cache`,
+		`--arch`, apkArch,
+		`--repositories-file`, filepath.Join(c.b.RootfsPath, apkReposFile),
+	}
+	if len(c.keys) > 0 {
+		args = append(args, `--keys-dir`, filepath.Join(c.b.RootfsPath, apkKeysDir))
+	} else {
+		args = append(args, `--allow-untrusted`)
+	}
+	// without a full set of subordinate IDs files can't be owned
+	// by anybody else than root
+	if insideUserNs && !setgroupsAllowed {
+		args = append(args, `--no-chown`)
+	}
+	args = append(args, "add")
+	args = append(args, strings.Fields(c.include)...)
+
+	// Do the install
+	sylog.Debugf("\n\tApk Path: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tMirrorURL: %s\n\tIncludes: %s\n\tKeys: %s\n", apkPath, apkArch, c.osversion, c.mirrorurl, c.include, c.keys)
+	cmd := exec.CommandContext(ctx, apkPath, args...)
+	if sylog.GetLevel() >= int(sylog.VerboseLevel) {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("while bootstrapping: %v", err)
+	}
+
+	// clean up bootstrap packages
+	os.RemoveAll(filepath.Join(c.b.RootfsPath, apkCacheDir))
+	os.MkdirAll(filepath.Join(c.b.RootfsPath, apkCacheDir), 0o755)
+
+	return nil
+}
+
+// Pack puts relevant objects in a Bundle!
+func (cp *ApkConveyorPacker) Pack(context.Context) (b *types.Bundle, err error) {
+	err = cp.insertBaseEnv()
+	if err != nil {
+		return nil, fmt.Errorf("while inserting base environment: %v", err)
+	}
+
+	err = cp.insertRunScript()
+	if err != nil {
+		return nil, fmt.Errorf("while inserting runscript: %v", err)
+	}
+
+	return cp.b, nil
+}
+
+func (c *ApkConveyor) getBootstrapOptions() (err error) {
+	var ok bool
+
+	// get mirrorURL, OSVerison, Keys and Includes components to definition
+	c.mirrorurl, ok = c.b.Recipe.Header["mirrorurl"]
+	if !ok {
+		return fmt.Errorf("invalid apk header, no mirrorurl specified")
+	}
+
+	// look for an OS version if the mirror specifies it
+	regex := regexp.MustCompile(`(?i)%{OSVERSION}`)
+	if regex.MatchString(c.mirrorurl) {
+		c.osversion, ok = c.b.Recipe.Header["osversion"]
+		if !ok {
+			return fmt.Errorf("invalid apk header, osversion referenced in mirror but no osversion specified")
+		}
+		c.mirrorurl = regex.ReplaceAllString(c.mirrorurl, c.osversion)
+	}
+	c.mirrorurl = strings.TrimSuffix(c.mirrorurl, "/")
+
+	c.keys = strings.Fields(c.b.Recipe.Header["keys"])
+
+	include := c.b.Recipe.Header["include"]
+
+	// check for include environment variable and add it to requires string
+	include += ` ` + os.Getenv("INCLUDE")
+
+	// trim leading and trailing whitespace
+	include = strings.TrimSpace(include)
+
+	// add alpine-base to start of include list by default
+	include = `alpine-base ` + include
+
+	c.include = include
+
+	return nil
+}
+
+func (c *ApkConveyor) genApkConfig() (err error) {
+	fileContent := ""
+	for _, repo := range apkRepos {
+		fileContent += c.mirrorurl + "/" + repo + "\n"
+	}
+
+	reposDir := filepath.Join(c.b.RootfsPath, filepath.Dir(apkReposFile))
+	err = os.MkdirAll(reposDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("while creating %v: %v", reposDir, err)
+	}
+
+	err = os.WriteFile(filepath.Join(c.b.RootfsPath, apkReposFile), []byte(fileContent), 0o644)
+	if err != nil {
+		return fmt.Errorf("while creating %v: %v", filepath.Join(c.b.RootfsPath, apkReposFile), err)
+	}
+
+	// if repository keys are specified, import them
+	if len(c.keys) > 0 {
+		err = c.importKeys()
+		if err != nil {
+			return fmt.Errorf("while importing repository keys: %v", err)
+		}
+	} else {
+		sylog.Warningf("No repository keys specified, skipping package signature verification")
+	}
+
+	return nil
+}
+
+func (c *ApkConveyor) importKeys() (err error) {
+	keysDir := filepath.Join(c.b.RootfsPath, apkKeysDir)
+	if err := os.MkdirAll(keysDir, 0o755); err != nil {
+		return fmt.Errorf("while creating %v: %v", keysDir, err)
+	}
+
+	for _, key := range c.keys {
+		var r io.ReadCloser
+
+		switch {
+		case strings.HasPrefix(key, "https://"):
+			resp, err := http.Get(key)
+			if err != nil {
+				return fmt.Errorf("while performing http request: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return fmt.Errorf("while fetching key %s: %s", key, resp.Status)
+			}
+			r = resp.Body
+		case filepath.IsAbs(key):
+			f, err := os.Open(key)
+			if err != nil {
+				return fmt.Errorf("while opening key %s: %v", key, err)
+			}
+			r = f
+		default:
+			// make sure keys are being imported over https
+			return fmt.Errorf("key %s must be fetched with https or be an absolute path", key)
+		}
+
+		// apk identifies a key by its file name, it must be preserved
+		keyPath := filepath.Join(keysDir, path.Base(key))
+		f, err := os.OpenFile(keyPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+		if err != nil {
+			r.Close()
+			return fmt.Errorf("while creating %s: %v", keyPath, err)
+		}
+		_, err = io.Copy(f, r)
+		r.Close()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("while writing %s: %v", keyPath, err)
+		}
+		sylog.Debugf("Imported repository key %s", keyPath)
+	}
+
+	sylog.Infof("Repository key import complete!")
+
+	return nil
+}
+
+// prepareFakerootEnv prepares a build environment to make fakeroot
+// working with apk by binding host devices as they can't be created
+func (c *ApkConveyor) prepareFakerootEnv() (func(), error) {
+	devs := []string{
+		"/dev/null",
+		"/dev/random",
+		"/dev/urandom",
+		"/dev/zero",
+	}
+
+	devPath := filepath.Join(c.b.RootfsPath, "dev")
+	if err := os.Mkdir(devPath, 0o755); err != nil {
+		return nil, fmt.Errorf("while creating %s: %s", devPath, err)
+	}
+
+	umountFn := func() {
+		for _, d := range devs {
+			path := filepath.Join(c.b.RootfsPath, d)
+			syscall.Unmount(path, syscall.MNT_DETACH)
+		}
+	}
+
+	// mount required block devices
+	for _, p := range devs {
+		rootfsPath := filepath.Join(c.b.RootfsPath, p)
+		if err := fs.Touch(rootfsPath); err != nil {
+			return umountFn, fmt.Errorf("while creating %s: %s", rootfsPath, err)
+		}
+		if err := syscall.Mount(p, rootfsPath, "", syscall.MS_BIND, ""); err != nil {
+			return umountFn, fmt.Errorf("while mounting %s to %s: %s", p, rootfsPath, err)
+		}
+	}
+
+	return umountFn, nil
+}
+
+//nolint:dupl
+func (c *ApkConveyor) makePseudoDevices() (err error) {
+	devPath := filepath.Join(c.b.RootfsPath, "dev")
+	err = os.Mkdir(devPath, 0o775)
+	if err != nil {
+		return fmt.Errorf("while creating %v: %v", devPath, err)
+	}
+
+	devs := []struct {
+		major int
+		minor int
+		path  string
+		mode  uint32
+	}{
+		{1, 3, "/dev/null", syscall.S_IFCHR | 0o666},
+		{1, 8, "/dev/random", syscall.S_IFCHR | 0o666},
+		{1, 9, "/dev/urandom", syscall.S_IFCHR | 0o666},
+		{1, 5, "/dev/zero", syscall.S_IFCHR | 0o666},
+	}
+
+	for _, dev := range devs {
+		d := int((dev.major << 8) | (dev.minor & 0xff) | ((dev.minor & 0xfff00) << 12))
+		path := filepath.Join(c.b.RootfsPath, dev.path)
+
+		if err := syscall.Mknod(path, dev.mode, d); err != nil {
+			return fmt.Errorf("while creating %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+func (cp *ApkConveyorPacker) insertBaseEnv() (err error) {
+	if err = makeBaseEnv(cp.b.RootfsPath, true); err != nil {
+		return
+	}
+	return nil
+}
+
+func (cp *ApkConveyorPacker) insertRunScript() (err error) {
+	err = os.WriteFile(filepath.Join(cp.b.RootfsPath, "/.singularity.d/runscript"), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		return
+	}
+
+	return nil
+}
+
+// CleanUp removes any tmpfs owned by the conveyorPacker on the filesystem
+func (c *ApkConveyor) CleanUp() {
+	c.b.Remove()
+}
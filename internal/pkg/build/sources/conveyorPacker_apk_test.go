@@ -0,0 +1,138 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apptainer/apptainer/internal/pkg/test"
+	"github.com/apptainer/apptainer/internal/pkg/test/tool/require"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/build/types/parser"
+)
+
+const apkDef = "../../../../examples/alpine/Apptainer"
+
+func TestApkBootstrapOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantErr    bool
+		wantMirror string
+		wantKeys   int
+	}{
+		{
+			name:    "NoMirror",
+			header:  map[string]string{"bootstrap": "apk"},
+			wantErr: true,
+		},
+		{
+			name: "MissingOSVersion",
+			header: map[string]string{
+				"bootstrap": "apk",
+				"mirrorurl": "https://mirror.example.com/alpine/%{OSVERSION}",
+			},
+			wantErr: true,
+		},
+		{
+			name: "OSVersion",
+			header: map[string]string{
+				"bootstrap": "apk",
+				"mirrorurl": "https://mirror.example.com/alpine/%{OSVERSION}/",
+				"osversion": "v3.20",
+			},
+			wantMirror: "https://mirror.example.com/alpine/v3.20",
+		},
+		{
+			name: "Keys",
+			header: map[string]string{
+				"bootstrap": "apk",
+				"mirrorurl": "https://mirror.example.com/alpine/edge",
+				"keys":      "https://mirror.example.com/a.rsa.pub /etc/apk/keys/b.rsa.pub",
+			},
+			wantMirror: "https://mirror.example.com/alpine/edge",
+			wantKeys:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ApkConveyor{
+				b: &types.Bundle{Recipe: types.Definition{Header: tt.header}},
+			}
+			err := c.getBootstrapOptions()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if c.mirrorurl != tt.wantMirror {
+				t.Errorf("got mirror %q, expected %q", c.mirrorurl, tt.wantMirror)
+			}
+			if len(c.keys) != tt.wantKeys {
+				t.Errorf("got %d keys, expected %d", len(c.keys), tt.wantKeys)
+			}
+			if !strings.HasPrefix(c.include, "alpine-base") {
+				t.Errorf("include %q doesn't start with alpine-base", c.include)
+			}
+		})
+	}
+}
+
+func TestApkConveyorPacker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	require.ArchIn(t, []string{"amd64", "arm64"})
+
+	_, staticErr := exec.LookPath("apk.static")
+	_, apkErr := exec.LookPath("apk")
+	if staticErr != nil && apkErr != nil {
+		t.Skip("skipping test, neither apk.static nor apk found")
+	}
+
+	test.EnsurePrivilege(t)
+
+	defFile, err := os.Open(apkDef)
+	if err != nil {
+		t.Fatalf("unable to open file %s: %v\n", apkDef, err)
+	}
+	defer defFile.Close()
+
+	// create bundle to build into
+	tmpDir := t.TempDir()
+	b, err := types.NewBundle(filepath.Join(tmpDir, "sbuild-apk"), tmpDir)
+	if err != nil {
+		return
+	}
+
+	b.Recipe, err = parser.ParseDefinitionFile(defFile)
+	if err != nil {
+		t.Fatalf("failed to parse definition file %s: %v\n", apkDef, err)
+	}
+
+	acp := &ApkConveyorPacker{}
+
+	err = acp.Get(t.Context(), b)
+	// clean up tmpfs since assembler isn't called
+	defer acp.CleanUp()
+	if err != nil {
+		t.Fatalf("failed to Get from %s: %v\n", apkDef, err)
+	}
+
+	_, err = acp.Pack(t.Context())
+	if err != nil {
+		t.Fatalf("failed to Pack from %s: %v\n", apkDef, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/util/bin"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	umocilayer "github.com/opencontainers/umoci/oci/layer"
+)
+
+// MmdebstrapConveyorPacker holds stuff that needs to be packed into the bundle
+type MmdebstrapConveyorPacker struct {
+	b          *types.Bundle
+	mirrorurl  string
+	osversion  string
+	suites     []string
+	components []string
+	include    []string
+	keyrings   []string
+}
+
+// Get downloads container information from the specified source
+func (cp *MmdebstrapConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err error) {
+	cp.b = b
+
+	// check for mmdebstrap on system
+	mmdebstrapPath, err := bin.FindBin("mmdebstrap")
+	if err != nil {
+		return fmt.Errorf("mmdebstrap is not in PATH... Perhaps 'apt-get install' it: %v", err)
+	}
+
+	if err = cp.getRecipeHeaderInfo(); err != nil {
+		return err
+	}
+
+	// Debian port arch values do not always match GOARCH values, so we need to look it up.
+	debArch, ok := debootstrapArchs[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("debian arch not known for GOARCH %s", runtime.GOARCH)
+	}
+
+	// as root, including root in a fakeroot user namespace where a nested
+	// unshare would fail, mmdebstrap can directly populate the rootfs.
+	// Otherwise the rootfs would be owned by subordinate IDs or by faked
+	// IDs, so mmdebstrap writes a tarball extracted as the caller instead
+	mode := mmdebstrapMode()
+	format := "directory"
+	target := cp.b.RootfsPath
+	if mode != "root" {
+		format = "tar"
+		target = filepath.Join(cp.b.TmpDir, "mmdebstrap.tar")
+		defer os.Remove(target)
+	}
+
+	args := cp.mmdebstrapArgs(mode, format, debArch, target)
+	cmd := exec.CommandContext(ctx, mmdebstrapPath, args...)
+	cmd.Env = os.Environ()
+	if !cp.b.SourceDateEpoch.IsZero() {
+		// mmdebstrap clamps timestamps and generates reproducible
+		// metadata when SOURCE_DATE_EPOCH is set
+		cmd.Env = append(cmd.Env, "SOURCE_DATE_EPOCH="+strconv.FormatInt(cp.b.SourceDateEpoch.Unix(), 10))
+	}
+	if sylog.GetLevel() >= int(sylog.VerboseLevel) {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+
+	sylog.Debugf("\n\tMmdebstrap Path: %s\n\tMode: %s\n\tIncludes: %s\n\tDetected Arch: %s\n\tOSVersion: %s\n\tSuites: %s\n\tComponents: %s\n\tMirrorURL: %s\n\tKeyrings: %s\n",
+		mmdebstrapPath, mode, cp.include, runtime.GOARCH, cp.osversion, cp.suites, cp.components, cp.mirrorurl, cp.keyrings)
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("while running mmdebstrap: %v", err)
+	}
+
+	if format == "tar" {
+		if err := extractRootfsTar(target, cp.b.RootfsPath); err != nil {
+			return fmt.Errorf("while extracting mmdebstrap tarball: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// mmdebstrapMode returns the mmdebstrap mode to use for the current user.
+// A root user maps all IDs unless it is the root user of a fakeroot user
+// namespace set up without subordinate IDs, mmdebstrap needs to fake
+// ownerships with fakechroot in that case.
+func mmdebstrapMode() string {
+	if os.Geteuid() != 0 {
+		return "unshare"
+	}
+	if size, err := uidMapSize(); err == nil && size == 1 {
+		return "fakechroot"
+	}
+	return "root"
+}
+
+// uidMapSize returns the number of user IDs mapped in the current
+// user namespace.
+func uidMapSize() (uint64, error) {
+	b, err := os.ReadFile("/proc/self/uid_map")
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, fmt.Errorf("malformed uid_map line %q", line)
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// extractRootfsTar extracts the tarball at path into the rootfs directory
+// with files owned by the caller.
+func extractRootfsTar(path, rootfs string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	unpackOptions := &umocilayer.UnpackOptions{
+		OnDiskFormat: umocilayer.DirRootfs{
+			MapOptions: umociMapOptions(),
+		},
+	}
+	return umocilayer.UnpackLayer(rootfs, f, unpackOptions)
+}
+
+// Pack puts relevant objects in a Bundle!
+func (cp *MmdebstrapConveyorPacker) Pack(context.Context) (*types.Bundle, error) {
+	// change root directory permissions to 0755
+	if err := os.Chmod(cp.b.RootfsPath, 0o755); err != nil {
+		return nil, fmt.Errorf("while changing bundle rootfs perms: %v", err)
+	}
+
+	err := cp.insertBaseEnv()
+	if err != nil {
+		return nil, fmt.Errorf("while inserting base environment: %v", err)
+	}
+
+	err = cp.insertRunScript()
+	if err != nil {
+		return nil, fmt.Errorf("while inserting runscript: %v", err)
+	}
+
+	return cp.b, nil
+}
+
+// mmdebstrapArgs returns the mmdebstrap command line arguments to bootstrap
+// the given target with the given mode, output format and debian architecture.
+func (cp *MmdebstrapConveyorPacker) mmdebstrapArgs(mode, format, debArch, target string) []string {
+	args := []string{
+		`--mode=` + mode,
+		`--format=` + format,
+		`--variant=minbase`,
+		`--architectures=` + debArch,
+		`--include=` + strings.Join(append([]string{"apt"}, cp.include...), ","),
+	}
+	if len(cp.components) > 0 {
+		args = append(args, `--components=`+strings.Join(cp.components, ","))
+	}
+	for _, k := range cp.keyrings {
+		args = append(args, `--keyring=`+k)
+	}
+
+	args = append(args, cp.osversion, target, cp.mirrorurl)
+
+	// additional suites are fetched from the same mirror, they are
+	// passed as one-line sources.list entries
+	components := "main"
+	if len(cp.components) > 0 {
+		components = strings.Join(cp.components, " ")
+	}
+	for _, s := range cp.suites {
+		args = append(args, fmt.Sprintf("deb %s %s %s", cp.mirrorurl, s, components))
+	}
+
+	return args
+}
+
+func (cp *MmdebstrapConveyorPacker) getRecipeHeaderInfo() (err error) {
+	var ok bool
+
+	// get mirrorURL, OSVerison, Suites, Components, Keyring and Includes components to definition
+	cp.mirrorurl, ok = cp.b.Recipe.Header["mirrorurl"]
+	if !ok {
+		return fmt.Errorf("invalid mmdebstrap header, no mirrorurl specified")
+	}
+
+	cp.osversion, ok = cp.b.Recipe.Header["osversion"]
+	if !ok {
+		return fmt.Errorf("invalid mmdebstrap header, no osversion specified")
+	}
+
+	cp.suites = splitHeaderList(cp.b.Recipe.Header["suites"])
+	cp.components = splitHeaderList(cp.b.Recipe.Header["components"])
+
+	cp.keyrings = splitHeaderList(cp.b.Recipe.Header["keyring"])
+	for _, k := range cp.keyrings {
+		if !filepath.IsAbs(k) {
+			return fmt.Errorf("invalid mmdebstrap header, keyring %s must be an absolute path", k)
+		}
+	}
+
+	// check for include environment variable and add it to requires string
+	cp.include = splitHeaderList(cp.b.Recipe.Header["include"] + ` ` + os.Getenv("INCLUDE"))
+
+	return nil
+}
+
+// splitHeaderList splits a header value holding a list separated
+// by whitespaces and/or commas.
+func splitHeaderList(val string) []string {
+	return strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+func (cp *MmdebstrapConveyorPacker) insertBaseEnv() (err error) {
+	if err = makeBaseEnv(cp.b.RootfsPath, true); err != nil {
+		return
+	}
+	return nil
+}
+
+func (cp *MmdebstrapConveyorPacker) insertRunScript() (err error) {
+	err = os.WriteFile(filepath.Join(cp.b.RootfsPath, "/.singularity.d/runscript"), []byte("#!/bin/sh\n"), 0o755)
+	if err != nil {
+		return
+	}
+
+	return nil
+}
+
+// CleanUp removes any tmpfs owned by the conveyorPacker on the filesystem
+func (cp *MmdebstrapConveyorPacker) CleanUp() {
+	cp.b.Remove()
+}
@@ -0,0 +1,195 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/apptainer/apptainer/internal/pkg/fakeroot"
+	"github.com/apptainer/apptainer/internal/pkg/test"
+	"github.com/apptainer/apptainer/internal/pkg/test/tool/require"
+	"github.com/apptainer/apptainer/pkg/build/types"
+	"github.com/apptainer/apptainer/pkg/build/types/parser"
+)
+
+const mmdebstrapDef = "../../../../examples/debian/Mmdebstrap"
+
+func TestMmdebstrapArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   map[string]string
+		mode     string
+		format   string
+		wantErr  bool
+		wantArgs []string
+	}{
+		{
+			name:    "NoMirror",
+			header:  map[string]string{"osversion": "bookworm"},
+			wantErr: true,
+		},
+		{
+			name:    "NoOSVersion",
+			header:  map[string]string{"mirrorurl": "http://deb.debian.org/debian"},
+			wantErr: true,
+		},
+		{
+			name: "RelativeKeyring",
+			header: map[string]string{
+				"mirrorurl": "http://deb.debian.org/debian",
+				"osversion": "bookworm",
+				"keyring":   "debian.gpg",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Minimal",
+			header: map[string]string{
+				"mirrorurl": "http://deb.debian.org/debian",
+				"osversion": "bookworm",
+			},
+			mode:   "root",
+			format: "directory",
+			wantArgs: []string{
+				"--mode=root",
+				"--format=directory",
+				"--variant=minbase",
+				"--architectures=amd64",
+				"--include=apt",
+				"bookworm",
+				"/rootfs",
+				"http://deb.debian.org/debian",
+			},
+		},
+		{
+			name: "Full",
+			header: map[string]string{
+				"mirrorurl":  "http://deb.debian.org/debian",
+				"osversion":  "bookworm",
+				"suites":     "bookworm-updates, bookworm-backports",
+				"components": "main contrib",
+				"keyring":    "/usr/share/keyrings/debian-archive-keyring.gpg",
+				"include":    "vim,less",
+			},
+			mode:   "unshare",
+			format: "tar",
+			wantArgs: []string{
+				"--mode=unshare",
+				"--format=tar",
+				"--variant=minbase",
+				"--architectures=amd64",
+				"--include=apt,vim,less",
+				"--components=main,contrib",
+				"--keyring=/usr/share/keyrings/debian-archive-keyring.gpg",
+				"bookworm",
+				"/tmp/rootfs.tar",
+				"http://deb.debian.org/debian",
+				"deb http://deb.debian.org/debian bookworm-updates main contrib",
+				"deb http://deb.debian.org/debian bookworm-backports main contrib",
+			},
+		},
+	}
+
+	t.Setenv("INCLUDE", "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := &MmdebstrapConveyorPacker{
+				b: &types.Bundle{
+					Recipe:     types.Definition{Header: tt.header},
+					RootfsPath: "/rootfs",
+				},
+			}
+			err := cp.getRecipeHeaderInfo()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			target := "/rootfs"
+			if tt.format == "tar" {
+				target = "/tmp/rootfs.tar"
+			}
+			args := cp.mmdebstrapArgs(tt.mode, tt.format, "amd64", target)
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %q, expected %q", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMmdebstrapConveyorPacker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	require.ArchIn(t, []string{"amd64", "arm64"})
+
+	if _, err := exec.LookPath("mmdebstrap"); err != nil {
+		t.Skip("skipping test, mmdebstrap not installed")
+	}
+
+	t.Run("Privileged", test.WithPrivilege(testMmdebstrapConveyorPacker))
+	// unshare mode requires subordinate IDs for the unprivileged user
+	t.Run("Unprivileged", test.WithoutPrivilege(func(t *testing.T) {
+		require.UserNamespace(t)
+		if !fakeroot.IsUIDMapped(uint32(os.Getuid())) {
+			t.Skipf("skipping test, no subordinate user IDs for user %d", os.Getuid())
+		}
+		testMmdebstrapConveyorPacker(t)
+	}))
+}
+
+func testMmdebstrapConveyorPacker(t *testing.T) {
+	defFile, err := os.Open(mmdebstrapDef)
+	if err != nil {
+		t.Fatalf("unable to open file %s: %v\n", mmdebstrapDef, err)
+	}
+	defer defFile.Close()
+
+	// create bundle to build into
+	tmpDir := t.TempDir()
+	b, err := types.NewBundle(filepath.Join(tmpDir, "sbuild-mmdebstrap"), tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create bundle: %v", err)
+	}
+
+	b.Recipe, err = parser.ParseDefinitionFile(defFile)
+	if err != nil {
+		t.Fatalf("failed to parse definition file %s: %v\n", mmdebstrapDef, err)
+	}
+
+	cp := &MmdebstrapConveyorPacker{}
+
+	err = cp.Get(t.Context(), b)
+	// clean up tmpfs since assembler isn't called
+	defer cp.CleanUp()
+	if err != nil {
+		t.Fatalf("failed to Get from %s: %v\n", mmdebstrapDef, err)
+	}
+
+	_, err = cp.Pack(t.Context())
+	if err != nil {
+		t.Fatalf("failed to Pack from %s: %v\n", mmdebstrapDef, err)
+	}
+
+	// the rootfs content must be owned by the caller
+	fi, err := os.Stat(filepath.Join(b.RootfsPath, "etc", "os-release"))
+	if err != nil {
+		t.Fatalf("while checking rootfs: %v", err)
+	}
+	if uid := fi.Sys().(*syscall.Stat_t).Uid; int(uid) != os.Getuid() {
+		t.Errorf("rootfs content owned by %d instead of %d", uid, os.Getuid())
+	}
+}
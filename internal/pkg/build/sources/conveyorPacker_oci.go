@@ -253,6 +253,30 @@ func (cp *OCIConveyorPacker) Pack(ctx context.Context) (*sytypes.Bundle, error)
 	return cp.b, nil
 }
 
+// InsertOCIMetadata creates in rootfs the base environment, runscript,
+// environment and labels derived from the OCI image config cfg, as done
+// when packing an OCI image.
+func InsertOCIMetadata(rootfs string, cfg v1.Config) error {
+	cp := &OCIConveyorPacker{
+		b:         &sytypes.Bundle{RootfsPath: rootfs},
+		imgConfig: cfg,
+	}
+
+	if err := makeBaseEnv(rootfs, true); err != nil {
+		return fmt.Errorf("while inserting base environment: %v", err)
+	}
+	if err := cp.insertRunScript(); err != nil {
+		return fmt.Errorf("while inserting runscript: %v", err)
+	}
+	if err := cp.insertEnv(); err != nil {
+		return fmt.Errorf("while inserting docker specific environment: %v", err)
+	}
+	if err := cp.insertOCILabels(); err != nil {
+		return fmt.Errorf("while inserting oci labels: %v", err)
+	}
+	return nil
+}
+
 func (cp *OCIConveyorPacker) insertOCIConfig() error {
 	conf, err := json.Marshal(cp.imgConfig)
 	if err != nil {
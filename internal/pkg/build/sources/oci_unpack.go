@@ -59,8 +59,19 @@ func UnpackRootfs(_ context.Context, srcImage v1.Image, destDir string) (err err
 		return fmt.Errorf("no extractable OCI/Docker tar layers found in this image")
 	}
 
-	var mapOptions umocilayer.MapOptions
+	mapOptions := umociMapOptions()
 
+	for _, l := range layers {
+		if err := extractLayer(l, mapOptions, destDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// umociMapOptions sets the umoci log level from the apptainer one and
+// returns the umoci mapping options to extract a rootfs as the caller.
+func umociMapOptions() (mapOptions umocilayer.MapOptions) {
 	loggerLevel := sylog.GetLevel()
 
 	// set the apex log level, for umoci
@@ -105,13 +116,7 @@ func UnpackRootfs(_ context.Context, srcImage v1.Image, destDir string) (err err
 		}
 		mapOptions.GIDMappings = append(mapOptions.GIDMappings, gidMap)
 	}
-
-	for _, l := range layers {
-		if err := extractLayer(l, mapOptions, destDir); err != nil {
-			return err
-		}
-	}
-	return nil
+	return mapOptions
 }
 
 func extractLayer(l v1.Layer, mapOptions umocilayer.MapOptions, destDir string) error {
@@ -20,6 +20,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/build/files"
 	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
 	"github.com/apptainer/apptainer/internal/pkg/fakeroot"
+	"github.com/apptainer/apptainer/internal/pkg/util/machine"
 	"github.com/apptainer/apptainer/pkg/build/types"
 	"github.com/apptainer/apptainer/pkg/sylog"
 )
@@ -34,6 +35,8 @@ type stage struct {
 	a Assembler
 	// b is an intermediate structure that encapsulates all information for the container, e.g., metadata, filesystems.
 	b *types.Bundle
+	// emulator runs scriptlets when the container architecture is not native.
+	emulator *machine.Emulator
 }
 
 const (
@@ -109,6 +112,14 @@ func (s *stage) runPostScript(sessionResolv, sessionHosts string) error {
 			}
 			cmdArgs = append(cmdArgs, "-B", strings.Join(fakerootBinds[:], ","))
 		}
+		emulationBinds := s.emulationBinds()
+		if len(emulationBinds) > 0 {
+			if err := s.makeFakerootBindpoints(emulationBinds); err != nil {
+				return fmt.Errorf("while creating emulator bindpoint: %v", err)
+			}
+			defer s.cleanFakerootBindpoints(emulationBinds)
+			cmdArgs = append(cmdArgs, "-B", strings.Join(emulationBinds, ","))
+		}
 		if len(s.b.Opts.Binds) != 0 {
 			for _, bind := range s.b.Opts.Binds {
 				cmdArgs = append(cmdArgs, "-B", bind)
@@ -160,6 +171,14 @@ func (s *stage) runTestScript(sessionResolv, sessionHosts string) error {
 		if sessionHosts != "" {
 			cmdArgs = append(cmdArgs, "-B", sessionHosts+":/etc/hosts")
 		}
+		emulationBinds := s.emulationBinds()
+		if len(emulationBinds) > 0 {
+			if err := s.makeFakerootBindpoints(emulationBinds); err != nil {
+				return fmt.Errorf("while creating emulator bindpoint: %v", err)
+			}
+			defer s.cleanFakerootBindpoints(emulationBinds)
+			cmdArgs = append(cmdArgs, "-B", strings.Join(emulationBinds, ","))
+		}
 		if len(s.b.Opts.Binds) != 0 {
 			for _, bind := range s.b.Opts.Binds {
 				cmdArgs = append(cmdArgs, "-B", bind)
@@ -53,6 +53,9 @@ const (
 	IpfsCacheType = "ipfs"
 	// NetCacheType specifies the cache holds images pulled from http(s) internet sources
 	NetCacheType = "net"
+	// OciLazyCacheType specifies the cache holds lazily pulled OCI images
+	// descriptors, layers and layer chunks fetched on demand
+	OciLazyCacheType = "oci-lazy"
 )
 
 var (
@@ -64,6 +67,7 @@ var (
 		OrasCacheType,
 		IpfsCacheType,
 		NetCacheType,
+		OciLazyCacheType,
 	}
 	// OciCacheTypes specifies the OCI cache types.
 	OciCacheTypes = []string{
@@ -0,0 +1,89 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/util/fs"
+)
+
+// Chunks is a cache of fixed size chunks of remote blobs. It's used by
+// lazily pulled images to keep the byte ranges already fetched from a
+// registry. Chunks are files named after the blob digest and chunk index
+// stored in the OciLazyCacheType directory.
+type Chunks struct {
+	dir string
+}
+
+// Chunks returns the chunk cache, or nil if the cache is disabled.
+func (h *Handle) Chunks() (*Chunks, error) {
+	if h.disabled {
+		return nil, nil
+	}
+	dir, err := h.GetFileCacheDir(OciLazyCacheType)
+	if err != nil {
+		return nil, err
+	}
+	return &Chunks{dir: dir}, nil
+}
+
+// OpenChunks returns the chunk cache stored in the directory dir, as
+// returned by Dir. It's intended for processes without a cache handle.
+func OpenChunks(dir string) (*Chunks, error) {
+	if !fs.IsDir(dir) {
+		return nil, fmt.Errorf("chunk cache directory %s doesn't exist", dir)
+	}
+	return &Chunks{dir: dir}, nil
+}
+
+// Dir returns the chunk cache directory.
+func (c *Chunks) Dir() string {
+	return c.dir
+}
+
+func (c *Chunks) path(blob string, index int64) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s.%d", strings.ReplaceAll(blob, ":", "-"), index))
+}
+
+// Get returns the content of the chunk index of blob, ok is false
+// if the chunk is not cached.
+func (c *Chunks) Get(blob string, index int64) (data []byte, ok bool) {
+	data, err := os.ReadFile(c.path(blob, index))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put stores the content of the chunk index of blob. Concurrent writers
+// of the same chunk are safe, the chunk is renamed atomically in place.
+func (c *Chunks) Put(blob string, index int64, data []byte) error {
+	f, err := fs.MakeTmpFile(c.dir, "tmp_", 0o600)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("while writing chunk: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path(blob, index)); err != nil {
+		return fmt.Errorf("could not finalize cached chunk: %v", err)
+	}
+	return nil
+}
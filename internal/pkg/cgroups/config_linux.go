@@ -10,6 +10,7 @@
 package cgroups
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -241,3 +242,61 @@ func LoadResources(path string) (spec specs.LinuxResources, err error) {
 
 	return
 }
+
+// MergeResources returns the resources resulting from the update of
+// resources by update. Values set in update replace the values in
+// resources, values not set in update are kept from resources. Lists,
+// like devices rules, are replaced as a whole.
+func MergeResources(resources, update *specs.LinuxResources) (*specs.LinuxResources, error) {
+	if resources == nil {
+		resources = &specs.LinuxResources{}
+	}
+	if update == nil {
+		return resources, nil
+	}
+
+	toMap := func(r *specs.LinuxResources) (map[string]any, error) {
+		m := make(map[string]any)
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		// keep numbers as is, large limits don't fit in a float64
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		return m, dec.Decode(&m)
+	}
+
+	dst, err := toMap(resources)
+	if err != nil {
+		return nil, err
+	}
+	src, err := toMap(update)
+	if err != nil {
+		return nil, err
+	}
+	mergeMaps(dst, src)
+
+	data, err := json.Marshal(dst)
+	if err != nil {
+		return nil, err
+	}
+	merged := &specs.LinuxResources{}
+	if err := json.Unmarshal(data, merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeMaps recursively merges the JSON objects src into dst.
+func mergeMaps(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cgroups
+
+import (
+	"math"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestMergeResources(t *testing.T) {
+	limit := int64(1 << 30)
+	reservation := int64(1 << 29)
+	newLimit := int64(8 << 30)
+	quota := int64(200000)
+	period := uint64(100000)
+	shares := uint64(math.MaxUint64)
+	pids := int64(500)
+
+	resources := &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{
+			Limit:       &limit,
+			Reservation: &reservation,
+		},
+		CPU: &specs.LinuxCPU{
+			Shares: &shares,
+		},
+		Devices: []specs.LinuxDeviceCgroup{
+			{Allow: false, Access: "rwm"},
+		},
+	}
+	update := &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{
+			Limit: &newLimit,
+		},
+		CPU: &specs.LinuxCPU{
+			Quota:  &quota,
+			Period: &period,
+		},
+		Pids: &specs.LinuxPids{
+			Limit: &pids,
+		},
+		Devices: []specs.LinuxDeviceCgroup{
+			{Allow: true, Access: "r"},
+		},
+	}
+
+	merged, err := MergeResources(resources, update)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if merged.Memory == nil || merged.Memory.Limit == nil || *merged.Memory.Limit != newLimit {
+		t.Errorf("memory limit not updated: %+v", merged.Memory)
+	}
+	if merged.Memory.Reservation == nil || *merged.Memory.Reservation != reservation {
+		t.Errorf("memory reservation not kept: %+v", merged.Memory)
+	}
+	if merged.CPU == nil || merged.CPU.Shares == nil || *merged.CPU.Shares != shares {
+		t.Errorf("cpu shares not kept: %+v", merged.CPU)
+	}
+	if merged.CPU.Quota == nil || *merged.CPU.Quota != quota || merged.CPU.Period == nil || *merged.CPU.Period != period {
+		t.Errorf("cpu quota not updated: %+v", merged.CPU)
+	}
+	if merged.Pids == nil || merged.Pids.Limit == nil || *merged.Pids.Limit != pids {
+		t.Errorf("pids limit not updated: %+v", merged.Pids)
+	}
+	if len(merged.Devices) != 1 || !merged.Devices[0].Allow {
+		t.Errorf("devices not replaced: %+v", merged.Devices)
+	}
+
+	// the original resources are left untouched
+	if *resources.Memory.Limit != limit || resources.CPU.Quota != nil {
+		t.Errorf("original resources modified")
+	}
+
+	merged, err = MergeResources(nil, update)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if merged.Memory == nil || *merged.Memory.Limit != newLimit {
+		t.Errorf("memory limit not set: %+v", merged.Memory)
+	}
+}
@@ -14,12 +14,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/apptainer/apptainer/internal/pkg/util/env"
 	"github.com/apptainer/apptainer/pkg/sylog"
 	lccgroups "github.com/opencontainers/cgroups"
+	"github.com/opencontainers/cgroups/fscommon"
 	lcmanager "github.com/opencontainers/cgroups/manager"
 	lcsystemd "github.com/opencontainers/cgroups/systemd"
 	lcspecconv "github.com/opencontainers/runc/libcontainer/specconv"
@@ -121,6 +124,70 @@ func (m *Manager) GetStats() (*lccgroups.Stats, error) {
 	return stats, nil
 }
 
+// OOMKillCount returns the number of processes of the managed cgroup killed
+// by the OOM killer.
+func (m *Manager) OOMKillCount() (uint64, error) {
+	if m.group == "" || m.cgroup == nil {
+		return 0, ErrUninitialized
+	}
+	return m.cgroup.OOMKillCount()
+}
+
+// PidsPeak returns the maximum number of processes reached in the managed
+// cgroup. It is only available with v2 cgroups on recent kernels.
+func (m *Manager) PidsPeak() (uint64, error) {
+	if m.group == "" || m.cgroup == nil {
+		return 0, ErrUninitialized
+	}
+	return fscommon.GetCgroupParamUint(m.cgroup.Path("pids"), "pids.peak")
+}
+
+// GetEvents returns the events counters of the managed cgroup, keyed by
+// <controller>.<event>, from the memory.events and pids.events files with
+// cgroups v2. With cgroups v1 only the memory.oom_kill counter is returned.
+func (m *Manager) GetEvents() (map[string]uint64, error) {
+	if m.group == "" || m.cgroup == nil {
+		return nil, ErrUninitialized
+	}
+
+	events := make(map[string]uint64)
+	if !lccgroups.IsCgroup2UnifiedMode() {
+		oomKills, err := m.cgroup.OOMKillCount()
+		if err != nil {
+			return nil, err
+		}
+		events["memory.oom_kill"] = oomKills
+		return events, nil
+	}
+
+	for _, controller := range []string{"memory", "pids"} {
+		data, err := os.ReadFile(filepath.Join(m.cgroup.Path(""), controller+".events"))
+		if errors.Is(err, os.ErrNotExist) {
+			// controller is not enabled for the cgroup
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			key, value, err := fscommon.ParseKeyValue(line)
+			if err != nil {
+				return nil, fmt.Errorf("while parsing %s.events: %w", controller, err)
+			}
+			events[controller+"."+key] = value
+		}
+	}
+	return events, nil
+}
+
+// GetPids returns the PIDs of all processes in the managed cgroup, and its
+// sub-cgroups.
+func (m *Manager) GetPids() ([]int, error) {
+	if m.group == "" || m.cgroup == nil {
+		return nil, ErrUninitialized
+	}
+	return m.cgroup.GetAllPids()
+}
+
 // UpdateFromSpec updates the existing managed cgroup using configuration from
 // an OCI LinuxResources spec struct.
 func (m *Manager) UpdateFromSpec(resources *specs.LinuxResources) (err error) {
@@ -128,6 +195,10 @@ func (m *Manager) UpdateFromSpec(resources *specs.LinuxResources) (err error) {
 		return ErrUninitialized
 	}
 
+	if err := m.checkUnified(resources.Unified); err != nil {
+		return err
+	}
+
 	spec := &specs.Spec{
 		Linux: &specs.Linux{
 			CgroupsPath: m.group,
@@ -165,6 +236,64 @@ func (m *Manager) UpdateFromSpec(resources *specs.LinuxResources) (err error) {
 	return nil
 }
 
+// checkUnified verifies that cgroups v2 unified resources only use the
+// controllers enabled for the managed cgroup, i.e. delegated by its parent.
+func (m *Manager) checkUnified(unified map[string]string) error {
+	if len(unified) == 0 {
+		return nil
+	}
+	if !lccgroups.IsCgroup2UnifiedMode() {
+		return fmt.Errorf("unified cgroup settings require cgroups v2")
+	}
+
+	path := filepath.Join(m.cgroup.Path(""), "cgroup.controllers")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("while reading enabled cgroup controllers: %w", err)
+	}
+	return checkUnifiedControllers(strings.Fields(string(data)), unified)
+}
+
+// checkUnifiedControllers verifies that unified resources are valid cgroups
+// v2 interface files of the enabled controllers, or of the cgroup core.
+func checkUnifiedControllers(enabled []string, unified map[string]string) error {
+	keys := make([]string, 0, len(unified))
+	for k := range unified {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		controller, file, ok := strings.Cut(k, ".")
+		if !ok || controller == "" || file == "" || strings.Contains(k, "/") {
+			return fmt.Errorf("invalid cgroup setting %q, must be in <controller>.<file> format", k)
+		}
+		if controller == "cgroup" || slices.Contains(enabled, controller) {
+			continue
+		}
+		return fmt.Errorf("cgroup setting %s requires the %s controller, which is not delegated to the container cgroup (enabled controllers: %s)",
+			k, controller, strings.Join(enabled, " "))
+	}
+	return nil
+}
+
+// GetUnified returns the current values of the cgroups v2 interface files
+// keys of the managed cgroup.
+func (m *Manager) GetUnified(keys []string) (map[string]string, error) {
+	if m.group == "" || m.cgroup == nil {
+		return nil, ErrUninitialized
+	}
+	values := make(map[string]string, len(keys))
+	for _, k := range keys {
+		data, err := os.ReadFile(filepath.Join(m.cgroup.Path(""), filepath.Base(k)))
+		if err != nil {
+			return nil, fmt.Errorf("while reading cgroup setting %s: %w", k, err)
+		}
+		values[k] = strings.TrimSpace(string(data))
+	}
+	return values, nil
+}
+
 // UpdateFromFile updates the existing managed cgroup using configuration
 // from a toml file.
 func (m *Manager) UpdateFromFile(path string) error {
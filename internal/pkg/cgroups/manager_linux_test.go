@@ -48,6 +48,51 @@ func TestCgroups(t *testing.T) {
 	runSystemdTests(t, tests)
 }
 
+func TestCheckUnifiedControllers(t *testing.T) {
+	enabled := []string{"cpu", "io", "memory", "pids"}
+	tests := []struct {
+		name    string
+		unified map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "Empty",
+			unified: map[string]string{},
+		},
+		{
+			name:    "Enabled",
+			unified: map[string]string{"memory.high": "1073741824", "io.max": "8:0 rbps=1048576"},
+		},
+		{
+			name:    "Core",
+			unified: map[string]string{"cgroup.max.depth": "2"},
+		},
+		{
+			name:    "NotEnabled",
+			unified: map[string]string{"memory.high": "max", "hugetlb.2MB.max": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "NoController",
+			unified: map[string]string{"high": "max"},
+			wantErr: true,
+		},
+		{
+			name:    "Path",
+			unified: map[string]string{"memory.high/../x": "max"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkUnifiedControllers(enabled, tt.unified)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func runCgroupfsTests(t *testing.T, tests CgroupTests) {
 	t.Run("cgroupfs", func(t *testing.T) {
 		for _, tt := range tests {
@@ -0,0 +1,173 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/klauspost/compress/zstd"
+	"github.com/moby/go-archive"
+)
+
+const (
+	sifArchiveName  = "checkpoint.tar.zst"
+	sifManifestName = "checkpoint.manifest"
+)
+
+// Export writes the checkpoint directory dir to the file out, as a zstd
+// compressed tar archive, or as a SIF image holding the archive and the
+// checkpoint manifest as data objects when out has a .sif extension.
+// Paths of the checkpoint directory matching excludes are not exported.
+func Export(dir, out string, excludes []string) error {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return fmt.Errorf("while reading checkpoint manifest: %s", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		return fmt.Errorf("%s already exists", out)
+	}
+
+	switch {
+	case strings.HasSuffix(out, ".sif"):
+		err = exportSIF(dir, out, excludes, m)
+	case strings.HasSuffix(out, ".tar.zst"), strings.HasSuffix(out, ".tzst"):
+		err = exportArchive(dir, out, excludes)
+	default:
+		return fmt.Errorf("unsupported checkpoint export format for %s, must be .tar.zst or .sif", out)
+	}
+	if err != nil {
+		os.Remove(out)
+	}
+	return err
+}
+
+// writeArchive writes the checkpoint directory dir to w as a zstd
+// compressed tar archive.
+func writeArchive(w io.Writer, dir string, excludes []string) error {
+	rc, err := archive.TarWithOptions(dir, &archive.TarOptions{
+		ExcludePatterns: excludes,
+	})
+	if err != nil {
+		return fmt.Errorf("while archiving %s: %s", dir, err)
+	}
+	defer rc.Close()
+
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, rc); err != nil {
+		zw.Close()
+		return fmt.Errorf("while archiving %s: %s", dir, err)
+	}
+	return zw.Close()
+}
+
+func exportArchive(dir, out string, excludes []string) error {
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := writeArchive(f, dir, excludes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func exportSIF(dir, out string, excludes []string, m *Manifest) error {
+	manifest, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	manifestInput, err := sif.NewDescriptorInput(sif.DataGenericJSON, bytes.NewReader(manifest),
+		sif.OptObjectName(sifManifestName),
+	)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, dir, excludes))
+	}()
+
+	archiveInput, err := sif.NewDescriptorInput(sif.DataGeneric, pr,
+		sif.OptObjectName(sifArchiveName),
+	)
+	if err != nil {
+		return err
+	}
+
+	f, err := sif.CreateContainerAtPath(out,
+		sif.OptCreateWithDescriptors(manifestInput, archiveInput),
+	)
+	if err != nil {
+		return fmt.Errorf("while creating sif file: %w", err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		return fmt.Errorf("while unloading sif file: %w", err)
+	}
+	return nil
+}
+
+// openArchive returns a reader of the checkpoint archive held by the SIF
+// image or the compressed tar archive in.
+func openArchive(in string) (io.Reader, func() error, error) {
+	fimg, err := sif.LoadContainerFromPath(in, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f.Close, nil
+	}
+
+	descs, err := fimg.GetDescriptors(sif.WithDataType(sif.DataGeneric))
+	if err == nil {
+		for _, d := range descs {
+			if d.Name() == sifArchiveName {
+				return d.GetReader(), fimg.UnloadContainer, nil
+			}
+		}
+	}
+	fimg.UnloadContainer()
+	return nil, nil, fmt.Errorf("%s doesn't contain a checkpoint", in)
+}
+
+// Import extracts the checkpoint exported to in into the directory dir,
+// and returns its manifest.
+func Import(in, dir string) (*Manifest, error) {
+	r, closeArchive, err := openArchive(in)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	// compression is detected by Untar
+	err = archive.Untar(r, dir, &archive.TarOptions{
+		NoLchown: os.Geteuid() != 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while extracting checkpoint: %s", err)
+	}
+
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid checkpoint: %s", in, err)
+	}
+	return m, nil
+}
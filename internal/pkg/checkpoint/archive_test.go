@@ -0,0 +1,154 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package checkpoint
+
+import (
+	"bytes"
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+// createSIF creates a SIF image at path with a data object holding
+// content.
+// createSIF creates a deterministic SIF image at path holding content,
+// images only differ by their content.
+func createSIF(t *testing.T, path, content string) {
+	di, err := sif.NewDescriptorInput(sif.DataGeneric, bytes.NewReader([]byte(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := sif.CreateContainerAtPath(path, sif.OptCreateWithDescriptors(di), sif.OptCreateDeterministic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "image.sif")
+	createSIF(t, image, "image content")
+
+	dir := t.TempDir()
+	m, err := NewManifest(CRIUBackend, "test", image)
+	if err != nil {
+		t.Fatalf("unexpected error while creating manifest: %s", err)
+	}
+	if m.ImageDigest == "" {
+		t.Fatalf("no image digest recorded in manifest")
+	}
+	if err := WriteManifest(dir, m); err != nil {
+		t.Fatalf("unexpected error while writing manifest: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "images"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images", "core.img"), []byte("core"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "overlay"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, out := range []string{"test.tar.zst", "test.sif"} {
+		t.Run(out, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), out)
+			if err := Export(dir, out, []string{"overlay"}); err != nil {
+				t.Fatalf("unexpected error while exporting: %s", err)
+			}
+			if err := Export(dir, out, nil); err == nil {
+				t.Errorf("unexpected success while overwriting %s", out)
+			}
+
+			importDir := t.TempDir()
+			im, err := Import(out, importDir)
+			if err != nil {
+				t.Fatalf("unexpected error while importing: %s", err)
+			}
+			if im.Backend != CRIUBackend || im.Name != "test" || im.ImageDigest != m.ImageDigest {
+				t.Errorf("unexpected imported manifest %+v", im)
+			}
+			b, err := os.ReadFile(filepath.Join(importDir, "images", "core.img"))
+			if err != nil || string(b) != "core" {
+				t.Errorf("checkpoint image not imported: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(importDir, "overlay")); !os.IsNotExist(err) {
+				t.Errorf("excluded path imported")
+			}
+		})
+	}
+
+	if err := Export(dir, filepath.Join(t.TempDir(), "test.tar"), nil); err == nil {
+		t.Errorf("unexpected success with unsupported format")
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	tmpDir := t.TempDir()
+	image := filepath.Join(tmpDir, "image.sif")
+	other := filepath.Join(tmpDir, "other.sif")
+	// same size content, images only differ by their data
+	createSIF(t, image, "image content")
+	createSIF(t, other, "other content")
+
+	dir := t.TempDir()
+	// no manifest, image can't be verified
+	if err := CheckImage(dir, other); err != nil {
+		t.Errorf("unexpected error without manifest: %s", err)
+	}
+
+	m, err := NewManifest(DMTCPBackend, "test", image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteManifest(dir, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckImage(dir, image); err != nil {
+		t.Errorf("unexpected error with the checkpoint image: %s", err)
+	}
+	if err := CheckImage(dir, other); err == nil {
+		t.Errorf("unexpected success with another image")
+	}
+
+	// signing the image doesn't change its digest
+	f, err := sif.LoadContainerFromPath(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	di, err := sif.NewDescriptorInput(sif.DataSignature, bytes.NewReader([]byte("signature")),
+		sif.OptSignatureMetadata(crypto.SHA256, make([]byte, 20)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddObject(di); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckImage(dir, image); err != nil {
+		t.Errorf("unexpected error with the signed checkpoint image: %s", err)
+	}
+
+	// images which are not SIF images can't be verified
+	raw := filepath.Join(tmpDir, "image.img")
+	if err := os.WriteFile(raw, []byte("image content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := ImageDigest(raw); err != nil || d != "" {
+		t.Errorf("got digest %q, %v for a raw image, want none", d, err)
+	}
+}
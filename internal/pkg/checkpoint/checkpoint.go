@@ -9,9 +9,15 @@
 package checkpoint
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
+	"github.com/apptainer/apptainer/internal/pkg/util/paths"
 	"github.com/apptainer/apptainer/pkg/syfs"
+	"go.yaml.in/yaml/v4"
 )
 
 const (
@@ -21,3 +27,120 @@ const (
 func StatePath() string {
 	return filepath.Join(syfs.ConfigDir(), checkpointStatePath)
 }
+
+// Manager manages the checkpoint directories of a backend, stored in the
+// backend directory of StatePath.
+type Manager struct {
+	dir string
+	// subdirs are created in new checkpoint directories
+	subdirs []string
+}
+
+// NewManager returns the manager of the checkpoints of backend, with
+// subdirs created in new checkpoint directories.
+func NewManager(backend string, subdirs ...string) *Manager {
+	return &Manager{
+		dir:     filepath.Join(StatePath(), backend),
+		subdirs: subdirs,
+	}
+}
+
+// Create creates the directory of the checkpoint name, and returns its
+// path.
+func (m *Manager) Create(name string) (string, error) {
+	path := filepath.Join(m.dir, name)
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return "", err
+	}
+	for _, d := range m.subdirs {
+		if err := os.MkdirAll(filepath.Join(path, d), 0o700); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// Get returns the path of the directory of the checkpoint name, which must
+// exist.
+func (m *Manager) Get(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("checkpoint name must not be empty")
+	}
+
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the paths of the checkpoint directories.
+func (m *Manager) List() ([]string, error) {
+	fis, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		if fi.IsDir() {
+			paths = append(paths, filepath.Join(m.dir, fi.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// Delete deletes the directory of the checkpoint name.
+func (m *Manager) Delete(name string) error {
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("checkpoint %q not found", name)
+	}
+	return os.RemoveAll(path)
+}
+
+// Config lists the binaries and libraries of a checkpoint backend to
+// bind into the container.
+type Config struct {
+	Bins []string `yaml:"bins"`
+	Libs []string `yaml:"libs"`
+}
+
+func parseConfig(confFile string) (*Config, error) {
+	confPath := filepath.Join(buildcfg.APPTAINER_CONFDIR, confFile)
+	buf, err := os.ReadFile(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	err = yaml.Unmarshal(buf, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// GetPaths resolves the binaries and libraries listed in the backend
+// configuration file confFile. Binaries are returned as source:destination
+// pairs binding them into /usr/bin in the container.
+func GetPaths(confFile string) ([]string, []string, error) {
+	conf, err := parseConfig(confFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	libs, bins, _, err := paths.Resolve(append(conf.Bins, conf.Libs...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usrBins := make([]string, 0, len(bins))
+	for _, bin := range bins {
+		usrBin := filepath.Join("/usr/bin", filepath.Base(bin))
+		usrBins = append(usrBins, strings.Join([]string{bin, usrBin}, ":"))
+	}
+
+	return usrBins, libs, nil
+}
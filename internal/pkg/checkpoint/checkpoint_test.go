@@ -0,0 +1,63 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	m := &Manager{dir: filepath.Join(t.TempDir(), "backend"), subdirs: []string{"images"}}
+
+	if _, err := m.List(); err == nil {
+		t.Errorf("unexpected success listing missing backend directory")
+	}
+
+	path, err := m.Create("test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fi, err := os.Stat(filepath.Join(path, "images")); err != nil || !fi.IsDir() {
+		t.Errorf("checkpoint subdirectory not created")
+	}
+	// regular files in the backend directory are not checkpoints
+	if err := os.WriteFile(filepath.Join(m.dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := m.Get("test"); err != nil || got != path {
+		t.Errorf("got %q, %v, want %q", got, err, path)
+	}
+	if _, err := m.Get(""); err == nil {
+		t.Errorf("unexpected success with empty name")
+	}
+	if _, err := m.Get("missing"); err == nil {
+		t.Errorf("unexpected success with missing checkpoint")
+	}
+
+	paths, err := m.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(paths) != 1 || paths[0] != path {
+		t.Errorf("got checkpoints %q, want [%q]", paths, path)
+	}
+
+	if err := m.Delete("test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint directory not deleted")
+	}
+	if err := m.Delete("test"); err == nil {
+		t.Errorf("unexpected success deleting missing checkpoint")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package criu
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
+	apptainerConfig "github.com/apptainer/apptainer/pkg/runtime/engine/apptainer/config"
+	"github.com/moby/go-archive"
+)
+
+type Entry struct {
+	path string
+}
+
+func (e *Entry) BindPath() apptainerConfig.BindPath {
+	return apptainerConfig.BindPath{
+		Source:      e.path,
+		Destination: containerStatepath,
+		Options: map[string]*apptainerConfig.BindOption{
+			"rw": {},
+		},
+	}
+}
+
+func (e *Entry) Path() string {
+	return e.path
+}
+
+func (e *Entry) Name() string {
+	return filepath.Base(e.path)
+}
+
+// DumpLog returns the path of the criu log file written while dumping.
+func (e *Entry) DumpLog() string {
+	return filepath.Join(e.path, dumpLogFile)
+}
+
+// SaveUpper archives the overlay upper directory upperDir in the
+// checkpoint, overlay whiteouts are preserved.
+func (e *Entry) SaveUpper(upperDir string) error {
+	rc, err := archive.TarWithOptions(upperDir, &archive.TarOptions{
+		WhiteoutFormat: archive.OverlayWhiteoutFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("while archiving %s: %s", upperDir, err)
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(filepath.Join(e.path, upperArchive), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return fmt.Errorf("while archiving %s: %s", upperDir, err)
+	}
+	return f.Close()
+}
+
+// RestoreOverlay extracts the overlay upper directory saved in the
+// checkpoint into a fresh overlay directory, and returns its path. An
+// empty path is returned if the checkpoint doesn't hold an upper directory.
+func (e *Entry) RestoreOverlay() (string, error) {
+	f, err := os.Open(filepath.Join(e.path, upperArchive))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	dir := filepath.Join(e.path, overlayDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	for _, d := range []string{"upper", "work"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			return "", err
+		}
+	}
+
+	err = archive.Untar(f, filepath.Join(dir, "upper"), &archive.TarOptions{
+		WhiteoutFormat: archive.OverlayWhiteoutFormat,
+	})
+	if err != nil {
+		return "", fmt.Errorf("while extracting overlay upper directory: %s", err)
+	}
+	return dir, nil
+}
+
+type Manager interface {
+	Create(string) (*Entry, error) // create checkpoint directory for criu state
+	Get(string) (*Entry, error)    // ensure directory with criu state exists
+	List() ([]*Entry, error)       // list checkpoint directories for criu state
+	Delete(string) error           // delete checkpoint directory for criu state
+}
+
+type checkpointManager struct {
+	m *checkpoint.Manager
+}
+
+func NewManager() Manager {
+	return checkpointManager{checkpoint.NewManager(criuPath, imagesDir)}
+}
+
+func (c checkpointManager) Create(name string) (*Entry, error) {
+	path, err := c.m.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{path}, nil
+}
+
+func (c checkpointManager) Get(name string) (*Entry, error) {
+	path, err := c.m.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{path}, nil
+}
+
+func (c checkpointManager) List() ([]*Entry, error) {
+	paths, err := c.m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(paths))
+	for _, p := range paths {
+		entries = append(entries, &Entry{p})
+	}
+	return entries, nil
+}
+
+func (c checkpointManager) Delete(name string) error {
+	return c.m.Delete(name)
+}
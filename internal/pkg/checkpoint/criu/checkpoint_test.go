@@ -0,0 +1,68 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package criu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpperRoundTrip(t *testing.T) {
+	e := &Entry{path: t.TempDir()}
+
+	// no upper directory saved
+	dir, err := e.RestoreOverlay()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if dir != "" {
+		t.Fatalf("unexpected overlay directory %s", dir)
+	}
+
+	upper := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(upper, "etc", "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(upper, "etc", "app", "app.conf"), []byte("debug=1\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SaveUpper(upper); err != nil {
+		t.Fatalf("unexpected error while saving upper directory: %s", err)
+	}
+
+	dir, err = e.RestoreOverlay()
+	if err != nil {
+		t.Fatalf("unexpected error while restoring overlay: %s", err)
+	}
+	if dir != filepath.Join(e.Path(), overlayDir) {
+		t.Fatalf("unexpected overlay directory %s", dir)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "work")); err != nil || !fi.IsDir() {
+		t.Errorf("missing overlay work directory")
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "upper", "etc", "app", "app.conf"))
+	if err != nil {
+		t.Fatalf("restored file missing: %s", err)
+	}
+	if string(b) != "debug=1\n" {
+		t.Errorf("unexpected restored content %q", b)
+	}
+
+	// restoring again starts from a fresh overlay directory
+	if err := os.WriteFile(filepath.Join(dir, "upper", "stale"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RestoreOverlay(); err != nil {
+		t.Fatalf("unexpected error while restoring overlay: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "upper", "stale")); !os.IsNotExist(err) {
+		t.Errorf("stale file found in restored overlay")
+	}
+}
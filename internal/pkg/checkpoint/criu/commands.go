@@ -0,0 +1,66 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package criu
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// commonArgs are the options shared by dump and restore, the same set of
+// resources must be handled on both sides.
+var commonArgs = []string{
+	"--tcp-established",
+	"--ext-unix-sk",
+	"--file-locks",
+	// bind mounts from the host are external to the dumped
+	// mount namespace and restored from the same host paths
+	"--ext-mount-map",
+	"auto",
+	"--enable-external-sharing",
+	"--enable-external-masters",
+}
+
+// DumpArgs returns the criu arguments to dump the process tree of pid
+// into the checkpoint entry e, from the host.
+func DumpArgs(pid int, e *Entry) []string {
+	args := []string{
+		"dump",
+		"--tree",
+		strconv.Itoa(pid),
+		"--images-dir",
+		filepath.Join(e.Path(), imagesDir),
+		"--work-dir",
+		e.Path(),
+		"--log-file",
+		dumpLogFile,
+	}
+	return append(args, commonArgs...)
+}
+
+// RestoreArgs returns the command restoring a checkpoint from inside the
+// container, the root filesystem being set up by the container runtime.
+// criu stays the parent of the restored process tree and exits with it.
+func RestoreArgs() []string {
+	args := []string{
+		"criu",
+		"restore",
+		"--images-dir",
+		filepath.Join(containerStatepath, imagesDir),
+		"--work-dir",
+		containerStatepath,
+		"--log-file",
+		restoreLogFile,
+		"--root",
+		"/",
+		// cgroups are managed by the container runtime
+		"--manage-cgroups=ignore",
+	}
+	return append(args, commonArgs...)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package criu
+
+import (
+	"os/exec"
+
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
+	"github.com/apptainer/apptainer/pkg/sylog"
+)
+
+const (
+	containerStatepath = "/.checkpoint"
+	imagesDir          = "images"
+	upperArchive       = "upper.tar"
+	overlayDir         = "overlay"
+	dumpLogFile        = "dump.log"
+	restoreLogFile     = "restore.log"
+)
+
+const (
+	criuPath = "criu"
+)
+
+// ExportExcludes returns the paths of a checkpoint which are not exported,
+// the overlay directory is extracted again from the upper directory
+// archive on restore.
+func ExportExcludes() []string {
+	return []string{overlayDir}
+}
+
+// GetPaths returns the CRIU binaries and libraries to bind into the
+// container to restore a checkpoint.
+func GetPaths() ([]string, []string, error) {
+	return checkpoint.GetPaths("criu-conf.yaml")
+}
+
+// QuickInstallationCheck is a quick smoke test to see if criu is installed
+// on the host by checking for the criu executable in the PATH. If not found
+// a warning is emitted.
+func QuickInstallationCheck() {
+	_, err := exec.LookPath("criu")
+	if err == nil {
+		return
+	}
+
+	sylog.Warningf("Unable to locate a criu installation, some functionality may not work as expected. Please ensure a criu installation exists or install it following instructions here: https://criu.org/Installation")
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
 	apptainerConfig "github.com/apptainer/apptainer/pkg/runtime/engine/apptainer/config"
 )
 
@@ -63,59 +64,43 @@ type Manager interface {
 	Delete(string) error           // delete checkpoint directory for dmtcp state
 }
 
-type checkpointManager struct{}
+type checkpointManager struct {
+	m *checkpoint.Manager
+}
 
 func NewManager() Manager {
-	return &checkpointManager{}
+	return checkpointManager{checkpoint.NewManager(dmtcpPath)}
 }
 
-func (checkpointManager) Create(name string) (*Entry, error) {
-	err := os.MkdirAll(filepath.Join(dmtcpDir(), name), 0o700)
+func (c checkpointManager) Create(name string) (*Entry, error) {
+	path, err := c.m.Create(name)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Entry{filepath.Join(dmtcpDir(), name)}, nil
+	return &Entry{path}, nil
 }
 
-func (checkpointManager) Get(name string) (*Entry, error) {
-	if name == "" {
-		return nil, fmt.Errorf("checkpoint name must not be empty")
-	}
-
-	_, err := os.Stat(filepath.Join(dmtcpDir(), name))
+func (c checkpointManager) Get(name string) (*Entry, error) {
+	path, err := c.m.Get(name)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Entry{filepath.Join(dmtcpDir(), name)}, nil
+	return &Entry{path}, nil
 }
 
-func (checkpointManager) List() ([]*Entry, error) {
-	fis, err := os.ReadDir(dmtcpDir())
+func (c checkpointManager) List() ([]*Entry, error) {
+	paths, err := c.m.List()
 	if err != nil {
 		return nil, err
 	}
 
-	entries := make([]*Entry, 0, len(fis))
-	for _, fi := range fis {
-		if !fi.IsDir() {
-			continue
-		}
-
-		entries = append(entries, &Entry{filepath.Join(dmtcpDir(), fi.Name())})
+	entries := make([]*Entry, 0, len(paths))
+	for _, p := range paths {
+		entries = append(entries, &Entry{p})
 	}
-
 	return entries, nil
 }
 
-func (checkpointManager) Delete(name string) error {
-	_, err := os.Stat(filepath.Join(dmtcpDir(), name))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("checkpoint %q not found", name)
-		}
-	}
-
-	return os.RemoveAll(filepath.Join(dmtcpDir(), name))
+func (c checkpointManager) Delete(name string) error {
+	return c.m.Delete(name)
 }
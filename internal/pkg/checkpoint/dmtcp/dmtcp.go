@@ -9,16 +9,10 @@
 package dmtcp
 
 import (
-	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
 
-	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
 	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
-	"github.com/apptainer/apptainer/internal/pkg/util/paths"
 	"github.com/apptainer/apptainer/pkg/sylog"
-	"go.yaml.in/yaml/v4"
 )
 
 const (
@@ -31,49 +25,8 @@ const (
 	dmtcpPath = "dmtcp"
 )
 
-func dmtcpDir() string {
-	return filepath.Join(checkpoint.StatePath(), dmtcpPath)
-}
-
-type Config struct {
-	Bins []string `yaml:"bins"`
-	Libs []string `yaml:"libs"`
-}
-
-func parseConfig() (*Config, error) {
-	confPath := filepath.Join(buildcfg.APPTAINER_CONFDIR, "dmtcp-conf.yaml")
-	buf, err := os.ReadFile(confPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var c Config
-	err = yaml.Unmarshal(buf, &c)
-	if err != nil {
-		return nil, err
-	}
-
-	return &c, nil
-}
-
 func GetPaths() ([]string, []string, error) {
-	conf, err := parseConfig()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	libs, bins, _, err := paths.Resolve(append(conf.Bins, conf.Libs...))
-	if err != nil {
-		return nil, nil, err
-	}
-
-	usrBins := make([]string, 0, len(bins))
-	for _, bin := range bins {
-		usrBin := filepath.Join("/usr/bin", filepath.Base(bin))
-		usrBins = append(usrBins, strings.Join([]string{bin, usrBin}, ":"))
-	}
-
-	return usrBins, libs, nil
+	return checkpoint.GetPaths("dmtcp-conf.yaml")
 }
 
 // QuickInstallationCheck is a quick smoke test to see if dmtcp is installed
@@ -0,0 +1,172 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	DMTCPBackend = "dmtcp"
+	CRIUBackend  = "criu"
+)
+
+const manifestFile = "manifest.json"
+
+// Manifest describes a checkpoint, it's stored in the checkpoint
+// directory and travels with exported checkpoints.
+type Manifest struct {
+	Backend string `json:"backend"`
+	Name    string `json:"name"`
+	// Image is the path of the container image the checkpoint was
+	// created from
+	Image string `json:"image,omitempty"`
+	// ImageDigest is the digest of the container image as computed by
+	// ImageDigest, empty if the image is not a SIF image
+	ImageDigest digest.Digest `json:"imageDigest,omitempty"`
+	Created     time.Time     `json:"created"`
+}
+
+// NewManifest returns the manifest of a checkpoint created from image.
+func NewManifest(backend, name, image string) (*Manifest, error) {
+	m := &Manifest{
+		Backend: backend,
+		Name:    name,
+		Created: time.Now().UTC(),
+	}
+	if image == "" {
+		return m, nil
+	}
+
+	path, err := filepath.Abs(image)
+	if err != nil {
+		return nil, err
+	}
+	m.Image = path
+	m.ImageDigest, err = ImageDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ImageDigest returns the digest of a SIF container image, computed from
+// the integrity protected fields of its header, and the descriptors and
+// data of its objects, so the whole image content is read. Signatures are
+// left out, signing the image doesn't change its digest. An empty digest
+// is returned for image directories and other image formats.
+func ImageDigest(image string) (digest.Digest, error) {
+	f, err := os.Open(image)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", nil
+	}
+	b := make([]byte, 64)
+	if n, err := io.ReadFull(f, b); err != nil || !bytes.Contains(b[:n], []byte("SIF_MAGIC")) {
+		return "", nil
+	}
+
+	fimg, err := sif.LoadContainer(f, sif.OptLoadWithFlag(os.O_RDONLY), sif.OptLoadWithCloseOnUnload(false))
+	if err != nil {
+		return "", fmt.Errorf("while loading SIF image %s: %s", image, err)
+	}
+	defer fimg.UnloadContainer()
+
+	d := digest.Canonical.Digester()
+	if _, err := io.Copy(d.Hash(), fimg.GetHeaderIntegrityReader()); err != nil {
+		return "", err
+	}
+	fimg.WithDescriptors(func(od sif.Descriptor) bool {
+		if od.DataType() == sif.DataSignature {
+			return false
+		}
+		if _, err = io.Copy(d.Hash(), od.GetIntegrityReader()); err == nil {
+			_, err = io.Copy(d.Hash(), od.GetReader())
+		}
+		return err != nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return d.Digest(), nil
+}
+
+// WriteManifest writes the manifest m in the checkpoint directory dir.
+func WriteManifest(dir string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), b, 0o600)
+}
+
+// ReadManifest reads the manifest of the checkpoint directory dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(b)
+}
+
+func parseManifest(b []byte) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("while parsing checkpoint manifest: %s", err)
+	}
+	if m.Backend != DMTCPBackend && m.Backend != CRIUBackend {
+		return nil, fmt.Errorf("unknown checkpoint backend %q in manifest", m.Backend)
+	}
+	return m, nil
+}
+
+// CheckImage checks that image is the container image the checkpoint
+// directory dir was created from, by comparing their digests.
+func CheckImage(dir, image string) error {
+	m, err := ReadManifest(dir)
+	if os.IsNotExist(err) {
+		sylog.Warningf("Checkpoint has no manifest, the container image can't be verified")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if m.ImageDigest == "" {
+		sylog.Warningf("Checkpoint was created from image %s which is not a SIF image, the container image can't be verified", m.Image)
+		return nil
+	}
+
+	d, err := ImageDigest(image)
+	if err != nil {
+		return fmt.Errorf("while computing image digest: %s", err)
+	}
+	if d != m.ImageDigest {
+		return fmt.Errorf("image %s (%s) is not the image %s (%s) checkpoint %s was created from", image, d, m.Image, m.ImageDigest, m.Name)
+	}
+	sylog.Debugf("Image %s matches checkpoint digest %s", image, d)
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/cache"
 	"github.com/apptainer/apptainer/internal/pkg/client"
 	"github.com/apptainer/apptainer/internal/pkg/ociimage"
+	"github.com/apptainer/apptainer/internal/pkg/ocilazy"
 	"github.com/apptainer/apptainer/internal/pkg/util/fs"
 	"github.com/apptainer/apptainer/internal/pkg/util/ociauth"
 	buildtypes "github.com/apptainer/apptainer/pkg/build/types"
@@ -162,6 +163,19 @@ func Pull(ctx context.Context, imgCache *cache.Handle, pullFrom string, opts Pul
 	return pull(ctx, imgCache, directTo, pullFrom, opts)
 }
 
+// PullLazy prepares a lazily pulled image in the cache from the docker://
+// URI pullFrom and returns the path of its descriptor. The image layers are
+// fetched on demand when the container runs.
+func PullLazy(ctx context.Context, imgCache *cache.Handle, pullFrom string, opts PullOptions) (imagePath string, err error) {
+	topts := transportOptions(opts)
+	// the lazy image driver resolves credentials by itself
+	// from the auth file
+	topts.AuthFilePath = opts.ReqAuthFile
+
+	sylog.Infof("Preparing lazily pulled image...")
+	return ocilazy.Prepare(ctx, imgCache, pullFrom, topts)
+}
+
 // PullToFile will build a SIF image from the specified oci URI and place it at the specified dest
 func PullToFile(ctx context.Context, imgCache *cache.Handle, pullTo, pullFrom string, sandbox bool, opts PullOptions) (imagePath string, err error) {
 	directTo := ""
@@ -39,7 +39,7 @@ func genConf(tmpl, in, out string) error {
 	if _, err := os.Stat(in); os.IsNotExist(err) {
 		inFile = ""
 	}
-	c, err := apptainerconf.Parse(inFile)
+	c, err := apptainerconf.ParseFile(inFile)
 	if err != nil {
 		return fmt.Errorf("unable to parse apptainer.conf file: %v", err)
 	}
@@ -0,0 +1,266 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
+	"github.com/apptainer/apptainer/internal/pkg/util/bin"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/capabilities"
+	"github.com/apptainer/apptainer/pkg/util/fs/proc"
+)
+
+// LazyDriverName is the name of the image driver serving lazily
+// pulled OCI images.
+const LazyDriverName = "lazy"
+
+type lazyInstance struct {
+	cmd    *exec.Cmd
+	target string
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+}
+
+// lazyDriver serves lazily pulled OCI images with the hidden lazy-mount
+// command and delegates all other mounts to the configured image driver.
+type lazyDriver struct {
+	next       image.Driver
+	cmdPrefix  []string
+	mu         sync.Mutex
+	instances  []*lazyInstance
+	stopped    atomic.Bool
+	errMu      sync.Mutex
+	mountErrCh chan error
+}
+
+// NewLazyDriver returns the lazy image driver wrapping the image driver
+// next, which may be nil. The driver is registered once under
+// LazyDriverName, the registered instance is returned if any.
+func NewLazyDriver(next image.Driver) (image.Driver, error) {
+	if d := image.GetDriver(LazyDriverName); d != nil {
+		return d, nil
+	}
+	d := &lazyDriver{
+		next:       next,
+		mountErrCh: make(chan error, 1),
+	}
+	if err := image.RegisterDriver(LazyDriverName, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *lazyDriver) Features() image.DriverFeature {
+	features := image.LazyFeature
+	if d.next != nil {
+		features |= d.next.Features()
+	}
+	return features
+}
+
+func (d *lazyDriver) Mount(params *image.MountParams, mfunc image.MountFunc) error {
+	if params.Filesystem != "lazy" {
+		if d.next == nil {
+			return fmt.Errorf("filesystem type %v not recognized by image driver", params.Filesystem)
+		}
+		return d.next.Mount(params, mfunc)
+	}
+
+	var extraFiles []*os.File
+	source := params.Source
+	if path.Dir(source) == "/proc/self/fd" {
+		fd, _ := strconv.Atoi(path.Base(source))
+		source = fmt.Sprintf("/proc/self/fd/%d", 3+len(extraFiles))
+		extraFiles = append(extraFiles, os.NewFile(uintptr(fd), params.Source))
+	}
+	target := params.Target
+	waitForMount := true
+	if strings.HasPrefix(target, "/dev/fd/") {
+		// privileged mode, the FUSE filesystem is already mounted
+		// by the engine, the server runs unprivileged
+		params.DontElevatePrivs = true
+		fd, _ := strconv.Atoi(path.Base(target))
+		target = fmt.Sprintf("/dev/fd/%d", 3+len(extraFiles))
+		extraFiles = append(extraFiles, os.NewFile(uintptr(fd), params.Target))
+		waitForMount = false
+	}
+
+	args := append([]string{}, d.cmdPrefix...)
+	args = append(args, filepath.Join(buildcfg.BINDIR, "apptainer"))
+	if sylog.GetLevel() >= int(sylog.DebugLevel) {
+		args = append(args, "--debug")
+	}
+	args = append(args, "lazy-mount", source, target)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.ExtraFiles = extraFiles
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		// Put the server in its own process group to avoid
+		// signals from interactive use
+		Setpgid: true,
+	}
+	if !params.DontElevatePrivs {
+		cmd.SysProcAttr.AmbientCaps = []uintptr{
+			uintptr(capabilities.Map["CAP_SYS_ADMIN"].Value),
+		}
+	}
+
+	instance := &lazyInstance{
+		cmd:    cmd,
+		target: params.Target,
+		done:   make(chan struct{}),
+	}
+	cmd.Stderr = &instance.stderr
+
+	sylog.Debugf("Executing %v", cmd.String())
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("lazy image server start failed: %v", err)
+	}
+
+	d.mu.Lock()
+	d.instances = append(d.instances, instance)
+	d.mu.Unlock()
+
+	go func() {
+		instance.err = cmd.Wait()
+		close(instance.done)
+
+		d.errMu.Lock()
+		defer d.errMu.Unlock()
+		if instance.err != nil && !d.stopped.Load() {
+			select {
+			case d.mountErrCh <- fmt.Errorf("lazy image server exited with error: %v: %s", instance.err, instance.stderr.String()):
+			default:
+			}
+		}
+	}()
+
+	if !waitForMount {
+		return nil
+	}
+
+	maxTime := 30 * time.Second
+	for totTime := time.Duration(0); totTime < maxTime; totTime += 25 * time.Millisecond {
+		select {
+		case <-instance.done:
+			return fmt.Errorf("lazy image server failed to mount %v: %s", params.Target, strings.TrimSpace(instance.stderr.String()))
+		case <-time.After(25 * time.Millisecond):
+		}
+
+		entries, err := proc.GetMountInfoEntry("/proc/self/mountinfo")
+		if err != nil {
+			_ = d.stop(params.Target, true)
+			return fmt.Errorf("failure to get mount info: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.Point == params.Target {
+				sylog.Debugf("%v mounted in %v", params.Target, totTime)
+				return nil
+			}
+		}
+	}
+
+	_ = d.stop(params.Target, true)
+	return fmt.Errorf("lazy image server failed to mount %v in %v", params.Target, maxTime)
+}
+
+func (d *lazyDriver) Start(params *image.DriverParams, containerPid int, hybrid bool) error {
+	if hybrid {
+		// Running in hybrid setuid-fakeroot mode, the server
+		// must first enter the container user namespace
+		nsenter, err := bin.FindBin("nsenter")
+		if err != nil {
+			return fmt.Errorf("failed to find nsenter: %v", err)
+		}
+		d.cmdPrefix = []string{
+			nsenter,
+			fmt.Sprintf("--user=/proc/%d/ns/user", containerPid),
+			"-F",
+		}
+	}
+	if d.next != nil {
+		return d.next.Start(params, containerPid, hybrid)
+	}
+	return nil
+}
+
+// stop waits for the server associated with target to exit, it's
+// terminated if kill is true or if it doesn't exit in time.
+func (d *lazyDriver) stop(target string, kill bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, instance := range d.instances {
+		if instance.target != target || instance.cmd.Process == nil {
+			continue
+		}
+		if kill {
+			_ = instance.cmd.Process.Signal(syscall.SIGTERM)
+		}
+		select {
+		case <-instance.done:
+		case <-time.After(time.Second):
+			sylog.Debugf("Terminating lazy image server pid %v after wait timeout", instance.cmd.Process.Pid)
+			_ = instance.cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-instance.done:
+			case <-time.After(time.Second):
+				_ = instance.cmd.Process.Kill()
+				<-instance.done
+			}
+		}
+	}
+	return nil
+}
+
+func (d *lazyDriver) Stop(target string) error {
+	d.errMu.Lock()
+	if !d.stopped.Swap(true) {
+		close(d.mountErrCh)
+	}
+	d.errMu.Unlock()
+	if err := d.stop(target, false); err != nil {
+		return err
+	}
+	if d.next != nil {
+		return d.next.Stop(target)
+	}
+	return nil
+}
+
+func (d *lazyDriver) MountErr() error {
+	if d.next == nil {
+		return <-d.mountErrCh
+	}
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- <-d.mountErrCh }()
+	go func() { errCh <- d.next.MountErr() }()
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return <-errCh
+}
@@ -0,0 +1,390 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagefs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+const (
+	extSuperblockOffset = 1024
+	extMagic            = 0xef53
+	extRootInode        = 2
+	extExtentMagic      = 0xf30a
+	extExtentsFlag      = 0x80000
+	extInlineDataFlag   = 0x10000000
+)
+
+// ext incompatible features.
+const (
+	extIncompatCompression = 0x1
+	extIncompatFiletype    = 0x2
+	extIncompatJournalDev  = 0x8
+	extIncompatMetaBG      = 0x10
+	extIncompat64Bit       = 0x80
+	extIncompatInlineData  = 0x8000
+	extIncompatEncrypt     = 0x10000
+)
+
+const extUnsupportedIncompat = extIncompatCompression | extIncompatJournalDev |
+	extIncompatMetaBG | extIncompatInlineData | extIncompatEncrypt
+
+// extInode holds the raw fields of an inode needed to read its content.
+type extInode struct {
+	flags  uint32
+	blocks uint32
+	acl    uint64
+	block  [60]byte
+}
+
+type ext3 struct {
+	r io.ReaderAt
+
+	blockSize      int64
+	inodeSize      int64
+	inodesPerGroup uint32
+	descSize       int64
+	descStart      int64
+	groups         uint32
+	incompat       uint32
+}
+
+func newExt3(r io.ReaderAt) (*ext3, error) {
+	var sb [256]byte
+	if _, err := r.ReadAt(sb[:], extSuperblockOffset); err != nil {
+		return nil, fmt.Errorf("while reading ext3 superblock: %w", err)
+	}
+	le := binary.LittleEndian
+	if le.Uint16(sb[56:]) != extMagic {
+		return nil, fmt.Errorf("not an ext3 filesystem")
+	}
+
+	logBlockSize := le.Uint32(sb[24:])
+	if logBlockSize > 6 {
+		return nil, fmt.Errorf("bad ext3 block size")
+	}
+
+	e := &ext3{
+		r:              r,
+		blockSize:      1024 << logBlockSize,
+		inodeSize:      128,
+		inodesPerGroup: le.Uint32(sb[40:]),
+		descSize:       32,
+	}
+	if le.Uint32(sb[76:]) >= 1 {
+		e.inodeSize = int64(le.Uint16(sb[88:]))
+		e.incompat = le.Uint32(sb[96:])
+	}
+	if e.incompat&extUnsupportedIncompat != 0 {
+		return nil, fmt.Errorf("unsupported ext3 features 0x%x", e.incompat&extUnsupportedIncompat)
+	}
+	if e.incompat&extIncompat64Bit != 0 {
+		if size := int64(le.Uint16(sb[254:])); size >= 32 {
+			e.descSize = size
+		}
+	}
+	if e.inodesPerGroup == 0 || e.inodeSize < 128 {
+		return nil, fmt.Errorf("bad ext3 superblock")
+	}
+
+	inodes := le.Uint32(sb[0:])
+	e.groups = (inodes + e.inodesPerGroup - 1) / e.inodesPerGroup
+	// the group descriptors are in the block following the superblock
+	e.descStart = (int64(le.Uint32(sb[20:])) + 1) * e.blockSize
+
+	return e, nil
+}
+
+func (e *ext3) root() (*node, error) {
+	return e.inode(extRootInode)
+}
+
+func (e *ext3) inode(ref uint64) (*node, error) {
+	if ref == 0 {
+		return nil, fmt.Errorf("bad inode number 0")
+	}
+	group := uint32((ref - 1) / uint64(e.inodesPerGroup))
+	index := int64((ref - 1) % uint64(e.inodesPerGroup))
+	if group >= e.groups {
+		return nil, fmt.Errorf("bad inode number %d", ref)
+	}
+
+	le := binary.LittleEndian
+	desc := make([]byte, e.descSize)
+	if _, err := e.r.ReadAt(desc, e.descStart+int64(group)*e.descSize); err != nil {
+		return nil, fmt.Errorf("while reading group descriptor %d: %w", group, err)
+	}
+	table := uint64(le.Uint32(desc[8:]))
+	if e.descSize >= 64 {
+		table |= uint64(le.Uint32(desc[0x28:])) << 32
+	}
+
+	raw := make([]byte, 128)
+	if _, err := e.r.ReadAt(raw, int64(table)*e.blockSize+index*e.inodeSize); err != nil {
+		return nil, fmt.Errorf("while reading inode %d: %w", ref, err)
+	}
+
+	mode := le.Uint16(raw[0:])
+	ei := &extInode{
+		flags:  le.Uint32(raw[32:]),
+		blocks: le.Uint32(raw[28:]),
+		acl:    uint64(le.Uint32(raw[104:])) | uint64(le.Uint16(raw[118:]))<<32,
+	}
+	copy(ei.block[:], raw[40:100])
+	if ei.flags&extInlineDataFlag != 0 {
+		return nil, fmt.Errorf("inode %d: inline data is not supported", ref)
+	}
+
+	n := &node{
+		mode:  fs.FileMode(mode & 0o777),
+		size:  int64(le.Uint32(raw[4:])),
+		mtime: time.Unix(int64(le.Uint32(raw[16:])), 0),
+		data:  ei,
+	}
+	if mode&0o4000 != 0 {
+		n.mode |= fs.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		n.mode |= fs.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		n.mode |= fs.ModeSticky
+	}
+
+	switch mode & 0o170000 {
+	case 0o040000:
+		n.mode |= fs.ModeDir
+	case 0o100000:
+		n.size |= int64(le.Uint32(raw[108:])) << 32
+	case 0o120000:
+		n.mode |= fs.ModeSymlink
+	case 0o060000:
+		n.mode |= fs.ModeDevice
+	case 0o020000:
+		n.mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o010000:
+		n.mode |= fs.ModeNamedPipe
+	case 0o140000:
+		n.mode |= fs.ModeSocket
+	default:
+		return nil, fmt.Errorf("unknown type for inode %d", ref)
+	}
+
+	return n, nil
+}
+
+// readBlock reads the physical block num.
+func (e *ext3) readBlock(num uint64) ([]byte, error) {
+	b := make([]byte, e.blockSize)
+	if num == 0 {
+		return b, nil
+	}
+	if _, err := e.r.ReadAt(b, int64(num)*e.blockSize); err != nil {
+		return nil, fmt.Errorf("while reading block %d: %w", num, err)
+	}
+	return b, nil
+}
+
+// mapBlock returns the physical block of the logical block lblk of an
+// inode, 0 is returned for holes.
+func (e *ext3) mapBlock(ei *extInode, lblk uint64) (uint64, error) {
+	if ei.flags&extExtentsFlag != 0 {
+		return e.mapExtent(ei.block[:], lblk)
+	}
+
+	le := binary.LittleEndian
+	perBlock := uint64(e.blockSize / 4)
+	if lblk < 12 {
+		return uint64(le.Uint32(ei.block[lblk*4:])), nil
+	}
+
+	// indirect blocks
+	lblk -= 12
+	depth, span := 1, perBlock
+	for lblk >= span {
+		lblk -= span
+		if depth++; depth > 3 {
+			return 0, fmt.Errorf("block %d out of range", lblk)
+		}
+		span *= perBlock
+	}
+	blk := uint64(le.Uint32(ei.block[(11+depth)*4:]))
+	for ; depth > 0; depth-- {
+		if blk == 0 {
+			return 0, nil
+		}
+		b, err := e.readBlock(blk)
+		if err != nil {
+			return 0, err
+		}
+		span /= perBlock
+		blk = uint64(le.Uint32(b[(lblk/span)*4:]))
+		lblk %= span
+	}
+	return blk, nil
+}
+
+// mapExtent returns the physical block of the logical block lblk in the
+// extent tree node data.
+func (e *ext3) mapExtent(data []byte, lblk uint64) (uint64, error) {
+	le := binary.LittleEndian
+	for {
+		if len(data) < 12 || le.Uint16(data[0:]) != extExtentMagic {
+			return 0, fmt.Errorf("bad extent header")
+		}
+		entries := int(le.Uint16(data[2:]))
+		depth := le.Uint16(data[6:])
+		if 12+entries*12 > len(data) {
+			return 0, fmt.Errorf("bad extent header")
+		}
+
+		if depth == 0 {
+			for i := range entries {
+				ext := data[12+i*12:]
+				start := uint64(le.Uint32(ext[0:]))
+				length := uint64(le.Uint16(ext[4:]))
+				uninit := length > 32768
+				if uninit {
+					length -= 32768
+				}
+				if lblk >= start && lblk < start+length {
+					if uninit {
+						return 0, nil
+					}
+					phys := uint64(le.Uint16(ext[6:]))<<32 | uint64(le.Uint32(ext[8:]))
+					return phys + lblk - start, nil
+				}
+			}
+			return 0, nil
+		}
+
+		// index node, find the last index covering lblk
+		next := uint64(0)
+		for i := range entries {
+			idx := data[12+i*12:]
+			if uint64(le.Uint32(idx[0:])) > lblk {
+				break
+			}
+			next = uint64(le.Uint16(idx[8:]))<<32 | uint64(le.Uint32(idx[4:]))
+		}
+		if next == 0 {
+			return 0, nil
+		}
+		b, err := e.readBlock(next)
+		if err != nil {
+			return 0, err
+		}
+		data = b
+	}
+}
+
+// readData reads the whole content of an inode.
+func (e *ext3) readData(n *node) ([]byte, error) {
+	b := make([]byte, n.size)
+	ra := &ext3Reader{e: e, ei: n.data.(*extInode), size: n.size}
+	if _, err := ra.ReadAt(b, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (e *ext3) readDir(n *node) ([]dirent, error) {
+	if !n.mode.IsDir() {
+		return nil, fmt.Errorf("not a directory")
+	}
+	data, err := e.readData(n)
+	if err != nil {
+		return nil, err
+	}
+
+	le := binary.LittleEndian
+	var entries []dirent
+	for off := 0; off+8 <= len(data); {
+		ino := le.Uint32(data[off:])
+		recLen := int(le.Uint16(data[off+4:]))
+		nameLen := int(data[off+6])
+		if e.incompat&extIncompatFiletype == 0 {
+			nameLen = int(le.Uint16(data[off+6:]))
+		}
+		if recLen < 8 || off+recLen > len(data) || 8+nameLen > recLen {
+			return nil, fmt.Errorf("bad directory entry at offset %d", off)
+		}
+		name := string(data[off+8 : off+8+nameLen])
+		// entries with inode 0 are unused, this also skips the
+		// hashed directory index nodes
+		if ino != 0 && name != "." && name != ".." {
+			entries = append(entries, dirent{name: name, ref: uint64(ino)})
+		}
+		off += recLen
+	}
+	return entries, nil
+}
+
+func (e *ext3) readLink(n *node) (string, error) {
+	if n.mode.Type() != fs.ModeSymlink {
+		return "", fmt.Errorf("not a symbolic link")
+	}
+	ei := n.data.(*extInode)
+
+	// fast symbolic links are stored in the inode block map
+	aclBlocks := uint32(0)
+	if ei.acl != 0 {
+		aclBlocks = uint32(e.blockSize / 512)
+	}
+	if n.size < 60 && ei.blocks == aclBlocks {
+		return string(ei.block[:n.size]), nil
+	}
+
+	data, err := e.readData(n)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (e *ext3) open(n *node) (io.ReaderAt, error) {
+	if !n.mode.IsRegular() {
+		return nil, fmt.Errorf("not a regular file")
+	}
+	return &ext3Reader{e: e, ei: n.data.(*extInode), size: n.size}, nil
+}
+
+// ext3Reader reads the content of an inode.
+type ext3Reader struct {
+	e    *ext3
+	ei   *extInode
+	size int64
+}
+
+func (er *ext3Reader) ReadAt(b []byte, off int64) (int, error) {
+	bs := er.e.blockSize
+	n := 0
+	for n < len(b) {
+		if off >= er.size {
+			return n, io.EOF
+		}
+		blk, err := er.e.mapBlock(er.ei, uint64(off/bs))
+		if err != nil {
+			return n, err
+		}
+		data, err := er.e.readBlock(blk)
+		if err != nil {
+			return n, err
+		}
+		end := min(bs, er.size-off+off%bs)
+		c := copy(b[n:], data[off%bs:end])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
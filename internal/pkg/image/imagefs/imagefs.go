@@ -0,0 +1,487 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package imagefs provides read-only access to the content of container
+// images without mounting them. Squashfs and ext3 filesystems are parsed
+// in process, so it works unprivileged and doesn't require any kernel
+// support nor a container runtime.
+package imagefs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/image/unpacker"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/ccoveille/go-safecast"
+)
+
+// maxSymlinks is the maximum number of symbolic links followed while
+// resolving a path.
+const maxSymlinks = 40
+
+// errSymlinkLoop is returned when resolving a path involves too many
+// symbolic links.
+var errSymlinkLoop = errors.New("too many levels of symbolic links")
+
+// node represents a filesystem inode.
+type node struct {
+	mode  fs.FileMode
+	size  int64
+	mtime time.Time
+	// data holds filesystem specific information about the inode
+	data any
+}
+
+// dirent represents a directory entry.
+type dirent struct {
+	name string
+	// ref is the filesystem specific reference to the entry inode
+	ref uint64
+}
+
+// backend is the interface implemented by the filesystem parsers.
+type backend interface {
+	// root returns the root directory inode.
+	root() (*node, error)
+	// inode returns the inode referenced by a directory entry.
+	inode(ref uint64) (*node, error)
+	// readDir returns the entries of a directory inode, "." and
+	// ".." excluded.
+	readDir(n *node) ([]dirent, error)
+	// readLink returns the target of a symbolic link inode.
+	readLink(n *node) (string, error)
+	// open returns a reader for the content of a regular file inode.
+	open(n *node) (io.ReaderAt, error)
+}
+
+// FS is a read-only fs.FS implementation on top of a filesystem parser.
+// Symbolic links are resolved within the filesystem, absolute targets
+// are relative to its root.
+type FS struct {
+	b backend
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadLinkFS = (*FS)(nil)
+)
+
+// lookup returns the inode of the directory entry name of dir.
+func (f *FS) lookup(dir *node, name string) (*node, error) {
+	entries, err := f.b.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.name == name {
+			return f.b.inode(e.ref)
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// resolve returns the inode of the path name, symbolic links are
+// followed, except for the last path component if follow is false.
+func (f *FS) resolve(name string, follow bool) (*node, error) {
+	root, err := f.b.root()
+	if err != nil {
+		return nil, err
+	}
+
+	links := 0
+	// stack of traversed directories, the last one being the current one
+	dirs := []*node{root}
+	todo := strings.Split(name, "/")
+	if name == "." {
+		todo = nil
+	}
+
+	for len(todo) > 0 {
+		elem := todo[0]
+		todo = todo[1:]
+
+		cur := dirs[len(dirs)-1]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(dirs) > 1 {
+				dirs = dirs[:len(dirs)-1]
+			}
+			continue
+		}
+		if !cur.mode.IsDir() {
+			return nil, fmt.Errorf("not a directory")
+		}
+
+		n, err := f.lookup(cur, elem)
+		if err != nil {
+			return nil, err
+		}
+		if n.mode.Type() != fs.ModeSymlink || (len(todo) == 0 && !follow) {
+			dirs = append(dirs, n)
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return nil, errSymlinkLoop
+		}
+		target, err := f.b.readLink(n)
+		if err != nil {
+			return nil, err
+		}
+		if path.IsAbs(target) {
+			dirs = dirs[:1]
+		}
+		todo = append(strings.Split(target, "/"), todo...)
+	}
+
+	return dirs[len(dirs)-1], nil
+}
+
+func (f *FS) stat(op, name string, follow bool) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n, err := f.resolve(name, follow)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return n, nil
+}
+
+// Open opens the named file.
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.stat("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fi := &fileInfo{name: path.Base(name), n: n}
+	if n.mode.IsDir() {
+		return &dir{fs: f, fi: fi}, nil
+	} else if !n.mode.IsRegular() {
+		return &file{fi: fi, r: io.NewSectionReader(eofReader{}, 0, 0)}, nil
+	}
+
+	r, err := f.b.open(n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{fi: fi, r: io.NewSectionReader(r, 0, n.size)}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// filename.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.stat("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	entries, err := f.readDir(n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (f *FS) readDir(n *node) ([]fs.DirEntry, error) {
+	dirents, err := f.b.readDir(n)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(dirents))
+	for _, e := range dirents {
+		en, err := f.b.inode(e.ref)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: e.name, n: en}))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// Stat returns information about the named file, symbolic links are
+// followed.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.stat("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(name), n: n}, nil
+}
+
+// Lstat returns information about the named file without following
+// symbolic links.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	n, err := f.stat("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(name), n: n}, nil
+}
+
+// ReadLink returns the target of the named symbolic link.
+func (f *FS) ReadLink(name string) (string, error) {
+	n, err := f.stat("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode.Type() != fs.ModeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	target, err := f.b.readLink(n)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return target, nil
+}
+
+type fileInfo struct {
+	name string
+	n    *node
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.n.size }
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.n.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.n.mtime }
+func (fi *fileInfo) IsDir() bool        { return fi.n.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+type eofReader struct{}
+
+func (eofReader) ReadAt([]byte, int64) (int, error) { return 0, io.EOF }
+
+// file is an opened regular file, or special file which reads as empty.
+type file struct {
+	fi *fileInfo
+	r  *io.SectionReader
+}
+
+func (f *file) Stat() (fs.FileInfo, error)              { return f.fi, nil }
+func (f *file) Read(b []byte) (int, error)              { return f.r.Read(b) }
+func (f *file) ReadAt(b []byte, off int64) (int, error) { return f.r.ReadAt(b, off) }
+func (f *file) Seek(off int64, whence int) (int64, error) {
+	return f.r.Seek(off, whence)
+}
+func (f *file) Close() error { return nil }
+
+// dir is an opened directory.
+type dir struct {
+	fs      *FS
+	fi      *fileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.fi, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.fi.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fs.readDir(d.fi.n)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.fi.name, Err: err}
+		}
+		d.entries, d.read = entries, true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+// New returns a read-only fs.FS for the squashfs or ext3 filesystem
+// image of type fsType read from r.
+func New(r io.ReaderAt, fsType int) (*FS, error) {
+	var (
+		b   backend
+		err error
+	)
+
+	switch fsType {
+	case image.SQUASHFS:
+		b, err = newSquashfs(r)
+	case image.EXT3:
+		b, err = newExt3(r)
+	default:
+		return nil, fmt.Errorf("unsupported filesystem type %d", fsType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FS{b: b}, nil
+}
+
+// partitionFS returns a read-only fs.FS for the image partition p.
+// Squashfs partitions using a compression not supported by the parser
+// are extracted by unsquashfs in a temporary directory added to tmpDirs,
+// only paths are extracted if any.
+func partitionFS(img *image.Image, p image.Section, paths []string, tmpDirs *[]string) (fs.FS, error) {
+	if p.Type == image.ENCRYPTSQUASHFS || p.Type == image.GOCRYPTFSSQUASHFS {
+		return nil, fmt.Errorf("encrypted images are not supported")
+	}
+	offset, err := safecast.Convert[int64](p.Offset)
+	if err != nil {
+		return nil, err
+	}
+	size, err := safecast.Convert[int64](p.Size)
+	if err != nil {
+		return nil, err
+	}
+	r := io.NewSectionReader(img.File, offset, size)
+	fsys, err := New(r, int(p.Type))
+	if errors.Is(err, errUnsupportedCompression) {
+		sylog.Debugf("Partition %s: %v, extracting it with unsquashfs", p.Name, err)
+		return extractFS(r, paths, tmpDirs)
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading partition %s: %w", p.Name, err)
+	}
+	return fsys, nil
+}
+
+// extractFS extracts paths, or everything if there is none, of the
+// squashfs filesystem read from r in a temporary directory added to
+// tmpDirs and returns a read-only fs.FS of it.
+func extractFS(r io.Reader, paths []string, tmpDirs *[]string) (fs.FS, error) {
+	s := unpacker.NewSquashfs()
+	if !s.HasUnsquashfs() {
+		return nil, fmt.Errorf("%w and unsquashfs was not found", errUnsupportedCompression)
+	}
+
+	dir, err := os.MkdirTemp("", "imagefs-")
+	if err != nil {
+		return nil, fmt.Errorf("while creating temporary directory: %w", err)
+	}
+	*tmpDirs = append(*tmpDirs, dir)
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if len(paths) == 0 {
+		err = s.ExtractAll(r, rootfs)
+	} else {
+		err = s.ExtractFiles(paths, r, rootfs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while extracting squashfs filesystem: %w", err)
+	}
+
+	root, err := os.OpenRoot(rootfs)
+	if err != nil {
+		return nil, err
+	}
+	return root.FS(), nil
+}
+
+// Open returns a read-only fs.FS giving access to the root filesystem of
+// the image img. For SIF images, the content of the overlay partitions
+// is taken into account. Encrypted images are not supported. Squashfs
+// filesystems using a compression not supported in process, like lzo,
+// are extracted with unsquashfs, paths limits the extraction to the
+// given relative paths if any. The returned cleanup function removes the
+// extracted files and must be called once the filesystem is no longer
+// used.
+func Open(img *image.Image, paths ...string) (fs.FS, func(), error) {
+	var tmpDirs []string
+	cleanup := func() {
+		for _, d := range tmpDirs {
+			if err := os.RemoveAll(d); err != nil {
+				sylog.Warningf("Could not remove %s: %v", d, err)
+			}
+		}
+	}
+
+	fsys, err := open(img, paths, &tmpDirs)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return fsys, cleanup, nil
+}
+
+func open(img *image.Image, paths []string, tmpDirs *[]string) (fs.FS, error) {
+	if img.Type == image.SANDBOX {
+		root, err := os.OpenRoot(img.Path)
+		if err != nil {
+			return nil, err
+		}
+		return root.FS(), nil
+	}
+
+	if img.File == nil {
+		return nil, fmt.Errorf("image is not open for read")
+	}
+
+	rootfs, err := img.GetRootFsPartition()
+	if err != nil {
+		return nil, err
+	}
+	root, err := partitionFS(img, *rootfs, paths, tmpDirs)
+	if err != nil {
+		return nil, err
+	} else if img.Type != image.SIF {
+		return root, nil
+	}
+
+	// overlay partitions are stacked on top of the root filesystem
+	// in the reverse order of their addition
+	overlays, err := img.GetOverlayPartitions()
+	if err != nil {
+		return nil, err
+	} else if len(overlays) == 0 {
+		return root, nil
+	}
+	// overlay files are stored in the upper directory
+	upperPaths := make([]string, 0, len(paths))
+	for _, p := range paths {
+		upperPaths = append(upperPaths, path.Join("upper", p))
+	}
+	layers := []fs.FS{root}
+	for _, p := range overlays {
+		layer, err := partitionFS(img, p, upperPaths, tmpDirs)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := fs.Sub(layer, "upper")
+		if err != nil {
+			return nil, err
+		}
+		layers = append([]fs.FS{upper}, layers...)
+	}
+
+	return &overlayFS{layers: layers}, nil
+}
@@ -0,0 +1,329 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagefs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/apptainer/apptainer/internal/pkg/test/tool/require"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+const testSquash = "../../../../pkg/image/testdata/squashfs.v4"
+
+// makeTree populates dir with files covering the different inode
+// types, directory sizes and file block mappings.
+func makeTree(t *testing.T, dir string) {
+	t.Helper()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	files := map[string][]byte{
+		".singularity.d/runscript":                   []byte("#!/bin/sh\necho hello\n"),
+		".singularity.d/env/90-environment.sh":       []byte("export FOO=bar\n"),
+		".singularity.d/labels.json":                 []byte("{}\n"),
+		"scif/apps/app1/scif/runscript":              []byte("app1\n"),
+		"usr/share/big":                              big,
+		"empty":                                      nil,
+		"usr/share/many/" + strings.Repeat("x", 200): []byte("long name\n"),
+	}
+	for i := range 300 {
+		files[filepath.Join("usr/share/many", strings.Repeat("f", i%100+1)+string(rune('a'+i/100)))] = nil
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"singularity":     ".singularity.d/runscript",
+		"environment":     "/usr/../.singularity.d/env/90-environment.sh",
+		"usr/share/up":    "../../../../../.singularity.d",
+		"usr/share/long":  strings.Repeat("./", 40) + "big",
+		"usr/share/loop":  "loop",
+		"usr/share/dead":  "nonexistent",
+		"usr/share/apps":  "/scif/apps",
+		"usr/share/runsc": "apps/app1/scif/runscript",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree checks that fsys gives access to the content created by
+// makeTree.
+func checkTree(t *testing.T, fsys fs.FS) {
+	t.Helper()
+
+	// the loop and dead symbolic links would make TestFS fail
+	sub, err := fs.Sub(fsys, ".singularity.d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "runscript", "env/90-environment.sh", "labels.json"); err != nil {
+		t.Errorf("unexpected filesystem behavior: %s", err)
+	}
+
+	contents := map[string]string{
+		"singularity":                                "#!/bin/sh\necho hello\n",
+		"environment":                                "export FOO=bar\n",
+		"usr/share/up/env/90-environment.sh":         "export FOO=bar\n",
+		"usr/share/runsc":                            "app1\n",
+		"usr/share/many/" + strings.Repeat("x", 200): "long name\n",
+		"empty": "",
+	}
+	for name, want := range contents {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Errorf("unexpected error while reading %s: %s", name, err)
+		} else if string(b) != want {
+			t.Errorf("unexpected content for %s: got %q instead of %q", name, b, want)
+		}
+	}
+
+	big, err := fs.ReadFile(fsys, "usr/share/long")
+	if err != nil {
+		t.Errorf("unexpected error while reading big file: %s", err)
+	} else if !bytes.Equal(big, bytes.Repeat([]byte("0123456789abcdef"), 64*1024)) {
+		t.Errorf("unexpected content for big file")
+	}
+
+	entries, err := fs.ReadDir(fsys, "usr/share/many")
+	if err != nil {
+		t.Errorf("unexpected error while reading directory: %s", err)
+	} else if len(entries) != 301 {
+		t.Errorf("unexpected number of entries: got %d instead of 301", len(entries))
+	}
+
+	if target, err := fs.ReadLink(fsys, "usr/share/apps"); err != nil {
+		t.Errorf("unexpected error while reading link: %s", err)
+	} else if target != "/scif/apps" {
+		t.Errorf("unexpected link target %q", target)
+	}
+	if fi, err := fs.Lstat(fsys, "usr/share/apps"); err != nil {
+		t.Errorf("unexpected error while reading link: %s", err)
+	} else if fi.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("unexpected mode %v for link", fi.Mode())
+	}
+
+	for _, name := range []string{"usr/share/loop", "usr/share/dead", "nonexistent/file", "empty/file"} {
+		if _, err := fs.ReadFile(fsys, name); err == nil {
+			t.Errorf("unexpected success while reading %s", name)
+		}
+	}
+	if _, err := fs.ReadFile(fsys, "usr/share/dead"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error for dead link: %v", err)
+	}
+}
+
+func mkfsExt3(t *testing.T, dir string) string {
+	t.Helper()
+
+	img := filepath.Join(t.TempDir(), "ext3.img")
+	cmd := exec.Command("mkfs.ext3", "-q", "-F", "-d", dir, img, "8M")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("while creating ext3 image: %s: %s", err, out)
+	}
+	return img
+}
+
+func TestExt3(t *testing.T) {
+	require.MkfsExt3(t)
+
+	dir := t.TempDir()
+	makeTree(t, dir)
+	img := mkfsExt3(t, dir)
+
+	f, err := os.Open(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fsys, err := New(f, image.EXT3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkTree(t, fsys)
+
+	if _, err := New(f, image.SQUASHFS); err == nil {
+		t.Errorf("unexpected success for ext3 image read as squashfs")
+	}
+}
+
+func TestSquashfs(t *testing.T) {
+	f, err := os.Open(testSquash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fsys, err := New(f, image.SQUASHFS)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := fstest.TestFS(fsys, "examplefile"); err != nil {
+		t.Errorf("unexpected filesystem behavior: %s", err)
+	}
+
+	b, err := fs.ReadFile(fsys, "examplefile")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	} else if string(b) != "Example File Contents\n" {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	if _, err := New(f, image.EXT3); err == nil {
+		t.Errorf("unexpected success for squashfs image read as ext3")
+	}
+
+	// lzo compression is left to unsquashfs
+	b, err = os.ReadFile(testSquash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint16(b[20:], squashfsLzo)
+	if _, err := New(bytes.NewReader(b), image.SQUASHFS); !errors.Is(err, errUnsupportedCompression) {
+		t.Errorf("unexpected error for lzo compression: %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("sandbox", func(t *testing.T) {
+		dir := t.TempDir()
+		makeTree(t, dir)
+
+		img, err := image.Init(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys, cleanup, err := Open(img)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer cleanup()
+		b, err := fs.ReadFile(fsys, ".singularity.d/runscript")
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if string(b) != "#!/bin/sh\necho hello\n" {
+			t.Errorf("unexpected content %q", b)
+		}
+	})
+
+	t.Run("ext3", func(t *testing.T) {
+		require.MkfsExt3(t)
+
+		dir := t.TempDir()
+		makeTree(t, dir)
+
+		img, err := image.Init(mkfsExt3(t, dir), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer img.File.Close()
+
+		fsys, cleanup, err := Open(img)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer cleanup()
+		checkTree(t, fsys)
+	})
+
+	t.Run("sif", func(t *testing.T) {
+		require.MkfsExt3(t)
+		require.Command(t, "debugfs")
+
+		// an overlay adding a file and hiding the one of the root
+		// filesystem with a whiteout
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "upper"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "upper", "overlay"), []byte("overlay\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		overlay := mkfsExt3(t, dir)
+		cmd := exec.Command("debugfs", "-w", "-f", "-", overlay)
+		cmd.Stdin = strings.NewReader("cd upper\nmknod examplefile c 0 0\n")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("while creating whiteout: %s: %s", err, out)
+		}
+
+		squash, err := os.ReadFile(testSquash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ext3, err := os.ReadFile(overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rootfs, err := sif.NewDescriptorInput(sif.DataPartition, bytes.NewReader(squash),
+			sif.OptPartitionMetadata(sif.FsSquash, sif.PartPrimSys, runtime.GOARCH),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		upper, err := sif.NewDescriptorInput(sif.DataPartition, bytes.NewReader(ext3),
+			sif.OptPartitionMetadata(sif.FsExt3, sif.PartOverlay, runtime.GOARCH),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "image.sif")
+		fp, err := sif.CreateContainerAtPath(path, sif.OptCreateWithDescriptors(rootfs, upper))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp.UnloadContainer()
+
+		img, err := image.Init(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer img.File.Close()
+
+		fsys, cleanup, err := Open(img)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer cleanup()
+		if _, err := fs.ReadFile(fsys, "examplefile"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("unexpected error for whiteout file: %v", err)
+		}
+		if b, err := fs.ReadFile(fsys, "overlay"); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if string(b) != "overlay\n" {
+			t.Errorf("unexpected content %q", b)
+		}
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		} else if len(entries) != 1 || entries[0].Name() != "overlay" {
+			t.Errorf("unexpected root entries %v", entries)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagefs
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// overlayFS merges filesystem layers the way overlayfs does for upper
+// directories of SIF overlay partitions, the first layer being the
+// uppermost one. Whiteouts are honored, opaque directories are not.
+type overlayFS struct {
+	layers []fs.FS
+}
+
+var _ fs.ReadDirFS = (*overlayFS)(nil)
+
+// isWhiteout returns true if the file information corresponds to an
+// overlay whiteout, a character device.
+func isWhiteout(fi fs.FileInfo) bool {
+	return fi.Mode()&fs.ModeCharDevice != 0
+}
+
+// Open opens the named file from the uppermost layer containing it.
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, l := range o.layers {
+		if fi, err := fs.Lstat(l, name); err == nil && isWhiteout(fi) {
+			break
+		}
+		f, err := l.Open(name)
+		if err == nil {
+			return f, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the merged entries of the named directory of all
+// layers sorted by filename.
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	seen := make(map[string]bool)
+	entries := make([]fs.DirEntry, 0)
+	found := false
+
+	for _, l := range o.layers {
+		fi, err := fs.Stat(l, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		} else if isWhiteout(fi) {
+			break
+		}
+		layerEntries, err := fs.ReadDir(l, name)
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range layerEntries {
+			if seen[e.Name()] {
+				continue
+			}
+			seen[e.Name()] = true
+			if e.Type()&fs.ModeCharDevice == 0 {
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
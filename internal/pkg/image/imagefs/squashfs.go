@@ -0,0 +1,602 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package imagefs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	squashfsMagic         = 0x73717368
+	squashfsSuperSize     = 96
+	squashfsMetadataSize  = 8192
+	squashfsInvalidFrag   = 0xffffffff
+	squashfsUncompressed  = 1 << 24
+	squashfsMetaUncompBit = 1 << 15
+)
+
+// squashfs compressor identifiers.
+const (
+	squashfsGzip = iota + 1
+	squashfsLzma
+	squashfsLzo
+	squashfsXz
+	squashfsLz4
+	squashfsZstd
+)
+
+// errUnsupportedCompression is returned for squashfs filesystems using
+// a compression the parser doesn't support.
+var errUnsupportedCompression = errors.New("unsupported squashfs compression")
+
+// squashfs inode types.
+const (
+	squashfsDirType = iota + 1
+	squashfsFileType
+	squashfsSymlinkType
+	squashfsBlkdevType
+	squashfsChrdevType
+	squashfsFifoType
+	squashfsSocketType
+	squashfsLDirType
+	squashfsLFileType
+	squashfsLSymlinkType
+	squashfsLBlkdevType
+	squashfsLChrdevType
+	squashfsLFifoType
+	squashfsLSocketType
+)
+
+type squashfsSuperblock struct {
+	Magic               uint32
+	Inodes              uint32
+	MkfsTime            uint32
+	BlockSize           uint32
+	Fragments           uint32
+	Compression         uint16
+	BlockLog            uint16
+	Flags               uint16
+	NoIDs               uint16
+	Major               uint16
+	Minor               uint16
+	RootInode           uint64
+	BytesUsed           uint64
+	IDTableStart        uint64
+	XattrIDTableStart   uint64
+	InodeTableStart     uint64
+	DirectoryTableStart uint64
+	FragmentTableStart  uint64
+	LookupTableStart    uint64
+}
+
+type squashfsFragment struct {
+	Start  uint64
+	Size   uint32
+	Unused uint32
+}
+
+// squashfsFile holds the data location of a regular file inode.
+type squashfsFile struct {
+	start      uint64
+	blocks     []uint32
+	fragment   uint32
+	fragOffset uint32
+}
+
+// squashfsDir holds the listing location of a directory inode.
+type squashfsDir struct {
+	block  uint32
+	offset uint16
+	size   uint32
+}
+
+type squashfs struct {
+	r     io.ReaderAt
+	sb    squashfsSuperblock
+	frags []squashfsFragment
+
+	mu sync.Mutex
+	// metadata caches uncompressed metadata blocks by position
+	metadata map[int64]*squashfsMetadata
+}
+
+type squashfsMetadata struct {
+	data []byte
+	next int64
+}
+
+func newSquashfs(r io.ReaderAt) (*squashfs, error) {
+	s := &squashfs{r: r, metadata: make(map[int64]*squashfsMetadata)}
+
+	err := binary.Read(io.NewSectionReader(r, 0, squashfsSuperSize), binary.LittleEndian, &s.sb)
+	if err != nil {
+		return nil, fmt.Errorf("while reading squashfs superblock: %w", err)
+	}
+	if s.sb.Magic != squashfsMagic {
+		return nil, fmt.Errorf("not a squashfs filesystem")
+	}
+	if s.sb.Major != 4 {
+		return nil, fmt.Errorf("unsupported squashfs version %d.%d", s.sb.Major, s.sb.Minor)
+	}
+	if s.sb.BlockSize == 0 || s.sb.BlockSize > 1<<20 {
+		return nil, fmt.Errorf("bad squashfs block size %d", s.sb.BlockSize)
+	}
+	switch s.sb.Compression {
+	case squashfsGzip, squashfsLzma, squashfsXz, squashfsLz4, squashfsZstd:
+	case squashfsLzo:
+		return nil, fmt.Errorf("%w lzo", errUnsupportedCompression)
+	default:
+		return nil, fmt.Errorf("unknown squashfs compression %d", s.sb.Compression)
+	}
+
+	if s.sb.Fragments > 0 {
+		if err := s.readFragmentTable(); err != nil {
+			return nil, fmt.Errorf("while reading squashfs fragment table: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// decompress decompresses data, size is the maximum size of the
+// uncompressed data.
+func (s *squashfs) decompress(data []byte, size int) ([]byte, error) {
+	var r io.Reader
+	var err error
+
+	switch s.sb.Compression {
+	case squashfsGzip:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	case squashfsLzma:
+		r, err = lzma.NewReader(bytes.NewReader(data))
+	case squashfsXz:
+		r, err = xz.NewReader(bytes.NewReader(data))
+	case squashfsLz4:
+		out := make([]byte, size)
+		n, err := lz4.UncompressBlock(data, out)
+		if err != nil {
+			return nil, err
+		}
+		return out[:n], nil
+	case squashfsZstd:
+		d, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.DecodeAll(data, make([]byte, 0, size))
+	}
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// readMetadata reads the metadata block at position pos.
+func (s *squashfs) readMetadata(pos int64) (*squashfsMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.metadata[pos]; ok {
+		return m, nil
+	}
+
+	var hdr [2]byte
+	if _, err := s.r.ReadAt(hdr[:], pos); err != nil {
+		return nil, fmt.Errorf("while reading metadata block at %d: %w", pos, err)
+	}
+	h := binary.LittleEndian.Uint16(hdr[:])
+	size := int(h &^ squashfsMetaUncompBit)
+	if size > squashfsMetadataSize {
+		return nil, fmt.Errorf("bad metadata block size %d at %d", size, pos)
+	}
+
+	data := make([]byte, size)
+	if _, err := s.r.ReadAt(data, pos+2); err != nil {
+		return nil, fmt.Errorf("while reading metadata block at %d: %w", pos, err)
+	}
+	if h&squashfsMetaUncompBit == 0 {
+		var err error
+		data, err = s.decompress(data, squashfsMetadataSize)
+		if err != nil {
+			return nil, fmt.Errorf("while decompressing metadata block at %d: %w", pos, err)
+		}
+	}
+
+	m := &squashfsMetadata{data: data, next: pos + 2 + int64(size)}
+	s.metadata[pos] = m
+	return m, nil
+}
+
+// metadataReader reads a metadata stream spanning over consecutive
+// metadata blocks.
+type metadataReader struct {
+	s   *squashfs
+	m   *squashfsMetadata
+	off int
+}
+
+func (s *squashfs) newMetadataReader(pos int64, offset int) (*metadataReader, error) {
+	m, err := s.readMetadata(pos)
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(m.data) {
+		return nil, fmt.Errorf("bad metadata offset %d at %d", offset, pos)
+	}
+	return &metadataReader{s: s, m: m, off: offset}, nil
+}
+
+func (mr *metadataReader) Read(b []byte) (int, error) {
+	if mr.off == len(mr.m.data) {
+		m, err := mr.s.readMetadata(mr.m.next)
+		if err != nil {
+			return 0, err
+		}
+		mr.m, mr.off = m, 0
+		if len(m.data) == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	n := copy(b, mr.m.data[mr.off:])
+	mr.off += n
+	return n, nil
+}
+
+func (s *squashfs) readFragmentTable() error {
+	count := int(s.sb.Fragments)
+	entrySize := binary.Size(squashfsFragment{})
+	blocks := (count*entrySize + squashfsMetadataSize - 1) / squashfsMetadataSize
+
+	index := make([]uint64, blocks)
+	err := binary.Read(io.NewSectionReader(s.r, int64(s.sb.FragmentTableStart), int64(blocks*8)), binary.LittleEndian, index)
+	if err != nil {
+		return err
+	}
+
+	s.frags = make([]squashfsFragment, count)
+	for i, pos := range index {
+		mr, err := s.newMetadataReader(int64(pos), 0)
+		if err != nil {
+			return err
+		}
+		first := i * squashfsMetadataSize / entrySize
+		last := min(count, first+squashfsMetadataSize/entrySize)
+		if err := binary.Read(mr, binary.LittleEndian, s.frags[first:last]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *squashfs) root() (*node, error) {
+	return s.inode(s.sb.RootInode)
+}
+
+// inode reads the inode referenced by ref, the upper bits are the
+// position of the metadata block relative to the inode table and the
+// lower 16 bits the offset in the uncompressed block.
+func (s *squashfs) inode(ref uint64) (*node, error) {
+	mr, err := s.newMetadataReader(int64(s.sb.InodeTableStart+ref>>16), int(ref&0xffff))
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr struct {
+		Type        uint16
+		Mode        uint16
+		UID         uint16
+		GUID        uint16
+		Mtime       uint32
+		InodeNumber uint32
+	}
+	if err := binary.Read(mr, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("while reading inode: %w", err)
+	}
+
+	n := &node{
+		mode:  fs.FileMode(hdr.Mode & 0o777),
+		mtime: time.Unix(int64(hdr.Mtime), 0),
+	}
+	if hdr.Mode&0o4000 != 0 {
+		n.mode |= fs.ModeSetuid
+	}
+	if hdr.Mode&0o2000 != 0 {
+		n.mode |= fs.ModeSetgid
+	}
+	if hdr.Mode&0o1000 != 0 {
+		n.mode |= fs.ModeSticky
+	}
+
+	read := func(v ...any) error {
+		for _, e := range v {
+			if err := binary.Read(mr, binary.LittleEndian, e); err != nil {
+				return fmt.Errorf("while reading inode %d: %w", hdr.InodeNumber, err)
+			}
+		}
+		return nil
+	}
+
+	switch hdr.Type {
+	case squashfsDirType:
+		var d struct {
+			StartBlock uint32
+			Nlink      uint32
+			FileSize   uint16
+			Offset     uint16
+			Parent     uint32
+		}
+		if err := read(&d); err != nil {
+			return nil, err
+		}
+		n.mode |= fs.ModeDir
+		n.size = int64(d.FileSize)
+		n.data = &squashfsDir{block: d.StartBlock, offset: d.Offset, size: uint32(d.FileSize)}
+	case squashfsLDirType:
+		var d struct {
+			Nlink      uint32
+			FileSize   uint32
+			StartBlock uint32
+			Parent     uint32
+			ICount     uint16
+			Offset     uint16
+			Xattr      uint32
+		}
+		if err := read(&d); err != nil {
+			return nil, err
+		}
+		n.mode |= fs.ModeDir
+		n.size = int64(d.FileSize)
+		n.data = &squashfsDir{block: d.StartBlock, offset: d.Offset, size: d.FileSize}
+	case squashfsFileType, squashfsLFileType:
+		f := &squashfsFile{}
+		if hdr.Type == squashfsFileType {
+			var r struct {
+				StartBlock uint32
+				Fragment   uint32
+				Offset     uint32
+				FileSize   uint32
+			}
+			if err := read(&r); err != nil {
+				return nil, err
+			}
+			f.start, f.fragment, f.fragOffset = uint64(r.StartBlock), r.Fragment, r.Offset
+			n.size = int64(r.FileSize)
+		} else {
+			var r struct {
+				StartBlock uint64
+				FileSize   uint64
+				Sparse     uint64
+				Nlink      uint32
+				Fragment   uint32
+				Offset     uint32
+				Xattr      uint32
+			}
+			if err := read(&r); err != nil {
+				return nil, err
+			}
+			f.start, f.fragment, f.fragOffset = r.StartBlock, r.Fragment, r.Offset
+			n.size = int64(r.FileSize)
+		}
+		bs := int64(s.sb.BlockSize)
+		count := n.size / bs
+		if f.fragment == squashfsInvalidFrag && n.size%bs != 0 {
+			count++
+		}
+		f.blocks = make([]uint32, count)
+		if err := read(f.blocks); err != nil {
+			return nil, err
+		}
+		n.data = f
+	case squashfsSymlinkType, squashfsLSymlinkType:
+		var l struct {
+			Nlink   uint32
+			Symlink uint32
+		}
+		if err := read(&l); err != nil {
+			return nil, err
+		}
+		target := make([]byte, l.Symlink)
+		if _, err := io.ReadFull(mr, target); err != nil {
+			return nil, fmt.Errorf("while reading inode %d: %w", hdr.InodeNumber, err)
+		}
+		n.mode |= fs.ModeSymlink
+		n.size = int64(l.Symlink)
+		n.data = string(target)
+	case squashfsBlkdevType, squashfsLBlkdevType:
+		n.mode |= fs.ModeDevice
+	case squashfsChrdevType, squashfsLChrdevType:
+		n.mode |= fs.ModeDevice | fs.ModeCharDevice
+	case squashfsFifoType, squashfsLFifoType:
+		n.mode |= fs.ModeNamedPipe
+	case squashfsSocketType, squashfsLSocketType:
+		n.mode |= fs.ModeSocket
+	default:
+		return nil, fmt.Errorf("unknown type %d for inode %d", hdr.Type, hdr.InodeNumber)
+	}
+
+	return n, nil
+}
+
+func (s *squashfs) readDir(n *node) ([]dirent, error) {
+	d, ok := n.data.(*squashfsDir)
+	if !ok {
+		return nil, fmt.Errorf("not a directory")
+	}
+	// the directory size accounts for the "." and ".." entries
+	if d.size <= 3 {
+		return nil, nil
+	}
+
+	mr, err := s.newMetadataReader(int64(s.sb.DirectoryTableStart)+int64(d.block), int(d.offset))
+	if err != nil {
+		return nil, err
+	}
+	r := io.LimitReader(mr, int64(d.size-3))
+
+	var entries []dirent
+	for {
+		var hdr struct {
+			Count       uint32
+			StartBlock  uint32
+			InodeNumber uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &hdr); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("while reading directory header: %w", err)
+		}
+		for i := uint32(0); i <= hdr.Count; i++ {
+			var e struct {
+				Offset      uint16
+				InodeNumber int16
+				Type        uint16
+				Size        uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+				return nil, fmt.Errorf("while reading directory entry: %w", err)
+			}
+			name := make([]byte, int(e.Size)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, fmt.Errorf("while reading directory entry: %w", err)
+			}
+			entries = append(entries, dirent{
+				name: string(name),
+				ref:  uint64(hdr.StartBlock)<<16 | uint64(e.Offset),
+			})
+		}
+	}
+}
+
+func (s *squashfs) readLink(n *node) (string, error) {
+	target, ok := n.data.(string)
+	if !ok {
+		return "", fmt.Errorf("not a symbolic link")
+	}
+	return target, nil
+}
+
+func (s *squashfs) open(n *node) (io.ReaderAt, error) {
+	f, ok := n.data.(*squashfsFile)
+	if !ok {
+		return nil, fmt.Errorf("not a regular file")
+	}
+	if f.fragment != squashfsInvalidFrag && int(f.fragment) >= len(s.frags) {
+		return nil, fmt.Errorf("bad fragment index %d", f.fragment)
+	}
+
+	// compute the position of each data block
+	pos := make([]int64, len(f.blocks))
+	p := int64(f.start)
+	for i, b := range f.blocks {
+		pos[i] = p
+		p += int64(b &^ squashfsUncompressed)
+	}
+	return &squashfsReader{s: s, f: f, pos: pos, size: n.size}, nil
+}
+
+// squashfsReader reads the content of a regular file.
+type squashfsReader struct {
+	s    *squashfs
+	f    *squashfsFile
+	pos  []int64
+	size int64
+
+	// last uncompressed block
+	cached int
+	block  []byte
+}
+
+// readBlock returns the uncompressed content of the file block i,
+// the last block may be stored in a fragment.
+func (sr *squashfsReader) readBlock(i int) ([]byte, error) {
+	if sr.block != nil && sr.cached == i {
+		return sr.block, nil
+	}
+
+	bs := int(sr.s.sb.BlockSize)
+	var pos int64
+	var size uint32
+	fragment := i >= len(sr.f.blocks)
+	if fragment {
+		frag := sr.s.frags[sr.f.fragment]
+		pos, size = int64(frag.Start), frag.Size
+	} else {
+		pos, size = sr.pos[i], sr.f.blocks[i]
+	}
+
+	var data []byte
+	if size == 0 {
+		// sparse block
+		data = make([]byte, bs)
+	} else {
+		data = make([]byte, size&^squashfsUncompressed)
+		if _, err := sr.s.r.ReadAt(data, pos); err != nil {
+			return nil, fmt.Errorf("while reading data block at %d: %w", pos, err)
+		}
+		if size&squashfsUncompressed == 0 {
+			var err error
+			data, err = sr.s.decompress(data, bs)
+			if err != nil {
+				return nil, fmt.Errorf("while decompressing data block at %d: %w", pos, err)
+			}
+		}
+	}
+
+	if fragment {
+		start := int(sr.f.fragOffset)
+		end := start + int(sr.size%int64(bs))
+		if end > len(data) {
+			return nil, fmt.Errorf("bad fragment offset %d", start)
+		}
+		data = data[start:end]
+	}
+
+	sr.cached, sr.block = i, data
+	return data, nil
+}
+
+func (sr *squashfsReader) ReadAt(b []byte, off int64) (int, error) {
+	bs := int64(sr.s.sb.BlockSize)
+	n := 0
+	for n < len(b) {
+		if off >= sr.size {
+			return n, io.EOF
+		}
+		data, err := sr.readBlock(int(off / bs))
+		if err != nil {
+			return n, err
+		}
+		boff := int(off % bs)
+		if boff >= len(data) {
+			return n, io.ErrUnexpectedEOF
+		}
+		c := copy(b[n:], data[boff:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Event types recorded in the events log of an instance.
+const (
+	// OOMEvent is recorded when processes of the instance are killed by
+	// the OOM killer.
+	OOMEvent = "oom"
+	// PidsMaxEvent is recorded when the instance fails to create processes
+	// because of its process limit.
+	PidsMaxEvent = "pids-max"
+	// ExitEvent is recorded when the instance process exits.
+	ExitEvent = "exit"
+)
+
+// Event is an entry of the events log of an instance.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Message  string    `json:"message"`
+	Count    uint64    `json:"count,omitempty"`
+	ExitCode *int      `json:"exitCode,omitempty"`
+	Signal   string    `json:"signal,omitempty"`
+}
+
+// GetEventsFilePath returns the path of the events log of the named
+// instance, stored along with its log files.
+func GetEventsFilePath(name string, subDir string) (string, error) {
+	path, err := getPath("", subDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(path, name+".events"), nil
+}
+
+// CreateEventsFile creates, or truncates, the events log at path.
+func CreateEventsFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// AppendEvent appends the event ev, as a single line of JSON, to the events
+// log at path.
+func AppendEvent(path string, ev *Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.events")
+
+	if err := AppendEvent(path, &Event{Type: OOMEvent}); err == nil {
+		t.Fatalf("unexpected success appending to a missing events log")
+	}
+	if err := CreateEventsFile(path); err != nil {
+		t.Fatalf("while creating events log: %s", err)
+	}
+
+	exitCode := 250
+	events := []*Event{
+		{Type: OOMEvent, Message: "1 process(es) killed by the OOM killer", Count: 1},
+		{Type: ExitEvent, Message: "container process killed by the OOM killer", ExitCode: &exitCode},
+	}
+	for _, ev := range events {
+		if err := AppendEvent(path, ev); err != nil {
+			t.Fatalf("while appending event: %s", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for ; scanner.Scan(); n++ {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("while decoding event %d: %s", n, err)
+		}
+		if n >= len(events) {
+			continue
+		}
+		if ev.Type != events[n].Type || ev.Message != events[n].Message || ev.Count != events[n].Count {
+			t.Errorf("got event %+v, want %+v", ev, events[n])
+		}
+	}
+	if n != len(events) {
+		t.Errorf("got %d events, want %d", n, len(events))
+	}
+
+	// the events log is truncated when an instance is started again
+	if err := CreateEventsFile(path); err != nil {
+		t.Fatalf("while truncating events log: %s", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Errorf("events log not truncated")
+	}
+}
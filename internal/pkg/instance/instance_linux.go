@@ -21,6 +21,7 @@ import (
 	"github.com/apptainer/apptainer/internal/pkg/util/user"
 	"github.com/apptainer/apptainer/pkg/syfs"
 	"github.com/apptainer/apptainer/pkg/sylog"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
 const (
@@ -56,6 +57,10 @@ type File struct {
 	LogOutPath  string `json:"logOutPath"`
 	Checkpoint  string `json:"checkpoint"`
 	ShareNSMode bool   `json:"sharensMode"`
+	Paused      bool   `json:"paused"`
+	// Resources holds the cgroups limits of the instance, when started
+	// with cgroups limits, including the later updates
+	Resources *specs.LinuxResources `json:"resources,omitempty"`
 }
 
 // ProcName returns process name based on instance name
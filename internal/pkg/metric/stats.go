@@ -0,0 +1,116 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metric
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	lccgroups "github.com/opencontainers/cgroups"
+	"golang.org/x/sys/unix"
+)
+
+// CPUStats holds the CPU time consumed by a container, in nanoseconds.
+type CPUStats struct {
+	User   uint64 `json:"userNs"`
+	System uint64 `json:"systemNs"`
+	Total  uint64 `json:"totalNs"`
+}
+
+// IOStats holds the number of bytes read and written by a container on
+// block devices.
+type IOStats struct {
+	Read  uint64 `json:"readBytes"`
+	Write uint64 `json:"writeBytes"`
+}
+
+// ContainerStats is the resource usage report of a container, collected
+// when it exits. Resource usage is only available for containers running
+// in a cgroup. ImageDigest identifies a SIF image by its header, and the
+// descriptors and data of its objects. MemoryPeak is omitted when the cgroup doesn't report it, as
+// on cgroups v2 without memory.peak.
+type ContainerStats struct {
+	Image       string    `json:"image"`
+	ImageDigest string    `json:"imageDigest,omitempty"`
+	Instance    string    `json:"instance,omitempty"`
+	Start       time.Time `json:"start"`
+	Stop        time.Time `json:"stop"`
+	ExitCode    int       `json:"exitCode"`
+	Signal      string    `json:"signal,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Cgroup      bool      `json:"cgroup"`
+	CPU         CPUStats  `json:"cpu"`
+	MemoryPeak  uint64    `json:"memoryPeakBytes,omitempty"`
+	IO          IOStats   `json:"io"`
+	OOMKills    uint64    `json:"oomKills"`
+	PidsPeak    uint64    `json:"pidsPeak,omitempty"`
+}
+
+// SetExitStatus records the exit code of the container process from its
+// wait status. A process killed by a signal gets the exit code 128+signal,
+// as reported by shells.
+func (s *ContainerStats) SetExitStatus(status syscall.WaitStatus) {
+	switch {
+	case status.Exited():
+		s.ExitCode = status.ExitStatus()
+	case status.Signaled():
+		s.ExitCode = 128 + int(status.Signal())
+		s.Signal = unix.SignalName(status.Signal())
+	}
+}
+
+// SetCgroupStats records the CPU, memory and IO usage of the container from
+// the final statistics of its cgroup.
+func (s *ContainerStats) SetCgroupStats(stats *lccgroups.Stats) {
+	s.Cgroup = true
+
+	s.CPU = CPUStats{
+		User:   stats.CpuStats.CpuUsage.UsageInUsermode,
+		System: stats.CpuStats.CpuUsage.UsageInKernelmode,
+		Total:  stats.CpuStats.CpuUsage.TotalUsage,
+	}
+	s.MemoryPeak = stats.MemoryStats.Usage.MaxUsage
+
+	s.IO = IOStats{}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch entry.Op {
+		case "Read":
+			s.IO.Read += entry.Value
+		case "Write":
+			s.IO.Write += entry.Value
+		}
+	}
+}
+
+// WriteFile writes the report in JSON format to the file path.
+func (s *ContainerStats) WriteFile(path string) error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("while writing stats file: %w", err)
+	}
+	return nil
+}
+
+// SendStats sends the report to apptheus, as a single line JSON message.
+func (a *Apptheus) SendStats(s *ContainerStats) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if _, err := a.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("while sending stats to apptheus: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package metric
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	lccgroups "github.com/opencontainers/cgroups"
+)
+
+func TestSetExitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   syscall.WaitStatus
+		exitCode int
+		signal   string
+	}{
+		{name: "success", status: 0, exitCode: 0},
+		{name: "failure", status: 3 << 8, exitCode: 3},
+		{name: "killed", status: syscall.WaitStatus(syscall.SIGKILL), exitCode: 137, signal: "SIGKILL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ContainerStats{}
+			s.SetExitStatus(tt.status)
+			if s.ExitCode != tt.exitCode {
+				t.Errorf("got exit code %d, want %d", s.ExitCode, tt.exitCode)
+			}
+			if s.Signal != tt.signal {
+				t.Errorf("got signal %q, want %q", s.Signal, tt.signal)
+			}
+		})
+	}
+}
+
+func TestSetCgroupStats(t *testing.T) {
+	cgStats := &lccgroups.Stats{}
+	cgStats.CpuStats.CpuUsage = lccgroups.CpuUsage{
+		TotalUsage:        300,
+		UsageInUsermode:   200,
+		UsageInKernelmode: 100,
+	}
+	cgStats.MemoryStats.Usage.MaxUsage = 4096
+	cgStats.BlkioStats.IoServiceBytesRecursive = []lccgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 10},
+		{Major: 8, Minor: 0, Op: "Write", Value: 20},
+		{Major: 8, Minor: 16, Op: "Read", Value: 1},
+		{Major: 8, Minor: 16, Op: "Total", Value: 1},
+	}
+
+	s := &ContainerStats{}
+	s.SetCgroupStats(cgStats)
+
+	if !s.Cgroup {
+		t.Errorf("cgroup not recorded")
+	}
+	if want := (CPUStats{User: 200, System: 100, Total: 300}); s.CPU != want {
+		t.Errorf("got cpu stats %+v, want %+v", s.CPU, want)
+	}
+	if s.MemoryPeak != 4096 {
+		t.Errorf("got memory peak %d, want 4096", s.MemoryPeak)
+	}
+	if want := (IOStats{Read: 11, Write: 20}); s.IO != want {
+		t.Errorf("got io stats %+v, want %+v", s.IO, want)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+
+	s := &ContainerStats{Image: "/tmp/image.sif", ExitCode: 1, OOMKills: 2}
+	if err := s.WriteFile(path); err != nil {
+		t.Fatalf("while writing stats file: %s", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &ContainerStats{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("while decoding stats file: %s", err)
+	}
+	if got.Image != s.Image || got.ExitCode != s.ExitCode || got.OOMKills != s.OOMKills {
+		t.Errorf("got %+v, want %+v", got, s)
+	}
+	// an unknown memory peak is not reported as 0
+	if bytes.Contains(b, []byte("memoryPeakBytes")) {
+		t.Errorf("unexpected memory peak in %s", b)
+	}
+}
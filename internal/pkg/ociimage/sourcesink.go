@@ -42,6 +42,31 @@ const (
 )
 
 func getDockerImage(ctx context.Context, src string, tOpts *TransportOptions, rt *progressClient.RoundTripper) (v1.Image, error) {
+	srcRef, err := DockerReference(src, tOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	pullOpts := []remote.Option{
+		remote.WithContext(ctx),
+	}
+
+	if tOpts != nil {
+		pullOpts = append(pullOpts,
+			remote.WithPlatform(tOpts.Platform),
+			ociauth.AuthOptn(tOpts.AuthConfig, tOpts.AuthFilePath))
+	}
+
+	if rt != nil {
+		pullOpts = append(pullOpts, remote.WithTransport(rt))
+	}
+
+	return remote.Image(srcRef, pullOpts...)
+}
+
+// DockerReference parses the registry reference src, without the docker://
+// prefix, and substitutes the first registry mirror configured for it if any.
+func DockerReference(src string, tOpts *TransportOptions) (name.Reference, error) {
 	var nameOpts []name.Option
 	if tOpts != nil && tOpts.Insecure {
 		nameOpts = append(nameOpts, name.Insecure)
@@ -103,21 +128,7 @@ func getDockerImage(ctx context.Context, src string, tOpts *TransportOptions, rt
 		}
 	}
 
-	pullOpts := []remote.Option{
-		remote.WithContext(ctx),
-	}
-
-	if tOpts != nil {
-		pullOpts = append(pullOpts,
-			remote.WithPlatform(tOpts.Platform),
-			ociauth.AuthOptn(tOpts.AuthConfig, tOpts.AuthFilePath))
-	}
-
-	if rt != nil {
-		pullOpts = append(pullOpts, remote.WithTransport(rt))
-	}
-
-	return remote.Image(srcRef, pullOpts...)
+	return srcRef, nil
 }
 
 // getOCIImage retrieves an image from a layout ref provided in <dir>[@digest] format.
@@ -0,0 +1,180 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/apptainer/apptainer/internal/pkg/cache"
+	"github.com/apptainer/apptainer/internal/pkg/util/ociauth"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// chunkSize is the size of the byte ranges fetched from a registry.
+const chunkSize = 1 << 20
+
+// fetchCall is an in progress fetch of a chunk.
+type fetchCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// blobReader reads a remote blob on demand with HTTP range requests.
+// Fetched chunks are kept in the chunk cache, concurrent reads of the
+// same chunk are coalesced into a single request.
+type blobReader struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	digest string
+	size   int64
+	chunks *cache.Chunks
+
+	mu       sync.Mutex
+	inflight map[int64]*fetchCall
+}
+
+// newBlobReader returns a reader of the blob digest of size bytes from the
+// repository repo, credentials are read from authFile.
+func newBlobReader(ctx context.Context, repo name.Repository, authFile string, digest string, size int64, chunks *cache.Chunks) (*blobReader, error) {
+	auth, err := ociauth.Keychain(authFile).Resolve(repo)
+	if err != nil {
+		return nil, fmt.Errorf("while resolving credentials for %s: %w", repo, err)
+	}
+	rt, err := transport.NewWithContext(ctx, repo.Registry, auth, remote.DefaultTransport, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, fmt.Errorf("while authenticating to %s: %w", repo.RegistryStr(), err)
+	}
+
+	return &blobReader{
+		ctx:      ctx,
+		client:   &http.Client{Transport: rt},
+		url:      fmt.Sprintf("%s://%s/v2/%s/blobs/%s", repo.Scheme(), repo.RegistryStr(), repo.RepositoryStr(), digest),
+		digest:   digest,
+		size:     size,
+		chunks:   chunks,
+		inflight: make(map[int64]*fetchCall),
+	}, nil
+}
+
+// Size returns the blob size.
+func (b *blobReader) Size() int64 {
+	return b.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (b *blobReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= b.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < b.size {
+		index := off / chunkSize
+		data, err := b.chunk(index)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], data[off-index*chunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunkLen returns the expected length of the chunk index.
+func (b *blobReader) chunkLen(index int64) int64 {
+	return min(chunkSize, b.size-index*chunkSize)
+}
+
+// chunk returns the content of the chunk index, from the cache or
+// from the registry.
+func (b *blobReader) chunk(index int64) ([]byte, error) {
+	if b.chunks != nil {
+		if data, ok := b.chunks.Get(b.digest, index); ok && int64(len(data)) == b.chunkLen(index) {
+			return data, nil
+		}
+	}
+
+	b.mu.Lock()
+	c, ok := b.inflight[index]
+	if ok {
+		b.mu.Unlock()
+		<-c.done
+		return c.data, c.err
+	}
+	c = &fetchCall{done: make(chan struct{})}
+	b.inflight[index] = c
+	b.mu.Unlock()
+
+	c.data, c.err = b.fetch(index)
+	if c.err == nil && b.chunks != nil {
+		if err := b.chunks.Put(b.digest, index, c.data); err != nil {
+			sylog.Debugf("Could not cache chunk %d of %s: %v", index, b.digest, err)
+		}
+	}
+	close(c.done)
+
+	b.mu.Lock()
+	delete(b.inflight, index)
+	b.mu.Unlock()
+
+	return c.data, c.err
+}
+
+// fetch requests the chunk index from the registry.
+func (b *blobReader) fetch(index int64) ([]byte, error) {
+	start := index * chunkSize
+	length := b.chunkLen(index)
+
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+length-1))
+
+	sylog.Debugf("Fetching bytes %d-%d of %s", start, start+length-1, b.digest)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("while fetching %s: %w", b.digest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the registry ignored the range, skip the leading bytes
+		if _, err := io.CopyN(io.Discard, resp.Body, start); err != nil {
+			return nil, fmt.Errorf("while fetching %s: %w", b.digest, err)
+		}
+	default:
+		return nil, fmt.Errorf("while fetching %s: unexpected status %s", b.digest, resp.Status)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("while fetching %s: %w", b.digest, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package ocilazy implements lazy pulling of OCI images. A lazily pulled
+// image is a small descriptor file stored in the cache, its root filesystem
+// is served through FUSE by merging the image layers on demand. eStargz
+// layers are read with HTTP range requests so only the accessed files are
+// fetched, other layers are downloaded once into the cache.
+package ocilazy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apptainer/apptainer/internal/pkg/cache"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// FormatEstargz is the format of eStargz layers read on demand.
+	FormatEstargz = "estargz"
+	// FormatTar is the format of layers fetched into the cache as tar archives.
+	FormatTar = "tar"
+)
+
+// Layer describes a layer of a lazily pulled image.
+type Layer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Format    string `json:"format"`
+	// TOCDigest is the digest of the eStargz table of contents.
+	TOCDigest string `json:"tocDigest,omitempty"`
+	// Tar is the name of the uncompressed layer in the cache directory.
+	Tar string `json:"tar,omitempty"`
+}
+
+// Descriptor describes a lazily pulled image, it's stored as a JSON
+// document starting with the lazy image media type.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	// Repository is the repository the layers are fetched from.
+	Repository string `json:"repository"`
+	// Digest is the digest of the image manifest.
+	Digest   string `json:"digest"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// AuthFile is the registry credentials file requested by the user.
+	AuthFile string `json:"authFile,omitempty"`
+	// CacheDir is the cache directory holding the descriptor,
+	// layers and chunks.
+	CacheDir string  `json:"cacheDir"`
+	Layers   []Layer `json:"layers"`
+	// Metadata is the name of the tar archive holding the container
+	// metadata generated from the image config, in the cache directory.
+	Metadata string `json:"metadata"`
+}
+
+// Load reads the image descriptor from r.
+func Load(r io.Reader) (*Descriptor, error) {
+	d := new(Descriptor)
+	if err := json.NewDecoder(r).Decode(d); err != nil {
+		return nil, fmt.Errorf("while decoding lazy image descriptor: %w", err)
+	}
+	if d.MediaType != image.LazyMediaType {
+		return nil, fmt.Errorf("unsupported lazy image media type %q", d.MediaType)
+	}
+	return d, nil
+}
+
+// tree returns the merged root filesystem of the image. The returned
+// files must be kept open as long as the tree is in use.
+func (d *Descriptor) tree(ctx context.Context) (*tree, []*os.File, error) {
+	var nameOpts []name.Option
+	if d.Insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	repo, err := name.NewRepository(d.Repository, nameOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	chunks, err := cache.OpenChunks(d.CacheDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := newTree()
+	var files []*os.File
+
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	for _, l := range d.Layers {
+		var entries []entry
+
+		switch l.Format {
+		case FormatEstargz:
+			br, err := newBlobReader(ctx, repo, d.AuthFile, l.Digest, l.Size, chunks)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			r, err := estargz.Open(io.NewSectionReader(br, 0, l.Size))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("while opening eStargz layer %s: %w", l.Digest, err)
+			}
+			// the table of contents digest comes from the image manifest,
+			// file contents are checked against the chunk digests it holds
+			v, err := r.VerifyTOC(digest.Digest(l.TOCDigest))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("while verifying eStargz layer %s: %w", l.Digest, err)
+			}
+			entries, err = estargzEntries(r, v)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+		case FormatTar:
+			f, err := os.Open(filepath.Join(d.CacheDir, l.Tar))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("while opening layer %s: %w", l.Digest, err)
+			}
+			files = append(files, f)
+			entries, err = tarEntries(f)
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("while reading layer %s: %w", l.Digest, err)
+			}
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unsupported format %q for layer %s", l.Format, l.Digest)
+		}
+
+		t.apply(entries, false)
+	}
+
+	f, err := os.Open(filepath.Join(d.CacheDir, d.Metadata))
+	if err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("while opening metadata: %w", err)
+	}
+	files = append(files, f)
+	entries, err := tarEntries(f)
+	if err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("while reading metadata: %w", err)
+	}
+	t.apply(entries, true)
+	t.finalize()
+
+	return t, files, nil
+}
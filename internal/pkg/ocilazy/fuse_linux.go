@@ -0,0 +1,207 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// attrTimeout is the validity of attributes and entries, the filesystem
+// is immutable.
+const attrTimeout = time.Hour
+
+// lazyFS is a read-only FUSE filesystem serving a tree, nodes are
+// identified by their inode number. Operations not implemented here
+// are answered by the default go-fuse filesystem with ENOSYS, the
+// filesystem being mounted read-only the kernel rejects writes first.
+type lazyFS struct {
+	fuse.RawFileSystem
+	tree *tree
+}
+
+func newLazyFS(t *tree) *lazyFS {
+	return &lazyFS{
+		RawFileSystem: fuse.NewDefaultRawFileSystem(),
+		tree:          t,
+	}
+}
+
+func (fs *lazyFS) String() string {
+	return "lazy"
+}
+
+func (fs *lazyFS) Lookup(_ <-chan struct{}, header *fuse.InHeader, name string, out *fuse.EntryOut) fuse.Status {
+	n := fs.tree.node(header.NodeId)
+	if n == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	if !n.isDir() {
+		return fuse.ENOTDIR
+	}
+	child, ok := n.children[name]
+	if !ok {
+		return fuse.ENOENT
+	}
+	out.NodeId = child.ino
+	out.Generation = 1
+	out.SetEntryTimeout(attrTimeout)
+	out.SetAttrTimeout(attrTimeout)
+	setAttr(&out.Attr, child)
+	return fuse.OK
+}
+
+func (fs *lazyFS) GetAttr(_ <-chan struct{}, input *fuse.GetAttrIn, out *fuse.AttrOut) fuse.Status {
+	n := fs.tree.node(input.NodeId)
+	if n == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	out.SetTimeout(attrTimeout)
+	setAttr(&out.Attr, n)
+	return fuse.OK
+}
+
+func (fs *lazyFS) Readlink(_ <-chan struct{}, header *fuse.InHeader) ([]byte, fuse.Status) {
+	n := fs.tree.node(header.NodeId)
+	if n == nil {
+		return nil, fuse.Status(syscall.ESTALE)
+	}
+	if n.mode&syscall.S_IFMT != syscall.S_IFLNK {
+		return nil, fuse.EINVAL
+	}
+	return []byte(n.link), fuse.OK
+}
+
+func (fs *lazyFS) Open(_ <-chan struct{}, input *fuse.OpenIn, out *fuse.OpenOut) fuse.Status {
+	n := fs.tree.node(input.NodeId)
+	if n == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	if input.Flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return fuse.EROFS
+	}
+	if n.isDir() {
+		return fuse.EISDIR
+	}
+	out.OpenFlags = fuse.FOPEN_KEEP_CACHE
+	return fuse.OK
+}
+
+func (fs *lazyFS) Read(_ <-chan struct{}, input *fuse.ReadIn, buf []byte) (fuse.ReadResult, fuse.Status) {
+	n := fs.tree.node(input.NodeId)
+	if n == nil {
+		return nil, fuse.Status(syscall.ESTALE)
+	}
+	if n.mode&syscall.S_IFMT != syscall.S_IFREG {
+		return nil, fuse.EINVAL
+	}
+	if input.Offset >= n.size {
+		return fuse.ReadResultData(nil), fuse.OK
+	}
+
+	r, err := n.open()
+	if err != nil {
+		sylog.Errorf("While opening inode %d: %v", n.ino, err)
+		return nil, fuse.EIO
+	}
+
+	size := min(uint64(len(buf)), n.size-input.Offset)
+	read, err := r.ReadAt(buf[:size], int64(input.Offset)) //nolint:gosec
+	if err != nil && !errors.Is(err, io.EOF) {
+		sylog.Errorf("While reading inode %d: %v", n.ino, err)
+		return nil, fuse.EIO
+	}
+	return fuse.ReadResultData(buf[:read]), fuse.OK
+}
+
+func (fs *lazyFS) OpenDir(_ <-chan struct{}, input *fuse.OpenIn, _ *fuse.OpenOut) fuse.Status {
+	n := fs.tree.node(input.NodeId)
+	if n == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	if !n.isDir() {
+		return fuse.ENOTDIR
+	}
+	return fuse.OK
+}
+
+func (fs *lazyFS) ReadDir(_ <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) fuse.Status {
+	n := fs.tree.node(input.NodeId)
+	if n == nil {
+		return fuse.Status(syscall.ESTALE)
+	}
+	if !n.isDir() {
+		return fuse.ENOTDIR
+	}
+
+	// the offset is the index of the next entry, the two first ones
+	// being . and ..
+	for i := input.Offset; i < uint64(len(n.names))+2; i++ {
+		var name string
+		var child *node
+		switch i {
+		case 0:
+			name, child = ".", n
+		case 1:
+			// the kernel handles .. itself, only the type matters
+			name, child = "..", n
+		default:
+			name = n.names[i-2]
+			child = n.children[name]
+		}
+		ok := out.AddDirEntry(fuse.DirEntry{
+			Mode: child.mode,
+			Name: name,
+			Ino:  child.ino,
+			Off:  i + 1,
+		})
+		if !ok {
+			break
+		}
+	}
+	return fuse.OK
+}
+
+func (fs *lazyFS) StatFs(_ <-chan struct{}, _ *fuse.InHeader, out *fuse.StatfsOut) fuse.Status {
+	out.Files = uint64(len(fs.tree.nodes))
+	out.Bsize = 4096
+	out.NameLen = 255
+	out.Frsize = 4096
+	return fuse.OK
+}
+
+// setAttr sets the FUSE attributes a of n.
+func setAttr(a *fuse.Attr, n *node) {
+	mtime := n.mtime.Unix()
+	if n.mtime.IsZero() || mtime < 0 {
+		mtime = 0
+	}
+	nsec := uint32(n.mtime.Nanosecond()) //nolint:gosec
+
+	a.Ino = n.ino
+	a.Size = n.size
+	a.Blocks = (n.size + 511) / 512
+	a.Atime = uint64(mtime)
+	a.Mtime = uint64(mtime)
+	a.Ctime = uint64(mtime)
+	a.Atimensec = nsec
+	a.Mtimensec = nsec
+	a.Ctimensec = nsec
+	a.Mode = n.mode
+	a.Nlink = n.nlink
+	a.Uid = n.uid
+	a.Gid = n.gid
+	a.Rdev = n.rdev
+	a.Blksize = 4096
+}
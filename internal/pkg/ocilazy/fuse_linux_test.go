@@ -0,0 +1,95 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"archive/tar"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestLazyFS(t *testing.T) {
+	tr := newTree()
+	tr.apply(tarLayer(t, []tarFile{
+		{name: "etc/", typ: tar.TypeDir},
+		{name: "etc/hosts", typ: tar.TypeReg, content: "localhost"},
+		{name: "bin", typ: tar.TypeSymlink, link: "usr/bin"},
+	}), false)
+	tr.finalize()
+
+	fs := newLazyFS(tr)
+	root := uint64(1)
+
+	lookup := func(parent uint64, name string) (*fuse.EntryOut, fuse.Status) {
+		out := &fuse.EntryOut{}
+		return out, fs.Lookup(nil, &fuse.InHeader{NodeId: parent}, name, out)
+	}
+
+	etc, st := lookup(root, "etc")
+	if st != fuse.OK || etc.Attr.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		t.Fatalf("lookup etc: got status %v, mode %o", st, etc.Attr.Mode)
+	}
+	if _, st := lookup(root, "missing"); st != fuse.ENOENT {
+		t.Errorf("lookup missing: got status %v, expected ENOENT", st)
+	}
+	hosts, st := lookup(etc.NodeId, "hosts")
+	if st != fuse.OK || hosts.Attr.Size != uint64(len("localhost")) {
+		t.Fatalf("lookup etc/hosts: got status %v, size %d", st, hosts.Attr.Size)
+	}
+	if _, st := lookup(hosts.NodeId, "x"); st != fuse.ENOTDIR {
+		t.Errorf("lookup in file: got status %v, expected ENOTDIR", st)
+	}
+
+	attr := &fuse.AttrOut{}
+	if st := fs.GetAttr(nil, &fuse.GetAttrIn{InHeader: fuse.InHeader{NodeId: 1000}}, attr); st != fuse.Status(syscall.ESTALE) {
+		t.Errorf("getattr of unknown inode: got status %v, expected ESTALE", st)
+	}
+
+	bin, _ := lookup(root, "bin")
+	link, st := fs.Readlink(nil, &fuse.InHeader{NodeId: bin.NodeId})
+	if st != fuse.OK || string(link) != "usr/bin" {
+		t.Errorf("readlink bin: got %q, status %v", link, st)
+	}
+
+	open := &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: hosts.NodeId}, Flags: syscall.O_RDWR}
+	if st := fs.Open(nil, open, &fuse.OpenOut{}); st != fuse.EROFS {
+		t.Errorf("open read-write: got status %v, expected EROFS", st)
+	}
+	open.Flags = syscall.O_RDONLY
+	if st := fs.Open(nil, open, &fuse.OpenOut{}); st != fuse.OK {
+		t.Errorf("open read-only: got status %v", st)
+	}
+
+	buf := make([]byte, 64)
+	res, st := fs.Read(nil, &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: hosts.NodeId}, Offset: 5}, buf)
+	if st != fuse.OK {
+		t.Fatalf("read etc/hosts: got status %v", st)
+	}
+	if b, _ := res.Bytes(nil); string(b) != "host" {
+		t.Errorf("read etc/hosts at offset 5: got %q, expected %q", b, "host")
+	}
+
+	// ., .., bin and etc
+	list := fuse.NewDirEntryList(make([]byte, 4096), 0)
+	if st := fs.ReadDir(nil, &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: root}}, list); st != fuse.OK {
+		t.Fatalf("readdir: got status %v", st)
+	}
+	if list.Offset != 4 {
+		t.Errorf("readdir: got offset %d, expected 4", list.Offset)
+	}
+	// a small buffer holds fewer entries, the next read resumes at
+	// the returned offset
+	list = fuse.NewDirEntryList(make([]byte, 64), 0)
+	fs.ReadDir(nil, &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: root}}, list)
+	if list.Offset == 0 || list.Offset >= 4 {
+		t.Errorf("readdir with small buffer: got offset %d", list.Offset)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+)
+
+// entry is a file of a layer.
+type entry struct {
+	// path is the cleaned path relative to the root, the root is "".
+	path string
+	// typ is the tar type flag of the entry.
+	typ byte
+	// mode holds the permission bits along with setuid, setgid and sticky bits.
+	mode     uint32
+	uid      uint32
+	gid      uint32
+	size     int64
+	mtime    time.Time
+	link     string
+	devmajor uint32
+	devminor uint32
+	// data opens the content of regular files.
+	data func() (io.ReaderAt, error)
+}
+
+// cleanPath returns the path p relative to the root.
+func cleanPath(p string) string {
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// tarEntries returns the entries of the uncompressed tar archive f,
+// regular file contents are read directly from f.
+func tarEntries(f *os.File) ([]entry, error) {
+	var entries []entry
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("while reading tar entry: %w", err)
+		}
+
+		e := entry{
+			path:     cleanPath(hdr.Name),
+			typ:      hdr.Typeflag,
+			mode:     uint32(hdr.Mode & 0o7777), //nolint:gosec
+			uid:      uint32(hdr.Uid),           //nolint:gosec
+			gid:      uint32(hdr.Gid),           //nolint:gosec
+			mtime:    hdr.ModTime,
+			link:     hdr.Linkname,
+			devmajor: uint32(hdr.Devmajor), //nolint:gosec
+			devminor: uint32(hdr.Devminor), //nolint:gosec
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeChar, tar.TypeBlock, tar.TypeFifo, tar.TypeDir, tar.TypeSymlink, tar.TypeLink:
+		case tar.TypeGNUSparse:
+			return nil, fmt.Errorf("sparse file %s is not supported", hdr.Name)
+		default:
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			// the file position is at the start of the entry content
+			// right after reading the header
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			size := hdr.Size
+			e.size = size
+			e.data = func() (io.ReaderAt, error) {
+				return io.NewSectionReader(f, offset, size), nil
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// verifiedFile reads the content of a regular file of an eStargz layer,
+// each chunk is checked against its digest from the table of contents
+// before any of its bytes is returned. The last chunk read is kept as
+// reads are mostly sequential.
+type verifiedFile struct {
+	r    *estargz.Reader
+	v    estargz.TOCEntryVerifier
+	name string
+	size int64
+
+	mu    sync.Mutex
+	chunk *estargz.TOCEntry
+	data  []byte
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *verifiedFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < f.size {
+		data, start, err := f.chunkAt(off)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], data[off-start:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunkAt returns the verified content of the chunk holding the byte
+// at offset off of the file along with the chunk offset in the file.
+func (f *verifiedFile) chunkAt(off int64) ([]byte, int64, error) {
+	ce, ok := f.r.ChunkEntryForOffset(f.name, off)
+	if !ok || ce.ChunkSize <= 0 {
+		return nil, 0, fmt.Errorf("no chunk found at offset %d of %s", off, f.name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.chunk == ce {
+		return f.data, ce.ChunkOffset, nil
+	}
+
+	sr, err := f.r.OpenFile(f.name)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, ce.ChunkSize)
+	if n, err := sr.ReadAt(data, ce.ChunkOffset); n != len(data) {
+		return nil, 0, fmt.Errorf("while reading %s: %w", f.name, err)
+	}
+
+	v, err := f.v.Verifier(ce)
+	if err != nil {
+		return nil, 0, fmt.Errorf("while verifying %s: %w", f.name, err)
+	}
+	if _, err := v.Write(data); err != nil {
+		return nil, 0, err
+	}
+	if !v.Verified() {
+		return nil, 0, fmt.Errorf("content of %s at offset %d doesn't match its digest", f.name, ce.ChunkOffset)
+	}
+
+	f.chunk = ce
+	f.data = data
+	return data, ce.ChunkOffset, nil
+}
+
+// estargzEntries returns the entries of the eStargz layer r, regular file
+// contents are checked with the verifier v returned by r.VerifyTOC.
+// Hardlinks are reported as such, pointing to the first path of the
+// linked file.
+func estargzEntries(r *estargz.Reader, v estargz.TOCEntryVerifier) ([]entry, error) {
+	root, ok := r.Lookup("")
+	if !ok {
+		return nil, fmt.Errorf("no root directory found in eStargz layer")
+	}
+
+	var entries []entry
+	seen := make(map[*estargz.TOCEntry]string)
+
+	var walk func(dir string, te *estargz.TOCEntry) error
+	walk = func(dir string, te *estargz.TOCEntry) error {
+		names := make([]string, 0)
+		te.ForeachChild(func(name string, _ *estargz.TOCEntry) bool {
+			names = append(names, name)
+			return true
+		})
+		sort.Strings(names)
+
+		for _, name := range names {
+			child, _ := te.LookupChild(name)
+			p := path.Join(dir, name)
+			if dir == "" && (name == estargz.PrefetchLandmark || name == estargz.NoPrefetchLandmark) {
+				continue
+			}
+			if orig, ok := seen[child]; ok {
+				entries = append(entries, entry{path: p, typ: tar.TypeLink, link: orig})
+				continue
+			}
+			seen[child] = p
+
+			e := entry{
+				path:     p,
+				mode:     uint32(child.Mode & 0o7777), //nolint:gosec
+				uid:      uint32(child.UID),           //nolint:gosec
+				gid:      uint32(child.GID),           //nolint:gosec
+				mtime:    child.ModTime(),
+				link:     child.LinkName,
+				devmajor: uint32(child.DevMajor), //nolint:gosec
+				devminor: uint32(child.DevMinor), //nolint:gosec
+			}
+			switch child.Type {
+			case "dir":
+				e.typ = tar.TypeDir
+			case "reg":
+				e.typ = tar.TypeReg
+				e.size = child.Size
+				f := &verifiedFile{r: r, v: v, name: child.Name, size: child.Size}
+				e.data = func() (io.ReaderAt, error) {
+					return f, nil
+				}
+			case "symlink":
+				e.typ = tar.TypeSymlink
+			case "char":
+				e.typ = tar.TypeChar
+			case "block":
+				e.typ = tar.TypeBlock
+			case "fifo":
+				e.typ = tar.TypeFifo
+			default:
+				continue
+			}
+			entries = append(entries, e)
+
+			if child.Type == "dir" {
+				if err := walk(p, child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk("", root); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,403 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/cache"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/opencontainers/go-digest"
+)
+
+type tarFile struct {
+	name    string
+	typ     byte
+	content string
+	link    string
+}
+
+func makeTar(t *testing.T, files []tarFile) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.name,
+			Typeflag: f.typ,
+			Mode:     0o644,
+			Size:     int64(len(f.content)),
+			Linkname: f.link,
+			ModTime:  time.Unix(0, 0),
+		}
+		if f.typ == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("while writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatalf("while writing tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("while closing tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarLayer(t *testing.T, files []tarFile) []entry {
+	t.Helper()
+
+	f, err := os.CreateTemp(t.TempDir(), "layer-")
+	if err != nil {
+		t.Fatalf("while creating layer: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if _, err := f.Write(makeTar(t, files)); err != nil {
+		t.Fatalf("while writing layer: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("while seeking layer: %v", err)
+	}
+	entries, err := tarEntries(f)
+	if err != nil {
+		t.Fatalf("while reading layer: %v", err)
+	}
+	return entries
+}
+
+func readNode(t *testing.T, n *node) string {
+	t.Helper()
+
+	r, err := n.open()
+	if err != nil {
+		t.Fatalf("while opening node: %v", err)
+	}
+	b := make([]byte, n.size)
+	if _, err := r.ReadAt(b, 0); err != nil && err != io.EOF {
+		t.Fatalf("while reading node: %v", err)
+	}
+	return string(b)
+}
+
+func TestTree(t *testing.T) {
+	lower := tarLayer(t, []tarFile{
+		{name: "etc/", typ: tar.TypeDir},
+		{name: "etc/passwd", typ: tar.TypeReg, content: "root"},
+		{name: "etc/hosts", typ: tar.TypeReg, content: "localhost"},
+		{name: "etc/hostname", typ: tar.TypeLink, link: "etc/hosts"},
+		{name: "opt/", typ: tar.TypeDir},
+		{name: "opt/app/bin", typ: tar.TypeReg, content: "lower"},
+		{name: "bin", typ: tar.TypeSymlink, link: "usr/bin"},
+	})
+	upper := tarLayer(t, []tarFile{
+		{name: "etc/.wh.passwd", typ: tar.TypeReg},
+		{name: "opt/app/.wh..wh..opq", typ: tar.TypeReg},
+		{name: "opt/app/run", typ: tar.TypeReg, content: "upper"},
+		{name: ".singularity.d/runscript", typ: tar.TypeReg, content: "image"},
+	})
+	metadata := tarLayer(t, []tarFile{
+		{name: ".singularity.d/", typ: tar.TypeDir},
+		{name: ".singularity.d/runscript", typ: tar.TypeReg, content: "metadata"},
+		{name: "etc/hosts", typ: tar.TypeReg, content: "ignored"},
+	})
+
+	tr := newTree()
+	tr.apply(lower, false)
+	tr.apply(upper, false)
+	tr.apply(metadata, true)
+	tr.finalize()
+
+	if n := tr.get("etc/passwd"); n != nil {
+		t.Errorf("etc/passwd not removed by whiteout")
+	}
+	if n := tr.get("opt/app/bin"); n != nil {
+		t.Errorf("opt/app/bin not removed by opaque whiteout")
+	}
+	if n := tr.get("opt/app/run"); n == nil || readNode(t, n) != "upper" {
+		t.Errorf("unexpected opt/app/run")
+	}
+	if n := tr.get("etc/hosts"); n == nil || readNode(t, n) != "localhost" {
+		t.Errorf("etc/hosts overridden by metadata layer")
+	}
+	if n := tr.get(".singularity.d/runscript"); n == nil || readNode(t, n) != "metadata" {
+		t.Errorf("runscript not overridden by metadata layer")
+	}
+	if n := tr.get("bin"); n == nil || n.link != "usr/bin" {
+		t.Errorf("unexpected bin symlink")
+	}
+
+	hosts, hostname := tr.get("etc/hosts"), tr.get("etc/hostname")
+	if hosts != hostname || hosts.nlink != 2 {
+		t.Errorf("etc/hostname is not a hard link to etc/hosts")
+	}
+
+	if len(tr.nodes) != len(tr.all()) {
+		t.Errorf("expected %d inodes, got %d", len(tr.all()), len(tr.nodes))
+	}
+	for i, n := range tr.nodes {
+		if n.ino != uint64(i+1) || tr.node(n.ino) != n {
+			t.Errorf("inode %d not indexed", n.ino)
+		}
+	}
+	if tr.root.ino != 1 {
+		t.Errorf("unexpected root inode %d", tr.root.ino)
+	}
+}
+
+// newRegistry returns a registry serving blobs, and the number of blob
+// requests it received.
+func newRegistry(t *testing.T, blobs map[string][]byte) (name.Repository, *atomic.Int32) {
+	t.Helper()
+
+	requests := new(atomic.Int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		dgst := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		b, ok := blobs[dgst]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	}))
+	t.Cleanup(srv.Close)
+
+	repo, err := name.NewRepository(strings.TrimPrefix(srv.URL, "http://")+"/test/image", name.Insecure)
+	if err != nil {
+		t.Fatalf("while parsing repository: %v", err)
+	}
+	return repo, requests
+}
+
+func newChunks(t *testing.T) *cache.Chunks {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "chunks")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("while creating chunk cache: %v", err)
+	}
+	chunks, err := cache.OpenChunks(dir)
+	if err != nil {
+		t.Fatalf("while opening chunk cache: %v", err)
+	}
+	return chunks
+}
+
+func TestBlobReader(t *testing.T) {
+	blob := make([]byte, 2*chunkSize+10)
+	for i := range blob {
+		blob[i] = byte(i % 251)
+	}
+	dgst := digest.FromBytes(blob).String()
+	repo, requests := newRegistry(t, map[string][]byte{dgst: blob})
+	chunks := newChunks(t)
+
+	br, err := newBlobReader(context.Background(), repo, "", dgst, int64(len(blob)), chunks)
+	if err != nil {
+		t.Fatalf("while creating blob reader: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		off  int64
+		size int
+	}{
+		{"Start", 0, 100},
+		{"AcrossChunks", chunkSize - 5, 10},
+		{"LastChunk", 2 * chunkSize, 10},
+		{"Cached", 10, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.size)
+			n, err := br.ReadAt(p, tt.off)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(p[:n], blob[tt.off:tt.off+int64(tt.size)]) {
+				t.Errorf("unexpected content at offset %d", tt.off)
+			}
+		})
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("expected 3 chunk requests, got %d", n)
+	}
+
+	p := make([]byte, 10)
+	if _, err := br.ReadAt(p, int64(len(blob))-5); err != io.EOF {
+		t.Errorf("expected EOF reading past the end, got %v", err)
+	}
+
+	// a new reader must only use the chunk cache
+	br, err = newBlobReader(context.Background(), repo, "", dgst, int64(len(blob)), chunks)
+	if err != nil {
+		t.Fatalf("while creating blob reader: %v", err)
+	}
+	if _, err := br.ReadAt(p, chunkSize); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("expected chunk to be cached, got %d requests", n)
+	}
+}
+
+// gzipCompression writes eStargz layers with a hand crafted footer, the
+// footer written by estargz relies on the gzip output of older Go versions.
+type gzipCompression struct {
+	estargz.GzipDecompressor
+}
+
+func (gzipCompression) Writer(w io.Writer) (estargz.WriteFlushCloser, error) {
+	return gzip.NewWriterLevel(w, gzip.BestCompression)
+}
+
+func (gzipCompression) WriteTOCAndFooter(w io.Writer, off int64, toc *estargz.JTOC, diffHash hash.Hash) (digest.Digest, error) {
+	tocJSON, err := json.Marshal(toc)
+	if err != nil {
+		return "", err
+	}
+	gz := gzip.NewWriter(w)
+	gw := io.Writer(gz)
+	if diffHash != nil {
+		gw = io.MultiWriter(gz, diffHash)
+	}
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: estargz.TOCTarName, Size: int64(len(tocJSON))}); err != nil {
+		return "", err
+	}
+	if _, err := tw.Write(tocJSON); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	// gzip header with the TOC offset as extra field, followed by an
+	// empty stored block, CRC32 and size
+	subfield := fmt.Sprintf("%016xSTARGZ", off)
+	footer := []byte{0x1f, 0x8b, 0x08, 0x04, 0, 0, 0, 0, 0, 0xff}
+	footer = binary.LittleEndian.AppendUint16(footer, uint16(4+len(subfield)))
+	footer = append(footer, 'S', 'G')
+	footer = binary.LittleEndian.AppendUint16(footer, uint16(len(subfield)))
+	footer = append(footer, subfield...)
+	footer = append(footer, 0x01, 0x00, 0x00, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0)
+	if len(footer) != estargz.FooterSize {
+		return "", fmt.Errorf("footer size %d, not %d", len(footer), estargz.FooterSize)
+	}
+	if _, err := w.Write(footer); err != nil {
+		return "", err
+	}
+	return digest.FromBytes(tocJSON), nil
+}
+
+func TestEstargzEntries(t *testing.T) {
+	layer := makeTar(t, []tarFile{
+		{name: "etc/", typ: tar.TypeDir},
+		{name: "etc/hosts", typ: tar.TypeReg, content: "localhost"},
+		{name: "etc/hostname", typ: tar.TypeLink, link: "etc/hosts"},
+		{name: "bin", typ: tar.TypeSymlink, link: "usr/bin"},
+	})
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(layer), 0, int64(len(layer))), estargz.WithCompression(&gzipCompression{}))
+	if err != nil {
+		t.Fatalf("while building eStargz layer: %v", err)
+	}
+	defer blob.Close()
+	b, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatalf("while reading eStargz layer: %v", err)
+	}
+
+	dgst := digest.FromBytes(b).String()
+	repo, _ := newRegistry(t, map[string][]byte{dgst: b})
+
+	br, err := newBlobReader(context.Background(), repo, "", dgst, int64(len(b)), newChunks(t))
+	if err != nil {
+		t.Fatalf("while creating blob reader: %v", err)
+	}
+	r, err := estargz.Open(io.NewSectionReader(br, 0, int64(len(b))))
+	if err != nil {
+		t.Fatalf("while opening eStargz layer: %v", err)
+	}
+	if _, err := r.VerifyTOC(digest.FromString("")); err == nil {
+		t.Errorf("unexpected success verifying the wrong table of contents digest")
+	}
+	v, err := r.VerifyTOC(blob.TOCDigest())
+	if err != nil {
+		t.Fatalf("while verifying table of contents: %v", err)
+	}
+	entries, err := estargzEntries(r, v)
+	if err != nil {
+		t.Fatalf("while reading entries: %v", err)
+	}
+
+	tr := newTree()
+	tr.apply(entries, false)
+	tr.finalize()
+
+	if n := tr.get("etc/hosts"); n == nil || readNode(t, n) != "localhost" {
+		t.Errorf("unexpected etc/hosts")
+	}
+	if tr.get("etc/hostname") != tr.get("etc/hosts") {
+		t.Errorf("etc/hostname is not a hard link to etc/hosts")
+	}
+	if n := tr.get("bin"); n == nil || n.link != "usr/bin" {
+		t.Errorf("unexpected bin symlink")
+	}
+
+	// content not matching the chunk digests must not be returned
+	entries, err = estargzEntries(r, badVerifier{})
+	if err != nil {
+		t.Fatalf("while reading entries: %v", err)
+	}
+	for _, e := range entries {
+		if e.typ != tar.TypeReg {
+			continue
+		}
+		ra, err := e.data()
+		if err != nil {
+			t.Fatalf("while opening %s: %v", e.path, err)
+		}
+		if _, err := ra.ReadAt(make([]byte, e.size), 0); err == nil {
+			t.Errorf("unexpected success reading %s with mismatching digest", e.path)
+		}
+	}
+}
+
+// badVerifier returns verifiers for a digest no content matches.
+type badVerifier struct{}
+
+func (badVerifier) Verifier(*estargz.TOCEntry) (digest.Verifier, error) {
+	return digest.FromString("tampered").Verifier(), nil
+}
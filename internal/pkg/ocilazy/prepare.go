@@ -0,0 +1,312 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/build/sources"
+	"github.com/apptainer/apptainer/internal/pkg/cache"
+	"github.com/apptainer/apptainer/internal/pkg/ociimage"
+	"github.com/apptainer/apptainer/internal/pkg/util/ociauth"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+)
+
+// Prepare resolves the docker:// image uri and returns the path of its lazy
+// image descriptor in the cache. Only the image manifest, config and the
+// table of contents of eStargz layers are fetched, other layers are
+// downloaded into the cache as they can't be read on demand.
+func Prepare(ctx context.Context, imgCache *cache.Handle, uri string, tOpts *ociimage.TransportOptions) (string, error) {
+	if imgCache == nil || imgCache.IsDisabled() {
+		return "", fmt.Errorf("lazy pulling requires the cache to be enabled")
+	}
+	if tOpts == nil {
+		tOpts = &ociimage.TransportOptions{}
+	}
+	if tOpts.AuthConfig != nil {
+		return "", fmt.Errorf("lazy pulling only supports credentials stored with 'apptainer registry login'")
+	}
+
+	ref, err := ociimage.DockerReference(strings.TrimPrefix(uri, "docker://"), tOpts)
+	if err != nil {
+		return "", fmt.Errorf("while parsing reference %s: %w", uri, err)
+	}
+
+	img, err := remote.Image(ref,
+		remote.WithContext(ctx),
+		remote.WithPlatform(tOpts.Platform),
+		ociauth.AuthOptn(nil, tOpts.AuthFilePath),
+	)
+	if err != nil {
+		return "", fmt.Errorf("while fetching image manifest: %w", err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := imgCache.GetEntry(cache.OciLazyCacheType, digest.Hex+".json")
+	if err != nil {
+		return "", err
+	}
+	defer entry.CleanTmp()
+	if entry.Exists && descriptorValid(entry.Path) {
+		sylog.Infof("Using cached lazy image descriptor")
+		return entry.Path, nil
+	}
+
+	chunks, err := imgCache.Chunks()
+	if err != nil {
+		return "", err
+	}
+
+	d := Descriptor{
+		MediaType:  image.LazyMediaType,
+		Repository: ref.Context().Name(),
+		Digest:     digest.String(),
+		Insecure:   ref.Context().Scheme() == "http",
+		AuthFile:   tOpts.AuthFilePath,
+		CacheDir:   chunks.Dir(),
+		Metadata:   digest.Hex + ".meta.tar",
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", err
+	}
+	for _, desc := range manifest.Layers {
+		l := Layer{
+			Digest:    desc.Digest.String(),
+			MediaType: string(desc.MediaType),
+			Size:      desc.Size,
+		}
+		if toc, ok := desc.Annotations[estargz.TOCJSONDigestAnnotation]; ok {
+			err := openEstargz(ctx, ref.Context(), tOpts.AuthFilePath, chunks, &l, toc)
+			if err == nil {
+				d.Layers = append(d.Layers, l)
+				continue
+			}
+			sylog.Warningf("Layer %s can't be read lazily, fetching it: %v", l.Digest, err)
+		}
+
+		l.Format = FormatTar
+		l.Tar = desc.Digest.Hex + ".tar"
+		if err := fetchLayer(imgCache, img, desc.Digest, l.Tar); err != nil {
+			return "", err
+		}
+		d.Layers = append(d.Layers, l)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("while fetching image config: %w", err)
+	}
+	if err := writeMetadata(imgCache, tOpts.TmpDir, cfg.Config, d.Metadata); err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(entry.TmpPath, b, 0o600); err != nil {
+		return "", err
+	}
+	if err := entry.Finalize(); err != nil {
+		return "", err
+	}
+
+	return entry.Path, nil
+}
+
+// descriptorValid returns true if all the cached files referenced
+// by the descriptor at path are still present.
+func descriptorValid(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	d, err := Load(f)
+	if err != nil {
+		return false
+	}
+	files := []string{d.Metadata}
+	for _, l := range d.Layers {
+		if l.Format == FormatTar {
+			files = append(files, l.Tar)
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(d.CacheDir, file)); err != nil {
+			sylog.Debugf("Cached lazy image file %s is missing", file)
+			return false
+		}
+	}
+	return true
+}
+
+// openEstargz checks that the layer l can be read lazily and fetches its
+// table of contents into the chunk cache.
+func openEstargz(ctx context.Context, repo name.Repository, authFile string, chunks *cache.Chunks, l *Layer, toc string) error {
+	br, err := newBlobReader(ctx, repo, authFile, l.Digest, l.Size, chunks)
+	if err != nil {
+		return err
+	}
+	r, err := estargz.Open(io.NewSectionReader(br, 0, l.Size))
+	if err != nil {
+		return err
+	}
+	// all chunk digests must be present to verify file contents
+	if _, err := r.VerifyTOC(digest.Digest(toc)); err != nil {
+		return err
+	}
+	l.Format = FormatEstargz
+	l.TOCDigest = toc
+	return nil
+}
+
+// fetchLayer stores the uncompressed content of the layer digest of img
+// as the cache file file.
+func fetchLayer(imgCache *cache.Handle, img v1.Image, digest v1.Hash, file string) error {
+	entry, err := imgCache.GetEntry(cache.OciLazyCacheType, file)
+	if err != nil {
+		return err
+	}
+	defer entry.CleanTmp()
+	if entry.Exists {
+		return nil
+	}
+
+	sylog.Infof("Fetching layer %s", digest)
+
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return fmt.Errorf("while fetching layer %s: %w", digest, err)
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(entry.TmpPath, os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return fmt.Errorf("while fetching layer %s: %w", digest, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return entry.Finalize()
+}
+
+// writeMetadata stores the container metadata generated from the
+// image config cfg as the tar archive file in the cache.
+func writeMetadata(imgCache *cache.Handle, tmpDir string, cfg v1.Config, file string) error {
+	entry, err := imgCache.GetEntry(cache.OciLazyCacheType, file)
+	if err != nil {
+		return err
+	}
+	defer entry.CleanTmp()
+	if entry.Exists {
+		return nil
+	}
+
+	rootfs, err := os.MkdirTemp(tmpDir, "lazy-metadata-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(rootfs)
+
+	if err := sources.InsertOCIMetadata(rootfs, cfg); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(entry.TmpPath, os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := writeTar(f, rootfs); err != nil {
+		f.Close()
+		return fmt.Errorf("while archiving metadata: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return entry.Finalize()
+}
+
+// writeTar writes the content of dir as a tar archive into w, files
+// are owned by root.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Serve serves the root filesystem of the lazy image descriptor source
+// on target until it's unmounted. When target is /dev/fd/N, N must be a
+// FUSE device file descriptor already mounted by the caller, otherwise
+// target is a directory where the filesystem is mounted.
+func Serve(ctx context.Context, source, target string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %w", source, err)
+	}
+	d, err := Load(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	t, files, err := d.tree(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	sylog.Debugf("Serving %s with %d inodes on %s", d.Digest, len(t.nodes), target)
+
+	// go-fuse handles /dev/fd/N targets as already mounted FUSE
+	// device file descriptors
+	server, err := fuse.NewServer(newLazyFS(t), target, &fuse.MountOptions{
+		AllowOther:         true,
+		FsName:             "lazy",
+		Name:               "lazy",
+		Options:            []string{"ro"},
+		DirectMount:        true,
+		DirectMountFlags:   unix.MS_NOSUID | unix.MS_NODEV | unix.MS_RDONLY,
+		DisableReadDirPlus: true,
+	})
+	if err != nil {
+		return fmt.Errorf("while mounting FUSE filesystem on %s: %w", target, err)
+	}
+	server.Serve()
+	return nil
+}
@@ -0,0 +1,295 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package ocilazy
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/apptainer/apptainer/pkg/sylog"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+	// metadataDir is the directory holding the container metadata,
+	// entries of the metadata layer always take precedence there.
+	metadataDir = ".singularity.d"
+)
+
+// node is an inode of the merged root filesystem.
+type node struct {
+	ino   uint64
+	mode  uint32
+	uid   uint32
+	gid   uint32
+	size  uint64
+	mtime time.Time
+	rdev  uint32
+	link  string
+	nlink uint32
+
+	children map[string]*node
+	// names holds the sorted children names once the tree is finalized.
+	names []string
+
+	data   func() (io.ReaderAt, error)
+	once   sync.Once
+	reader io.ReaderAt
+	err    error
+}
+
+func (n *node) isDir() bool {
+	return n.mode&syscall.S_IFMT == syscall.S_IFDIR
+}
+
+// open returns the reader of the node content, it's opened once
+// on first access.
+func (n *node) open() (io.ReaderAt, error) {
+	n.once.Do(func() {
+		if n.data == nil {
+			n.err = syscall.EINVAL
+			return
+		}
+		n.reader, n.err = n.data()
+	})
+	return n.reader, n.err
+}
+
+// tree is the merged root filesystem of the layers of an image.
+type tree struct {
+	root *node
+	// nodes is indexed by inode number minus one once finalized.
+	nodes []*node
+}
+
+func newTree() *tree {
+	return &tree{
+		root: &node{
+			mode:     syscall.S_IFDIR | 0o755,
+			children: make(map[string]*node),
+		},
+	}
+}
+
+// get returns the node at path p or nil.
+func (t *tree) get(p string) *node {
+	n := t.root
+	if p == "" {
+		return n
+	}
+	for _, name := range strings.Split(p, "/") {
+		if n.children == nil {
+			return nil
+		}
+		if n = n.children[name]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// mkdirAll returns the directory at path p, missing directories
+// are created implicitly, non-directory entries in the way are
+// replaced.
+func (t *tree) mkdirAll(p string) *node {
+	n := t.root
+	if p == "" {
+		return n
+	}
+	for _, name := range strings.Split(p, "/") {
+		child := n.children[name]
+		if child == nil || !child.isDir() {
+			child = &node{
+				mode:     syscall.S_IFDIR | 0o755,
+				children: make(map[string]*node),
+			}
+			n.children[name] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// fileMode returns the file type bits corresponding to a tar type flag.
+func fileMode(typ byte) uint32 {
+	switch typ {
+	case tar.TypeDir:
+		return syscall.S_IFDIR
+	case tar.TypeSymlink:
+		return syscall.S_IFLNK
+	case tar.TypeChar:
+		return syscall.S_IFCHR
+	case tar.TypeBlock:
+		return syscall.S_IFBLK
+	case tar.TypeFifo:
+		return syscall.S_IFIFO
+	}
+	return syscall.S_IFREG
+}
+
+// isMetadata returns true if p is in the container metadata directory.
+func isMetadata(p string) bool {
+	return p == metadataDir || strings.HasPrefix(p, metadataDir+"/")
+}
+
+// apply merges the entries of a layer on top of the tree. Whiteouts of
+// the layer are processed first as they only apply to lower layers. When
+// fill is true, entries are only added when missing, except for those in
+// the container metadata directory.
+func (t *tree) apply(entries []entry, fill bool) {
+	for _, e := range entries {
+		dir, base := path.Split(e.path)
+		dir = strings.TrimSuffix(dir, "/")
+		if !strings.HasPrefix(base, whiteoutPrefix) {
+			continue
+		}
+		parent := t.get(dir)
+		if parent == nil || !parent.isDir() {
+			continue
+		}
+		if base == whiteoutOpaque {
+			parent.children = make(map[string]*node)
+			continue
+		}
+		delete(parent.children, strings.TrimPrefix(base, whiteoutPrefix))
+	}
+
+	for _, e := range entries {
+		dir, base := path.Split(e.path)
+		dir = strings.TrimSuffix(dir, "/")
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			continue
+		}
+
+		if e.path == "" {
+			if e.typ == tar.TypeDir && !fill {
+				t.root.mode = syscall.S_IFDIR | e.mode
+				t.root.uid = e.uid
+				t.root.gid = e.gid
+				t.root.mtime = e.mtime
+			}
+			continue
+		}
+
+		parent := t.mkdirAll(dir)
+		existing := parent.children[base]
+		if fill && existing != nil && !isMetadata(e.path) {
+			continue
+		}
+
+		if e.typ == tar.TypeLink {
+			target := t.get(cleanPath(e.link))
+			if target == nil || target.isDir() {
+				sylog.Debugf("Skipping hardlink %s, target %s not found", e.path, e.link)
+				continue
+			}
+			parent.children[base] = target
+			continue
+		}
+
+		n := &node{
+			mode:  fileMode(e.typ) | e.mode,
+			uid:   e.uid,
+			gid:   e.gid,
+			mtime: e.mtime,
+			link:  e.link,
+			rdev:  e.devmajor<<8 | e.devminor&0xff | (e.devminor&^0xff)<<12,
+			data:  e.data,
+		}
+		switch e.typ {
+		case tar.TypeDir:
+			if existing != nil && existing.isDir() {
+				// keep the content of lower layers
+				existing.mode = n.mode
+				existing.uid = n.uid
+				existing.gid = n.gid
+				existing.mtime = n.mtime
+				continue
+			}
+			n.children = make(map[string]*node)
+		case tar.TypeSymlink:
+			n.size = uint64(len(e.link))
+		case tar.TypeReg:
+			n.size = uint64(e.size) //nolint:gosec
+		}
+		parent.children[base] = n
+	}
+}
+
+// finalize assigns inode numbers and link counts to the nodes reachable
+// from the root, it must be called once all the layers are applied.
+func (t *tree) finalize() {
+	t.nodes = t.nodes[:0]
+	for _, n := range t.all() {
+		n.ino = 0
+	}
+
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.ino != 0 {
+			// another name of an already numbered file
+			n.nlink++
+			return
+		}
+		t.nodes = append(t.nodes, n)
+		n.ino = uint64(len(t.nodes))
+		n.nlink = 1
+		if !n.isDir() {
+			return
+		}
+		n.nlink = 2
+		n.names = make([]string, 0, len(n.children))
+		for name := range n.children {
+			n.names = append(n.names, name)
+		}
+		sort.Strings(n.names)
+		for _, name := range n.names {
+			child := n.children[name]
+			if child.isDir() {
+				n.nlink++
+			}
+			walk(child)
+		}
+	}
+	walk(t.root)
+}
+
+// all returns all the nodes reachable from the root.
+func (t *tree) all() []*node {
+	var nodes []*node
+	seen := make(map[*node]bool)
+	var walk func(n *node)
+	walk = func(n *node) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(t.root)
+	return nodes
+}
+
+// node returns the node with the inode number ino or nil.
+func (t *tree) node(ino uint64) *node {
+	if ino == 0 || ino > uint64(len(t.nodes)) {
+		return nil
+	}
+	return t.nodes[ino-1]
+}
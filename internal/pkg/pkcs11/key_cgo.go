@@ -0,0 +1,322 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build cgo
+
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"sync"
+
+	p11 "github.com/miekg/pkcs11"
+	pkcs11uri "github.com/stefanberger/go-pkcs11uri"
+)
+
+// Key is a key stored in a PKCS#11 token. A Key opened with OpenPrivateKey
+// implements crypto.Signer.
+type Key struct {
+	mu      sync.Mutex
+	ctx     *p11.Ctx
+	session p11.SessionHandle
+	private p11.ObjectHandle
+	pub     crypto.PublicKey
+}
+
+// OpenPrivateKey opens the private key identified by the PKCS#11 URI uri,
+// pin is called if the URI doesn't specify the PIN to log in to the token.
+func OpenPrivateKey(uri string, pin PinFunc) (*Key, error) {
+	return openKey(uri, true, pin)
+}
+
+// OpenPublicKey opens the public key identified by the PKCS#11 URI uri.
+// The public key is read from a public key or a certificate object, pin
+// is called if the URI doesn't specify the PIN and the key can't be found
+// without login.
+func OpenPublicKey(uri string, pin PinFunc) (*Key, error) {
+	return openKey(uri, false, pin)
+}
+
+func openKey(uri string, private bool, pin PinFunc) (*Key, error) {
+	p11uri, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	module, err := p11uri.GetModule()
+	if err != nil {
+		return nil, fmt.Errorf("while searching PKCS#11 module: %w", err)
+	}
+
+	ctx := p11.New(module)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, p11.Error(p11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("while initializing PKCS#11 module %s: %w", module, err)
+	}
+
+	k := &Key{ctx: ctx}
+	if err := k.open(p11uri, private, pin); err != nil {
+		k.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+// findSlot returns the slot of the token matching the URI.
+func (k *Key) findSlot(p11uri *pkcs11uri.Pkcs11URI) (uint, error) {
+	if s, ok := p11uri.GetPathAttribute("slot-id", false); ok {
+		slot, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("bad slot-id %q: %w", s, err)
+		}
+		return uint(slot), nil
+	}
+
+	slots, err := k.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("while listing PKCS#11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		ti, err := k.ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		match := func(attr, value string) bool {
+			v, ok := p11uri.GetPathAttribute(attr, false)
+			return !ok || v == value
+		}
+		if match("token", ti.Label) && match("manufacturer", ti.ManufacturerID) &&
+			match("serial", ti.SerialNumber) && match("model", ti.Model) {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("no matching PKCS#11 token found")
+}
+
+func (k *Key) open(p11uri *pkcs11uri.Pkcs11URI, private bool, pin PinFunc) error {
+	slot, err := k.findSlot(p11uri)
+	if err != nil {
+		return err
+	}
+	k.session, err = k.ctx.OpenSession(slot, p11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("while opening PKCS#11 session: %w", err)
+	}
+
+	login := func() error {
+		var p string
+		if p11uri.HasPIN() {
+			p, err = p11uri.GetPIN()
+		} else if pin != nil {
+			p, err = pin()
+		} else {
+			return fmt.Errorf("PIN required to log in to the token")
+		}
+		if err != nil {
+			return fmt.Errorf("while getting PIN: %w", err)
+		}
+		err = k.ctx.Login(k.session, p11.CKU_USER, p)
+		if err != nil && !errors.Is(err, p11.Error(p11.CKR_USER_ALREADY_LOGGED_IN)) {
+			return fmt.Errorf("while logging in to the token: %w", err)
+		}
+		return nil
+	}
+
+	if private {
+		if err := login(); err != nil {
+			return err
+		}
+		k.private, err = k.findObject(p11uri, p11.CKO_PRIVATE_KEY)
+		if err != nil {
+			return err
+		}
+	}
+
+	k.pub, err = k.publicKey(p11uri)
+	if errors.Is(err, ErrNoKey) && !private && (p11uri.HasPIN() || pin != nil) {
+		// some tokens require to log in to find public objects
+		if err := login(); err != nil {
+			return err
+		}
+		k.pub, err = k.publicKey(p11uri)
+	}
+	return err
+}
+
+// findObject returns the object of class matching the URI.
+func (k *Key) findObject(p11uri *pkcs11uri.Pkcs11URI, class uint) (p11.ObjectHandle, error) {
+	template := []*p11.Attribute{p11.NewAttribute(p11.CKA_CLASS, class)}
+	if id, ok := p11uri.GetPathAttribute("id", false); ok {
+		template = append(template, p11.NewAttribute(p11.CKA_ID, []byte(id)))
+	}
+	if label, ok := p11uri.GetPathAttribute("object", false); ok {
+		template = append(template, p11.NewAttribute(p11.CKA_LABEL, label))
+	}
+
+	if err := k.ctx.FindObjectsInit(k.session, template); err != nil {
+		return 0, fmt.Errorf("while searching PKCS#11 objects: %w", err)
+	}
+	objs, _, err := k.ctx.FindObjects(k.session, 2)
+	if ferr := k.ctx.FindObjectsFinal(k.session); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("while searching PKCS#11 objects: %w", err)
+	}
+
+	switch len(objs) {
+	case 0:
+		return 0, ErrNoKey
+	case 1:
+		return objs[0], nil
+	default:
+		return 0, fmt.Errorf("PKCS#11 URI matches more than one object")
+	}
+}
+
+func (k *Key) attributes(obj p11.ObjectHandle, types ...uint) ([][]byte, error) {
+	template := make([]*p11.Attribute, len(types))
+	for i, t := range types {
+		template[i] = p11.NewAttribute(t, nil)
+	}
+	attrs, err := k.ctx.GetAttributeValue(k.session, obj, template)
+	if err != nil {
+		return nil, fmt.Errorf("while reading PKCS#11 object attributes: %w", err)
+	}
+	values := make([][]byte, len(attrs))
+	for i, a := range attrs {
+		values[i] = a.Value
+	}
+	return values, nil
+}
+
+// publicKey returns the public key from the public key or certificate
+// object matching the URI.
+func (k *Key) publicKey(p11uri *pkcs11uri.Pkcs11URI) (crypto.PublicKey, error) {
+	obj, err := k.findObject(p11uri, p11.CKO_PUBLIC_KEY)
+	if errors.Is(err, ErrNoKey) {
+		cert, err := k.findObject(p11uri, p11.CKO_CERTIFICATE)
+		if err != nil {
+			return nil, err
+		}
+		v, err := k.attributes(cert, p11.CKA_VALUE)
+		if err != nil {
+			return nil, err
+		}
+		return certificatePublicKey(v[0])
+	} else if err != nil {
+		return nil, err
+	}
+
+	v, err := k.attributes(obj, p11.CKA_KEY_TYPE)
+	if err != nil {
+		return nil, err
+	}
+	// CK_ULONG attribute values use the native byte order
+	var keyType uint
+	switch len(v[0]) {
+	case 8:
+		keyType = uint(binary.NativeEndian.Uint64(v[0]))
+	case 4:
+		keyType = uint(binary.NativeEndian.Uint32(v[0]))
+	default:
+		return nil, fmt.Errorf("bad PKCS#11 key type attribute")
+	}
+
+	switch keyType {
+	case p11.CKK_RSA:
+		v, err := k.attributes(obj, p11.CKA_MODULUS, p11.CKA_PUBLIC_EXPONENT)
+		if err != nil {
+			return nil, err
+		}
+		e := new(big.Int).SetBytes(v[1])
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("bad RSA public exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(v[0]), E: int(e.Int64())}, nil
+	case p11.CKK_EC:
+		v, err := k.attributes(obj, p11.CKA_EC_PARAMS, p11.CKA_EC_POINT)
+		if err != nil {
+			return nil, err
+		}
+		return ecPublicKey(v[0], v[1])
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 key type 0x%x", keyType)
+	}
+}
+
+// Public returns the public key of k.
+func (k *Key) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs digest with the private key of k, opts gives the hash
+// function used to compute digest.
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if k.private == 0 {
+		return nil, fmt.Errorf("no private key available")
+	}
+
+	var mech *p11.Mechanism
+	data := digest
+	switch k.pub.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("RSA PSS signatures are not supported")
+		}
+		var err error
+		if data, err = digestInfo(opts.HashFunc(), digest); err != nil {
+			return nil, err
+		}
+		mech = p11.NewMechanism(p11.CKM_RSA_PKCS, nil)
+	case *ecdsa.PublicKey:
+		mech = p11.NewMechanism(p11.CKM_ECDSA, nil)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k.pub)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if err := k.ctx.SignInit(k.session, []*p11.Mechanism{mech}, k.private); err != nil {
+		return nil, fmt.Errorf("while initializing PKCS#11 signature: %w", err)
+	}
+	sig, err := k.ctx.Sign(k.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("while signing with PKCS#11 key: %w", err)
+	}
+
+	if _, ok := k.pub.(*ecdsa.PublicKey); ok {
+		return ecdsaSignature(sig)
+	}
+	return sig, nil
+}
+
+// Close closes the token session and unloads the PKCS#11 module.
+func (k *Key) Close() error {
+	if k.ctx == nil {
+		return nil
+	}
+	if k.session != 0 {
+		_ = k.ctx.CloseSession(k.session)
+	}
+	_ = k.ctx.Finalize()
+	k.ctx.Destroy()
+	k.ctx = nil
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !cgo
+
+package pkcs11
+
+import (
+	"crypto"
+	"errors"
+	"io"
+)
+
+var errNoCgo = errors.New("PKCS#11 support requires a build with cgo enabled")
+
+// Key is a key stored in a PKCS#11 token.
+type Key struct{}
+
+// OpenPrivateKey opens the private key identified by the PKCS#11 URI uri.
+func OpenPrivateKey(string, PinFunc) (*Key, error) {
+	return nil, errNoCgo
+}
+
+// OpenPublicKey opens the public key identified by the PKCS#11 URI uri.
+func OpenPublicKey(string, PinFunc) (*Key, error) {
+	return nil, errNoCgo
+}
+
+// Public returns the public key of k.
+func (k *Key) Public() crypto.PublicKey {
+	return nil
+}
+
+// Sign signs digest with the private key of k.
+func (k *Key) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, errNoCgo
+}
+
+// Close closes the token session.
+func (k *Key) Close() error {
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build cgo
+
+package pkcs11
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apptainer/apptainer/internal/pkg/test/tool/require"
+	ocipkcs11 "github.com/containers/ocicrypt/crypto/pkcs11"
+	p11 "github.com/miekg/pkcs11"
+)
+
+const (
+	testToken = "apptainer"
+	testPin   = "1234"
+)
+
+// softHSM initializes a SoftHSM token in a temporary directory and
+// returns the path of the SoftHSM module.
+func softHSM(t *testing.T) string {
+	require.Command(t, "softhsm2-util")
+
+	module := ""
+	for _, dir := range ocipkcs11.GetDefaultModuleDirectories() {
+		if _, err := os.Stat(filepath.Join(dir, "libsofthsm2.so")); err == nil {
+			module = filepath.Join(dir, "libsofthsm2.so")
+			break
+		}
+	}
+	if module == "" {
+		t.Skip("SoftHSM module not found")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokens)
+	if err := os.WriteFile(conf, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	cmd := exec.Command("softhsm2-util", "--init-token", "--free", "--label", testToken, "--pin", testPin, "--so-pin", testPin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("while initializing token: %s: %s", err, out)
+	}
+	return module
+}
+
+// generateKey generates a key pair with the given label in the token.
+func generateKey(t *testing.T, module, label string, mech uint, pubAttrs []*p11.Attribute) {
+	ctx := p11.New(module)
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx.Finalize()
+		ctx.Destroy()
+	}()
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session p11.SessionHandle
+	for _, slot := range slots {
+		if ti, err := ctx.GetTokenInfo(slot); err == nil && ti.Label == testToken {
+			session, err = ctx.OpenSession(slot, p11.CKF_SERIAL_SESSION|p11.CKF_RW_SESSION)
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	defer ctx.CloseSession(session)
+
+	if err := ctx.Login(session, p11.CKU_USER, testPin); err != nil {
+		t.Fatal(err)
+	}
+
+	pub := append([]*p11.Attribute{
+		p11.NewAttribute(p11.CKA_TOKEN, true),
+		p11.NewAttribute(p11.CKA_VERIFY, true),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+	}, pubAttrs...)
+	priv := []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_TOKEN, true),
+		p11.NewAttribute(p11.CKA_SIGN, true),
+		p11.NewAttribute(p11.CKA_PRIVATE, true),
+		p11.NewAttribute(p11.CKA_SENSITIVE, true),
+		p11.NewAttribute(p11.CKA_LABEL, label),
+	}
+	_, _, err = ctx.GenerateKeyPair(session, []*p11.Mechanism{p11.NewMechanism(mech, nil)}, pub, priv)
+	if err != nil {
+		t.Fatalf("while generating key pair: %s", err)
+	}
+}
+
+func TestSoftHSM(t *testing.T) {
+	module := softHSM(t)
+
+	generateKey(t, module, "rsa", p11.CKM_RSA_PKCS_KEY_PAIR_GEN, []*p11.Attribute{
+		p11.NewAttribute(p11.CKA_MODULUS_BITS, 2048),
+		p11.NewAttribute(p11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+	})
+	generateKey(t, module, "ecdsa", p11.CKM_EC_KEY_PAIR_GEN, []*p11.Attribute{
+		// prime256v1 curve OID
+		p11.NewAttribute(p11.CKA_EC_PARAMS, []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}),
+	})
+
+	pin := func() (string, error) { return testPin, nil }
+
+	for _, label := range []string{"rsa", "ecdsa"} {
+		t.Run(label, func(t *testing.T) {
+			uri := fmt.Sprintf("pkcs11:token=%s;object=%s?module-path=%s", testToken, label, module)
+
+			if _, err := OpenPrivateKey(uri, nil); err == nil {
+				t.Errorf("unexpected success without PIN")
+			}
+
+			priv, err := OpenPrivateKey(uri, pin)
+			if err != nil {
+				t.Fatalf("while opening private key: %s", err)
+			}
+			defer priv.Close()
+
+			message := []byte("signed message")
+			sig, err := priv.Signer().SignMessage(bytes.NewReader(message))
+			if err != nil {
+				t.Fatalf("while signing: %s", err)
+			}
+
+			pub, err := OpenPublicKey(uri+"&pin-value="+testPin, nil)
+			if err != nil {
+				t.Fatalf("while opening public key: %s", err)
+			}
+			defer pub.Close()
+
+			v, err := pub.Verifier()
+			if err != nil {
+				t.Fatalf("while loading verifier: %s", err)
+			}
+			if err := v.VerifySignature(bytes.NewReader(sig), bytes.NewReader(message)); err != nil {
+				t.Errorf("invalid signature: %s", err)
+			}
+			tampered := []byte(strings.ToUpper(string(message)))
+			if err := v.VerifySignature(bytes.NewReader(sig), bytes.NewReader(tampered)); err == nil {
+				t.Errorf("unexpected valid signature for tampered message")
+			}
+		})
+	}
+
+	uri := fmt.Sprintf("pkcs11:token=%s;object=missing?module-path=%s&pin-value=%s", testToken, module, testPin)
+	if _, err := OpenPrivateKey(uri, nil); err == nil {
+		t.Errorf("unexpected success with missing key")
+	}
+}
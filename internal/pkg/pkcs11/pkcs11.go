@@ -0,0 +1,176 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package pkcs11 gives access to keys stored in hardware security modules
+// or tokens through a PKCS#11 module, keys are identified by a PKCS#11 URI
+// as described in RFC 7512.
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	ocipkcs11 "github.com/containers/ocicrypt/crypto/pkcs11"
+	"github.com/sigstore/sigstore/pkg/signature"
+	pkcs11uri "github.com/stefanberger/go-pkcs11uri"
+)
+
+// PinFunc returns the PIN used to log in to a token when it's not
+// specified by the PKCS#11 URI.
+type PinFunc func() (string, error)
+
+// ErrNoKey is returned when the PKCS#11 URI doesn't match any key.
+var ErrNoKey = errors.New("no matching key found")
+
+// parseURI parses a PKCS#11 URI, the module is searched in the default
+// module directories of the different Linux distributions when the URI
+// specifies it by name.
+func parseURI(uri string) (*pkcs11uri.Pkcs11URI, error) {
+	p11uri, err := ocipkcs11.ParsePkcs11Uri(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := p11uri.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#11 URI: %w", err)
+	}
+	if _, ok := p11uri.GetPathAttribute("id", false); !ok {
+		if _, ok := p11uri.GetPathAttribute("object", false); !ok {
+			return nil, fmt.Errorf("PKCS#11 URI requires an 'id' or 'object' attribute")
+		}
+	}
+	p11uri.SetModuleDirectories(ocipkcs11.GetDefaultModuleDirectories())
+	// the module is loaded in the user process, any module
+	// explicitly requested by the user can be used
+	p11uri.SetAllowAnyModule(true)
+	return p11uri, nil
+}
+
+// the ASN.1 DigestInfo prefixes of RSA PKCS#1 v1.5 signatures, see
+// RFC 8017 section 9.2.
+var digestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// digestInfo returns the DigestInfo structure of digest for a RSA
+// PKCS#1 v1.5 signature.
+func digestInfo(hash crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := digestInfoPrefix[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %s", hash)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("bad digest size %d for %s", len(digest), hash)
+	}
+	return append(append([]byte{}, prefix...), digest...), nil
+}
+
+// ecdsaSignature converts a raw ECDSA signature as returned by PKCS#11,
+// r and s concatenated, into its ASN.1 encoding.
+func ecdsaSignature(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, fmt.Errorf("bad ECDSA signature size %d", len(raw))
+	}
+	half := len(raw) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(raw[:half]),
+		S: new(big.Int).SetBytes(raw[half:]),
+	})
+}
+
+var curveOIDs = []struct {
+	oid   asn1.ObjectIdentifier
+	curve elliptic.Curve
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}, elliptic.P256()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 34}, elliptic.P384()},
+	{asn1.ObjectIdentifier{1, 3, 132, 0, 35}, elliptic.P521()},
+}
+
+// ecPublicKey returns the ECDSA public key from the CKA_EC_PARAMS and
+// CKA_EC_POINT attributes of a PKCS#11 public key object.
+func ecPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("unsupported elliptic curve parameters: %w", err)
+	}
+	var curve elliptic.Curve
+	for _, c := range curveOIDs {
+		if c.oid.Equal(oid) {
+			curve = c.curve
+			break
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("unsupported elliptic curve %s", oid)
+	}
+
+	// the point is a DER encoded octet string, some modules
+	// return the raw point though
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw) //nolint:staticcheck
+	if x == nil {
+		return nil, fmt.Errorf("bad elliptic curve point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// certificatePublicKey returns the public key of a DER encoded X.509
+// certificate.
+func certificatePublicKey(der []byte) (crypto.PublicKey, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing certificate: %w", err)
+	}
+	return cert.PublicKey, nil
+}
+
+// signer implements a sigstore signer on top of a PKCS#11 key.
+type signer struct {
+	key  *Key
+	hash crypto.Hash
+}
+
+// Signer returns a signature.Signer generating signatures with the
+// private key k, messages are hashed with SHA-256 by default. Like
+// the signers loaded from PEM files, RSA keys produce PKCS#1 v1.5
+// signatures and ECDSA keys produce ASN.1 encoded signatures.
+func (k *Key) Signer() signature.Signer {
+	return &signer{key: k, hash: crypto.SHA256}
+}
+
+func (s *signer) PublicKey(...signature.PublicKeyOption) (crypto.PublicKey, error) {
+	return s.key.Public(), nil
+}
+
+func (s *signer) SignMessage(message io.Reader, opts ...signature.SignOption) ([]byte, error) {
+	hashes := []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+	digest, hash, err := signature.ComputeDigestForSigning(message, s.hash, hashes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.key.Sign(nil, digest, hash)
+}
+
+// Verifier returns a signature.Verifier for the public key of k.
+func (k *Key) Verifier() (signature.Verifier, error) {
+	return signature.LoadVerifier(k.Public(), crypto.SHA256)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr bool
+	}{
+		{
+			name: "ObjectLabel",
+			uri:  "pkcs11:token=test;object=key?module-path=/usr/lib/softhsm/libsofthsm2.so",
+		},
+		{
+			name: "ObjectID",
+			uri:  "pkcs11:token=test;id=%01%02?module-name=softhsm2&pin-value=1234",
+		},
+		{
+			name:    "NoObject",
+			uri:     "pkcs11:token=test?module-name=softhsm2",
+			wantErr: true,
+		},
+		{
+			name:    "NotPKCS11",
+			uri:     "file:///key.pem",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseURI(tt.uri)
+			if tt.wantErr && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestDigestInfo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		hh := h.New()
+		hh.Write([]byte("message"))
+		digest := hh.Sum(nil)
+
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, h, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// recover the padded DigestInfo from the signature
+		m := new(big.Int).Exp(new(big.Int).SetBytes(sig), big.NewInt(int64(key.E)), key.N).Bytes()
+
+		di, err := digestInfo(h, digest)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", h, err)
+		}
+		if !bytes.HasSuffix(m, di) {
+			t.Errorf("unexpected DigestInfo for %s", h)
+		}
+	}
+
+	if _, err := digestInfo(crypto.SHA256, []byte("short")); err == nil {
+		t.Errorf("unexpected success with bad digest size")
+	}
+	if _, err := digestInfo(crypto.MD5, make([]byte, crypto.MD5.Size())); err == nil {
+		t.Errorf("unexpected success with unsupported hash")
+	}
+}
+
+func TestECDSA(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// public key attributes as returned by PKCS#11 modules
+		var oid asn1.ObjectIdentifier
+		for _, c := range curveOIDs {
+			if c.curve == curve {
+				oid = c.oid
+			}
+		}
+		params, err := asn1.Marshal(oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := elliptic.Marshal(curve, key.X, key.Y) //nolint:staticcheck
+		point, err := asn1.Marshal(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range [][]byte{point, raw} {
+			pub, err := ecPublicKey(params, p)
+			if err != nil {
+				t.Fatalf("unexpected error for %s: %s", curve.Params().Name, err)
+			}
+			if !pub.Equal(&key.PublicKey) {
+				t.Errorf("unexpected public key for %s", curve.Params().Name)
+			}
+		}
+
+		// raw signature as returned by PKCS#11 modules
+		digest := sha256.Sum256([]byte("message"))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+
+		der, err := ecdsaSignature(sig)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", curve.Params().Name, err)
+		}
+		if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], der) {
+			t.Errorf("invalid signature for %s", curve.Params().Name)
+		}
+	}
+
+	if _, err := ecdsaSignature([]byte{1, 2, 3}); err == nil {
+		t.Errorf("unexpected success with bad signature size")
+	}
+	params, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 3})
+	if _, err := ecPublicKey(params, []byte{4}); err == nil {
+		t.Errorf("unexpected success with unsupported curve")
+	}
+}
@@ -17,8 +17,11 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
 	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/internal/pkg/metric"
 	fakerootConfig "github.com/apptainer/apptainer/internal/pkg/runtime/engine/fakeroot/config"
 	"github.com/apptainer/apptainer/internal/pkg/util/bin"
 	"github.com/apptainer/apptainer/internal/pkg/util/crypt"
@@ -43,7 +46,7 @@ import (
 // For better understanding of runtime flow in general refer to
 // https://github.com/opencontainers/runtime-spec/blob/master/runtime.md#lifecycle.
 // CleanupContainer is performing step 8/9 here.
-func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, _ syscall.WaitStatus) error {
+func (e *EngineOperations) CleanupContainer(ctx context.Context, fatal error, status syscall.WaitStatus) error {
 	sylog.Debugf("Cleanup container")
 	if fd := e.EngineConfig.GetShareNSFd(); fd != -1 && e.EngineConfig.GetShareNSMode() {
 		br := lock.NewByteRange(fd, 0, 0)
@@ -66,6 +69,11 @@ func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, _ sysc
 		}
 	}
 
+	// report resource usage before the cgroup is destroyed
+	if e.EngineConfig.GetStatsFile() != "" || e.CommonConfig.ApptheusSocket != nil {
+		e.reportStats(fatal, status)
+	}
+
 	// close the connection between apptainer and apptheus
 	if e.CommonConfig.ApptheusSocket != nil {
 		if err := e.CommonConfig.ApptheusSocket.Close(); err != nil {
@@ -149,6 +157,57 @@ func (e *EngineOperations) CleanupContainer(ctx context.Context, _ error, _ sysc
 	return nil
 }
 
+// reportStats collects the resource usage of the container from its cgroup,
+// and writes it to the stats file and to apptheus when monitoring is enabled.
+func (e *EngineOperations) reportStats(fatal error, status syscall.WaitStatus) {
+	stats := &metric.ContainerStats{
+		Image: e.EngineConfig.GetImage(),
+		Start: containerStart,
+		Stop:  time.Now(),
+	}
+	if e.EngineConfig.GetInstance() {
+		stats.Instance = e.CommonConfig.ContainerID
+	}
+	stats.SetExitStatus(status)
+	if fatal != nil {
+		stats.Error = fatal.Error()
+	}
+
+	if cgroupsManager != nil {
+		cgStats, err := cgroupsManager.GetStats()
+		if err != nil {
+			sylog.Warningf("Could not get container cgroup stats: %s", err)
+		} else {
+			stats.SetCgroupStats(cgStats)
+		}
+		if stats.OOMKills, err = cgroupsManager.OOMKillCount(); err != nil {
+			sylog.Debugf("Could not get container OOM kill count: %s", err)
+		}
+		if stats.PidsPeak, err = cgroupsManager.PidsPeak(); err != nil {
+			sylog.Debugf("Could not get container peak number of processes: %s", err)
+		}
+	}
+
+	// the digest of a SIF image covers its whole content, it's computed
+	// once the cgroup statistics have been collected
+	if d, err := checkpoint.ImageDigest(stats.Image); err != nil {
+		sylog.Debugf("Could not compute digest of image %s: %s", stats.Image, err)
+	} else {
+		stats.ImageDigest = d.String()
+	}
+
+	if path := e.EngineConfig.GetStatsFile(); path != "" {
+		if err := stats.WriteFile(path); err != nil {
+			sylog.Errorf("Could not write %s: %s", path, err)
+		}
+	}
+	if e.CommonConfig.ApptheusSocket != nil {
+		if err := e.CommonConfig.ApptheusSocket.SendStats(stats); err != nil {
+			sylog.Debugf("Could not report stats to apptheus: %s", err)
+		}
+	}
+}
+
 func umount() (err error) {
 	var errs []string
 	var oldEffective uint64
@@ -18,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
 	"github.com/apptainer/apptainer/internal/pkg/cgroups"
@@ -65,6 +66,7 @@ var (
 	imageDriver    image.Driver
 	umountPoints   []umountPoint
 	cgroupsManager *cgroups.Manager
+	containerStart time.Time
 )
 
 // defaultCNIConfPath is the default directory to CNI network configuration files.
@@ -184,6 +186,14 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		return fmt.Errorf("%q: no such image driver", driverName)
 	}
 
+	// lazily pulled images are served by the lazy image driver
+	// wrapping the configured one
+	if images := c.engine.EngineConfig.GetImageList(); len(images) > 0 && images[0].Type == image.LAZY {
+		if imageDriver, err = driver.NewLazyDriver(imageDriver); err != nil {
+			return fmt.Errorf("while registering lazy image driver: %s", err)
+		}
+	}
+
 	p := &mount.Points{}
 	system := &mount.System{Points: p, Mount: c.mount}
 
@@ -255,6 +265,9 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	if err := c.addScratchMount(system); err != nil {
 		return err
 	}
+	if err := c.addTmpfsMount(system); err != nil {
+		return err
+	}
 	if err := c.addLibsMount(system); err != nil {
 		return err
 	}
@@ -472,6 +485,12 @@ func (c *container) isLayerEnabled() bool {
 }
 
 func (c *container) mount(point *mount.Point, system *mount.System) error {
+	if c.engine.EngineConfig.GetReadOnlyStrict() && strictReadOnlyTags[system.CurrentTag()] {
+		// propagation changes don't take other flags into account
+		if flags, _ := mount.ConvertOptions(point.Options); !mount.HasPropagationFlag(flags) {
+			point.Options = append(point.Options, "ro")
+		}
+	}
 	if _, err := mount.GetOffset(point.InternalOptions); err == nil {
 		if err := c.mountImage(point, system); err != nil {
 			return fmt.Errorf("while mounting image %s: %s", point.Source, err)
@@ -484,6 +503,22 @@ func (c *container) mount(point *mount.Point, system *mount.System) error {
 	return nil
 }
 
+// strictReadOnlyTags are the tags of the mount points made read-only
+// with --read-only-strict. Kernel and device filesystems, the session
+// layer and the tmpfs mounts under OtherTag are left as is.
+var strictReadOnlyTags = map[mount.AuthorizedTag]bool{
+	mount.RootfsTag:    true,
+	mount.ImageBindTag: true,
+	mount.HostfsTag:    true,
+	mount.BindsTag:     true,
+	mount.HomeTag:      true,
+	mount.TmpTag:       true,
+	mount.ScratchTag:   true,
+	mount.FilesTag:     true,
+	mount.UserbindsTag: true,
+	mount.CwdTag:       true,
+}
+
 // setupImageDriver prepares the image driver to start
 func (c *container) setupImageDriver(system *mount.System, containerPid int) error {
 	if imageDriver == nil {
@@ -763,6 +798,15 @@ func (c *container) mountGeneric(mnt *mount.Point, system *mount.System) (err er
 		}
 	}
 
+	if bindMount && !remount {
+		if uidMap, gidMap, err := mount.GetIDMap(mnt.InternalOptions); err == nil {
+			if err := c.idmapMount(source, dest, uidMap, gidMap); err != nil {
+				return fmt.Errorf("can't mount %s as an ID-mapped mount: %s", source, err)
+			}
+			return nil
+		}
+	}
+
 mount:
 	err = nil
 	if !bindMount && !remount && mnt.Type == "overlay" && tag == mount.LayerTag &&
@@ -881,6 +925,9 @@ mount:
 				// execution by ignoring the error and warn user if the bind mount
 				// need to be mounted read-only
 				if flags&syscall.MS_RDONLY != 0 {
+					if c.engine.EngineConfig.GetReadOnlyStrict() {
+						return fmt.Errorf("could not remount %s read-only: %s", mnt.Destination, err)
+					}
 					sylog.Warningf("Could not remount %s read-only: %s", mnt.Destination, err)
 				} else {
 					sylog.Verbosef("Could not remount %s: %s", mnt.Destination, err)
@@ -917,6 +964,172 @@ mount:
 	return nil
 }
 
+// idmapMount bind mounts source on dest with the ID mappings uidMap and
+// gidMap holding host IDs. The mount is created by this process, which
+// lives in the host user namespace with the hybrid fakeroot workflow and
+// in the container user namespace otherwise, then it's sent to the RPC
+// server to be attached in the container mount namespace.
+func (c *container) idmapMount(source, dest string, uidMap, gidMap specs.LinuxIDMapping) error {
+	sylog.Debugf("Mounting %s to %s with ID mappings %v and %v", source, dest, uidMap, gidMap)
+
+	// the ID-mapped user namespace is a child of the user namespace of
+	// this process, its mappings target IDs of this user namespace
+	var err error
+	if uidMap, err = localIDMapping(uidMap, "/proc/self/uid_map"); err != nil {
+		return err
+	}
+	if gidMap, err = localIDMapping(gidMap, "/proc/self/gid_map"); err != nil {
+		return err
+	}
+
+	socketPair := c.engine.EngineConfig.GetUnixSocketPair()
+	if socketPair[0] == -1 {
+		return fmt.Errorf("no socket to pass the mount to the RPC server")
+	}
+
+	var dropPrivilege priv.DropPrivFunc
+	if c.engine.EngineConfig.GetFakeroot() && os.Geteuid() != 0 {
+		if dropPrivilege, err = priv.Escalate(); err != nil {
+			return err
+		}
+	}
+	fd, err := mount.OpenIDMapTree(source, uidMap, gidMap)
+	if dropPrivilege != nil {
+		if err := dropPrivilege(); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Sendmsg(socketPair[0], []byte{0}, unix.UnixRights(fd), nil, 0); err != nil {
+		return fmt.Errorf("while sending mount to the RPC server: %s", err)
+	}
+	return c.rpcOps.IDMapMount(socketPair[1], dest)
+}
+
+// hostID returns the ID mapped to the container ID id by the user
+// namespace mappings, the ID is returned as is without mappings.
+func hostID(id uint32, mappings []specs.LinuxIDMapping) (uint32, error) {
+	if len(mappings) == 0 {
+		return id, nil
+	}
+	for _, m := range mappings {
+		if id >= m.ContainerID && id-m.ContainerID < m.Size {
+			return m.HostID + id - m.ContainerID, nil
+		}
+	}
+	return 0, fmt.Errorf("container ID %d is not mapped in the user namespace", id)
+}
+
+// namespaceID returns the ID of the user namespace described by mappings
+// corresponding to the host ID id, the ID is returned as is without
+// mappings.
+func namespaceID(id uint32, mappings []specs.LinuxIDMapping) (uint32, error) {
+	if len(mappings) == 0 {
+		return id, nil
+	}
+	for _, m := range mappings {
+		if id >= m.HostID && id-m.HostID < m.Size {
+			return m.ContainerID + id - m.HostID, nil
+		}
+	}
+	return 0, fmt.Errorf("host ID %d is not mapped in the user namespace", id)
+}
+
+// readIDMappings returns the ID mappings of a uid_map or gid_map file.
+func readIDMappings(path string) ([]specs.LinuxIDMapping, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []specs.LinuxIDMapping
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var m specs.LinuxIDMapping
+		if _, err := fmt.Sscanf(line, "%d %d %d", &m.ContainerID, &m.HostID, &m.Size); err != nil {
+			return nil, fmt.Errorf("while parsing %s: %s", path, err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// localIDMapping returns the ID-mapped mount mapping m, whose host IDs
+// are translated to IDs of the user namespace with the mappings of the
+// uid_map or gid_map file path.
+func localIDMapping(m specs.LinuxIDMapping, path string) (specs.LinuxIDMapping, error) {
+	mappings, err := readIDMappings(path)
+	if err != nil {
+		return m, err
+	}
+	m.HostID, err = namespaceID(m.HostID, mappings)
+	return m, err
+}
+
+// idmapOption returns the internal mount option of an ID-mapped bind
+// mount where the files of the invoking user are owned by the container
+// user, given as UID[:GID] by user or the container process user if empty.
+func (c *container) idmapOption(user string) (string, error) {
+	if !c.userNS {
+		return "", fmt.Errorf("ID-mapped mounts require --fakeroot or --userns")
+	}
+
+	fakeroot := c.engine.EngineConfig.GetFakeroot()
+
+	// container IDs of the invoking user
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	if fakeroot {
+		uid, gid = 0, 0
+	}
+	cuid, cgid := uid, gid
+	if user != "" {
+		ids := strings.SplitN(user, ":", 2)
+		id, err := strconv.ParseUint(ids[0], 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("bad idmap UID %q", ids[0])
+		}
+		cuid, cgid = uint32(id), uint32(id)
+		if len(ids) > 1 {
+			id, err := strconv.ParseUint(ids[1], 10, 32)
+			if err != nil {
+				return "", fmt.Errorf("bad idmap GID %q", ids[1])
+			}
+			cgid = uint32(id)
+		}
+	}
+
+	var uidMappings, gidMappings []specs.LinuxIDMapping
+	if linux := c.engine.EngineConfig.OciConfig.Linux; linux != nil {
+		uidMappings, gidMappings = linux.UIDMappings, linux.GIDMappings
+	}
+
+	// files are owned by the invoking user on disk
+	hostUID, err := hostID(uid, uidMappings)
+	if err != nil {
+		return "", err
+	}
+	hostGID, err := hostID(gid, gidMappings)
+	if err != nil {
+		return "", err
+	}
+
+	// the container user is identified by its host IDs too, idmapMount
+	// translates them for the user namespace creating the mount
+	if cuid, err = hostID(cuid, uidMappings); err != nil {
+		return "", err
+	}
+	if cgid, err = hostID(cgid, gidMappings); err != nil {
+		return "", err
+	}
+
+	return mount.IDMapOption(
+		specs.LinuxIDMapping{ContainerID: hostUID, HostID: cuid, Size: 1},
+		specs.LinuxIDMapping{ContainerID: hostGID, HostID: cgid, Size: 1},
+	), nil
+}
+
 // mount image via loop
 func (c *container) mountImage(mnt *mount.Point, system *mount.System) error {
 	var key []byte
@@ -973,6 +1186,10 @@ func (c *container) mountImage(mnt *mount.Point, system *mount.System) error {
 			if features&image.Ext3Feature != 0 {
 				return c.mountImageDriver(params, system, c.rpcOps.Mount)
 			}
+		case "lazy":
+			if features&image.LazyFeature != 0 {
+				return c.mountImageDriver(params, system, c.rpcOps.Mount)
+			}
 		}
 	}
 
@@ -980,6 +1197,9 @@ func (c *container) mountImage(mnt *mount.Point, system *mount.System) error {
 		// no non-image driver alternative for this one
 		return fmt.Errorf("gocryptfs image driver unavailable")
 	}
+	if mountType == "lazy" {
+		return fmt.Errorf("lazy image driver unavailable")
+	}
 
 	attachFlag := os.O_RDWR
 	loopFlags := uint32(unix.LO_FLAGS_AUTOCLEAR)
@@ -1080,6 +1300,8 @@ func (c *container) addRootfsMount(system *mount.System) error {
 	case image.GOCRYPTFSSQUASHFS:
 		mountType = "gocryptfs"
 		key = c.engine.EngineConfig.GetEncryptionKey()
+	case image.LAZY:
+		mountType = "lazy"
 	case image.SANDBOX:
 		sylog.Debugf("Mounting directory rootfs: %v\n", rootfs)
 		flags |= syscall.MS_BIND
@@ -2151,9 +2373,18 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 			continue
 		}
 
+		var options []string
+		if user, ok := b.IDMap(); ok {
+			option, err := c.idmapOption(user)
+			if err != nil {
+				return fmt.Errorf("while preparing ID-mapped mount of %s: %s", src, err)
+			}
+			options = append(options, option)
+		}
+
 		sylog.Debugf("Adding %s to mount list\n", src)
 
-		if err := system.Points.AddBind(mount.UserbindsTag, src, dst, flags); err == mount.ErrMountExists {
+		if err := system.Points.AddBind(mount.UserbindsTag, src, dst, flags, options...); err == mount.ErrMountExists {
 			sylog.Warningf("While bind mounting '%s:%s': %s", src, dst, err)
 		} else if err != nil {
 			return fmt.Errorf("unable to add %s to mount list: %s", src, err)
@@ -2319,6 +2550,42 @@ func (c *container) addScratchMount(system *mount.System) error {
 	return nil
 }
 
+// addTmpfsMount adds the tmpfs filesystems requested with --tmpfs, they
+// are the only writable locations of the container with --read-only-strict.
+func (c *container) addTmpfsMount(system *mount.System) error {
+	tmpfs := c.engine.EngineConfig.GetTmpfs()
+	if len(tmpfs) == 0 {
+		return nil
+	}
+	if !c.engine.EngineConfig.File.UserBindControl {
+		sylog.Warningf("Ignoring tmpfs mount request: user bind control disabled by system administrator")
+		return nil
+	}
+
+	for _, t := range tmpfs {
+		flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV)
+		options := []string{}
+		for _, o := range t.Options {
+			switch o {
+			case "noexec":
+				flags |= syscall.MS_NOEXEC
+			case "noatime":
+				flags |= syscall.MS_NOATIME
+			case "exec":
+			default:
+				options = append(options, o)
+			}
+		}
+
+		sylog.Debugf("Adding tmpfs %s to mount list\n", t.Destination)
+		err := system.Points.AddFS(mount.OtherTag, t.Destination, "tmpfs", flags, strings.Join(options, ","))
+		if err != nil {
+			return fmt.Errorf("unable to add tmpfs %s to mount list: %s", t.Destination, err)
+		}
+	}
+	return nil
+}
+
 func (c *container) isMounted(dest string) bool {
 	sylog.Debugf("Checking if %s is already mounted", dest)
 
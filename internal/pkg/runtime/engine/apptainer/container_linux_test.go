@@ -0,0 +1,89 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestLocalIDMapping(t *testing.T) {
+	// fakeroot mappings with subordinate IDs
+	mappings := []specs.LinuxIDMapping{
+		{ContainerID: 0, HostID: 1000, Size: 1},
+		{ContainerID: 1, HostID: 100000, Size: 65536},
+	}
+
+	tests := []struct {
+		name string
+		// uid_map of the process creating the mount
+		uidMap string
+		// container ID of the user owning the files in the container
+		id     uint32
+		wantID uint32
+	}{
+		{
+			name:   "HybridRoot",
+			uidMap: "0 0 4294967295",
+			id:     0,
+			wantID: 1000,
+		},
+		{
+			name:   "HybridSubordinate",
+			uidMap: "0 0 4294967295",
+			id:     5,
+			wantID: 100004,
+		},
+		{
+			name:   "UserNamespaceRoot",
+			uidMap: "0 1000 1\n1 100000 65536\n",
+			id:     0,
+			wantID: 0,
+		},
+		{
+			name:   "UserNamespaceSubordinate",
+			uidMap: "0 1000 1\n1 100000 65536\n",
+			id:     5,
+			wantID: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "uid_map")
+			if err := os.WriteFile(path, []byte(tt.uidMap), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			id, err := hostID(tt.id, mappings)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			m, err := localIDMapping(specs.LinuxIDMapping{ContainerID: 1000, HostID: id, Size: 1}, path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if m.HostID != tt.wantID || m.ContainerID != 1000 {
+				t.Errorf("got mapping %v, want host ID %d", m, tt.wantID)
+			}
+		})
+	}
+
+	// host IDs not mapped in the user namespace can't be used
+	path := filepath.Join(t.TempDir(), "uid_map")
+	if err := os.WriteFile(path, []byte("0 1000 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := localIDMapping(specs.LinuxIDMapping{HostID: 100000, Size: 1}, path); err == nil {
+		t.Errorf("unexpected success with unmapped host ID")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apptainer/apptainer/internal/pkg/cgroups"
+	"github.com/apptainer/apptainer/internal/pkg/instance"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	units "github.com/docker/go-units"
+	"golang.org/x/sys/unix"
+)
+
+// oomExitCode is the exit code of a container whose process was killed by
+// the OOM killer, distinct from the exit code 137 of a process killed with
+// SIGKILL.
+const oomExitCode = 250
+
+// eventsPollInterval is the interval between two checks of the container
+// cgroup events counters.
+const eventsPollInterval = time.Second
+
+// containerEvents watches the events of the container, it is set by the
+// master once the container process is started.
+var containerEvents atomic.Pointer[eventsMonitor]
+
+// eventsMonitor reports the memory and pids events of the container cgroup,
+// and records them in the events log of an instance.
+type eventsMonitor struct {
+	manager    *cgroups.Manager
+	eventsFile string
+
+	mu       sync.Mutex
+	counters map[string]uint64
+	oomKills uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startEventsMonitor starts watching the events of the container cgroup,
+// recording them in eventsFile for an instance. When apptainer didn't place
+// the container in its own cgroup, the events of the cgroup the container
+// process pid is a member of are watched instead.
+func startEventsMonitor(manager *cgroups.Manager, pid int, eventsFile string) {
+	if manager == nil {
+		var err error
+		manager, err = cgroups.GetManagerForPid(pid)
+		if err != nil {
+			sylog.Debugf("Could not get cgroup of container process: %s", err)
+			manager = nil
+		}
+	}
+	if manager == nil && eventsFile == "" {
+		return
+	}
+
+	m := &eventsMonitor{
+		manager:    manager,
+		eventsFile: eventsFile,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if eventsFile != "" {
+		if err := instance.CreateEventsFile(eventsFile); err != nil {
+			sylog.Warningf("Could not create instance events log: %s", err)
+			m.eventsFile = ""
+		}
+	}
+	if manager != nil {
+		counters, err := manager.GetEvents()
+		if err != nil {
+			sylog.Debugf("Could not get container cgroup events: %s", err)
+			m.manager = nil
+		}
+		m.counters = counters
+	}
+
+	containerEvents.Store(m)
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(eventsPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// check reports the events which occurred since the previous check.
+func (m *eventsMonitor) check() {
+	if m.manager == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counters, err := m.manager.GetEvents()
+	if err != nil {
+		sylog.Debugf("Could not get container cgroup events: %s", err)
+		return
+	}
+	previous := m.counters
+	m.counters = counters
+
+	if n := counters["memory.oom_kill"] - previous["memory.oom_kill"]; n > 0 {
+		m.oomKills += n
+		m.record(&instance.Event{
+			Type:    instance.OOMEvent,
+			Message: fmt.Sprintf("%d process(es) killed by the OOM killer, %s", n, m.memoryUsage()),
+			Count:   n,
+		})
+	}
+	if n := counters["pids.max"] - previous["pids.max"]; n > 0 {
+		m.record(&instance.Event{
+			Type:    instance.PidsMaxEvent,
+			Message: fmt.Sprintf("%d process creation(s) failed, %s", n, m.pidsUsage()),
+			Count:   n,
+		})
+	}
+}
+
+// memoryUsage describes the memory limit and peak usage of the container.
+func (m *eventsMonitor) memoryUsage() string {
+	stats, err := m.manager.GetStats()
+	if err != nil {
+		return "container exceeded its memory limit"
+	}
+	return describeMemoryUsage(stats.MemoryStats.Usage.Limit, stats.MemoryStats.Usage.MaxUsage)
+}
+
+// describeMemoryUsage describes a memory limit and peak usage, the peak is
+// omitted when it is not available (memory.peak requires Linux 5.19 with
+// cgroups v2).
+func describeMemoryUsage(limit, peak uint64) string {
+	var msg string
+	if limit == 0 || limit == math.MaxUint64 {
+		msg = "container ran out of memory"
+	} else {
+		msg = fmt.Sprintf("container exceeded memory limit of %s", units.BytesSize(float64(limit)))
+	}
+	if peak > 0 {
+		msg += fmt.Sprintf(", peak %s", units.BytesSize(float64(peak)))
+	}
+	return msg
+}
+
+// pidsUsage describes the process limit of the container.
+func (m *eventsMonitor) pidsUsage() string {
+	stats, err := m.manager.GetStats()
+	if err != nil || stats.PidsStats.Limit == 0 {
+		return "container reached its process limit"
+	}
+	return fmt.Sprintf("container reached its process limit of %d", stats.PidsStats.Limit)
+}
+
+// record reports the event ev, and appends it to the instance events log.
+func (m *eventsMonitor) record(ev *instance.Event) {
+	ev.Time = time.Now()
+
+	switch ev.Type {
+	case instance.OOMEvent:
+		sylog.Errorf("%s", ev.Message)
+	case instance.PidsMaxEvent:
+		sylog.Warningf("%s", ev.Message)
+	default:
+		sylog.Debugf("%s", ev.Message)
+	}
+
+	if m.eventsFile != "" {
+		if err := instance.AppendEvent(m.eventsFile, ev); err != nil {
+			sylog.Debugf("Could not record instance event: %s", err)
+		}
+	}
+}
+
+// finishEvents stops watching the container events once its process has
+// exited with status, and records the exit event. It returns the status of
+// the container, with a distinct exit code when the container process was
+// killed by the OOM killer.
+func finishEvents(status syscall.WaitStatus) syscall.WaitStatus {
+	m := containerEvents.Load()
+	if m == nil {
+		return status
+	}
+	close(m.stop)
+	<-m.done
+	// catch the events that occurred since the last check
+	m.check()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ev := &instance.Event{Type: instance.ExitEvent}
+	switch {
+	case status.Signaled() && status.Signal() == syscall.SIGKILL && m.oomKills > 0:
+		sylog.Errorf("Container process was killed by the OOM killer, exiting with code %d", oomExitCode)
+		status = syscall.WaitStatus(oomExitCode << 8)
+		ev.Message = "container process killed by the OOM killer"
+		ev.Signal = unix.SignalName(syscall.SIGKILL)
+	case status.Signaled():
+		ev.Message = fmt.Sprintf("container process killed by signal %s", unix.SignalName(status.Signal()))
+		ev.Signal = unix.SignalName(status.Signal())
+	default:
+		ev.Message = fmt.Sprintf("container process exited with code %d", status.ExitStatus())
+	}
+	if !status.Signaled() {
+		exitCode := status.ExitStatus()
+		ev.ExitCode = &exitCode
+	}
+	m.record(ev)
+
+	return status
+}
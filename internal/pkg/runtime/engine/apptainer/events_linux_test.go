@@ -0,0 +1,53 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDescribeMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit uint64
+		peak  uint64
+		want  string
+	}{
+		{
+			name:  "Limit",
+			limit: 64 << 20,
+			peak:  64 << 20,
+			want:  "container exceeded memory limit of 64MiB, peak 64MiB",
+		},
+		{
+			name:  "NoLimit",
+			limit: math.MaxUint64,
+			peak:  1 << 30,
+			want:  "container ran out of memory, peak 1GiB",
+		},
+		{
+			name:  "LimitNoPeak",
+			limit: 64 << 20,
+			want:  "container exceeded memory limit of 64MiB",
+		},
+		{
+			name: "NoLimitNoPeak",
+			want: "container ran out of memory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeMemoryUsage(tt.limit, tt.peak); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
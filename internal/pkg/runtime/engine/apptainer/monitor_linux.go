@@ -51,7 +51,7 @@ func (e *EngineOperations) MonitorContainer(pid int, signals chan os.Signal) (sy
 			} else if wpid != pid {
 				continue
 			}
-			return status, nil
+			return finishEvents(status), nil
 		case syscall.SIGURG:
 			// Ignore SIGURG, which is used for non-cooperative goroutine
 			// preemption starting with Go 1.14. For more information, see
@@ -45,6 +45,7 @@ import (
 	apptainerConfig "github.com/apptainer/apptainer/pkg/runtime/engine/apptainer/config"
 	"github.com/apptainer/apptainer/pkg/runtime/engine/config"
 	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/apptainer/pkg/util/apptainerconf"
 	"github.com/apptainer/apptainer/pkg/util/capabilities"
 	"github.com/apptainer/apptainer/pkg/util/fs/proc"
 	"github.com/apptainer/apptainer/pkg/util/namespaces"
@@ -91,6 +92,24 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		if !fs.IsOwner(buildcfg.APPTAINER_CONF_FILE, 0) {
 			return fmt.Errorf("%s must be owned by root", buildcfg.APPTAINER_CONF_FILE)
 		}
+		// check for ownership of apptainer.conf drop-in files
+		dropins, err := apptainerconf.DropInFiles(buildcfg.APPTAINER_CONF_FILE)
+		if err != nil {
+			return fmt.Errorf("while listing configuration drop-in files: %s", err)
+		}
+		if len(dropins) > 0 && !fs.IsOwner(apptainerconf.DropInDir(buildcfg.APPTAINER_CONF_FILE), 0) {
+			return fmt.Errorf("%s must be owned by root", apptainerconf.DropInDir(buildcfg.APPTAINER_CONF_FILE))
+		}
+		for _, f := range dropins {
+			if !fs.IsOwner(f, 0) {
+				return fmt.Errorf("%s must be owned by root", f)
+			}
+		}
+		// check for ownership of the per-user and per-group overrides
+		overrides := apptainerconf.OverridesFile(buildcfg.APPTAINER_CONF_FILE)
+		if _, err := os.Stat(overrides); err == nil && !fs.IsOwner(overrides, 0) {
+			return fmt.Errorf("%s must be owned by root", overrides)
+		}
 		// check for ownership of capability.json
 		if !fs.IsOwner(buildcfg.CAPABILITY_FILE, 0) {
 			return fmt.Errorf("%s must be owned by root", buildcfg.CAPABILITY_FILE)
@@ -198,7 +217,7 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 	}
 
 	sylog.Debugf("image driver is %v", e.EngineConfig.File.ImageDriver)
-	if sendFd || e.EngineConfig.File.ImageDriver != "" {
+	if sendFd || e.EngineConfig.File.ImageDriver != "" || imageDriver != nil || hasIDMapBinds(e) {
 		fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
 		if err != nil {
 			return fmt.Errorf("failed to create socketpair to pass file descriptor: %s", err)
@@ -1084,6 +1103,17 @@ func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Conf
 	return nil
 }
 
+// hasIDMapBinds returns whether ID-mapped bind mounts are requested,
+// they are passed to the RPC server over the unix socket pair.
+func hasIDMapBinds(e *EngineOperations) bool {
+	for _, b := range e.EngineConfig.GetBindPath() {
+		if _, ok := b.IDMap(); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // openDevFuse is a helper function that opens /dev/fuse once for each
 // plugin that wants to mount a FUSE filesystem.
 func openDevFuse(e *EngineOperations, starterConfig *starter.Config) (bool, error) {
@@ -1171,6 +1201,19 @@ func (e *EngineOperations) setSessionLayer(img *image.Image) error {
 		}
 	}
 
+	if e.EngineConfig.GetReadOnlyStrict() {
+		switch {
+		case writableImage:
+			return fmt.Errorf("cannot use --writable in conjunction with --read-only-strict")
+		case writableTmpfs:
+			return fmt.Errorf("cannot use --writable-tmpfs in conjunction with --read-only-strict")
+		case hasOverlayImage:
+			return fmt.Errorf("cannot use --overlay in conjunction with --read-only-strict")
+		case hasSIFOverlay:
+			return fmt.Errorf("cannot use SIF image %s with an overlay partition in conjunction with --read-only-strict", img.Path)
+		}
+	}
+
 	if e.EngineConfig.File.EnableOverlay == "no" {
 		if hasOverlayImage {
 			return fmt.Errorf("overlay images requires 'enable overlay', but set to 'no' by administrator")
@@ -1239,6 +1282,14 @@ func (e *EngineOperations) loadImages(starterConfig *starter.Config, userNS bool
 		return err
 	}
 
+	// lazily pulled images are served by the lazy image driver
+	// wrapping the configured one
+	if img.Type == image.LAZY {
+		if imageDriver, err = driver.NewLazyDriver(imageDriver); err != nil {
+			return fmt.Errorf("while registering lazy image driver: %s", err)
+		}
+	}
+
 	rootFs, err := img.GetRootFsPartition()
 	if err != nil {
 		return fmt.Errorf("while getting root filesystem partition in %s: %s", e.EngineConfig.GetImage(), err)
@@ -1454,7 +1505,7 @@ func (e *EngineOperations) loadBindImages(starterConfig *starter.Config, userNS
 
 		sylog.Debugf("Loading data image %s", imagePath)
 
-		writable := !binds[i].Readonly()
+		writable := !binds[i].Readonly() && !e.EngineConfig.GetReadOnlyStrict()
 		img, err := e.loadImage(imagePath, writable, userNS, elevated)
 		if err != nil && !image.IsReadOnlyFilesytem(err) {
 			return nil, fmt.Errorf("failed to load data image %s: %s", imagePath, err)
@@ -1556,6 +1607,12 @@ func (e *EngineOperations) loadImage(path string, writable bool, userNS bool, el
 		if !e.EngineConfig.File.AllowContainerDir {
 			return nil, fmt.Errorf("configuration disallows users from running sandbox containers")
 		}
+	// Lazily pulled OCI image, the root filesystem is served from
+	// user owned files like a sandbox directory
+	case image.LAZY:
+		if !e.EngineConfig.File.AllowContainerDir {
+			return nil, fmt.Errorf("configuration disallows users from running lazily pulled containers")
+		}
 	// SIF
 	case image.SIF:
 		if elevated && !squashfs.SetuidMountAllowed(e.EngineConfig.File) && !hasFeature(image.SquashFeature) {
@@ -30,6 +30,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/apptainer/apptainer/internal/pkg/cgroups"
 	"github.com/apptainer/apptainer/internal/pkg/checkpoint/dmtcp"
 	"github.com/apptainer/apptainer/internal/pkg/fakeroot"
 	"github.com/apptainer/apptainer/internal/pkg/instance"
@@ -345,6 +346,7 @@ func (e *EngineOperations) StartProcess(masterConnFd int) error {
 // Here, however, apptainer engine does not escalate privileges.
 func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 	sylog.Debugf("Post start process")
+	containerStart = time.Now()
 
 	callbackType := (apptainercallback.PostStartProcess)(nil)
 	callbacks, err := plugin.LoadCallbacks(callbackType)
@@ -429,8 +431,12 @@ func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 
 		// If we are using cgroups with this instance then mark that in the instance config.
 		// We don't store the path, as we will get the cgroup manager by Pid.
-		if e.EngineConfig.GetCgroupsJSON() != "" {
+		if cgJSON := e.EngineConfig.GetCgroupsJSON(); cgJSON != "" {
 			file.Cgroup = true
+			file.Resources, err = cgroups.UnmarshalJSONResources(cgJSON)
+			if err != nil {
+				return fmt.Errorf("while decoding cgroups configuration: %s", err)
+			}
 		}
 
 		// grab configuration to store in instance file
@@ -444,6 +450,12 @@ func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 			return err
 		}
 
+		eventsFile, err := instance.GetEventsFilePath(name, instance.LogSubDir)
+		if err != nil {
+			return fmt.Errorf("could not find events path: %s", err)
+		}
+		startEventsMonitor(cgroupsManager, pid, eventsFile)
+
 		if !e.EngineConfig.GetShareNSMode() {
 			// send SIGUSR1 to the parent process in order to tell it
 			// to detach container process and run as instance.
@@ -467,6 +479,8 @@ func (e *EngineOperations) PostStartProcess(_ context.Context, pid int) error {
 				return err
 			}
 		}
+	} else {
+		startEventsMonitor(cgroupsManager, pid, "")
 	}
 
 	return nil
@@ -879,6 +893,12 @@ func runActionScript(engineConfig *apptainerConfig.EngineConfig) ([]string, []st
 			argv = dmtcp.InjectArgs(dmtcpConfig, argv)
 			sylog.Debugf("Injected DMTCP args %+q", argv)
 		}
+		criuConfig := engineConfig.GetCRIUConfig()
+		if criuConfig.Enabled {
+			// the restored process tree replaces the instance process
+			argv = append([]string{}, criuConfig.Args...)
+			sylog.Debugf("Injected CRIU args %+q", argv)
+		}
 
 		cmd, err := shell.LookPath(ctx, argv[0])
 		if err != nil {
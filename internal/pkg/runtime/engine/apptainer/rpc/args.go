@@ -43,6 +43,13 @@ type MountArgs struct {
 	Data       string
 }
 
+// IDMapMountArgs defines the arguments to attach an ID-mapped bind
+// mount received over a unix socket.
+type IDMapMountArgs struct {
+	Socket int
+	Target string
+}
+
 // UnmountArgs defines the arguments to unmount.
 type UnmountArgs struct {
 	Target       string
@@ -45,6 +45,16 @@ func (t *RPC) Mount(source string, target string, filesystem string, flags uintp
 	return err
 }
 
+// IDMapMount calls the RPC attaching on target the ID-mapped bind mount
+// sent over the unix socket.
+func (t *RPC) IDMapMount(socket int, target string) error {
+	arguments := &args.IDMapMountArgs{
+		Socket: socket,
+		Target: target,
+	}
+	return t.Client.Call(t.Name+".IDMapMount", arguments, nil)
+}
+
 // Unmount calls the unmount RPC using the supplied arguments.
 func (t *RPC) Unmount(target string, flags int) error {
 	arguments := &args.UnmountArgs{
@@ -73,6 +73,33 @@ func (t *Methods) Mount(arguments *args.MountArgs, mountErr *error) (err error)
 	return
 }
 
+// IDMapMount receives a detached ID-mapped bind mount over unix socket
+// and attaches it on the target.
+func (t *Methods) IDMapMount(arguments *args.IDMapMountArgs, _ *int) (err error) {
+	buf := make([]byte, unix.CmsgSpace(4))
+	_, _, _, _, err = unix.Recvmsg(arguments.Socket, make([]byte, 1), buf, 0) //nolint:dogsled
+	if err != nil {
+		return fmt.Errorf("while receiving ID-mapped mount: %s", err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(buf)
+	if err != nil || len(msgs) == 0 {
+		return fmt.Errorf("while parsing socket control message: %v", err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) == 0 {
+		return fmt.Errorf("while getting ID-mapped mount file descriptor: %v", err)
+	}
+	defer unix.Close(fds[0])
+
+	mainthread.Execute(func() {
+		err = unix.MoveMount(fds[0], "", unix.AT_FDCWD, arguments.Target, unix.MOVE_MOUNT_F_EMPTY_PATH)
+	})
+	if err != nil {
+		return fmt.Errorf("while mounting on %s: %s", arguments.Target, err)
+	}
+	return nil
+}
+
 // Unmount performs an unmount with the specified arguments.
 func (t *Methods) Unmount(arguments *args.UnmountArgs, unmountErr *error) (err error) {
 	mainthread.Execute(func() {
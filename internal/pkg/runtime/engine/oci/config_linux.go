@@ -33,8 +33,10 @@ type EngineConfig struct {
 	ErrorStreams   [2]int           `json:"errorStreams"`
 	InputStreams   [2]int           `json:"inputStreams"`
 	SyncSocket     string           `json:"syncSocket"`
+	ConsoleSocket  string           `json:"consoleSocket"`
 	EmptyProcess   bool             `json:"emptyProcess"`
 	Exec           bool             `json:"exec"`
+	PidSocket      int              `json:"pidSocket"`
 	SystemdCgroups bool             `json:"systemdCgroups"`
 	Cgroups        *cgroups.Manager `json:"-"`
 
@@ -101,6 +103,18 @@ func (e *EngineConfig) GetPidFile() string {
 	return e.PidFile
 }
 
+// SetConsoleSocket sets the path of the unix socket receiving the master
+// side of the container terminal.
+func (e *EngineConfig) SetConsoleSocket(path string) {
+	e.ConsoleSocket = path
+}
+
+// GetConsoleSocket returns the path of the unix socket receiving the master
+// side of the container terminal.
+func (e *EngineConfig) GetConsoleSocket() string {
+	return e.ConsoleSocket
+}
+
 // SetSystemdCgroups sets whether to manage cgroups with systemd.
 func (e *EngineConfig) SetSystemdCgroups(systemd bool) {
 	e.SystemdCgroups = systemd
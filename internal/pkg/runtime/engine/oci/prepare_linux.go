@@ -21,6 +21,7 @@ import (
 	"github.com/apptainer/apptainer/pkg/sylog"
 	"github.com/apptainer/apptainer/pkg/util/apptainerconf"
 	"github.com/apptainer/apptainer/pkg/util/capabilities"
+	"github.com/apptainer/apptainer/pkg/util/unix"
 	"github.com/ccoveille/go-safecast"
 	"github.com/creack/pty"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -184,9 +185,18 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 					return err
 				}
 			}
-			e.EngineConfig.MasterPts = int(master.Fd())
-			if err := starterConfig.KeepFileDescriptor(e.EngineConfig.MasterPts); err != nil {
-				return err
+			if socket := e.EngineConfig.GetConsoleSocket(); socket != "" {
+				// the master side is handed off to the console socket
+				// owner, and not kept for logging and attach
+				if err := unix.SendFile(socket, master); err != nil {
+					return fmt.Errorf("while sending terminal to console socket: %s", err)
+				}
+				master.Close()
+			} else {
+				e.EngineConfig.MasterPts = int(master.Fd())
+				if err := starterConfig.KeepFileDescriptor(e.EngineConfig.MasterPts); err != nil {
+					return err
+				}
 			}
 			e.EngineConfig.SlavePts = int(slave.Fd())
 			if err := starterConfig.KeepFileDescriptor(e.EngineConfig.SlavePts); err != nil {
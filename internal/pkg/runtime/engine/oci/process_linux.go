@@ -87,7 +87,7 @@ func (e *EngineOperations) StartProcess(masterConnFd int) error {
 	}
 	args[0] = bpath
 
-	if e.EngineConfig.MasterPts != -1 {
+	if e.EngineConfig.SlavePts != -1 {
 		slaveFd := e.EngineConfig.SlavePts
 		if err := syscall.Dup3(slaveFd, int(os.Stdin.Fd()), 0); err != nil {
 			return err
@@ -98,8 +98,10 @@ func (e *EngineOperations) StartProcess(masterConnFd int) error {
 		if err := syscall.Dup3(slaveFd, int(os.Stderr.Fd()), 0); err != nil {
 			return err
 		}
-		if err := syscall.Close(e.EngineConfig.MasterPts); err != nil {
-			return err
+		if e.EngineConfig.MasterPts != -1 {
+			if err := syscall.Close(e.EngineConfig.MasterPts); err != nil {
+				return err
+			}
 		}
 		if err := syscall.Close(slaveFd); err != nil {
 			return err
@@ -154,6 +156,12 @@ func (e *EngineOperations) StartProcess(masterConnFd int) error {
 		}
 	}
 
+	if e.EngineConfig.PidSocket > 0 {
+		if err := sendPid(e.EngineConfig.PidSocket); err != nil {
+			return err
+		}
+	}
+
 	if err := security.Configure(&e.EngineConfig.OciConfig.Spec); err != nil {
 		return fmt.Errorf("failed to apply security configuration: %s", err)
 	}
@@ -162,6 +170,21 @@ func (e *EngineOperations) StartProcess(masterConnFd int) error {
 	return fmt.Errorf("exec %s failed: %s", args[0], err)
 }
 
+// sendPid sends the credentials of the current process over the socket
+// fd, the kernel translates its PID to the PID namespace of the receiver.
+func sendPid(fd int) error {
+	defer syscall.Close(fd)
+	cred := syscall.UnixCredentials(&syscall.Ucred{
+		Pid: int32(os.Getpid()),
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	})
+	if err := syscall.Sendmsg(fd, []byte{0}, cred, nil, 0); err != nil {
+		return fmt.Errorf("while sending process PID: %s", err)
+	}
+	return nil
+}
+
 // PreStartProcess is called from master after before container startup.
 //
 // Additional privileges may be gained when running
@@ -343,6 +366,10 @@ func (e *EngineOperations) handleStream(l net.Listener, logger *instance.Logger,
 	var tbuf *copy.TerminalBuffer
 
 	hasTerminal := e.EngineConfig.OciConfig.Process.Terminal
+	if hasTerminal && e.EngineConfig.MasterPts == -1 {
+		// the terminal was handed off to the console socket
+		return
+	}
 
 	inputWriters = &copy.MultiWriter{}
 	outputWriters = &copy.MultiWriter{}
@@ -424,7 +451,7 @@ func (e *EngineOperations) handleControl(masterConn net.Conn, attach, control ne
 	var master *os.File
 	started := false
 
-	if e.EngineConfig.OciConfig.Process.Terminal {
+	if e.EngineConfig.OciConfig.Process.Terminal && e.EngineConfig.MasterPts != -1 {
 		master = os.NewFile(uintptr(e.EngineConfig.MasterPts), "control-master-pts")
 	}
 
@@ -23,6 +23,8 @@ import (
 
 	"github.com/apptainer/apptainer/internal/pkg/buildcfg"
 	"github.com/apptainer/apptainer/internal/pkg/cgroups"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint"
+	"github.com/apptainer/apptainer/internal/pkg/checkpoint/criu"
 	"github.com/apptainer/apptainer/internal/pkg/checkpoint/dmtcp"
 	"github.com/apptainer/apptainer/internal/pkg/fakeroot"
 	"github.com/apptainer/apptainer/internal/pkg/image/driver"
@@ -263,6 +265,11 @@ func (l *Launcher) Exec(ctx context.Context, image string, args []string, instan
 		l.engineConfig.SetWritableTmpfs(l.cfg.WritableTmpfs)
 	}
 
+	// Strict read-only container, with tmpfs mounts as the only writable locations.
+	if err := l.setReadOnlyStrict(); err != nil {
+		sylog.Fatalf("While setting read-only configuration: %s", err)
+	}
+
 	// Additional user requested library binds into /.singularity.d/libs.
 	l.engineConfig.AppendLibrariesPath(l.cfg.ContainLibs...)
 
@@ -327,6 +334,10 @@ func (l *Launcher) Exec(ctx context.Context, image string, args []string, instan
 		l.cfg.Namespaces.User = !l.cfg.IgnoreUserns
 	}
 
+	if err := l.setStatsFile(); err != nil {
+		sylog.Fatalf("While setting stats file: %s", err)
+	}
+
 	err = l.setCgroups(instanceName)
 	if err != nil {
 		sylog.Fatalf("Error while setting cgroups, err: %s", err)
@@ -722,6 +733,37 @@ func (l *Launcher) setFuseMounts() error {
 	return nil
 }
 
+// setReadOnlyStrict sets engine configuration for requested tmpfs mounts
+// and --read-only-strict, which refuses any option providing a writable
+// overlay or mount outside of the tmpfs mounts.
+func (l *Launcher) setReadOnlyStrict() error {
+	tmpfs := make([]apptainerConfig.TmpfsMount, 0, len(l.cfg.Tmpfs))
+	for _, t := range l.cfg.Tmpfs {
+		tm, err := apptainerConfig.ParseTmpfsString(t)
+		if err != nil {
+			return fmt.Errorf("while parsing tmpfs %q: %w", t, err)
+		}
+		tmpfs = append(tmpfs, tm)
+	}
+	l.engineConfig.SetTmpfs(tmpfs)
+
+	if !l.cfg.ReadOnlyStrict {
+		return nil
+	}
+	switch {
+	case l.cfg.Writable:
+		return fmt.Errorf("--writable can't be used in conjunction with --read-only-strict")
+	case l.cfg.WritableTmpfs:
+		return fmt.Errorf("--writable-tmpfs can't be used in conjunction with --read-only-strict")
+	case len(l.engineConfig.GetOverlayImage()) > 0:
+		return fmt.Errorf("--overlay can't be used in conjunction with --read-only-strict")
+	case len(l.cfg.FuseMount) > 0:
+		return fmt.Errorf("--fusemount can't be used in conjunction with --read-only-strict")
+	}
+	l.engineConfig.SetReadOnlyStrict(true)
+	return nil
+}
+
 // Set engine flags to disable mounts, to allow overriding them if they are set true
 // in the apptainer.conf.
 func (l *Launcher) setNoMountFlags() {
@@ -1125,11 +1167,12 @@ func (l *Launcher) setCgroups(instanceName string) error {
 		return nil
 	}
 
-	if instanceName == "" {
+	if instanceName == "" && l.cfg.StatsFile == "" {
 		return nil
 	}
 
-	// If we are an instance, always use a cgroup if possible, to enable stats.
+	// If we are an instance, or a stats file is requested, always use a
+	// cgroup if possible, to enable stats.
 	err := cgroups.CanUseCgroups(l.engineConfig.File.SystemdCgroups)
 	if err == nil {
 		// CanUseCgroups catches cases where fakeroot is already
@@ -1151,7 +1194,9 @@ func (l *Launcher) setCgroups(instanceName string) error {
 		}
 	}
 
-	if l.cfg.ShareNSMode {
+	if instanceName == "" {
+		sylog.Warningf("Resource usage will not be recorded in stats file - %v", err)
+	} else if l.cfg.ShareNSMode {
 		sylog.Debugf("Instance stats will not be available - %v", err)
 	} else {
 		sylog.Infof("Instance stats will not be available - %v", err)
@@ -1159,6 +1204,26 @@ func (l *Launcher) setCgroups(instanceName string) error {
 	return nil
 }
 
+// setStatsFile sets the file receiving the resource usage report of the
+// container. It is created early, as the user, to catch errors before the
+// container starts.
+func (l *Launcher) setStatsFile() error {
+	if l.cfg.StatsFile == "" {
+		return nil
+	}
+	path, err := filepath.Abs(l.cfg.StatsFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	l.engineConfig.SetStatsFile(path)
+	return nil
+}
+
 // PrepareImage performs any image preparation required before execution.
 // This is currently limited to extraction or FUSE mount when using the user namespace,
 // and activating any image driver plugins that might handle the image mount.
@@ -1171,6 +1236,14 @@ func (l *Launcher) prepareImage(_ context.Context, insideUserNs bool, image stri
 	fileconf := l.engineConfig.File
 	driver.InitImageDrivers(true, l.cfg.Namespaces.User || insideUserNs, fileconf, desiredFeatures)
 
+	// lazily pulled images are always served by the lazy image driver
+	if fs.IsFile(image) && imgutil.IsLazy(image) {
+		if l.cfg.Unsquash {
+			sylog.Warningf("--unsquash is ignored for lazily pulled images")
+		}
+		return nil
+	}
+
 	// convert image file to sandbox if either it was requested by
 	// `--unsquash` or we cannot mount the image directly and there's
 	// no image driver.
@@ -1419,6 +1492,13 @@ func convertImage(filename string, unsquashfsPath string, tmpDir string) (rootfs
 
 // SetCheckpointConfig sets EngineConfig entries to bind the provided list of libs and bins.
 func (l *Launcher) SetCheckpointConfig() error {
+	if l.cfg.CRIURestore != "" {
+		if l.cfg.DMTCPLaunch != "" || l.cfg.DMTCPRestart != "" {
+			return fmt.Errorf("--restore can't be used in conjunction with DMTCP checkpointing")
+		}
+		return l.injectCRIUConfig()
+	}
+
 	if l.cfg.DMTCPLaunch == "" && l.cfg.DMTCPRestart == "" {
 		return nil
 	}
@@ -1426,6 +1506,56 @@ func (l *Launcher) SetCheckpointConfig() error {
 	return l.injectDMTCPConfig()
 }
 
+func (l *Launcher) injectCRIUConfig() error {
+	sylog.Debugf("Injecting CRIU configuration")
+	criu.QuickInstallationCheck()
+
+	// criu requires full privileges to restore namespaces and processes
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("restoring a CRIU checkpoint requires root privileges")
+	}
+
+	bins, libs, err := criu.GetPaths()
+	if err != nil {
+		return err
+	}
+
+	m := criu.NewManager()
+	e, err := m.Get(l.cfg.CRIURestore)
+	if err != nil {
+		return err
+	}
+
+	if err := checkpoint.CheckImage(e.Path(), l.engineConfig.GetImage()); err != nil {
+		return err
+	}
+
+	overlay, err := e.RestoreOverlay()
+	if err != nil {
+		return err
+	}
+	if overlay != "" {
+		if l.cfg.Writable || l.cfg.WritableTmpfs {
+			return fmt.Errorf("--restore can't be used in conjunction with --writable or --writable-tmpfs, the checkpoint provides the writable overlay")
+		}
+		// the first writable overlay holds the upper directory
+		sylog.Debugf("Injecting checkpoint overlay: %q", overlay)
+		l.engineConfig.SetOverlayImage(append([]string{overlay}, l.engineConfig.GetOverlayImage()...))
+	}
+
+	sylog.Debugf("Injecting checkpoint state bind: %q", l.cfg.CRIURestore)
+	l.engineConfig.SetBindPath(append(l.engineConfig.GetBindPath(), e.BindPath()))
+	l.engineConfig.AppendFilesPath(bins...)
+	l.engineConfig.AppendLibrariesPath(libs...)
+	l.engineConfig.SetCRIUConfig(apptainerConfig.CRIUConfig{
+		Enabled:    true,
+		Checkpoint: l.cfg.CRIURestore,
+		Args:       criu.RestoreArgs(),
+	})
+
+	return nil
+}
+
 func (l *Launcher) injectDMTCPConfig() error {
 	sylog.Debugf("Injecting DMTCP configuration")
 	dmtcp.QuickInstallationCheck()
@@ -1458,6 +1588,20 @@ func (l *Launcher) injectDMTCPConfig() error {
 		return err
 	}
 
+	if config.Restart {
+		if err := checkpoint.CheckImage(e.Path(), l.engineConfig.GetImage()); err != nil {
+			return err
+		}
+	} else {
+		manifest, err := checkpoint.NewManifest(checkpoint.DMTCPBackend, config.Checkpoint, l.engineConfig.GetImage())
+		if err != nil {
+			return fmt.Errorf("while creating checkpoint manifest: %s", err)
+		}
+		if err := checkpoint.WriteManifest(e.Path(), manifest); err != nil {
+			return fmt.Errorf("while writing checkpoint manifest: %s", err)
+		}
+	}
+
 	sylog.Debugf("Injecting checkpoint state bind: %q", config.Checkpoint)
 	l.engineConfig.SetBindPath(append(l.engineConfig.GetBindPath(), e.BindPath()))
 	l.engineConfig.AppendFilesPath(bins...)
@@ -35,6 +35,10 @@ type launchOptions struct {
 	WritableTmpfs bool
 	// OverlayPaths holds paths to image or directory overlays to be applied.
 	OverlayPaths []string
+	// ReadOnlyStrict mounts the container read-only, except for tmpfs mounts, and refuses writable overlays.
+	ReadOnlyStrict bool
+	// Tmpfs lists tmpfs filesystems to mount into the container, in <dest>[:<opts>] format.
+	Tmpfs []string
 	// Scratchdir lists paths into the container to be mounted from a temporary location on the host.
 	ScratchDirs []string
 	// WorkDir is the parent path for scratch directories, and contained home/tmp on the host.
@@ -115,6 +119,8 @@ type launchOptions struct {
 
 	// CGroupsJSON is a JSON format cgroups resource limit specification to apply.
 	CGroupsJSON string
+	// StatsFile is a file receiving the resource usage report of the container when it exits.
+	StatsFile string
 
 	// ConfigFile is an alternate apptainer.conf that will be used by unprivileged installations only.
 	ConfigFile string
@@ -150,6 +156,7 @@ type launchOptions struct {
 
 	DMTCPLaunch       string
 	DMTCPRestart      string
+	CRIURestore       string
 	Unsquash          bool
 	IgnoreSubuid      bool
 	IgnoreFakerootCmd bool
@@ -211,6 +218,22 @@ func OptOverlayPaths(op []string) Option {
 	}
 }
 
+// OptReadOnlyStrict mounts the container read-only, except for tmpfs mounts, and refuses writable overlays.
+func OptReadOnlyStrict(b bool) Option {
+	return func(lo *launchOptions) error {
+		lo.ReadOnlyStrict = b
+		return nil
+	}
+}
+
+// OptTmpfs sets tmpfs filesystems to mount into the container, in <dest>[:<opts>] format.
+func OptTmpfs(tmpfs []string) Option {
+	return func(lo *launchOptions) error {
+		lo.Tmpfs = tmpfs
+		return nil
+	}
+}
+
 // OptScratchDirs sets temporary host directories to create and bind into the container.
 func OptScratchDirs(sd []string) Option {
 	return func(lo *launchOptions) error {
@@ -434,6 +457,14 @@ func OptCgroupsJSON(cj string) Option {
 	}
 }
 
+// OptStatsFile sets a file receiving the resource usage report of the container when it exits.
+func OptStatsFile(path string) Option {
+	return func(lo *launchOptions) error {
+		lo.StatsFile = path
+		return nil
+	}
+}
+
 // OptConfigFile specifies an alternate apptainer.conf that will be used by unprivileged installations only.
 func OptConfigFile(c string) Option {
 	return func(lo *launchOptions) error {
@@ -538,6 +569,14 @@ func OptDMTCPRestart(a string) Option {
 	}
 }
 
+// OptCRIURestore
+func OptCRIURestore(a string) Option {
+	return func(lo *launchOptions) error {
+		lo.CRIURestore = a
+		return nil
+	}
+}
+
 // OptUnsquash
 func OptUnsquash(b bool) Option {
 	return func(lo *launchOptions) error {
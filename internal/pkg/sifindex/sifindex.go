@@ -0,0 +1,403 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package sifindex handles multi-architecture SIF images. A multi-architecture
+// SIF image holds one system partition per platform, each one of them with its
+// related metadata objects in a dedicated descriptor group. The first platform
+// is stored as the primary system partition so older versions still run it.
+package sifindex
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apptainer/apptainer/internal/pkg/ociplatform"
+	"github.com/apptainer/apptainer/pkg/sylog"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/ccoveille/go-safecast"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/uuid"
+)
+
+// PlatformDescName is the name of the SIF descriptor recording the
+// platform, including variant, of the system partition within a group.
+const PlatformDescName = "platform.json"
+
+// ErrNoPlatform is returned when no system partition matches a platform.
+var ErrNoPlatform = errors.New("no system partition found for platform")
+
+// Platform describes a system partition contained in a SIF image.
+type Platform struct {
+	ggcrv1.Platform
+	// GroupID is the descriptor group of the system partition.
+	GroupID uint32 `json:"groupID"`
+	// ID is the descriptor ID of the system partition.
+	ID uint32 `json:"id"`
+	// Primary reports if this is the primary system partition.
+	Primary bool `json:"primary"`
+}
+
+// Source is a single-architecture SIF image to add into a multi-architecture
+// SIF image.
+type Source struct {
+	Path string
+	// Platform overrides the platform recorded in the source image if set.
+	Platform *ggcrv1.Platform
+}
+
+// Platforms returns the platforms of all the system partitions found in f,
+// the primary system partition comes first.
+func Platforms(f *sif.FileImage) ([]Platform, error) {
+	descs, err := f.GetDescriptors(sif.WithDataType(sif.DataPartition))
+	if err != nil {
+		return nil, err
+	}
+
+	var primary []Platform
+	var others []Platform
+
+	for _, d := range descs {
+		_, pt, arch, err := d.PartitionMetadata()
+		if err != nil {
+			return nil, err
+		}
+		if pt != sif.PartPrimSys && pt != sif.PartSystem {
+			continue
+		}
+		p := Platform{
+			Platform: platformFromGroup(f, d.GroupID(), arch),
+			GroupID:  d.GroupID(),
+			ID:       d.ID(),
+			Primary:  pt == sif.PartPrimSys,
+		}
+		if p.Primary {
+			primary = append(primary, p)
+		} else {
+			others = append(others, p)
+		}
+	}
+
+	return append(primary, others...), nil
+}
+
+// platformFromGroup returns the platform recorded in the platform descriptor
+// of the group, or the one derived from the partition architecture.
+func platformFromGroup(f *sif.FileImage, groupID uint32, arch string) ggcrv1.Platform {
+	d, err := f.GetDescriptor(
+		sif.WithDataType(sif.DataGenericJSON),
+		sif.WithGroupID(groupID),
+		func(d sif.Descriptor) (bool, error) { return d.Name() == PlatformDescName, nil },
+	)
+	if err == nil {
+		var p ggcrv1.Platform
+		b, err := d.GetData()
+		if err == nil {
+			err = json.Unmarshal(b, &p)
+		}
+		if err == nil {
+			return p
+		}
+		sylog.Warningf("Invalid %s descriptor in group %d: %v", PlatformDescName, groupID, err)
+	}
+
+	p, err := ociplatform.PlatformFromArch(arch)
+	if err != nil {
+		return ggcrv1.Platform{OS: "linux", Architecture: arch}
+	}
+	return *p
+}
+
+// Select returns the system partition descriptor from f that best matches the
+// requested platform. An exact match of architecture and variant is preferred,
+// then the highest variant of the same architecture not above the requested
+// one. ErrNoPlatform is returned if no partition has the requested architecture.
+func Select(f *sif.FileImage, want ggcrv1.Platform) (sif.Descriptor, error) {
+	platforms, err := Platforms(f)
+	if err != nil {
+		return sif.Descriptor{}, err
+	}
+
+	best := -1
+	for i, p := range platforms {
+		if p.Architecture != want.Architecture {
+			continue
+		}
+		if p.Variant == want.Variant {
+			best = i
+			break
+		}
+		if want.Variant != "" && compareVariant(p.Variant, want.Variant) > 0 {
+			continue
+		}
+		if best < 0 || compareVariant(p.Variant, platforms[best].Variant) > 0 {
+			best = i
+		}
+	}
+	if best < 0 {
+		return sif.Descriptor{}, fmt.Errorf("%w %s", ErrNoPlatform, want.String())
+	}
+
+	return f.GetDescriptor(sif.WithID(platforms[best].ID))
+}
+
+// compareVariant compares the platform variants a and b, numerically for
+// versions like v7 or v10, and as strings otherwise.
+func compareVariant(a, b string) int {
+	av, aErr := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	bv, bErr := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if aErr == nil && bErr == nil {
+		return cmp.Compare(av, bv)
+	}
+	return strings.Compare(a, b)
+}
+
+// IsIndex returns true if f contains other system partitions in addition
+// to the primary system partition.
+func IsIndex(f *sif.FileImage) bool {
+	if _, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys)); err != nil {
+		return false
+	}
+	descs, err := f.GetDescriptors(sif.WithPartitionType(sif.PartSystem))
+	return err == nil && len(descs) > 0
+}
+
+// Create creates a multi-architecture SIF image at path from the single
+// architecture SIF images srcs. The system partition of the first source
+// becomes the primary system partition. Signatures are not copied as they
+// are not valid anymore in the new image.
+func Create(path string, srcs []Source) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("no source image")
+	}
+
+	var dis []sif.DescriptorInput
+	seen := make(map[string]string)
+
+	for i, src := range srcs {
+		groupID, err := safecast.Convert[uint32](i + 1)
+		if err != nil {
+			return err
+		}
+
+		f, err := sif.LoadContainerFromPath(src.Path, sif.OptLoadWithFlag(os.O_RDONLY))
+		if err != nil {
+			return fmt.Errorf("while loading %s: %w", src.Path, err)
+		}
+		defer f.UnloadContainer()
+
+		if IsIndex(f) {
+			return fmt.Errorf("%s is already a multi-architecture image", src.Path)
+		}
+
+		part, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+		if err != nil {
+			return fmt.Errorf("while searching primary system partition in %s: %w", src.Path, err)
+		}
+		_, _, arch, err := part.PartitionMetadata()
+		if err != nil {
+			return err
+		}
+
+		platform := platformFromGroup(f, part.GroupID(), arch)
+		if src.Platform != nil {
+			platform = *src.Platform
+			if platform.Architecture != arch {
+				sylog.Warningf("Platform %s of %s does not match partition architecture %s", platform.String(), src.Path, arch)
+			}
+		}
+		if prev, ok := seen[platform.String()]; ok {
+			return fmt.Errorf("%s and %s have the same platform %s", prev, src.Path, platform.String())
+		}
+		seen[platform.String()] = src.Path
+
+		in, err := groupInputs(f, part.GroupID(), groupID, i == 0, len(dis))
+		if err != nil {
+			return fmt.Errorf("while reading %s: %w", src.Path, err)
+		}
+		dis = append(dis, in...)
+
+		pin, err := platformInput(platform, groupID)
+		if err != nil {
+			return err
+		}
+		dis = append(dis, pin)
+	}
+
+	return create(path, dis)
+}
+
+// Extract creates a single architecture SIF image at path holding the
+// system partition of the multi-architecture SIF image src that best
+// matches the platform want, along with its related objects.
+func Extract(path, src string, want ggcrv1.Platform) error {
+	f, err := sif.LoadContainerFromPath(src, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		return fmt.Errorf("while loading %s: %w", src, err)
+	}
+	defer f.UnloadContainer()
+
+	part, err := Select(f, want)
+	if err != nil {
+		return err
+	}
+	_, _, arch, err := part.PartitionMetadata()
+	if err != nil {
+		return err
+	}
+
+	dis, err := groupInputs(f, part.GroupID(), 1, true, 0)
+	if err != nil {
+		return fmt.Errorf("while reading %s: %w", src, err)
+	}
+	pin, err := platformInput(platformFromGroup(f, part.GroupID(), arch), 1)
+	if err != nil {
+		return err
+	}
+
+	return create(path, append(dis, pin))
+}
+
+// platformInput returns the descriptor input recording platform in the
+// group groupID.
+func platformInput(platform ggcrv1.Platform, groupID uint32) (sif.DescriptorInput, error) {
+	pb, err := json.Marshal(platform)
+	if err != nil {
+		return sif.DescriptorInput{}, err
+	}
+	return sif.NewDescriptorInput(sif.DataGenericJSON, bytes.NewReader(pb),
+		sif.OptObjectName(PlatformDescName),
+		sif.OptGroupID(groupID),
+	)
+}
+
+// create creates a SIF image at path with the descriptors dis.
+func create(path string, dis []sif.DescriptorInput) error {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return fmt.Errorf("sif id generation failed: %v", err)
+	}
+
+	f, err := sif.CreateContainerAtPath(
+		path,
+		sif.OptCreateWithDescriptors(dis...),
+		sif.OptCreateWithID(id.String()),
+		sif.OptCreateWithLaunchScript("#!/usr/bin/env run-singularity\n"),
+	)
+	if err != nil {
+		return fmt.Errorf("while creating container: %w", err)
+	}
+
+	return f.UnloadContainer()
+}
+
+// groupInputs returns descriptor inputs for all the objects of the group
+// srcGroup in f, to be added in the group dstGroup. The system partition
+// is primary when primary is true. offset is the number of descriptors
+// preceding those in the destination image, it's used to remap links.
+func groupInputs(f *sif.FileImage, srcGroup, dstGroup uint32, primary bool, offset int) ([]sif.DescriptorInput, error) {
+	descs, err := f.GetDescriptors(sif.WithGroupID(srcGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	// map source descriptor IDs to destination ones, destination
+	// descriptors are numbered by order of creation starting at 1
+	ids := make(map[uint32]uint32)
+	n := offset
+	for _, d := range descs {
+		if d.DataType() == sif.DataSignature {
+			continue
+		}
+		if d.DataType() == sif.DataGenericJSON && d.Name() == PlatformDescName {
+			continue
+		}
+		n++
+		id, err := safecast.Convert[uint32](n)
+		if err != nil {
+			return nil, err
+		}
+		ids[d.ID()] = id
+	}
+
+	var dis []sif.DescriptorInput
+
+	for _, d := range descs {
+		if _, ok := ids[d.ID()]; !ok {
+			if d.DataType() == sif.DataSignature {
+				sylog.Warningf("Skipping signature object %d, the image must be signed again", d.ID())
+			}
+			continue
+		}
+
+		opts := []sif.DescriptorInputOpt{
+			sif.OptGroupID(dstGroup),
+			sif.OptObjectTime(d.CreatedAt()),
+		}
+		if name := d.Name(); name != "" {
+			opts = append(opts, sif.OptObjectName(name))
+		}
+		if link, isGroup := d.LinkedID(); link != 0 {
+			switch {
+			case isGroup && link == srcGroup:
+				opts = append(opts, sif.OptLinkedGroupID(dstGroup))
+			case ids[link] != 0:
+				opts = append(opts, sif.OptLinkedID(ids[link]))
+			}
+		}
+
+		switch d.DataType() {
+		case sif.DataPartition:
+			fs, pt, arch, err := d.PartitionMetadata()
+			if err != nil {
+				return nil, err
+			}
+			if pt == sif.PartPrimSys || pt == sif.PartSystem {
+				pt = sif.PartSystem
+				if primary {
+					pt = sif.PartPrimSys
+				}
+			}
+			opts = append(opts, sif.OptPartitionMetadata(fs, pt, arch))
+		case sif.DataCryptoMessage:
+			ft, mt, err := d.CryptoMessageMetadata()
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, sif.OptCryptoMessageMetadata(ft, mt))
+		case sif.DataSBOM:
+			sf, err := d.SBOMMetadata()
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, sif.OptSBOMMetadata(sf))
+		}
+
+		di, err := sif.NewDescriptorInput(d.DataType(), d.GetReader(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		dis = append(dis, di)
+	}
+
+	return dis, nil
+}
+
+// String returns a human readable list of platforms.
+func String(platforms []Platform) string {
+	s := make([]string, 0, len(platforms))
+	for _, p := range platforms {
+		s = append(s, p.Platform.String())
+	}
+	return strings.Join(s, ", ")
+}
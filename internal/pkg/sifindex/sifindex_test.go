@@ -0,0 +1,212 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sifindex
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/sif/v2/pkg/sif"
+	ggcrv1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func createImage(t *testing.T, path, arch string, data string) {
+	t.Helper()
+
+	def, err := sif.NewDescriptorInput(sif.DataDeffile, bytes.NewReader([]byte("bootstrap: scratch\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := sif.NewDescriptorInput(sif.DataPartition, bytes.NewReader([]byte(data)),
+		sif.OptPartitionMetadata(sif.FsRaw, sif.PartPrimSys, arch),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := sif.NewDescriptorInput(sif.DataSignature, bytes.NewReader([]byte("signature")),
+		sif.OptLinkedID(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := sif.CreateContainerAtPath(path, sif.OptCreateWithDescriptors(def, part, sig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.UnloadContainer(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	amd64 := filepath.Join(dir, "amd64.sif")
+	arm64 := filepath.Join(dir, "arm64.sif")
+	armv6 := filepath.Join(dir, "armv6.sif")
+	armv7 := filepath.Join(dir, "armv7.sif")
+	createImage(t, amd64, "amd64", "amd64 rootfs")
+	createImage(t, arm64, "arm64", "arm64 rootfs")
+	createImage(t, armv6, "arm", "armv6 rootfs")
+	createImage(t, armv7, "arm", "armv7 rootfs")
+
+	index := filepath.Join(dir, "index.sif")
+
+	t.Run("SamePlatform", func(t *testing.T) {
+		err := Create(index, []Source{{Path: armv6}, {Path: armv7}})
+		if err == nil {
+			t.Fatalf("unexpected success with images of the same platform")
+		}
+		os.Remove(index)
+	})
+
+	err := Create(index, []Source{
+		{Path: amd64},
+		{Path: arm64},
+		{Path: armv6, Platform: &ggcrv1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{Path: armv7},
+	})
+	if err != nil {
+		t.Fatalf("while creating index: %s", err)
+	}
+
+	f, err := sif.LoadContainerFromPath(index, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	if !IsIndex(f) {
+		t.Fatalf("image is not reported as a multi-architecture image")
+	}
+	if f.PrimaryArch() != "amd64" {
+		t.Errorf("got primary arch %s, expected amd64", f.PrimaryArch())
+	}
+	if sigs, _ := f.GetDescriptors(sif.WithDataType(sif.DataSignature)); len(sigs) > 0 {
+		t.Errorf("signatures were copied in the index")
+	}
+
+	platforms, err := Platforms(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"linux/amd64", "linux/arm64", "linux/arm/v6", "linux/arm/v7"}
+	if len(platforms) != len(want) {
+		t.Fatalf("got %d platforms, expected %d", len(platforms), len(want))
+	}
+	for i, p := range platforms {
+		if p.String() != want[i] {
+			t.Errorf("got platform %s, expected %s", p.String(), want[i])
+		}
+		if p.Primary != (i == 0) {
+			t.Errorf("unexpected primary flag for platform %s", p.String())
+		}
+		defs, err := f.GetDescriptors(sif.WithDataType(sif.DataDeffile), sif.WithGroupID(p.GroupID))
+		if err != nil || len(defs) != 1 {
+			t.Errorf("definition file missing for platform %s", p.String())
+		}
+	}
+
+	tests := []struct {
+		platform ggcrv1.Platform
+		wantData string
+		wantErr  error
+	}{
+		{ggcrv1.Platform{OS: "linux", Architecture: "amd64"}, "amd64 rootfs", nil},
+		{ggcrv1.Platform{OS: "linux", Architecture: "arm64"}, "arm64 rootfs", nil},
+		{ggcrv1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, "armv6 rootfs", nil},
+		{ggcrv1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "armv7 rootfs", nil},
+		{ggcrv1.Platform{OS: "linux", Architecture: "arm", Variant: "v8"}, "armv7 rootfs", nil},
+		{ggcrv1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"}, "", ErrNoPlatform},
+		{ggcrv1.Platform{OS: "linux", Architecture: "s390x"}, "", ErrNoPlatform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform.String(), func(t *testing.T) {
+			d, err := Select(f, tt.platform)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, expected %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			b, err := d.GetData()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.wantData {
+				t.Errorf("got partition %q, expected %q", b, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestCompareVariant(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v8", "v10", -1},
+		{"v10", "v8", 1},
+		{"v7", "v7", 0},
+		{"", "v8", -1},
+		{"v8", "", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVariant(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVariant(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+
+	amd64 := filepath.Join(dir, "amd64.sif")
+	arm64 := filepath.Join(dir, "arm64.sif")
+	createImage(t, amd64, "amd64", "amd64 rootfs")
+	createImage(t, arm64, "arm64", "arm64 rootfs")
+
+	index := filepath.Join(dir, "index.sif")
+	if err := Create(index, []Source{{Path: amd64}, {Path: arm64}}); err != nil {
+		t.Fatalf("while creating index: %s", err)
+	}
+
+	extracted := filepath.Join(dir, "extracted.sif")
+	if err := Extract(extracted, index, ggcrv1.Platform{OS: "linux", Architecture: "arm64"}); err != nil {
+		t.Fatalf("while extracting platform: %s", err)
+	}
+
+	f, err := sif.LoadContainerFromPath(extracted, sif.OptLoadWithFlag(os.O_RDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	if IsIndex(f) {
+		t.Errorf("extracted image is reported as a multi-architecture image")
+	}
+	d, err := f.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	if err != nil {
+		t.Fatalf("primary system partition missing: %s", err)
+	}
+	if b, _ := d.GetData(); string(b) != "arm64 rootfs" {
+		t.Errorf("got partition %q, expected %q", b, "arm64 rootfs")
+	}
+	if defs, err := f.GetDescriptors(sif.WithDataType(sif.DataDeffile)); err != nil || len(defs) != 1 {
+		t.Errorf("definition file missing in extracted image")
+	}
+
+	err = Extract(filepath.Join(dir, "s390x.sif"), index, ggcrv1.Platform{OS: "linux", Architecture: "s390x"})
+	if !errors.Is(err, ErrNoPlatform) {
+		t.Errorf("got error %v, expected %v", err, ErrNoPlatform)
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/apptainer/sif/v2/pkg/integrity"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/opencontainers/go-digest"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+)
+
+// countersignMediaType is the DSSE payload type of countersignatures.
+const countersignMediaType = "application/vnd.apptainer.sif-countersignature+json"
+
+var (
+	errNoSignature            = errors.New("no signature to countersign")
+	errNotSignature           = errors.New("object is not a signature")
+	errCountersignMismatch    = errors.New("countersignature does not match signature")
+	errFingerprintMismatch    = errors.New("signing entity fingerprint mismatch")
+	errUnknownCountersignType = errors.New("countersignature format not recognized")
+	errNoKeyMaterialDSSE      = errors.New("key material not provided for DSSE countersignature")
+	errNoKeyMaterialPGP       = errors.New("key material not provided for PGP countersignature")
+)
+
+// countersignMetadata is the message signed by a countersignature, it
+// identifies the signature object being countersigned by its ID and the
+// digest of its content. As signature objects cover the image metadata,
+// the countersignature transitively covers the signed objects as well.
+type countersignMetadata struct {
+	Version   int `json:"version"`
+	Signature struct {
+		ID     uint32        `json:"id"`
+		Digest digest.Digest `json:"digest"`
+	} `json:"signature"`
+}
+
+// getCountersignMetadata returns the countersignature metadata of the
+// signature object sig.
+func getCountersignMetadata(sig sif.Descriptor) (countersignMetadata, error) {
+	d, err := digest.SHA256.FromReader(sig.GetReader())
+	if err != nil {
+		return countersignMetadata{}, err
+	}
+
+	md := countersignMetadata{Version: 1}
+	md.Signature.ID = sig.ID()
+	md.Signature.Digest = d
+	return md, nil
+}
+
+// matches checks that md describes the signature object sig.
+func (md countersignMetadata) matches(sig sif.Descriptor) error {
+	if md.Signature.ID != sig.ID() {
+		return fmt.Errorf("%w: signature ID %d instead of %d", errCountersignMismatch, md.Signature.ID, sig.ID())
+	}
+	if err := md.Signature.Digest.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", errCountersignMismatch, err)
+	}
+	d, err := md.Signature.Digest.Algorithm().FromReader(sig.GetReader())
+	if err != nil {
+		return err
+	}
+	if d != md.Signature.Digest {
+		return fmt.Errorf("%w: signature %d was modified", errCountersignMismatch, sig.ID())
+	}
+	return nil
+}
+
+// isCountersignature returns true if od is a signature object linked to
+// another signature object.
+func isCountersignature(f *sif.FileImage, od sif.Descriptor) bool {
+	if od.DataType() != sif.DataSignature {
+		return false
+	}
+	id, isGroup := od.LinkedID()
+	if isGroup || id == 0 {
+		return false
+	}
+	target, err := f.GetDescriptor(sif.WithID(id))
+	return err == nil && target.DataType() == sif.DataSignature
+}
+
+// getCountersignatures returns the countersignatures found in f, sorted by
+// ID, so a countersignature always comes after the signature it covers.
+func getCountersignatures(f *sif.FileImage) ([]sif.Descriptor, error) {
+	sigs, err := f.GetDescriptors(
+		sif.WithDataType(sif.DataSignature),
+		func(od sif.Descriptor) (bool, error) {
+			return isCountersignature(f, od), nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(sigs, func(a, b sif.Descriptor) int { return int(a.ID()) - int(b.ID()) })
+	return sigs, nil
+}
+
+// getCountersignTargets returns the signature objects to countersign. When
+// ids is empty, all the signatures not already countersigned are returned,
+// which extends each existing signature chain by one link.
+func getCountersignTargets(f *sif.FileImage, ids []uint32) ([]sif.Descriptor, error) {
+	if len(ids) > 0 {
+		targets := make([]sif.Descriptor, 0, len(ids))
+		for _, id := range ids {
+			od, err := f.GetDescriptor(sif.WithID(id))
+			if err != nil {
+				return nil, err
+			}
+			if od.DataType() != sif.DataSignature {
+				return nil, fmt.Errorf("%w (%d)", errNotSignature, id)
+			}
+			targets = append(targets, od)
+		}
+		return targets, nil
+	}
+
+	sigs, err := f.GetDescriptors(sif.WithDataType(sif.DataSignature))
+	if err != nil {
+		return nil, err
+	}
+
+	countersigned := make(map[uint32]bool)
+	for _, od := range sigs {
+		if isCountersignature(f, od) {
+			id, _ := od.LinkedID()
+			countersigned[id] = true
+		}
+	}
+
+	var targets []sif.Descriptor
+	for _, od := range sigs {
+		if !countersigned[od.ID()] {
+			targets = append(targets, od)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errNoSignature
+	}
+	return targets, nil
+}
+
+// countersign adds a countersignature to f for each signature object
+// selected by ids, see getCountersignTargets. Key material is taken from
+// ss if set, from the PGP entity e otherwise, used with config.
+func countersign(ctx context.Context, f *sif.FileImage, ids []uint32, ss []signature.Signer, e *openpgp.Entity, config *packet.Config) error {
+	if len(ss) == 0 && e == nil {
+		return integrity.ErrNoKeyMaterial
+	}
+
+	targets, err := getCountersignTargets(f, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		md, err := getCountersignMetadata(target)
+		if err != nil {
+			return fmt.Errorf("failed to get countersignature metadata: %w", err)
+		}
+		msg, err := json.Marshal(md)
+		if err != nil {
+			return fmt.Errorf("failed to encode countersignature metadata: %w", err)
+		}
+
+		var (
+			b  bytes.Buffer
+			fp []byte
+		)
+		ht := crypto.SHA256
+		if len(ss) > 0 {
+			s := dsse.WrapMultiSigner(countersignMediaType, ss...)
+			sig, err := s.SignMessage(bytes.NewReader(msg), options.WithContext(ctx), options.WithCryptoSignerOpts(ht))
+			if err != nil {
+				return fmt.Errorf("failed to sign message: %w", err)
+			}
+			b.Write(sig)
+		} else {
+			w, err := clearsign.Encode(&b, e.PrivateKey, config)
+			if err != nil {
+				return fmt.Errorf("failed to sign message: %w", err)
+			}
+			if _, err := w.Write(msg); err != nil {
+				return fmt.Errorf("failed to sign message: %w", err)
+			}
+			if err := w.Close(); err != nil {
+				return fmt.Errorf("failed to sign message: %w", err)
+			}
+			fp = e.PrimaryKey.Fingerprint
+		}
+
+		di, err := sif.NewDescriptorInput(sif.DataSignature, &b,
+			sif.OptNoGroup(),
+			sif.OptLinkedID(target.ID()),
+			sif.OptSignatureMetadata(ht, fp),
+		)
+		if err != nil {
+			return err
+		}
+		if err := f.AddObject(di); err != nil {
+			return fmt.Errorf("failed to add object: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Result describes the result of a signature or countersignature
+// verification, it is implemented by integrity.VerifyResult and
+// CountersignResult.
+type Result interface {
+	Signature() sif.Descriptor
+	Keys() []crypto.PublicKey
+	Entity() *openpgp.Entity
+	Error() error
+}
+
+// CountersignResult describes the result of a countersignature
+// verification.
+type CountersignResult struct {
+	sig    sif.Descriptor
+	target sif.Descriptor
+	keys   []crypto.PublicKey
+	e      *openpgp.Entity
+	err    error
+}
+
+// Signature returns the countersignature object associated with the result.
+func (r CountersignResult) Signature() sif.Descriptor {
+	return r.sig
+}
+
+// Target returns the signature object covered by the countersignature.
+func (r CountersignResult) Target() sif.Descriptor {
+	return r.target
+}
+
+// Keys returns the public key(s) used to verify the countersignature.
+func (r CountersignResult) Keys() []crypto.PublicKey {
+	return r.keys
+}
+
+// Entity returns the signing entity, or nil if the signing entity could not
+// be determined.
+func (r CountersignResult) Entity() *openpgp.Entity {
+	return r.e
+}
+
+// Error returns an error describing the reason verification failed, or nil
+// if verification was successful.
+func (r CountersignResult) Error() error {
+	return r.err
+}
+
+// Fingerprints returns the fingerprints of the entities that produced the
+// signature described by r. PGP entities are identified by the fingerprint
+// of their primary key, other keys by the hex encoded SHA-256 digest of
+// their DER encoded public key.
+func Fingerprints(r Result) []string {
+	if e := r.Entity(); e != nil {
+		return []string{hex.EncodeToString(e.PrimaryKey.Fingerprint)}
+	}
+
+	fps := make([]string, 0, len(r.Keys()))
+	for _, pub := range r.Keys() {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(der)
+		fps = append(fps, hex.EncodeToString(sum[:]))
+	}
+	return fps
+}
+
+// wrappedVerifier records the public keys of verifiers accepting a
+// signature.
+type wrappedVerifier struct {
+	signature.Verifier
+	keys *[]crypto.PublicKey
+}
+
+func (wv wrappedVerifier) VerifySignature(sig, message io.Reader, opts ...signature.VerifyOption) error {
+	if err := wv.Verifier.VerifySignature(sig, message, opts...); err != nil {
+		return err
+	}
+	pub, err := wv.Verifier.PublicKey()
+	if err != nil {
+		return err
+	}
+	*wv.keys = append(*wv.keys, pub)
+	return nil
+}
+
+// dsseEnvelope describes a DSSE envelope.
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+// verifyCountersignature verifies the countersignature sig over the
+// signature object target with key material km, populating r.
+func verifyCountersignature(ctx context.Context, km keyMaterial, sig, target sif.Descriptor, r *CountersignResult) error {
+	ht, fp, err := sig.SignatureMetadata()
+	if err != nil {
+		return err
+	}
+	data, err := sig.GetData()
+	if err != nil {
+		return err
+	}
+
+	var msg []byte
+	var env dsseEnvelope
+	if json.Unmarshal(data, &env) == nil && env.PayloadType == countersignMediaType {
+		if len(km.svs) == 0 {
+			return errNoKeyMaterialDSSE
+		}
+		vs := make([]signature.Verifier, 0, len(km.svs))
+		for _, v := range km.svs {
+			vs = append(vs, wrappedVerifier{Verifier: v, keys: &r.keys})
+		}
+		v := dsse.WrapMultiVerifier(countersignMediaType, 1, vs...)
+		if err := v.VerifySignature(bytes.NewReader(data), nil, options.WithContext(ctx), options.WithHash(ht)); err != nil {
+			return &integrity.SignatureNotValidError{ID: sig.ID(), Err: err}
+		}
+		if msg, err = base64.StdEncoding.DecodeString(env.Payload); err != nil {
+			if msg, err = base64.URLEncoding.DecodeString(env.Payload); err != nil {
+				return &integrity.SignatureNotValidError{ID: sig.ID(), Err: err}
+			}
+		}
+	} else if b, _ := clearsign.Decode(data); b != nil {
+		if km.kr == nil {
+			return errNoKeyMaterialPGP
+		}
+		hashes := []crypto.Hash{crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512}
+		r.e, err = openpgp.CheckDetachedSignatureAndHash(km.kr, bytes.NewReader(b.Bytes), b.ArmoredSignature.Body, hashes, nil)
+		if err != nil {
+			return &integrity.SignatureNotValidError{ID: sig.ID(), Err: err}
+		}
+		if !bytes.Equal(r.e.PrimaryKey.Fingerprint, fp) {
+			return errFingerprintMismatch
+		}
+		msg = b.Plaintext
+	} else {
+		return errUnknownCountersignType
+	}
+
+	var md countersignMetadata
+	if err := json.Unmarshal(msg, &md); err != nil {
+		return &integrity.SignatureNotValidError{ID: sig.ID(), Err: err}
+	}
+	return md.matches(target)
+}
@@ -0,0 +1,381 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signature
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apptainer/container-key-client/client"
+	"github.com/apptainer/sif/v2/pkg/integrity"
+	"github.com/apptainer/sif/v2/pkg/sif"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// getTestFingerprint returns the fingerprint of the public key of v.
+func getTestFingerprint(t *testing.T, v signature.Verifier) string {
+	t.Helper()
+
+	pub, err := v.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// countersignedImage returns a copy of the image at path, countersigned
+// according to opts.
+func countersignedImage(t *testing.T, path string, opts ...SignOpt) string {
+	t.Helper()
+
+	path, err := tempFileFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(path) })
+
+	for _, opt := range opts {
+		if err := Sign(t.Context(), path, opt, OptSignCountersign()); err != nil {
+			t.Fatalf("failed to countersign: %v", err)
+		}
+	}
+	return path
+}
+
+func TestCountersign(t *testing.T) {
+	ecdsa := getTestSigner(t, "ecdsa-private.pem")
+	es := mockEntitySelector(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    []SignOpt
+		wantErr error
+	}{
+		{
+			name:    "ErrNoKeyMaterial",
+			path:    filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif"),
+			opts:    []SignOpt{OptSignCountersign()},
+			wantErr: integrity.ErrNoKeyMaterial,
+		},
+		{
+			name:    "NoSignature",
+			path:    filepath.Join("..", "..", "..", "test", "images", "one-group.sif"),
+			opts:    []SignOpt{OptSignWithSigner(ecdsa), OptSignCountersign()},
+			wantErr: errNoSignature,
+		},
+		{
+			name:    "NotSignature",
+			path:    filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif"),
+			opts:    []SignOpt{OptSignWithSigner(ecdsa), OptSignCountersign(1)},
+			wantErr: errNotSignature,
+		},
+		{
+			name: "Signer",
+			path: filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif"),
+			opts: []SignOpt{OptSignWithSigner(ecdsa), OptSignCountersign()},
+		},
+		{
+			name: "SignerID",
+			path: filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif"),
+			opts: []SignOpt{OptSignWithSigner(ecdsa), OptSignCountersign(3)},
+		},
+		{
+			name: "EntitySelector",
+			path: filepath.Join("..", "..", "..", "test", "images", "one-group-signed-pgp.sif"),
+			opts: []SignOpt{OptSignEntitySelector(es), OptSignCountersign()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Signing modifies the file, so work with a temporary file.
+			path, err := tempFileFrom(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+
+			if got, want := Sign(t.Context(), path, tt.opts...), tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			f, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.UnloadContainer()
+
+			sigs, err := getCountersignatures(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sigs) != 1 {
+				t.Fatalf("got %d countersignatures, want 1", len(sigs))
+			}
+			if id, isGroup := sigs[0].LinkedID(); id != 3 || isGroup {
+				t.Errorf("got countersignature linked to %d (group %v), want 3", id, isGroup)
+			}
+		})
+	}
+}
+
+func TestVerifyCountersign(t *testing.T) {
+	ecdsaSigner := getTestSigner(t, "ecdsa-private.pem")
+	rsaSigner := getTestSigner(t, "rsa-private.pem")
+
+	ed25519 := getTestVerifier(t, "ed25519-public.pem")
+	ecdsa := getTestVerifier(t, "ecdsa-public.pem")
+	rsa := getTestVerifier(t, "rsa-public.pem")
+	ed25519FP := getTestFingerprint(t, ed25519)
+	ecdsaFP := getTestFingerprint(t, ecdsa)
+	rsaFP := getTestFingerprint(t, rsa)
+
+	// Start up a mock HKP server.
+	e := getTestEntity(t)
+	s := httptest.NewServer(mockHKP{e: e})
+	defer s.Close()
+
+	dsse := filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif")
+	pgp := filepath.Join("..", "..", "..", "test", "images", "one-group-signed-pgp.sif")
+
+	// ed25519 (and rsa) signature countersigned by ecdsa.
+	countersigned := countersignedImage(t, dsse, OptSignWithSigner(ecdsaSigner))
+	// ed25519 (and rsa) signature countersigned by ecdsa, then by rsa.
+	chained := countersignedImage(t, dsse, OptSignWithSigner(ecdsaSigner), OptSignWithSigner(rsaSigner))
+	// PGP signature countersigned by the same entity.
+	selfCountersigned := countersignedImage(t, pgp, OptSignEntitySelector(mockEntitySelector(t)))
+
+	tests := []struct {
+		name             string
+		path             string
+		opts             []VerifyOpt
+		wantCountersigns []uint32
+		wantErr          error
+	}{
+		{
+			name:    "NoKey",
+			path:    countersigned,
+			opts:    []VerifyOpt{OptVerifyWithVerifier(ed25519)},
+			wantErr: &integrity.SignatureNotValidError{},
+		},
+		{
+			name:             "Countersigned",
+			path:             countersigned,
+			opts:             []VerifyOpt{OptVerifyWithVerifier(ed25519), OptVerifyWithVerifier(ecdsa)},
+			wantCountersigns: []uint32{4},
+		},
+		{
+			name:             "Chained",
+			path:             chained,
+			opts:             []VerifyOpt{OptVerifyWithVerifier(ed25519), OptVerifyWithVerifier(ecdsa), OptVerifyWithVerifier(rsa)},
+			wantCountersigns: []uint32{4, 5},
+		},
+		{
+			name: "PGP",
+			path: selfCountersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithPGP(client.OptBaseURL(s.URL)),
+			},
+			wantCountersigns: []uint32{4},
+		},
+		{
+			name: "RequireSigners",
+			path: countersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyRequireSigners(2),
+			},
+			wantCountersigns: []uint32{4},
+		},
+		{
+			name: "RequireSignersNotEnough",
+			path: countersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyRequireSigners(3),
+			},
+			wantCountersigns: []uint32{4},
+			wantErr:          errNotEnoughSigners,
+		},
+		{
+			name:    "RequireSignersInvalid",
+			path:    countersigned,
+			opts:    []VerifyOpt{OptVerifyRequireSigners(0)},
+			wantErr: errInvalidSignersPolicy,
+		},
+		{
+			name: "RequireFingerprint",
+			path: countersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyRequireFingerprint(ed25519FP),
+				OptVerifyRequireFingerprint(ecdsaFP),
+			},
+			wantCountersigns: []uint32{4},
+		},
+		{
+			name: "RequireFingerprintMissing",
+			path: countersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyRequireFingerprint(rsaFP),
+			},
+			wantCountersigns: []uint32{4},
+			wantErr:          errNotSignedByRequired,
+		},
+		{
+			name: "RequireCountersign",
+			path: countersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyRequireCountersign(),
+			},
+			wantCountersigns: []uint32{4},
+		},
+		{
+			name: "RequireCountersignMissing",
+			path: dsse,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyRequireCountersign(),
+			},
+			wantErr: errNotCountersigned,
+		},
+		{
+			name: "RequireCountersignSameEntity",
+			path: selfCountersigned,
+			opts: []VerifyOpt{
+				OptVerifyWithPGP(client.OptBaseURL(s.URL)),
+				OptVerifyRequireCountersign(),
+			},
+			wantCountersigns: []uint32{4},
+			wantErr:          errNotCountersigned,
+		},
+		{
+			name: "RequireCountersignOrder",
+			path: chained,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyWithVerifier(rsa),
+				OptVerifyRequireCountersign(),
+				OptVerifyRequireFingerprint(ed25519FP),
+				OptVerifyRequireFingerprint(ecdsaFP),
+				OptVerifyRequireFingerprint(rsaFP),
+			},
+			wantCountersigns: []uint32{4, 5},
+		},
+		{
+			name: "RequireCountersignWrongOrder",
+			path: chained,
+			opts: []VerifyOpt{
+				OptVerifyWithVerifier(ed25519),
+				OptVerifyWithVerifier(ecdsa),
+				OptVerifyWithVerifier(rsa),
+				OptVerifyRequireCountersign(),
+				OptVerifyRequireFingerprint(ed25519FP),
+				OptVerifyRequireFingerprint(rsaFP),
+				OptVerifyRequireFingerprint(ecdsaFP),
+			},
+			wantCountersigns: []uint32{4, 5},
+			wantErr:          errCountersignOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var countersigns []uint32
+
+			cb := func(_ *sif.FileImage, r CountersignResult) bool {
+				if r.Error() == nil {
+					countersigns = append(countersigns, r.Signature().ID())
+					if id, _ := r.Signature().LinkedID(); id != r.Target().ID() {
+						t.Errorf("got target %v, want %v", r.Target().ID(), id)
+					}
+					if len(Fingerprints(r)) == 0 {
+						t.Errorf("no fingerprint for countersignature %v", r.Signature().ID())
+					}
+				}
+				return false
+			}
+			tt.opts = append(tt.opts, OptVerifyCountersignCallback(cb))
+
+			err := Verify(t.Context(), tt.path, tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+
+			if got, want := countersigns, tt.wantCountersigns; len(got) != len(want) {
+				t.Errorf("got countersignatures %v, want %v", got, want)
+			} else {
+				for i := range got {
+					if got[i] != want[i] {
+						t.Errorf("got countersignatures %v, want %v", got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCountersignMetadata(t *testing.T) {
+	f, err := sif.LoadContainerFromPath(filepath.Join("..", "..", "..", "test", "images", "one-group-signed-dsse.sif"),
+		sif.OptLoadWithFlag(os.O_RDONLY),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.UnloadContainer()
+
+	sig, err := f.GetDescriptor(sif.WithID(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := f.GetDescriptor(sif.WithID(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := getCountersignMetadata(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := md.matches(sig); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := md.matches(other); !errors.Is(err, errCountersignMismatch) {
+		t.Errorf("got error %v, want %v", err, errCountersignMismatch)
+	}
+
+	// the digest must match as well
+	md.Signature.ID = other.ID()
+	if err := md.matches(other); !errors.Is(err, errCountersignMismatch) {
+		t.Errorf("got error %v, want %v", err, errCountersignMismatch)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+// errNoTrustedTime is recorded for keys with a signature not covered by a valid countersignature
+// from a trusted entity.
+var errNoTrustedTime = errors.New("signature time not vouched for by a trusted countersignature")
+
+// signingTimeKeyRing wraps a PGP keyring so that revocation and expiration of keys are evaluated
+// at the time the signatures of an image are known to have existed, rather than at the time of
+// verification.
+//
+// The creation time of a signature is chosen by its signer, so it's only trusted when vouched for
+// by a valid PGP countersignature from another entity required by the verification policy, the
+// earliest one being taken as the signing time. The creation time of a countersignature is chosen
+// by the countersigner too, any other valid key could vouch for a backdated time. Keys which
+// were valid at the trusted time of each of their signatures are presented without their
+// expiration time and revocations, so that images signed before a key expired or was retired
+// remain valid. Other keys, including those with a signature not countersigned, are presented as
+// is. As a key revoked as compromised or without a reason is revoked at any time, it never
+// validates any signature.
+type signingTimeKeyRing struct {
+	openpgp.KeyRing
+
+	// errs holds, for each key ID that issued a signature in the image, nil if the key was valid
+	// at the trusted time of all of its signatures, or the reason it was not otherwise.
+	errs map[uint64]error
+}
+
+// newSigningTimeKeyRing returns a keyring wrapping kr, evaluating keys at the time of the PGP
+// signatures of f vouched for by countersignatures from the entities with fingerprints trusted.
+func newSigningTimeKeyRing(ctx context.Context, f *sif.FileImage, kr openpgp.KeyRing, trusted []string) (*signingTimeKeyRing, error) {
+	skr := &signingTimeKeyRing{
+		KeyRing: kr,
+		errs:    make(map[uint64]error),
+	}
+
+	sigs, err := f.GetDescriptors(sif.WithDataType(sif.DataSignature))
+	if err != nil {
+		return nil, err
+	}
+	countersigs, err := getCountersignatures(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, od := range sigs {
+		b, err := decodePGPSignature(od)
+		if err != nil {
+			return nil, err
+		} else if b == nil {
+			continue
+		}
+
+		t, vouched := trustedTime(ctx, kr, od, countersigs, trusted)
+
+		packets := packet.NewReader(b.ArmoredSignature.Body)
+		for {
+			// Malformed signatures are reported during verification.
+			p, err := packets.Next()
+			if err != nil {
+				break
+			}
+
+			sig, ok := p.(*packet.Signature)
+			if !ok || sig.IssuerKeyId == nil {
+				continue
+			}
+			id := *sig.IssuerKeyId
+
+			for _, k := range kr.KeysByIdUsage(id, packet.KeyFlagSign) {
+				// The first reason a key was not valid is kept.
+				if prev, seen := skr.errs[id]; seen && prev != nil {
+					continue
+				}
+				if vouched {
+					skr.errs[id] = validAt(k, t)
+				} else {
+					skr.errs[id] = errNoTrustedTime
+				}
+			}
+		}
+	}
+
+	return skr, nil
+}
+
+// decodePGPSignature returns the clear-signed block of the signature object od, or nil if od is
+// not a PGP signature.
+func decodePGPSignature(od sif.Descriptor) (*clearsign.Block, error) {
+	data, err := od.GetData()
+	if err != nil {
+		return nil, err
+	}
+	b, _ := clearsign.Decode(data)
+	return b, nil
+}
+
+// trustedTime returns the creation time of the earliest PGP countersignature over the signature
+// object od among countersigs, which is valid with keys of kr as they are now and made by one of
+// the entities with fingerprints trusted other than the signer of od. False is returned if there
+// is none.
+func trustedTime(ctx context.Context, kr openpgp.KeyRing, od sif.Descriptor, countersigs []sif.Descriptor, trusted []string) (time.Time, bool) {
+	var t time.Time
+	found := false
+
+	_, signer, err := od.SignatureMetadata()
+	if err != nil {
+		return t, false
+	}
+
+	for _, cs := range countersigs {
+		if id, _ := cs.LinkedID(); id != od.ID() {
+			continue
+		}
+		// DSSE countersignatures don't record their creation time.
+		b, err := decodePGPSignature(cs)
+		if err != nil || b == nil {
+			continue
+		}
+		var r CountersignResult
+		if err := verifyCountersignature(ctx, keyMaterial{kr: kr}, cs, od, &r); err != nil {
+			continue
+		}
+		fp := r.e.PrimaryKey.Fingerprint
+		if bytes.Equal(fp, signer) || !slices.ContainsFunc(trusted, func(s string) bool {
+			return strings.EqualFold(s, hex.EncodeToString(fp))
+		}) {
+			continue
+		}
+
+		p, err := packet.NewReader(b.ArmoredSignature.Body).Next()
+		if err != nil {
+			continue
+		}
+		if sig, ok := p.(*packet.Signature); ok && (!found || sig.CreationTime.Before(t)) {
+			t = sig.CreationTime
+			found = true
+		}
+	}
+
+	return t, found
+}
+
+// revokedAt returns true if one of revocations applies at time t. Keys superseded or retired, and
+// user IDs no longer valid, are revoked from the time of the revocation, other revocations,
+// including those without a reason, apply at any time.
+func revokedAt(revocations []*packet.Signature, t time.Time) bool {
+	for _, r := range revocations {
+		if r.RevocationReason == nil {
+			return true
+		}
+		switch *r.RevocationReason {
+		case packet.KeySuperseded, packet.KeyRetired, packet.UserIDNotValid:
+			if !r.CreationTime.After(t) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// validAt returns an error if key k was revoked or expired at time t.
+func validAt(k openpgp.Key, t time.Time) error {
+	e := k.Entity
+	selfSig, ident := e.PrimarySelfSignature()
+	bySubkey := k.PublicKey != e.PrimaryKey
+
+	if revokedAt(e.Revocations, t) || (bySubkey && revokedAt(k.Revocations, t)) || (ident != nil && revokedAt(ident.Revocations, t)) {
+		return pgperrors.ErrKeyRevoked
+	}
+	if selfSig != nil && e.PrimaryKey.KeyExpired(selfSig, t) {
+		return pgperrors.ErrKeyExpired
+	}
+	if bySubkey && k.SelfSignature != nil && k.PublicKey.KeyExpired(k.SelfSignature, t) {
+		return pgperrors.ErrKeyExpired
+	}
+	return nil
+}
+
+// KeysByIdUsage returns the keys with the given id meeting requiredUsage, as they were at the
+// time of the signatures they issued.
+func (kr *signingTimeKeyRing) KeysByIdUsage(id uint64, requiredUsage byte) []openpgp.Key {
+	keys := kr.KeyRing.KeysByIdUsage(id, requiredUsage)
+
+	// Keys revoked or expired at signing time, or without a trusted signing time, are evaluated
+	// as they are now.
+	if err, ok := kr.errs[id]; !ok || err != nil {
+		return keys
+	}
+
+	for i, k := range keys {
+		keys[i] = withoutExpiry(k)
+	}
+	return keys
+}
+
+// withoutExpiry returns a copy of k without expiration time and revocations. The original entity
+// is left untouched.
+func withoutExpiry(k openpgp.Key) openpgp.Key {
+	orig := k.Entity
+
+	e := *orig
+	e.Revocations = nil
+	e.Identities = make(map[string]*openpgp.Identity, len(orig.Identities))
+	for name, ident := range orig.Identities {
+		i := *ident
+		i.Revocations = nil
+		if ident.SelfSignature != nil {
+			sig := *ident.SelfSignature
+			sig.KeyLifetimeSecs = nil
+			i.SelfSignature = &sig
+		}
+		e.Identities[name] = &i
+	}
+	k.Entity = &e
+
+	if k.PublicKey == orig.PrimaryKey {
+		k.SelfSignature, _ = e.PrimarySelfSignature()
+	} else if k.SelfSignature != nil {
+		sig := *k.SelfSignature
+		sig.KeyLifetimeSecs = nil
+		k.SelfSignature = &sig
+	}
+	k.Revocations = nil
+
+	return k
+}
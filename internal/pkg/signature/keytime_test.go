@@ -0,0 +1,235 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signature
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/apptainer/sif/v2/pkg/integrity"
+	"github.com/apptainer/sif/v2/pkg/sif"
+)
+
+func TestSigningTimeKeyRing(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) func() time.Time {
+		return func() time.Time { return now.Add(d) }
+	}
+
+	// newEntity returns a key created two days ago, expiring after lifetime.
+	newEntity := func(t *testing.T, lifetime time.Duration) *openpgp.Entity {
+		e, err := openpgp.NewEntity("test", "", "test@test.com", &packet.Config{
+			Algorithm:       packet.PubKeyAlgoEdDSA,
+			Time:            at(-48 * time.Hour),
+			KeyLifetimeSecs: uint32(lifetime.Seconds()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	revoked := func(reason packet.ReasonForRevocation, d time.Duration) func(*testing.T, *openpgp.Entity) {
+		return func(t *testing.T, e *openpgp.Entity) {
+			if err := e.RevokeKey(reason, "", &packet.Config{Time: at(d)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// tsa countersigns the signatures, vouching for their time.
+	tsa := newEntity(t, 0)
+	trusted := []string{hex.EncodeToString(tsa.PrimaryKey.Fingerprint)}
+
+	// other is a valid key not trusted to vouch for signature times.
+	other := newEntity(t, 0)
+
+	tests := []struct {
+		name          string
+		lifetime      time.Duration
+		update        func(*testing.T, *openpgp.Entity)
+		signedAt      time.Duration
+		countersignAt time.Duration
+		noCountersign bool
+		untrusted     bool
+		wantErr       error
+	}{
+		{
+			name:          "Valid",
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+		},
+		{
+			name:          "SignedBeforeExpiry",
+			lifetime:      36 * time.Hour,
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+		},
+		{
+			name:          "SignedAfterExpiry",
+			lifetime:      36 * time.Hour,
+			signedAt:      -time.Hour,
+			countersignAt: -time.Hour,
+			wantErr:       pgperrors.ErrKeyExpired,
+		},
+		{
+			name:          "BackdatedSignature",
+			lifetime:      36 * time.Hour,
+			signedAt:      -24 * time.Hour,
+			countersignAt: -time.Hour,
+			wantErr:       pgperrors.ErrKeyExpired,
+		},
+		{
+			name:          "BackdatedCountersignature",
+			lifetime:      36 * time.Hour,
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+			untrusted:     true,
+			wantErr:       pgperrors.ErrKeyExpired,
+		},
+		{
+			name:          "NotCountersigned",
+			lifetime:      36 * time.Hour,
+			signedAt:      -24 * time.Hour,
+			noCountersign: true,
+			wantErr:       pgperrors.ErrKeyExpired,
+		},
+		{
+			name:          "SignedBeforeRetirement",
+			update:        revoked(packet.KeyRetired, -12*time.Hour),
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+		},
+		{
+			name:          "SignedAfterRetirement",
+			update:        revoked(packet.KeyRetired, -30*time.Hour),
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+			wantErr:       pgperrors.ErrKeyRevoked,
+		},
+		{
+			name:          "SignedBeforeCompromise",
+			update:        revoked(packet.KeyCompromised, -12*time.Hour),
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+			wantErr:       pgperrors.ErrKeyRevoked,
+		},
+		{
+			name:          "SignedBeforeUnspecifiedRevocation",
+			update:        revoked(packet.NoReason, -12*time.Hour),
+			signedAt:      -24 * time.Hour,
+			countersignAt: -24 * time.Hour,
+			wantErr:       pgperrors.ErrKeyRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEntity(t, tt.lifetime)
+
+			path, err := tempFileFrom(filepath.Join("..", "..", "..", "test", "images", "one-group.sif"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(path)
+
+			f, err := sif.LoadContainerFromPath(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.UnloadContainer()
+
+			s, err := integrity.NewSigner(f, integrity.OptSignWithEntity(e), integrity.OptSignWithTime(at(tt.signedAt)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Sign(); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.noCountersign {
+				cs := tsa
+				if tt.untrusted {
+					cs = other
+				}
+				err := countersign(context.Background(), f, nil, nil, cs, &packet.Config{Time: at(tt.countersignAt)})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.update != nil {
+				tt.update(t, e)
+			}
+
+			kr, err := newSigningTimeKeyRing(context.Background(), f, openpgp.EntityList{e, tsa, other}, trusted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v, err := integrity.NewVerifier(f, integrity.OptVerifyWithKeyRing(kr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := v.Verify(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			// The keys of the underlying keyring must be left untouched.
+			if tt.lifetime != 0 {
+				if sig, _ := e.PrimarySelfSignature(); sig.KeyLifetimeSecs == nil {
+					t.Errorf("original key lifetime was modified")
+				}
+			}
+		})
+	}
+}
+
+func TestSigningEntity(t *testing.T) {
+	e, err := openpgp.NewEntity("test", "", "test@test.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se, err := signingEntity(e, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if se.PrivateKey != e.PrivateKey {
+		t.Errorf("unexpected signing key without subkey")
+	}
+
+	if err := e.AddSigningSubkey(&packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}); err != nil {
+		t.Fatal(err)
+	}
+	se, err = signingEntity(e, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if se.PrivateKey != e.Subkeys[len(e.Subkeys)-1].PrivateKey {
+		t.Errorf("signing subkey not selected")
+	}
+	if se.PrimaryKey != e.PrimaryKey {
+		t.Errorf("unexpected primary key")
+	}
+
+	if err := e.RevokeKey(packet.KeySuperseded, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signingEntity(e, time.Now()); err == nil {
+		t.Errorf("unexpected success with revoked key")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	errNotEnoughSigners     = errors.New("image not signed by enough entities")
+	errNotCountersigned     = errors.New("signature not countersigned by another entity")
+	errCountersignOrder     = errors.New("required entities not found in countersignature order")
+	errInvalidSignersPolicy = errors.New("number of required signers must be positive")
+)
+
+// policy describes the requirements on the valid signatures of an image,
+// in addition to their cryptographic validity.
+type policy struct {
+	signers      int
+	fingerprints []string
+	countersign  bool
+}
+
+// chain records the valid signatures and countersignatures of an image.
+type chain struct {
+	// signers holds the fingerprints of the entities that produced each
+	// valid signature, indexed by signature ID.
+	signers map[uint32][]string
+	// links holds the signature ID covered by each valid
+	// countersignature, indexed by countersignature ID.
+	links map[uint32]uint32
+}
+
+func newChain() *chain {
+	return &chain{
+		signers: make(map[uint32][]string),
+		links:   make(map[uint32]uint32),
+	}
+}
+
+// signedBy returns true if signature id was produced by the entity with
+// fingerprint fp.
+func (c *chain) signedBy(id uint32, fp string) bool {
+	return slices.ContainsFunc(c.signers[id], func(s string) bool { return strings.EqualFold(s, fp) })
+}
+
+// countersignatures returns the IDs of the valid countersignatures of
+// signature id, sorted.
+func (c *chain) countersignatures(id uint32) []uint32 {
+	var ids []uint32
+	for cs, target := range c.links {
+		if target == id {
+			ids = append(ids, cs)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// roots returns the IDs of the valid signatures which are not
+// countersignatures, sorted.
+func (c *chain) roots() []uint32 {
+	var ids []uint32
+	for id := range c.signers {
+		if _, ok := c.links[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// follows returns true if signature id is signed by fps[0] and starts a
+// countersignature chain by the entities of fps, in this order.
+func (c *chain) follows(id uint32, fps []string) bool {
+	if !c.signedBy(id, fps[0]) {
+		return false
+	}
+	if len(fps) == 1 {
+		return true
+	}
+	for _, cs := range c.countersignatures(id) {
+		if c.follows(cs, fps[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if the signatures recorded in c don't meet the
+// requirements of p.
+func (p policy) check(c *chain) error {
+	var signers []string
+	for _, fps := range c.signers {
+		for _, fp := range fps {
+			fp = strings.ToLower(fp)
+			if !slices.Contains(signers, fp) {
+				signers = append(signers, fp)
+			}
+		}
+	}
+
+	if len(signers) < p.signers {
+		return fmt.Errorf("%w: %d signer(s) found, %d required", errNotEnoughSigners, len(signers), p.signers)
+	}
+
+	for _, fp := range p.fingerprints {
+		if !slices.Contains(signers, strings.ToLower(fp)) {
+			return fmt.Errorf("%w: no valid signature from %s", errNotSignedByRequired, fp)
+		}
+	}
+
+	if !p.countersign {
+		return nil
+	}
+
+	for _, id := range c.roots() {
+		countersigned := slices.ContainsFunc(c.countersignatures(id), func(cs uint32) bool {
+			for _, fp := range c.signers[cs] {
+				if !c.signedBy(id, fp) {
+					return true
+				}
+			}
+			return false
+		})
+		if !countersigned {
+			return fmt.Errorf("%w (signature %d)", errNotCountersigned, id)
+		}
+	}
+
+	// With several required entities, signatures from the first one must
+	// be countersigned by the second one, and so on.
+	if len(p.fingerprints) > 1 {
+		found := false
+		for _, id := range c.roots() {
+			if !c.signedBy(id, p.fingerprints[0]) {
+				continue
+			}
+			if !c.follows(id, p.fingerprints) {
+				return fmt.Errorf("%w (signature %d)", errCountersignOrder, id)
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("%w: no signature from %s", errCountersignOrder, p.fingerprints[0])
+		}
+	}
+
+	return nil
+}
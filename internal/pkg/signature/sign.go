@@ -11,7 +11,10 @@ package signature
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/apptainer/apptainer/internal/pkg/sypgp"
 	"github.com/apptainer/sif/v2/pkg/integrity"
 	"github.com/apptainer/sif/v2/pkg/sif"
@@ -20,6 +23,12 @@ import (
 
 type signer struct {
 	opts []integrity.SignerOpt
+
+	// key material and signature IDs used for countersignatures.
+	ss             []signature.Signer
+	e              *openpgp.Entity
+	countersign    bool
+	countersignIDs []uint32
 }
 
 // SignOpt are used to configure s.
@@ -29,6 +38,7 @@ type SignOpt func(s *signer) error
 func OptSignWithSigner(ss signature.Signer) SignOpt {
 	return func(s *signer) error {
 		s.opts = append(s.opts, integrity.OptSignWithSigner(ss))
+		s.ss = append(s.ss, ss)
 		return nil
 	}
 }
@@ -42,12 +52,45 @@ func OptSignEntitySelector(f sypgp.EntitySelector) SignOpt {
 			return err
 		}
 
+		e, err = signingEntity(e, time.Now())
+		if err != nil {
+			return err
+		}
+
 		s.opts = append(s.opts, integrity.OptSignWithEntity(e))
+		s.e = e
 
 		return nil
 	}
 }
 
+// signingEntity returns an entity signing with the newest valid signing subkey of e, if any, or
+// with the primary key of e otherwise. An error is returned if e is revoked or expired at now.
+func signingEntity(e *openpgp.Entity, now time.Time) (*openpgp.Entity, error) {
+	k, ok := e.SigningKey(now)
+	if !ok {
+		if e.Revoked(now) {
+			return nil, fmt.Errorf("key %X has been revoked", e.PrimaryKey.Fingerprint)
+		}
+		if sig, _ := e.PrimarySelfSignature(); sig != nil && e.PrimaryKey.KeyExpired(sig, now) {
+			return nil, fmt.Errorf("key %X has expired", e.PrimaryKey.Fingerprint)
+		}
+		return e, nil
+	}
+
+	if k.PublicKey == e.PrimaryKey {
+		return e, nil
+	}
+	if k.PrivateKey == nil || k.PrivateKey.Encrypted {
+		return nil, fmt.Errorf("private part of signing subkey %016X is not available", k.PublicKey.KeyId)
+	}
+
+	// The signature is made with the subkey, but still identifies the primary key fingerprint.
+	se := *e
+	se.PrivateKey = k.PrivateKey
+	return &se, nil
+}
+
 // OptSignGroup specifies that a signature be applied to cover all objects in the group with the
 // specified groupID. This may be called multiple times to add multiple group signatures.
 func OptSignGroup(groupID uint32) SignOpt {
@@ -67,11 +110,23 @@ func OptSignObjects(ids ...uint32) SignOpt {
 	}
 }
 
+// OptSignCountersign specifies that countersignatures be applied over the signature objects with
+// the specified ids, rather than signatures over data objects. If no id is specified, every
+// signature not already countersigned is countersigned, extending each signature chain.
+func OptSignCountersign(ids ...uint32) SignOpt {
+	return func(s *signer) error {
+		s.countersign = true
+		s.countersignIDs = append(s.countersignIDs, ids...)
+		return nil
+	}
+}
+
 // Sign adds one or more digital signatures to the SIF image found at path, according to opts. Key
 // material must be provided via OptSignEntitySelector.
 //
 // By default, one digital signature is added per object group in f. To override this behavior,
-// consider using OptSignGroup and/or OptSignObject.
+// consider using OptSignGroup and/or OptSignObject. To countersign existing signatures, use
+// OptSignCountersign.
 func Sign(ctx context.Context, path string, opts ...SignOpt) error {
 	// Apply options to signer.
 	s := signer{
@@ -92,6 +147,11 @@ func Sign(ctx context.Context, path string, opts ...SignOpt) error {
 	}
 	defer f.UnloadContainer()
 
+	// Apply countersignature(s).
+	if s.countersign {
+		return countersign(ctx, f, s.countersignIDs, s.ss, s.e, nil)
+	}
+
 	// Apply signature(s).
 	is, err := integrity.NewSigner(f, s.opts...)
 	if err != nil {
@@ -35,6 +35,10 @@ var errNotSignedByRequired = errors.New("image not signed by required entities")
 
 type VerifyCallback func(*sif.FileImage, integrity.VerifyResult) bool
 
+// CountersignCallback is called after a countersignature is verified. If it returns true, a
+// verification error reported by the result is ignored.
+type CountersignCallback func(*sif.FileImage, CountersignResult) bool
+
 type verifier struct {
 	certs         []*x509.Certificate
 	intermediates *x509.CertPool
@@ -48,6 +52,8 @@ type verifier struct {
 	all           bool
 	legacy        bool
 	cb            VerifyCallback
+	csCb          CountersignCallback
+	policy        policy
 }
 
 // VerifyOpt are used to configure v.
@@ -151,6 +157,47 @@ func OptVerifyCallback(cb VerifyCallback) VerifyOpt {
 	}
 }
 
+// OptVerifyCountersignCallback registers cb as the countersignature verification callback.
+func OptVerifyCountersignCallback(cb CountersignCallback) VerifyOpt {
+	return func(v *verifier) error {
+		v.csCb = cb
+		return nil
+	}
+}
+
+// OptVerifyRequireSigners requires valid signatures or countersignatures from at least n distinct
+// entities.
+func OptVerifyRequireSigners(n int) VerifyOpt {
+	return func(v *verifier) error {
+		if n <= 0 {
+			return errInvalidSignersPolicy
+		}
+		v.policy.signers = n
+		return nil
+	}
+}
+
+// OptVerifyRequireFingerprint requires a valid signature or countersignature from the entity with
+// fingerprint fp. This may be called multiple times to require more than one entity. See
+// Fingerprints for the fingerprint of non-PGP keys.
+func OptVerifyRequireFingerprint(fp string) VerifyOpt {
+	return func(v *verifier) error {
+		v.policy.fingerprints = append(v.policy.fingerprints, fp)
+		return nil
+	}
+}
+
+// OptVerifyRequireCountersign requires each valid signature to be countersigned by another entity.
+// When combined with more than one OptVerifyRequireFingerprint, signatures by the first required
+// entity must also be countersigned by the second one, whose countersignatures must be
+// countersigned by the third one, and so on.
+func OptVerifyRequireCountersign() VerifyOpt {
+	return func(v *verifier) error {
+		v.policy.countersign = true
+		return nil
+	}
+}
+
 // newVerifier constructs a new verifier based on opts.
 func newVerifier(opts []VerifyOpt) (verifier, error) {
 	v := verifier{}
@@ -177,30 +224,34 @@ func verifyCertificate(c *x509.Certificate, intermediates, roots *x509.CertPool)
 	return c.Verify(opts)
 }
 
-// getOpts returns integrity.VerifierOpt necessary to validate f.
-func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.VerifierOpt, error) {
-	iopts := []integrity.VerifierOpt{
-		integrity.OptVerifyWithContext(ctx),
-	}
+// keyMaterial holds the key material used to verify signatures.
+type keyMaterial struct {
+	svs []signature.Verifier
+	kr  openpgp.KeyRing
+}
+
+// getKeyMaterial returns the key material specified by v.
+func (v verifier) getKeyMaterial(ctx context.Context) (keyMaterial, error) {
+	var km keyMaterial
 
 	// Add key material from certificate(s).
 	for _, c := range v.certs {
 		// verify that the leaf certificate is not tampered and that is adequate for signing purposes.
 		chain, err := verifyCertificate(c, v.intermediates, v.roots)
 		if err != nil {
-			return nil, err
+			return keyMaterial{}, err
 		}
 
 		// Verify that the certificate is issued by a trustworthy CA (i.e the certificate chain is not revoked or expired).
 		if v.ocsp {
 			if len(chain) != 1 {
-				return nil, fmt.Errorf("unhandled OCSP condition, chain length %d != 1", len(chain))
+				return keyMaterial{}, fmt.Errorf("unhandled OCSP condition, chain length %d != 1", len(chain))
 			}
 
 			ocspErr := OCSPVerify(chain[0]...)
 			if ocspErr != nil {
 				// TODO: We need to decide whether this should be strict or permissive.
-				return nil, ocspErr
+				return keyMaterial{}, ocspErr
 			}
 
 			sylog.Debugf("OCSP validation has passed")
@@ -209,16 +260,14 @@ func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.Ve
 		// verify the signature by using the certificate.
 		sv, err := signature.LoadVerifier(c.PublicKey, crypto.SHA256)
 		if err != nil {
-			return nil, err
+			return keyMaterial{}, err
 		}
 
-		iopts = append(iopts, integrity.OptVerifyWithVerifier(sv))
+		km.svs = append(km.svs, sv)
 	}
 
 	// Add explicitly provided key material source(s).
-	for _, sv := range v.svs {
-		iopts = append(iopts, integrity.OptVerifyWithVerifier(sv))
-	}
+	km.svs = append(km.svs, v.svs...)
 
 	// Add PGP key material, if applicable.
 	if v.pgp {
@@ -226,13 +275,13 @@ func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.Ve
 		if v.pgpOpts != nil {
 			hkr, err := sypgp.NewHybridKeyRing(ctx, v.pgpOpts...)
 			if err != nil {
-				return nil, err
+				return keyMaterial{}, err
 			}
 			kr = hkr
 		} else {
 			pkr, err := sypgp.PublicKeyRing()
 			if err != nil {
-				return nil, err
+				return keyMaterial{}, err
 			}
 			kr = pkr
 		}
@@ -241,11 +290,54 @@ func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.Ve
 		global := sypgp.NewHandle(buildcfg.APPTAINER_CONFDIR, sypgp.GlobalHandleOpt())
 		gkr, err := global.LoadPubKeyring()
 		if err != nil {
-			return nil, err
+			return keyMaterial{}, err
+		}
+		km.kr = sypgp.NewMultiKeyRing(gkr, kr)
+	}
+
+	return km, nil
+}
+
+// getImageKeyMaterial returns the key material specified by v, with PGP keys evaluated at the
+// time the signatures of f were countersigned by entities required by the policy of v.
+func (v verifier) getImageKeyMaterial(ctx context.Context, f *sif.FileImage) (keyMaterial, error) {
+	km, err := v.getKeyMaterial(ctx)
+	if err != nil {
+		return keyMaterial{}, err
+	}
+
+	if km.kr != nil {
+		kr, err := newSigningTimeKeyRing(ctx, f, km.kr, v.policy.fingerprints)
+		if err != nil {
+			return keyMaterial{}, err
 		}
-		kr = sypgp.NewMultiKeyRing(gkr, kr)
+		km.kr = kr
+	}
+
+	return km, nil
+}
 
-		iopts = append(iopts, integrity.OptVerifyWithKeyRing(kr))
+// getOpts returns integrity.VerifierOpt necessary to validate f.
+func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.VerifierOpt, error) {
+	km, err := v.getImageKeyMaterial(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return v.getVerifierOpts(ctx, f, km)
+}
+
+// getVerifierOpts returns integrity.VerifierOpt necessary to validate f with key material km.
+func (v verifier) getVerifierOpts(ctx context.Context, f *sif.FileImage, km keyMaterial) ([]integrity.VerifierOpt, error) {
+	iopts := []integrity.VerifierOpt{
+		integrity.OptVerifyWithContext(ctx),
+	}
+
+	for _, sv := range km.svs {
+		iopts = append(iopts, integrity.OptVerifyWithVerifier(sv))
+	}
+
+	if km.kr != nil {
+		iopts = append(iopts, integrity.OptVerifyWithKeyRing(km.kr))
 	}
 
 	// Add group IDs, if applicable.
@@ -295,10 +387,16 @@ func (v verifier) getOpts(ctx context.Context, f *sif.FileImage) ([]integrity.Ve
 //
 // To use raw key material, use OptVerifyWithVerifier.
 //
-// To use PGP key material, use OptVerifyWithPGP.
+// To use PGP key material, use OptVerifyWithPGP. PGP keys are checked for revocation and expiration
+// at the time each signature was countersigned, or at the time of verification for signatures not
+// countersigned.
 //
 // By default, non-legacy signatures for all object groups are verified. To override the default
 // behavior, consider using OptVerifyGroup, OptVerifyObject, OptVerifyAll, and/or OptVerifyLegacy.
+//
+// Countersignatures of the verified signatures are verified as well. To require signatures from
+// given entities, or countersignatures, consider using OptVerifyRequireSigners,
+// OptVerifyRequireFingerprint, and/or OptVerifyRequireCountersign.
 func Verify(ctx context.Context, path string, opts ...VerifyOpt) error {
 	v, err := newVerifier(opts)
 	if err != nil {
@@ -312,8 +410,25 @@ func Verify(ctx context.Context, path string, opts ...VerifyOpt) error {
 	}
 	defer f.UnloadContainer()
 
-	// Get options to validate f.
-	vopts, err := v.getOpts(ctx, f)
+	// Get key material and options to validate f.
+	km, err := v.getImageKeyMaterial(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	// Record valid signatures, to check their countersignatures and the policy.
+	c := newChain()
+	checked := make(map[uint32]bool)
+	cb := v.cb
+	v.cb = func(f *sif.FileImage, r integrity.VerifyResult) bool {
+		checked[r.Signature().ID()] = true
+		if r.Error() == nil {
+			c.signers[r.Signature().ID()] = Fingerprints(r)
+		}
+		return cb != nil && cb(f, r)
+	}
+
+	vopts, err := v.getVerifierOpts(ctx, f, km)
 	if err != nil {
 		return err
 	}
@@ -323,8 +438,50 @@ func Verify(ctx context.Context, path string, opts ...VerifyOpt) error {
 	if err != nil {
 		return err
 	}
+	if err := iv.Verify(); err != nil {
+		return err
+	}
+
+	// Verify countersignature(s) of the signatures verified above.
+	sigs, err := getCountersignatures(f)
+	if err != nil {
+		return err
+	}
+	for _, sig := range sigs {
+		id, _ := sig.LinkedID()
+		if !checked[id] {
+			continue
+		}
+		checked[sig.ID()] = true
+
+		target, err := f.GetDescriptor(sif.WithID(id))
+		if err != nil {
+			return err
+		}
+
+		r := CountersignResult{sig: sig, target: target}
+		err = verifyCountersignature(ctx, km, sig, target, &r)
+		if err == nil {
+			if _, ok := c.signers[id]; ok {
+				c.signers[sig.ID()] = Fingerprints(r)
+				c.links[sig.ID()] = id
+			}
+		}
+
+		// Call countersignature callback, if applicable.
+		if v.csCb != nil {
+			r.err = err
+			if ignoreError := v.csCb(f, r); ignoreError {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("countersignature %d: %w", sig.ID(), err)
+		}
+	}
 
-	return iv.Verify()
+	return v.policy.check(c)
 }
 
 // VerifyFingerprints verifies an image and checks it was signed by *all* of the provided
@@ -0,0 +1,255 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/apptainer/apptainer/pkg/sylog"
+)
+
+var (
+	errKeyNotFound     = errors.New("no key matching given fingerprint found")
+	errSubkeyNotFound  = errors.New("no signing subkey matching given key ID found")
+	errInvalidLifetime = errors.New("invalid key lifetime")
+)
+
+// revocationReasons maps the revocation reasons accepted on the command line
+// to their RFC4880 codes.
+var revocationReasons = map[string]packet.ReasonForRevocation{
+	"unspecified": packet.NoReason,
+	"superseded":  packet.KeySuperseded,
+	"compromised": packet.KeyCompromised,
+	"retired":     packet.KeyRetired,
+}
+
+// ParseRevocationReason returns the revocation reason code corresponding
+// to s, one of unspecified, superseded, compromised or retired.
+//
+// Signatures made by a key revoked as compromised are never valid, signatures
+// made before any other revocation remain valid.
+func ParseRevocationReason(s string) (packet.ReasonForRevocation, error) {
+	r, ok := revocationReasons[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason %q", s)
+	}
+	return r, nil
+}
+
+// ParseLifetime parses a key lifetime such as 30d, 6m or 1y. Durations
+// understood by time.ParseDuration are accepted too. A zero lifetime, or
+// "never", means the key doesn't expire.
+func ParseLifetime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "never" || s == "0" {
+		return 0, nil
+	}
+
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'm': 30 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	if len(s) > 1 {
+		if unit, ok := units[s[len(s)-1]]; ok {
+			n, err := strconv.ParseUint(s[:len(s)-1], 10, 32)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("%w: %q", errInvalidLifetime, s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q", errInvalidLifetime, s)
+	}
+	return d, nil
+}
+
+// lifetimeSecs returns the number of seconds between the creation time of
+// pk and now plus d, as stored in self-signatures. A zero d gives a zero
+// lifetime, meaning the key doesn't expire.
+func lifetimeSecs(pk *packet.PublicKey, now time.Time, d time.Duration) (uint32, error) {
+	if d == 0 {
+		return 0, nil
+	}
+	secs := now.Add(d).Sub(pk.CreationTime).Seconds()
+	if secs > math.MaxUint32 {
+		return 0, fmt.Errorf("%w: expiration too far in the future", errInvalidLifetime)
+	}
+	return uint32(secs), nil
+}
+
+// ExpirationTime returns the time the key or subkey pk expires according to
+// self-signature sig, and false if it doesn't expire.
+func ExpirationTime(pk *packet.PublicKey, sig *packet.Signature) (time.Time, bool) {
+	if sig == nil || sig.KeyLifetimeSecs == nil || *sig.KeyLifetimeSecs == 0 {
+		return time.Time{}, false
+	}
+	return pk.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second), true
+}
+
+// PassphraseFunc returns the passphrase protecting the private keys of e.
+type PassphraseFunc func(e *openpgp.Entity) ([]byte, error)
+
+// updatePrivateKey applies update to the decrypted private key matching
+// fingerprint, then stores it back in the private keyring, encrypted with the
+// same passphrase, and refreshes its public part in the public keyring.
+func (keyring *Handle) updatePrivateKey(fingerprint string, pass PassphraseFunc, update func(*openpgp.Entity, *packet.Config) error) (*openpgp.Entity, error) {
+	if keyring.global {
+		return nil, fmt.Errorf("operation not supported for global keyring")
+	}
+
+	el, err := keyring.LoadPrivKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list local secret keyring: %v", err)
+	}
+
+	fingerprint = strings.ToUpper(strings.TrimPrefix(fingerprint, "0x"))
+	e := findKeyByFingerprint(el, fingerprint)
+	if e == nil {
+		return nil, errKeyNotFound
+	}
+
+	var passphrase []byte
+	if e.PrivateKey.Encrypted {
+		if pass == nil {
+			return nil, fmt.Errorf("private key is encrypted and no passphrase was provided")
+		}
+		if passphrase, err = pass(e); err != nil {
+			return nil, err
+		}
+		if err := e.DecryptPrivateKeys(passphrase); err != nil {
+			return nil, fmt.Errorf("unable to decrypt private key: %v", err)
+		}
+	}
+
+	config := &packet.Config{DefaultHash: crypto.SHA384}
+	if err := update(e, config); err != nil {
+		return nil, err
+	}
+
+	if passphrase != nil {
+		if err := e.EncryptPrivateKeys(passphrase, nil); err != nil {
+			return nil, fmt.Errorf("unable to encrypt private key: %v", err)
+		}
+	}
+
+	sylog.Verbosef("Updating local secret keyring: %v", keyring.SecretPath())
+	if err := keyring.storePrivKeyring(el); err != nil {
+		return nil, err
+	}
+
+	pl, err := keyring.LoadPubKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load local keyring: %v", err)
+	}
+	if newList := removeKey(pl, fingerprint); newList != nil {
+		pl = newList
+	}
+	pl = append(pl, e)
+
+	sylog.Verbosef("Updating local keyring: %v", keyring.PublicPath())
+	if err := keyring.storePubKeyring(pl); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// RevokeKey adds a revocation signature with the given reason to the key
+// matching fingerprint, in both the private and public keyrings. The
+// revoked public key is returned, so that it can be pushed to a key server.
+func (keyring *Handle) RevokeKey(fingerprint string, reason packet.ReasonForRevocation, pass PassphraseFunc) (*openpgp.Entity, error) {
+	return keyring.updatePrivateKey(fingerprint, pass, func(e *openpgp.Entity, config *packet.Config) error {
+		return e.RevokeKey(reason, "", config)
+	})
+}
+
+// SetKeyExpiry updates the key matching fingerprint to expire d from now.
+// A zero d removes the expiration time.
+func (keyring *Handle) SetKeyExpiry(fingerprint string, d time.Duration, pass PassphraseFunc) (*openpgp.Entity, error) {
+	return keyring.updatePrivateKey(fingerprint, pass, func(e *openpgp.Entity, config *packet.Config) error {
+		now := config.Now()
+		secs, err := lifetimeSecs(e.PrimaryKey, now, d)
+		if err != nil {
+			return err
+		}
+
+		for _, ident := range e.Identities {
+			sig := ident.SelfSignature
+			if sig == nil {
+				continue
+			}
+			sig.CreationTime = now
+			sig.KeyLifetimeSecs = nil
+			if secs != 0 {
+				sig.KeyLifetimeSecs = &secs
+			}
+			if err := sig.SignUserId(ident.UserId.Id, e.PrimaryKey, e.PrivateKey, config); err != nil {
+				return fmt.Errorf("while signing user ID %q: %v", ident.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddSigningSubkey adds a new signing subkey, expiring d from now, to the
+// key matching fingerprint. A zero d creates a subkey without expiration.
+// Subkeys use the algorithm and the length of the primary key if it is an
+// RSA key, and are 4096 bits RSA keys otherwise.
+func (keyring *Handle) AddSigningSubkey(fingerprint string, d time.Duration, pass PassphraseFunc) (*openpgp.Entity, error) {
+	return keyring.updatePrivateKey(fingerprint, pass, func(e *openpgp.Entity, config *packet.Config) error {
+		config.Algorithm = packet.PubKeyAlgoRSA
+		config.RSABits = 4096
+		if bits, err := e.PrimaryKey.BitLength(); err == nil && e.PrimaryKey.PubKeyAlgo == packet.PubKeyAlgoRSA {
+			config.RSABits = int(bits)
+		}
+
+		// The subkey lifetime is relative to its creation time, which is now.
+		if d > 0 {
+			if d.Seconds() > math.MaxUint32 {
+				return fmt.Errorf("%w: expiration too far in the future", errInvalidLifetime)
+			}
+			config.KeyLifetimeSecs = uint32(d.Seconds())
+		}
+
+		return e.AddSigningSubkey(config)
+	})
+}
+
+// RevokeSubkey adds a revocation signature with the given reason to the
+// signing subkey identified by keyID, an hexadecimal key ID or fingerprint,
+// of the key matching fingerprint.
+func (keyring *Handle) RevokeSubkey(fingerprint, keyID string, reason packet.ReasonForRevocation, pass PassphraseFunc) (*openpgp.Entity, error) {
+	keyID = strings.ToUpper(strings.TrimPrefix(keyID, "0x"))
+
+	return keyring.updatePrivateKey(fingerprint, pass, func(e *openpgp.Entity, config *packet.Config) error {
+		for i := range e.Subkeys {
+			sk := &e.Subkeys[i]
+			if !sk.Sig.FlagSign {
+				continue
+			}
+			if keyID == fmt.Sprintf("%016X", sk.PublicKey.KeyId) || keyID == fmt.Sprintf("%X", sk.PublicKey.Fingerprint) {
+				return e.RevokeSubkey(sk, reason, "", config)
+			}
+		}
+		return errSubkeyNotFound
+	})
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func TestParseLifetime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "never", want: 0},
+		{in: "0", want: 0},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: "2w", want: 14 * 24 * time.Hour},
+		{in: "6m", want: 180 * 24 * time.Hour},
+		{in: "1Y", want: 365 * 24 * time.Hour},
+		{in: "36h", want: 36 * time.Hour},
+		{in: "0d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "1x", wantErr: true},
+		{in: "y", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseLifetime(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if d != tt.want {
+				t.Errorf("got %s, want %s", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRevocationReason(t *testing.T) {
+	if r, err := ParseRevocationReason("Compromised"); err != nil || r != packet.KeyCompromised {
+		t.Errorf("unexpected result: %v, %v", r, err)
+	}
+	if _, err := ParseRevocationReason("lost"); err == nil {
+		t.Errorf("unexpected success with unknown reason")
+	}
+}
+
+// reloadKey returns the private and public keys matching e from keyring.
+func reloadKey(t *testing.T, keyring *Handle, e *openpgp.Entity) (*openpgp.Entity, *openpgp.Entity) {
+	t.Helper()
+
+	fp := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+
+	el, err := keyring.LoadPrivKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv := findKeyByFingerprint(el, fp)
+	if priv == nil || priv.PrivateKey == nil {
+		t.Fatalf("private key not found")
+	}
+
+	el, err = keyring.LoadPubKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := findKeyByFingerprint(el, fp)
+	if pub == nil {
+		t.Fatalf("public key not found")
+	}
+	if len(el) != 1 {
+		t.Errorf("unexpected number of public keys: %d", len(el))
+	}
+
+	return priv, pub
+}
+
+func TestKeyLifecycle(t *testing.T) {
+	keyring := NewHandle(t.TempDir())
+
+	e, err := keyring.GenKeyPair(GenKeyPairOptions{
+		Name:      "test",
+		Email:     "test@test.com",
+		KeyLength: 2048,
+		Password:  "1234",
+	})
+	if err != nil {
+		t.Fatalf("while generating key pair: %s", err)
+	}
+	fp := fmt.Sprintf("%x", e.PrimaryKey.Fingerprint)
+
+	pass := func(*openpgp.Entity) ([]byte, error) { return []byte("1234"), nil }
+	badPass := func(*openpgp.Entity) ([]byte, error) { return []byte("4321"), nil }
+
+	if _, err := keyring.SetKeyExpiry(fp, time.Hour, badPass); err == nil {
+		t.Errorf("unexpected success with bad passphrase")
+	}
+	if _, err := keyring.SetKeyExpiry("0xDEADBEEF", time.Hour, pass); !errors.Is(err, errKeyNotFound) {
+		t.Errorf("unexpected error with unknown key: %v", err)
+	}
+
+	t.Run("Expire", func(t *testing.T) {
+		if _, err := keyring.SetKeyExpiry(fp, 24*time.Hour, pass); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		priv, pub := reloadKey(t, keyring, e)
+		if !priv.PrivateKey.Encrypted {
+			t.Errorf("private key is not encrypted anymore")
+		}
+		for _, k := range []*openpgp.Entity{priv, pub} {
+			sig, _ := k.PrimarySelfSignature()
+			exp, ok := ExpirationTime(k.PrimaryKey, sig)
+			if !ok {
+				t.Fatalf("key doesn't expire")
+			}
+			if d := time.Until(exp); d < 23*time.Hour || d > 25*time.Hour {
+				t.Errorf("unexpected expiration time %s", exp)
+			}
+		}
+
+		if _, err := keyring.SetKeyExpiry(fp, 0, pass); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, pub = reloadKey(t, keyring, e)
+		sig, _ := pub.PrimarySelfSignature()
+		if _, ok := ExpirationTime(pub.PrimaryKey, sig); ok {
+			t.Errorf("key still expires")
+		}
+	})
+
+	var subkeyID uint64
+
+	t.Run("AddSubkey", func(t *testing.T) {
+		if _, err := keyring.AddSigningSubkey(fp, 0, pass); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		priv, pub := reloadKey(t, keyring, e)
+		if err := priv.DecryptPrivateKeys([]byte("1234")); err != nil {
+			t.Fatalf("while decrypting private keys: %s", err)
+		}
+		k, ok := pub.SigningKey(time.Now())
+		if !ok || k.PublicKey == pub.PrimaryKey {
+			t.Fatalf("signing subkey not found")
+		}
+		subkeyID = k.PublicKey.KeyId
+	})
+
+	t.Run("RevokeSubkey", func(t *testing.T) {
+		if _, err := keyring.RevokeSubkey(fp, "0123456789ABCDEF", packet.KeyRetired, pass); !errors.Is(err, errSubkeyNotFound) {
+			t.Errorf("unexpected error with unknown subkey: %v", err)
+		}
+		id := fmt.Sprintf("%016x", subkeyID)
+		if _, err := keyring.RevokeSubkey(fp, id, packet.KeyRetired, pass); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, pub := reloadKey(t, keyring, e)
+		if k, ok := pub.SigningKey(time.Now()); !ok || k.PublicKey != pub.PrimaryKey {
+			t.Errorf("revoked subkey still used for signing")
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		if _, err := keyring.RevokeKey(fp, packet.KeyCompromised, pass); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, pub := reloadKey(t, keyring, e)
+		if !pub.Revoked(time.Now()) {
+			t.Errorf("key is not revoked")
+		}
+		if !pub.Revoked(e.PrimaryKey.CreationTime) {
+			t.Errorf("compromised key is not revoked at creation time")
+		}
+	})
+
+	global := NewHandle(t.TempDir(), GlobalHandleOpt())
+	if _, err := global.RevokeKey(fp, packet.KeyCompromised, pass); err == nil {
+		t.Errorf("unexpected success with global keyring")
+	}
+}
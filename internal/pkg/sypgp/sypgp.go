@@ -292,6 +292,26 @@ func printEntity(w io.Writer, index int, e *openpgp.Entity) {
 	fmt.Fprintf(tw, "\tFingerprint:\t%0X\n", e.PrimaryKey.Fingerprint)
 	bits, _ := e.PrimaryKey.BitLength()
 	fmt.Fprintf(tw, "\tLength (in bits):\t%d\n", bits)
+	if sig, _ := e.PrimarySelfSignature(); sig != nil {
+		if t, ok := ExpirationTime(e.PrimaryKey, sig); ok {
+			fmt.Fprintf(tw, "\tExpiration time:\t%s\n", t)
+		}
+	}
+	for _, r := range e.Revocations {
+		fmt.Fprintf(tw, "\tRevocation time:\t%s\n", r.CreationTime)
+	}
+	for _, sk := range e.Subkeys {
+		if !sk.Sig.FlagSign {
+			continue
+		}
+		status := ""
+		if len(sk.Revocations) > 0 {
+			status = " (revoked)"
+		} else if t, ok := ExpirationTime(sk.PublicKey, sk.Sig); ok {
+			status = fmt.Sprintf(" (expires %s)", t)
+		}
+		fmt.Fprintf(tw, "\tSigning subkey:\t%016X%s\n", sk.PublicKey.KeyId, status)
+	}
 	tw.Flush()
 	fmt.Fprintln(w)
 }
@@ -370,10 +390,8 @@ func (keyring *Handle) storePrivKeyring(keys openpgp.EntityList) error {
 	}
 	defer f.Close()
 
-	for _, k := range keys {
-		if err := k.Serialize(f); err != nil {
-			return fmt.Errorf("could not store private key: %s", err)
-		}
+	if err := storePrivKeys(f, keys); err != nil {
+		return fmt.Errorf("could not store private key: %s", err)
 	}
 
 	return nil
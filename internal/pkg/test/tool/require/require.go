@@ -266,6 +266,14 @@ func DMTCP(t *testing.T) {
 	}
 }
 
+// CRIU checks that the criu command is available
+func CRIU(t *testing.T) {
+	_, err := exec.LookPath("criu")
+	if err != nil {
+		t.Skipf("criu not found on PATH: %v", err)
+	}
+}
+
 // Filesystem checks that the current test could use the
 // corresponding filesystem, if the filesystem is not
 // listed in /proc/filesystems, the current test is skipped
@@ -12,16 +12,24 @@ package bin
 
 import (
 	"fmt"
+	"strings"
 )
 
 // FindBin returns the path to the named binary, or an error if it is not found.
 // We don't list any default because we want a deliberate decision about whether
 // to use the SuidBinaryPath which is more restrictive when in the suid flow.
 func FindBin(name string) (path string, err error) {
+	// QEMU user emulation interpreters, named after the emulated
+	// architecture (e.g. qemu-aarch64-static)
+	if strings.HasPrefix(name, "qemu-") && !strings.ContainsRune(name, '/') {
+		return findOnPath(name, false)
+	}
+
 	switch name {
 	// Basic system executables that we assume are always on PATH
 	// We will search for these only in default PATH when in the suid flow
-	case "cp",
+	case "cat",
+		"cp",
 		"dd",
 		"mkfs.ext3",
 		"mknod",
@@ -48,7 +56,9 @@ func FindBin(name string) (path string, err error) {
 		return findOnPath("ldconfig", false)
 	// All other executables
 	// We will always search the user's PATH first for these
-	case "curl",
+	case "apk",
+		"apk.static",
+		"curl",
 		"debootstrap",
 		"dnf",
 		"fakeroot",
@@ -56,6 +66,7 @@ func FindBin(name string) (path string, err error) {
 		"fuse-overlayfs",
 		"fuse2fs",
 		"go",
+		"mmdebstrap",
 		"mksquashfs",
 		"newgidmap",
 		"newuidmap",
@@ -0,0 +1,109 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/apptainer/apptainer/internal/pkg/util/bin"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// ErrIDMapUnsupported is returned when the kernel or the filesystem
+// doesn't support ID-mapped mounts.
+var ErrIDMapUnsupported = errors.New("ID-mapped mounts are not supported")
+
+// usernsFd returns a file descriptor of a new user namespace with the
+// ID mappings uidMap and gidMap. The user namespace is created by a cat
+// child process blocked on reading an empty pipe, it exits as soon as
+// its user namespace file has been opened and the pipe is closed.
+func usernsFd(uidMap, gidMap specs.LinuxIDMapping) (*os.File, error) {
+	cat, err := bin.FindBin("cat")
+	if err != nil {
+		return nil, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("while creating pipe: %w", err)
+	}
+
+	pid, err := syscall.ForkExec(cat, []string{cat}, &syscall.ProcAttr{
+		Files: []uintptr{r.Fd(), uintptr(syscall.Stderr), uintptr(syscall.Stderr)},
+		Sys: &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUSER,
+			UidMappings: []syscall.SysProcIDMap{
+				{ContainerID: int(uidMap.ContainerID), HostID: int(uidMap.HostID), Size: int(uidMap.Size)},
+			},
+			GidMappings: []syscall.SysProcIDMap{
+				{ContainerID: int(gidMap.ContainerID), HostID: int(gidMap.HostID), Size: int(gidMap.Size)},
+			},
+			Pdeathsig: syscall.SIGKILL,
+		},
+	})
+	r.Close()
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("while creating user namespace: %w", err)
+	}
+	defer func() {
+		// closing the pipe ends cat, it's killed in case the pipe
+		// was inherited by another process in the meantime
+		w.Close()
+		_ = unix.Kill(pid, unix.SIGKILL)
+		_, _ = unix.Wait4(pid, nil, 0, nil)
+	}()
+
+	return os.Open(fmt.Sprintf("/proc/%d/ns/user", pid))
+}
+
+// OpenIDMapTree returns a file descriptor of a detached recursive bind
+// mount of source with an ID mapping, it can be attached with move_mount.
+// Files owned by the container IDs of uidMap and gidMap on disk appear
+// as owned by their host IDs and conversely, other IDs are not mapped.
+// ErrIDMapUnsupported is returned if the kernel or the source filesystem
+// doesn't support ID-mapped mounts.
+func OpenIDMapTree(source string, uidMap, gidMap specs.LinuxIDMapping) (int, error) {
+	userns, err := usernsFd(uidMap, gidMap)
+	if err != nil {
+		return -1, err
+	}
+	defer userns.Close()
+
+	fd, err := unix.OpenTree(unix.AT_FDCWD, source, unix.OPEN_TREE_CLONE|unix.OPEN_TREE_CLOEXEC|unix.AT_RECURSIVE)
+	if errors.Is(err, unix.ENOSYS) {
+		return -1, fmt.Errorf("%w by the kernel", ErrIDMapUnsupported)
+	} else if err != nil {
+		return -1, fmt.Errorf("while cloning %s: %w", source, err)
+	}
+
+	attr := &unix.MountAttr{
+		Attr_set:  unix.MOUNT_ATTR_IDMAP,
+		Userns_fd: uint64(userns.Fd()),
+	}
+	err = unix.MountSetattr(fd, "", unix.AT_EMPTY_PATH|unix.AT_RECURSIVE, attr)
+	switch {
+	case err == nil:
+		return fd, nil
+	case errors.Is(err, unix.ENOSYS):
+		err = fmt.Errorf("%w by the kernel", ErrIDMapUnsupported)
+	case errors.Is(err, unix.EINVAL):
+		err = fmt.Errorf("%w by the filesystem of %s", ErrIDMapUnsupported, source)
+	case errors.Is(err, unix.EPERM):
+		err = fmt.Errorf("%w without privileges over the filesystem of %s", ErrIDMapUnsupported, source)
+	default:
+		err = fmt.Errorf("while setting ID mapping on %s: %w", source, err)
+	}
+	unix.Close(fd)
+	return -1, err
+}
@@ -156,6 +156,7 @@ var authorizedImage = map[string]fsContext{
 	"ext3":      {true},
 	"squashfs":  {true},
 	"gocryptfs": {true},
+	"lazy":      {true},
 }
 
 var authorizedFS = map[string]fsContext{
@@ -172,7 +173,7 @@ var authorizedFS = map[string]fsContext{
 	"selinuxfs": {false},
 }
 
-var internalOptions = []string{"loop", "offset", "sizelimit", "key", "skip-on-error"}
+var internalOptions = []string{"loop", "offset", "sizelimit", "key", "skip-on-error", "idmap"}
 
 // Point describes a mount point.
 type Point struct {
@@ -371,6 +372,33 @@ func SkipOnError(options []string) bool {
 	return false
 }
 
+// IDMapOption returns the internal option of an ID-mapped bind mount
+// using the user and group ID mappings uidMap and gidMap.
+func IDMapOption(uidMap, gidMap specs.LinuxIDMapping) string {
+	return fmt.Sprintf("idmap=%d:%d:%d/%d:%d:%d",
+		uidMap.ContainerID, uidMap.HostID, uidMap.Size,
+		gidMap.ContainerID, gidMap.HostID, gidMap.Size,
+	)
+}
+
+// GetIDMap returns the user and group ID mappings of an ID-mapped
+// bind mount from the internal options.
+func GetIDMap(options []string) (uidMap, gidMap specs.LinuxIDMapping, err error) {
+	for _, opt := range options {
+		if strings.HasPrefix(opt, "idmap=") {
+			_, err = fmt.Sscanf(opt, "idmap=%d:%d:%d/%d:%d:%d",
+				&uidMap.ContainerID, &uidMap.HostID, &uidMap.Size,
+				&gidMap.ContainerID, &gidMap.HostID, &gidMap.Size,
+			)
+			if err != nil {
+				err = fmt.Errorf("bad idmap option %s: %s", opt, err)
+			}
+			return uidMap, gidMap, err
+		}
+	}
+	return uidMap, gidMap, fmt.Errorf("idmap option not found")
+}
+
 // HasRemountFlag checks if remount flag is set or not.
 func HasRemountFlag(flags uintptr) bool {
 	return flags&syscall.MS_REMOUNT != 0
@@ -577,7 +605,7 @@ func (p *Points) Import(points map[AuthorizedTag]PointList) error {
 			}
 			// check if this is a bind mount point
 			if flags&syscall.MS_BIND != 0 {
-				if err = p.AddBind(tag, point.Source, point.Destination, flags); err == nil {
+				if err = p.AddBind(tag, point.Source, point.Destination, flags, point.InternalOptions...); err == nil {
 					continue
 				}
 				return err
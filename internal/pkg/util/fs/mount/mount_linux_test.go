@@ -11,6 +11,7 @@ package mount
 
 import (
 	"fmt"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -281,6 +282,32 @@ func TestBind(t *testing.T) {
 	if !hasBind {
 		t.Errorf("option rbind not applied for /mnt")
 	}
+	points.RemoveAll()
+
+	uidMap := specs.LinuxIDMapping{ContainerID: 1000, HostID: 100000, Size: 1}
+	gidMap := specs.LinuxIDMapping{ContainerID: 1000, HostID: 100100, Size: 1}
+	if err := points.AddBind(UserbindsTag, "/", "/mnt", syscall.MS_BIND, IDMapOption(uidMap, gidMap)); err != nil {
+		t.Fatalf("%s", err)
+	}
+	bind = points.GetByDest("/mnt")
+	if len(bind) != 1 {
+		t.Fatalf("more than one mount point for /mnt has been returned")
+	}
+	for _, option := range bind[0].Options {
+		if strings.HasPrefix(option, "idmap") {
+			t.Errorf("idmap option passed as mount option for /mnt")
+		}
+	}
+	u, g, err := GetIDMap(bind[0].InternalOptions)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if u != uidMap || g != gidMap {
+		t.Errorf("unexpected ID mappings %v and %v for /mnt", u, g)
+	}
+	if _, _, err := GetIDMap(nil); err == nil {
+		t.Errorf("should have failed without idmap option")
+	}
 }
 
 func TestRemount(t *testing.T) {
@@ -20,7 +20,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/apptainer/apptainer/internal/pkg/util/fs"
 	"github.com/apptainer/apptainer/pkg/sylog"
@@ -220,95 +222,309 @@ func ArchFromContainer(container string) string {
 
 const binfmtMisc = "/proc/sys/fs/binfmt_misc"
 
+// binfmtPrefix is the prefix of binfmt_misc entries registered
+// by apptainer, entries are named <prefix><qemu arch>-<pid> after
+// the process owning them so concurrent builds don't share them.
+const binfmtPrefix = "apptainer-qemu-"
+
+// binfmtOwner returns the PID of the process which registered the
+// apptainer binfmt_misc entry name, it returns false for other entries.
+func binfmtOwner(name string) (int, bool) {
+	if !strings.HasPrefix(name, binfmtPrefix) {
+		return 0, false
+	}
+	i := strings.LastIndexByte(name, '-')
+	pid, err := strconv.Atoi(name[i+1:])
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
 type binfmtEntry struct {
-	magic      string
-	enabled    bool
-	persistent bool
+	name        string
+	interpreter string
+	magic       string
+	enabled     bool
+	persistent  bool
 }
 
-func canEmulate(arch string) bool {
-	var format format
+// qemuArchs maps GOARCH values to QEMU user emulation target names.
+var qemuArchs = map[string]string{
+	"386":      "i386",
+	"amd64":    "x86_64",
+	"arm":      "arm",
+	"armbe":    "armeb",
+	"arm64":    "aarch64",
+	"arm64be":  "aarch64_be",
+	"s390x":    "s390x",
+	"ppc64":    "ppc64",
+	"ppc64le":  "ppc64le",
+	"mips":     "mips",
+	"mipsle":   "mipsel",
+	"mips64":   "mips64",
+	"mips64le": "mips64el",
+	"riscv64":  "riscv64",
+}
+
+// QemuArch returns the QEMU user emulation target name for the
+// architecture passed in argument.
+func QemuArch(arch string) (string, error) {
+	qarch, ok := qemuArchs[arch]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownArch, arch)
+	}
+	return qarch, nil
+}
 
+func findFormat(arch string) (format, bool) {
 	for _, f := range formats {
 		if arch == f.Arch {
-			format = f
-			break
+			return f, true
+		}
+	}
+	return format{}, false
+}
+
+// binfmtMask returns the binfmt_misc mask for the format, it ignores
+// the OS ABI fields and accepts both executables and shared objects
+// (static PIE binaries) as QEMU does.
+func binfmtMask(f format) []byte {
+	mask := make([]byte, len(f.ElfMagic))
+	for i := range mask {
+		mask[i] = 0xff
+	}
+	// EI_OSABI
+	mask[7] = 0x00
+	// e_type, ET_EXEC and ET_DYN
+	if f.Endianness == binary.LittleEndian {
+		mask[16] = 0xfe
+	} else {
+		mask[17] = 0xfe
+	}
+	return mask
+}
+
+func parseBinfmtEntry(name string, b []byte) *binfmtEntry {
+	entry := &binfmtEntry{name: name}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		t := scanner.Text()
+
+		if t == "enabled" {
+			entry.enabled = true
+		} else if strings.HasPrefix(t, "interpreter") {
+			splitted := strings.Split(t, " ")
+			if len(splitted) > 1 {
+				entry.interpreter = splitted[1]
+			}
+		} else if strings.HasPrefix(t, "magic") {
+			splitted := strings.Split(t, " ")
+			if len(splitted) > 1 {
+				entry.magic = splitted[1]
+			}
+		} else if strings.HasPrefix(t, "flags") {
+			splitted := strings.Split(t, " ")
+			if len(splitted) > 1 {
+				entry.persistent = strings.Contains(splitted[1], "F")
+			}
 		}
 	}
 
+	return entry
+}
+
+// findBinfmtEntry returns the enabled binfmt_misc entry handling the
+// architecture passed in argument, or nil if there is none.
+func findBinfmtEntry(arch string) *binfmtEntry {
+	format, ok := findFormat(arch)
 	// no architecture format found
-	if format.Arch == "" {
-		return false
+	if !ok {
+		return nil
 	}
 
 	// look at /proc/sys/fs/binfmt_misc
 	content, err := os.ReadFile(filepath.Join(binfmtMisc, "status"))
 	if err != nil {
 		sylog.Warningf("%v", err)
-		return false
+		return nil
 	}
 	if string(content) != "enabled\n" {
-		return false
+		return nil
 	}
 
 	entries, err := os.ReadDir(binfmtMisc)
 	if err != nil {
-		return false
+		return nil
 	}
 
 	archMagic := hex.EncodeToString(format.ElfMagic)
 
-	for _, entry := range entries {
-		f := filepath.Join(binfmtMisc, entry.Name())
-		b, err := os.ReadFile(f)
+	var found *binfmtEntry
+
+	for _, e := range entries {
+		if e.Name() == "status" || e.Name() == "register" {
+			continue
+		}
+		// entries registered by other builds are removed once
+		// they are done and can't be relied on
+		if _, ok := binfmtOwner(e.Name()); ok {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(binfmtMisc, e.Name()))
 		if err != nil {
 			continue
 		}
 
-		entry := new(binfmtEntry)
-
-		scanner := bufio.NewScanner(bytes.NewReader(b))
-		for scanner.Scan() {
-			t := scanner.Text()
-
-			if t == "enabled" {
-				entry.enabled = true
-			} else if strings.HasPrefix(t, "magic") {
-				splitted := strings.Split(t, " ")
-				if len(splitted) > 1 {
-					entry.magic = splitted[1]
-				}
-			} else if strings.HasPrefix(t, "flags") {
-				splitted := strings.Split(t, " ")
-				if len(splitted) > 1 {
-					entry.persistent = strings.Contains(splitted[1], "F")
-				}
-			}
+		entry := parseBinfmtEntry(e.Name(), b)
+		if !entry.enabled || entry.magic != archMagic {
+			continue
 		}
-
-		if entry.enabled && entry.persistent && entry.magic == archMagic {
-			return true
+		// prefer entries with the F flag as they work
+		// inside containers without further setup
+		if entry.persistent {
+			return entry
+		} else if found == nil {
+			found = entry
 		}
 	}
 
-	return false
+	return found
 }
 
-// CompatibleWith returns if the current machine architecture is
-// compatible or can run via emulation the architecture passed in
-// argument.
-func CompatibleWith(arch string) bool {
-	currentArch := runtime.GOARCH
+func canEmulate(arch string) bool {
+	entry := findBinfmtEntry(arch)
+	return entry != nil && entry.persistent
+}
 
-	if currentArch == arch {
-		return true
+// Emulator describes how binaries of a foreign architecture are
+// executed through a QEMU user emulation interpreter.
+type Emulator struct {
+	// Arch is the emulated architecture.
+	Arch string
+	// Interpreter is the path of the interpreter on the host.
+	Interpreter string
+	// NeedsBind reports if the interpreter must be present at the
+	// same path inside the container, which is the case for entries
+	// registered without the F flag.
+	NeedsBind bool
+
+	name string
+}
+
+// FindEmulator returns the emulator already registered in binfmt_misc
+// for the architecture passed in argument, or nil if there is none.
+func FindEmulator(arch string) *Emulator {
+	entry := findBinfmtEntry(arch)
+	if entry == nil {
+		return nil
+	}
+	return &Emulator{
+		Arch:        arch,
+		Interpreter: entry.interpreter,
+		NeedsBind:   !entry.persistent,
+	}
+}
+
+// RegisterEmulator registers the interpreter in binfmt_misc for the
+// architecture passed in argument with the F flag, so the kernel opens
+// the interpreter immediately and it doesn't need to be visible from
+// containers. This requires privileges, the entry must be removed with
+// Unregister once not needed anymore.
+func RegisterEmulator(arch, interpreter string) (*Emulator, error) {
+	format, ok := findFormat(arch)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownArch, arch)
+	}
+	qarch, err := QemuArch(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Emulator{
+		Arch:        arch,
+		Interpreter: interpreter,
+		name:        fmt.Sprintf("%s%s-%d", binfmtPrefix, qarch, os.Getpid()),
+	}
+
+	removeStaleEmulators()
+
+	rule := fmt.Sprintf(":%s:M::%s:%s:%s:F",
+		e.name,
+		escapeBinfmt(format.ElfMagic),
+		escapeBinfmt(binfmtMask(format)),
+		interpreter,
+	)
+	if err := os.WriteFile(filepath.Join(binfmtMisc, "register"), []byte(rule), 0o200); err != nil {
+		return nil, fmt.Errorf("while registering %s in binfmt_misc: %w", interpreter, err)
+	}
+
+	return e, nil
+}
+
+// Unregister removes the binfmt_misc entry registered by
+// RegisterEmulator, it does nothing for pre-existing entries.
+func (e *Emulator) Unregister() error {
+	if e.name == "" {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(binfmtMisc, e.name), []byte("-1"), 0o200); err != nil {
+		return fmt.Errorf("while removing binfmt_misc entry %s: %w", e.name, err)
+	}
+	e.name = ""
+	return nil
+}
+
+// removeStaleEmulators removes the binfmt_misc entries left behind by
+// builds which didn't terminate properly.
+func removeStaleEmulators() {
+	entries, err := os.ReadDir(binfmtMisc)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		pid, ok := binfmtOwner(e.Name())
+		if !ok || syscall.Kill(pid, 0) != syscall.ESRCH {
+			continue
+		}
+		stale := &Emulator{name: e.Name()}
+		if err := stale.Unregister(); err != nil {
+			sylog.Warningf("%v", err)
+		}
+	}
+}
+
+// escapeBinfmt returns the hex escaped form of b used
+// in binfmt_misc registration rules.
+func escapeBinfmt(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		fmt.Fprintf(&sb, "\\x%02x", c)
 	}
+	return sb.String()
+}
 
+// IsNative returns if binaries of the architecture passed in argument
+// run natively on the current machine, without emulation.
+func IsNative(arch string) bool {
+	if arch == runtime.GOARCH {
+		return true
+	}
 	for _, f := range formats {
-		if arch == f.Arch && f.Compatible == currentArch {
+		if arch == f.Arch && f.Compatible == runtime.GOARCH {
 			return true
 		}
 	}
+	return false
+}
 
+// CompatibleWith returns if the current machine architecture is
+// compatible or can run via emulation the architecture passed in
+// argument.
+func CompatibleWith(arch string) bool {
+	if IsNative(arch) {
+		return true
+	}
 	return canEmulate(arch)
 }
@@ -0,0 +1,131 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package machine
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBinfmtEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		enabled     bool
+		persistent  bool
+		interpreter string
+		magic       string
+	}{
+		{
+			name: "Persistent",
+			content: `enabled
+interpreter /usr/bin/qemu-aarch64-static
+flags: OCF
+offset 0
+magic 7f454c460201010000000000000000000200b700
+mask ffffffffffffff00fffffffffffffffffeffffff
+`,
+			enabled:     true,
+			persistent:  true,
+			interpreter: "/usr/bin/qemu-aarch64-static",
+			magic:       "7f454c460201010000000000000000000200b700",
+		},
+		{
+			name: "DisabledNotPersistent",
+			content: `disabled
+interpreter /usr/bin/qemu-riscv64
+flags:
+offset 0
+magic 7f454c460201010000000000000000000200f300
+`,
+			interpreter: "/usr/bin/qemu-riscv64",
+			magic:       "7f454c460201010000000000000000000200f300",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := parseBinfmtEntry(tt.name, []byte(tt.content))
+			if e.enabled != tt.enabled {
+				t.Errorf("unexpected enabled value: got %v, want %v", e.enabled, tt.enabled)
+			}
+			if e.persistent != tt.persistent {
+				t.Errorf("unexpected persistent value: got %v, want %v", e.persistent, tt.persistent)
+			}
+			if e.interpreter != tt.interpreter {
+				t.Errorf("unexpected interpreter: got %q, want %q", e.interpreter, tt.interpreter)
+			}
+			if e.magic != tt.magic {
+				t.Errorf("unexpected magic: got %q, want %q", e.magic, tt.magic)
+			}
+		})
+	}
+}
+
+func TestBinfmtMask(t *testing.T) {
+	tests := []struct {
+		arch string
+		mask string
+	}{
+		{"arm64", "ffffffffffffff00fffffffffffffffffeffffff"},
+		{"s390x", "ffffffffffffff00fffffffffffffffffffeffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			f, ok := findFormat(tt.arch)
+			if !ok {
+				t.Fatalf("no format for %s", tt.arch)
+			}
+			if mask := hex.EncodeToString(binfmtMask(f)); mask != tt.mask {
+				t.Errorf("unexpected mask: got %s, want %s", mask, tt.mask)
+			}
+			rule := escapeBinfmt(f.ElfMagic)
+			if !strings.HasPrefix(rule, `\x7f\x45\x4c\x46`) || len(rule) != 4*len(f.ElfMagic) {
+				t.Errorf("unexpected escaped magic %s", rule)
+			}
+		})
+	}
+}
+
+func TestBinfmtOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantPid int
+		wantOk  bool
+	}{
+		{name: "apptainer-qemu-aarch64-1234", wantPid: 1234, wantOk: true},
+		{name: "apptainer-qemu-aarch64_be-42", wantPid: 42, wantOk: true},
+		{name: "apptainer-qemu-aarch64", wantOk: false},
+		{name: "apptainer-qemu-aarch64-0", wantOk: false},
+		{name: "qemu-aarch64", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pid, ok := binfmtOwner(tt.name)
+			if ok != tt.wantOk || pid != tt.wantPid {
+				t.Errorf("got %d, %v, want %d, %v", pid, ok, tt.wantPid, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestQemuArch(t *testing.T) {
+	for _, f := range formats {
+		if _, err := QemuArch(f.Arch); err != nil {
+			t.Errorf("no QEMU target for %s: %v", f.Arch, err)
+		}
+	}
+	if _, err := QemuArch("sparc"); !errors.Is(err, ErrUnknownArch) {
+		t.Errorf("unexpected error for unknown arch: %v", err)
+	}
+}
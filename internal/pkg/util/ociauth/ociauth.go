@@ -97,6 +97,12 @@ func (sk *apptainerKeychain) Resolve(target authn.Resource) (authn.Authenticator
 	}), nil
 }
 
+// Keychain returns a keychain resolving credentials from reqAuthFile, or
+// from the default auth file when reqAuthFile is empty.
+func Keychain(reqAuthFile string) authn.Keychain {
+	return &apptainerKeychain{reqAuthFile: reqAuthFile}
+}
+
 // ConfigFileFromPath creates a configfile.Configfile object (part of docker/cli
 // API) associated with the auth file at path.
 func ConfigFileFromPath(path string) (*configfile.ConfigFile, error) {
@@ -198,5 +204,5 @@ func AuthOptn(ociAuth *authn.AuthConfig, reqAuthFile string) remote.Option {
 		return remote.WithAuth(authn.FromConfig(*ociAuth))
 	}
 
-	return remote.WithAuthFromKeychain(&apptainerKeychain{reqAuthFile: reqAuthFile})
+	return remote.WithAuthFromKeychain(Keychain(reqAuthFile))
 }
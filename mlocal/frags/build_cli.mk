@@ -37,8 +37,13 @@ $(singularity_INSTALL):
 	@echo " INSTALL" $@
 	$(V)ln -sf apptainer $(singularity_INSTALL)
 
+apptainer_runc_INSTALL := $(DESTDIR)$(BINDIR)/apptainer-runc
+$(apptainer_runc_INSTALL):
+	@echo " INSTALL" $@
+	$(V)ln -sf apptainer $(apptainer_runc_INSTALL)
+
 CLEANFILES += $(apptainer)
-INSTALLFILES += $(apptainer_INSTALL) $(singularity_INSTALL)
+INSTALLFILES += $(apptainer_INSTALL) $(singularity_INSTALL) $(apptainer_runc_INSTALL)
 ALL += $(apptainer)
 
 
@@ -14,15 +14,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
 // Definition describes how to build an image.
 type Definition struct {
-	Header     map[string]string `json:"header"`
-	ImageData  `json:"imageData"`
-	BuildData  Data              `json:"buildData"`
-	CustomData map[string]string `json:"customData"`
+	Header map[string]string `json:"header"`
+	// HeaderComments holds the comments of the header, keyed by the
+	// header keyword they precede or are on the line of, comments after
+	// the last keyword are keyed by an empty string.
+	HeaderComments map[string][]string `json:"headerComments,omitempty"`
+	ImageData      `json:"imageData"`
+	BuildData      Data              `json:"buildData"`
+	CustomData     map[string]string `json:"customData"`
 
 	// Raw contains the raw definition file content that is applied when this
 	// Definition is built. For multi-stage builds parsed with parser.All(),
@@ -152,13 +157,118 @@ func UpdateDefinitionRaw(defs *[]Definition) {
 	}
 }
 
+// canonicalHeaders holds the canonical spelling of header keywords, which
+// are case insensitive, in the order they are written. Other keywords are
+// written after them in lexical order.
+var canonicalHeaders = []struct{ key, name string }{
+	{"bootstrap", "Bootstrap"},
+	{"from", "From"},
+	{"stage", "Stage"},
+	{"registry", "Registry"},
+	{"namespace", "Namespace"},
+	{"library", "Library"},
+	{"fingerprints", "Fingerprints"},
+	{"osversion", "OSVersion"},
+	{"mirrorurl", "MirrorURL"},
+	{"updateurl", "UpdateURL"},
+	{"confurl", "ConfURL"},
+	{"include", "Include"},
+	{"includecmd", "IncludeCmd"},
+	{"setopt", "SetOpt"},
+	{"suites", "Suites"},
+	{"components", "Components"},
+	{"keyring", "Keyring"},
+	{"keys", "Keys"},
+	{"product", "Product"},
+	{"user", "User"},
+	{"regcode", "Regcode"},
+	{"productpgp", "ProductPGP"},
+	{"registerurl", "RegisterURL"},
+	{"modules", "Modules"},
+	{"target", "Target"},
+	{"frontend", "Frontend"},
+	{"filename", "Filename"},
+	{"buildargs", "BuildArgs"},
+}
+
+// appSectionOrder is the order app sections are written in, for each app.
+var appSectionOrder = []string{
+	"appfiles",
+	"appenv",
+	"appinstall",
+	"apprun",
+	"appstart",
+	"apptest",
+	"applabels",
+	"apphelp",
+}
+
+// formatScript removes leading and trailing blank lines from a section
+// script. Other lines are kept as is, as whitespace may be significant,
+// e.g. in heredocs.
+func formatScript(script string) string {
+	lines := strings.Split(script, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// quoteFile quotes a %files path if it contains whitespace.
+func quoteFile(path string) string {
+	if strings.ContainsAny(path, " \t") {
+		return `"` + path + `"`
+	}
+	return path
+}
+
+// writeHeader writes the header of d, bootstrap first, with canonical
+// keywords, each preceded by its comments. Values spanning multiple lines
+// are written as continuations.
+func writeHeader(w io.Writer, header map[string]string, comments map[string][]string) {
+	written := make(map[string]bool, len(header))
+
+	writeComments := func(key string) {
+		for _, c := range comments[key] {
+			fmt.Fprintln(w, c)
+		}
+	}
+	write := func(name, v string) {
+		writeComments(strings.ToLower(name))
+		v = strings.ReplaceAll(v, "\n", "\\n\\\n")
+		fmt.Fprintf(w, "%s: %s\n", name, v)
+	}
+
+	for _, h := range canonicalHeaders {
+		if v, ok := header[h.key]; ok {
+			write(h.name, v)
+			written[h.key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		if !written[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k, header[k])
+	}
+	writeComments("")
+}
+
 func writeSectionIfExists(w io.Writer, ident string, s Script) {
-	if len(s.Script) > 0 {
+	if script := formatScript(s.Script); len(script) > 0 {
 		fmt.Fprintf(w, "%%%s", ident)
 		if len(s.Args) > 0 {
-			fmt.Fprintf(w, " %s", s.Args)
+			fmt.Fprintf(w, " %s", strings.TrimSpace(s.Args))
 		}
-		fmt.Fprintf(w, "\n%s\n\n", s.Script)
+		fmt.Fprintf(w, "\n%s\n\n", script)
 	}
 }
 
@@ -172,7 +282,11 @@ func writeFilesIfExists(w io.Writer, f []Files) {
 			fmt.Fprintln(w)
 
 			for _, ft := range f.Files {
-				fmt.Fprintf(w, "\t%s\t%s\n", ft.Src, ft.Dst)
+				if ft.Dst == "" {
+					fmt.Fprintf(w, "\t%s\n", quoteFile(ft.Src))
+					continue
+				}
+				fmt.Fprintf(w, "\t%s %s\n", quoteFile(ft.Src), quoteFile(ft.Dst))
 			}
 			fmt.Fprintln(w)
 		}
@@ -182,41 +296,62 @@ func writeFilesIfExists(w io.Writer, f []Files) {
 func writeLabelsIfExists(w io.Writer, l map[string]string) {
 	if len(l) > 0 {
 		fmt.Fprintln(w, "%labels")
-		for k, v := range l {
-			fmt.Fprintf(w, "\t%s %s\n", k, v)
+		keys := make([]string, 0, len(l))
+		for k := range l {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "\t%s %s\n", k, l[k])
 		}
 		fmt.Fprintln(w)
 	}
 }
 
-// populateRaw is a helper func to output a Definition struct
-// into a definition file.
-func populateRaw(d *Definition, w io.Writer) {
-	// ensure bootstrap is the first parameter in the header
-	if v, ok := d.Header["bootstrap"]; ok {
-		fmt.Fprintf(w, "%s: %s\n", "bootstrap", v)
-	}
-
-	for k, v := range d.Header {
-		// filter out bootstrap parameter since it should already be added
-		if k == "bootstrap" {
-			continue
+// writeAppsIfExists writes the sections of the SCIF apps of d, in the order
+// apps appear in the definition file.
+func writeAppsIfExists(w io.Writer, d *Definition) {
+	for _, app := range d.AppOrder {
+		for _, section := range appSectionOrder {
+			if script, ok := d.CustomData[section+" "+app]; ok {
+				writeSectionIfExists(w, section+" "+app, Script{Script: script})
+			}
 		}
+	}
+}
 
-		fmt.Fprintf(w, "%s: %s\n", k, v)
+// populateRaw is a helper func to output a Definition struct
+// into a definition file, with sections in canonical order.
+func populateRaw(d *Definition, w io.Writer) {
+	if len(d.Header) > 0 || len(d.HeaderComments) > 0 {
+		writeHeader(w, d.Header, d.HeaderComments)
+		fmt.Fprintln(w)
 	}
-	fmt.Fprintln(w)
 
-	writeLabelsIfExists(w, d.Labels)
+	writeSectionIfExists(w, "arguments", d.BuildData.Arguments)
+	writeSectionIfExists(w, "pre", d.BuildData.Pre)
+	writeSectionIfExists(w, "setup", d.BuildData.Setup)
 	writeFilesIfExists(w, d.BuildData.Files)
-
-	writeSectionIfExists(w, "help", d.Help)
 	writeSectionIfExists(w, "environment", d.Environment)
+	writeSectionIfExists(w, "post", d.BuildData.Post)
 	writeSectionIfExists(w, "runscript", d.Runscript)
-	writeSectionIfExists(w, "test", d.Test)
 	writeSectionIfExists(w, "startscript", d.Startscript)
-	writeSectionIfExists(w, "pre", d.BuildData.Pre)
-	writeSectionIfExists(w, "setup", d.BuildData.Setup)
-	writeSectionIfExists(w, "post", d.BuildData.Post)
-	writeSectionIfExists(w, "arguments", d.BuildData.Arguments)
+	writeSectionIfExists(w, "test", d.Test)
+	writeLabelsIfExists(w, d.Labels)
+	writeSectionIfExists(w, "help", d.Help)
+	writeAppsIfExists(w, d)
+}
+
+// WriteDefinitions writes the definitions of a single or multi-stage build
+// to w as a definition file in canonical form: header keywords, sections
+// and labels are written in a fixed order, with normalized whitespace.
+// Comments of the header are kept with the keyword they precede, other
+// comments outside of script sections are not preserved.
+func WriteDefinitions(w io.Writer, defs ...Definition) error {
+	var buf bytes.Buffer
+	for i := range defs {
+		populateRaw(&defs[i], &buf)
+	}
+	_, err := w.Write(append(bytes.TrimRight(buf.Bytes(), "\n"), '\n'))
+	return err
 }
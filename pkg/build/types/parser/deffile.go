@@ -364,19 +364,36 @@ func doHeader(h string, d *types.Definition) error {
 	header := make(map[string]string)
 	keyCont, valCont := "", ""
 
+	// comments are attached to the keyword following them
+	comments := make(map[string][]string)
+	var pending []string
+	setHeader := func(key, val string) {
+		header[key] = val
+		if len(pending) > 0 {
+			comments[key] = append(comments[key], pending...)
+			pending = nil
+		}
+	}
+
 	for _, line := range toks {
 		var key, val string
 		// skip empty or comment lines
 		if line = strings.TrimSpace(line); line == "" || strings.Index(line, "#") == 0 {
 			if len(keyCont) > 0 {
-				d.Header[keyCont] = valCont
+				setHeader(keyCont, valCont)
 				keyCont, valCont = "", ""
 			}
+			if line != "" {
+				pending = append(pending, line)
+			}
 			continue
 		}
 
 		// trim any comments on header lines
-		trimLine := strings.Split(line, "#")[0]
+		trimLine, comment, found := strings.Cut(line, "#")
+		if found {
+			pending = append(pending, "# "+strings.TrimSpace(comment))
+		}
 		if len(valCont) == 0 {
 			linetoks := strings.SplitN(trimLine, ":", 2)
 			if len(linetoks) == 1 {
@@ -407,13 +424,19 @@ func doHeader(h string, d *types.Definition) error {
 				return fmt.Errorf("invalid header keyword found: %s", key)
 			}
 		}
-		header[key] = val
+		setHeader(key, val)
+	}
+	if len(pending) > 0 {
+		comments[""] = pending
 	}
 
 	// only set header if some values are found
 	if len(header) != 0 {
 		d.Header = header
 	}
+	if len(comments) != 0 {
+		d.HeaderComments = comments
+	}
 
 	return nil
 }
@@ -476,8 +499,13 @@ func All(r io.Reader) ([]types.Definition, error) {
 	}
 
 	// add anything remaining above first found Bootstrap
-	// handles case of no header
-	splitBuf = append([][]byte{buf[:]}, splitBuf...)
+	// handles case of no header, comments only are kept with
+	// the header of the first stage
+	if len(splitBuf) > 0 && onlyComments(buf) {
+		splitBuf[0] = append(bytes.Clone(buf), splitBuf[0]...)
+	} else {
+		splitBuf = append([][]byte{buf[:]}, splitBuf...)
+	}
 
 	if len(splitBuf) == 0 {
 		return nil, errEmptyDefinition
@@ -508,6 +536,16 @@ func All(r io.Reader) ([]types.Definition, error) {
 	return stages, nil
 }
 
+// onlyComments returns true if b holds comments or blank lines only.
+func onlyComments(b []byte) bool {
+	for line := range strings.Lines(string(b)) {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
 // IsValidDefinition returns whether or not the given file is a valid definition
 func IsValidDefinition(source string) (valid bool, err error) {
 	defFile, err := os.Open(source)
@@ -534,9 +572,10 @@ func IsValidDefinition(source string) (valid bool, err error) {
 // due to the unique initialization state of the empty definition for this check, it should only
 // be used by populateDefinition()
 func isEmpty(d types.Definition) bool {
-	// clear raw data for comparison
+	// clear raw data and comments for comparison
 	d.Raw = nil
 	d.FullRaw = nil
+	d.HeaderComments = nil
 
 	// initialize empty definition fully
 	emptyDef := types.Definition{}
@@ -602,4 +641,8 @@ var validHeaders = map[string]bool{
 	"frontend":     true,
 	"filename":     true,
 	"buildargs":    true,
+	"keys":         true,
+	"suites":       true,
+	"components":   true,
+	"keyring":      true,
 }
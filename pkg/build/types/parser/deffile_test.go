@@ -11,9 +11,11 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -445,3 +447,101 @@ func TestParseAll(t *testing.T) {
 		}))
 	}
 }
+
+// trimLines removes leading and trailing blank lines of a script.
+func trimLines(script string) string {
+	lines := strings.Split(script, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestWriteDefinitions(t *testing.T) {
+	files, err := filepath.Glob("testdata_good/*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range files {
+		if filepath.Ext(path) == ".json" || filepath.Base(path) == "result" {
+			continue
+		}
+		t.Run(path, func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defs, err := All(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", path, err)
+			}
+
+			var formatted bytes.Buffer
+			if err := types.WriteDefinitions(&formatted, defs...); err != nil {
+				t.Fatal(err)
+			}
+
+			// the formatted definition file must be equivalent
+			fdefs, err := All(bytes.NewReader(formatted.Bytes()))
+			if err != nil {
+				t.Fatalf("failed to parse formatted %s: %v\n%s", path, err, formatted.String())
+			}
+			assert.Equal(t, len(fdefs), len(defs))
+			for i := range defs {
+				assert.DeepEqual(t, fdefs[i].Header, defs[i].Header)
+				assert.DeepEqual(t, fdefs[i].HeaderComments, defs[i].HeaderComments)
+				assert.DeepEqual(t, fdefs[i].Labels, defs[i].Labels)
+				assert.DeepEqual(t, fdefs[i].BuildData.Files, defs[i].BuildData.Files)
+				assert.DeepEqual(t, fdefs[i].AppOrder, defs[i].AppOrder)
+				assert.Equal(t, trimLines(fdefs[i].BuildData.Post.Script), trimLines(defs[i].BuildData.Post.Script))
+			}
+
+			// and formatting must be idempotent
+			var again bytes.Buffer
+			if err := types.WriteDefinitions(&again, fdefs...); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, again.String(), formatted.String())
+		})
+	}
+}
+
+func TestWriteDefinitionsVerbatim(t *testing.T) {
+	def := "# base image\n" +
+		"Bootstrap: docker # pinned\n" +
+		"From: alpine:3.20\n" +
+		"\n" +
+		"%post\n" +
+		"\n" +
+		"    cat > /etc/motd <<EOF  \n" +
+		"keep trailing spaces  \n" +
+		"EOF\n" +
+		"    apk add \\ \n" +
+		"        curl\n" +
+		"\n"
+	want := "# base image\n" +
+		"# pinned\n" +
+		"Bootstrap: docker\n" +
+		"From: alpine:3.20\n" +
+		"\n" +
+		"%post\n" +
+		"    cat > /etc/motd <<EOF  \n" +
+		"keep trailing spaces  \n" +
+		"EOF\n" +
+		"    apk add \\ \n" +
+		"        curl\n"
+
+	defs, err := All(strings.NewReader(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var formatted bytes.Buffer
+	if err := types.WriteDefinitions(&formatted, defs...); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, formatted.String(), want)
+}
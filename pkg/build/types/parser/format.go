@@ -0,0 +1,72 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/apptainer/apptainer/pkg/build/types"
+)
+
+// Definition formats.
+const (
+	// FormatDef is the definition file format.
+	FormatDef = "def"
+	// FormatJSON is the JSON form of definitions, see types.DefinitionSchema.
+	FormatJSON = "json"
+	// FormatYAML is the YAML form of definitions, see types.DefinitionSchema.
+	FormatYAML = "yaml"
+)
+
+// FormatFromPath returns the format of the definition at path according to
+// its extension: FormatJSON for .json, FormatYAML for .yaml and .yml, and
+// FormatDef otherwise.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatDef
+	}
+}
+
+// AllFormat parses the definitions read from r in format, see All. JSON and
+// YAML definitions are validated against types.DefinitionSchema, and
+// converted to a definition file, so that the returned definitions are
+// identical to those parsed from the equivalent definition file.
+func AllFormat(r io.Reader, format string) ([]types.Definition, error) {
+	var defs []types.Definition
+	var err error
+
+	switch format {
+	case FormatDef:
+		return All(r)
+	case FormatJSON:
+		defs, err = types.NewDefinitionsFromJSON(r)
+	case FormatYAML:
+		defs, err = types.NewDefinitionsFromYAML(r)
+	default:
+		return nil, fmt.Errorf("unknown definition format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := types.WriteDefinitions(&buf, defs...); err != nil {
+		return nil, err
+	}
+	return All(&buf)
+}
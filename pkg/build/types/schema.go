@@ -0,0 +1,239 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"go.yaml.in/yaml/v4"
+)
+
+// DefinitionSchema is the JSON Schema of the JSON and YAML forms of
+// definitions, a single Definition or an array of Definitions for
+// multi-stage builds.
+//
+//go:embed definition.schema.json
+var DefinitionSchema []byte
+
+// FieldError is a schema validation error of a definition field.
+type FieldError struct {
+	// Field is the path of the invalid field, e.g. 0.header.bootstrap for
+	// the first stage of a multi-stage definition, or (root).
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// SchemaError records the schema validation errors of a definition.
+type SchemaError struct {
+	Errors []FieldError
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Error())
+	}
+	return "definition does not match schema: " + strings.Join(msgs, "; ")
+}
+
+// ValidateDefinitionJSON validates the JSON form of definitions against
+// DefinitionSchema, returning a *SchemaError listing the invalid fields.
+func ValidateDefinitionJSON(data []byte) error {
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(DefinitionSchema),
+		gojsonschema.NewBytesLoader(data),
+	)
+	if err != nil {
+		return fmt.Errorf("while validating definition: %v", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	serr := &SchemaError{}
+	propertyName := false
+	for _, re := range result.Errors() {
+		switch re.Type() {
+		case "condition_else", "condition_then":
+			// conditional errors duplicate the errors of the applied schema
+			continue
+		case "pattern":
+			// the pattern of an invalid property name is reported after it
+			if propertyName {
+				propertyName = false
+				continue
+			}
+		case "invalid_property_name":
+			propertyName = true
+		}
+		serr.Errors = append(serr.Errors, FieldError{Field: re.Field(), Message: re.Description()})
+	}
+	return serr
+}
+
+// NewDefinitionsFromJSON creates Definitions from their JSON form, a single
+// definition object or an array of definitions for multi-stage builds,
+// validated against DefinitionSchema. Raw contents are regenerated from the
+// other fields.
+func NewDefinitionsFromJSON(r io.Reader) ([]Definition, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("while reading definition: %v", err)
+	}
+	if err := ValidateDefinitionJSON(data); err != nil {
+		return nil, err
+	}
+
+	var defs []Definition
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &defs)
+	} else {
+		defs = make([]Definition, 1)
+		err = json.Unmarshal(data, &defs[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while decoding definition: %v", err)
+	}
+
+	var full bytes.Buffer
+	for i := range defs {
+		var buf bytes.Buffer
+		populateRaw(&defs[i], &buf)
+		defs[i].Raw = buf.Bytes()
+		full.Write(buf.Bytes())
+	}
+	for i := range defs {
+		defs[i].FullRaw = full.Bytes()
+	}
+
+	return defs, nil
+}
+
+// NewDefinitionsFromYAML creates Definitions from their YAML form, which
+// follows the same schema as the JSON form, see NewDefinitionsFromJSON.
+func NewDefinitionsFromYAML(r io.Reader) ([]Definition, error) {
+	var doc interface{}
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("while decoding YAML definition: %v", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("while decoding YAML definition: %v", err)
+	}
+
+	return NewDefinitionsFromJSON(bytes.NewReader(data))
+}
+
+// definitionsDocument returns the generic form of defs, without their raw
+// contents, as a single definition or an array of definitions.
+func definitionsDocument(defs []Definition) (interface{}, error) {
+	data, err := json.Marshal(defs)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		delete(d, "raw")
+		delete(d, "fullraw")
+		pruneDocument(d)
+	}
+
+	if len(docs) == 1 {
+		return docs[0], nil
+	}
+	return docs, nil
+}
+
+// pruneDocument removes empty values from the generic form of a definition,
+// and normalizes whitespace of scripts, so that it only holds what is needed
+// to build the image. It returns true if m is empty once pruned.
+func pruneDocument(m map[string]interface{}) bool {
+	for k, v := range m {
+		switch v := v.(type) {
+		case nil:
+			delete(m, k)
+		case string:
+			if k == "script" {
+				v = formatScript(v)
+				m[k] = v
+			}
+			if v == "" {
+				delete(m, k)
+			}
+		case []interface{}:
+			var items []interface{}
+			for _, item := range v {
+				if im, ok := item.(map[string]interface{}); ok && pruneDocument(im) {
+					continue
+				}
+				items = append(items, item)
+			}
+			if len(items) == 0 {
+				delete(m, k)
+			} else {
+				m[k] = items
+			}
+		case map[string]interface{}:
+			// header values and labels may be empty strings
+			if k == "header" || k == "labels" || k == "customData" {
+				if len(v) == 0 {
+					delete(m, k)
+				}
+				continue
+			}
+			if pruneDocument(v) {
+				delete(m, k)
+			}
+		}
+	}
+	return len(m) == 0
+}
+
+// WriteDefinitionsJSON writes defs to w in JSON form, without their raw
+// contents and empty values.
+func WriteDefinitionsJSON(w io.Writer, defs ...Definition) error {
+	doc, err := definitionsDocument(defs)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteDefinitionsYAML writes defs to w in YAML form, without their raw
+// contents and empty values.
+func WriteDefinitionsYAML(w io.Writer, defs ...Definition) error {
+	doc, err := definitionsDocument(defs)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateDefinitionJSON(t *testing.T) {
+	// definitions serialized by the parser tests must be valid
+	files, err := filepath.Glob("parser/testdata_good/*/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, "_sections.json") {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateDefinitionJSON(data); err != nil {
+			t.Errorf("%s: unexpected error: %v", f, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		json   string
+		fields []string
+	}{
+		{
+			name:   "NotObject",
+			json:   `"bootstrap: docker"`,
+			fields: []string{"(root)"},
+		},
+		{
+			name:   "UnknownHeader",
+			json:   `{"header": {"bootstrap": "docker", "form": "alpine"}}`,
+			fields: []string{"header"},
+		},
+		{
+			name:   "UnknownField",
+			json:   `{"header": {"bootstrap": "docker"}, "post": "true"}`,
+			fields: []string{"(root)"},
+		},
+		{
+			name:   "Stages",
+			json:   `[{"header": {"bootstrap": "docker"}}, {"buildData": {"files": [{"files": [{"destination": "/opt"}]}], "buildScripts": {"post": "true"}}}]`,
+			fields: []string{"1.buildData.files.0.files.0", "1.buildData.buildScripts.post"},
+		},
+		{
+			name:   "EmptyStages",
+			json:   `[]`,
+			fields: []string{"(root)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDefinitionJSON([]byte(tt.json))
+			var serr *SchemaError
+			if !errors.As(err, &serr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var fields []string
+			for _, fe := range serr.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("got invalid fields %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestDefinitionsYAML(t *testing.T) {
+	const yamlDef = `
+- header:
+    bootstrap: docker
+    from: alpine
+    stage: build
+  buildData:
+    buildScripts:
+      post:
+        script: |
+          apk add --no-cache gcc
+- header:
+    bootstrap: scratch
+  buildData:
+    files:
+      - args: from build
+        files:
+          - source: /usr/bin/gcc
+            destination: /gcc
+  imageData:
+    labels:
+      author: me
+`
+	defs, err := NewDefinitionsFromYAML(strings.NewReader(yamlDef))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("got %d stages, want 2", len(defs))
+	}
+	if defs[0].Header["stage"] != "build" || defs[1].BuildData.Files[0].Args != "from build" || defs[1].Labels["author"] != "me" {
+		t.Errorf("unexpected definitions: %+v", defs)
+	}
+	if !bytes.Contains(defs[0].Raw, []byte("%post\napk add --no-cache gcc\n")) || !bytes.HasPrefix(defs[1].FullRaw, defs[0].Raw) {
+		t.Errorf("unexpected raw definition: %s", defs[0].FullRaw)
+	}
+
+	// converting back and forth must give the same definitions
+	var out bytes.Buffer
+	if err := WriteDefinitionsYAML(&out, defs...); err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewDefinitionsFromYAML(&out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out.String())
+	}
+	if !bytes.Equal(again[0].FullRaw, defs[0].FullRaw) {
+		t.Errorf("got %s, want %s", again[0].FullRaw, defs[0].FullRaw)
+	}
+
+	out.Reset()
+	if err := WriteDefinitionsJSON(&out, defs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), `"raw"`) || !strings.HasPrefix(out.String(), "{") {
+		t.Errorf("unexpected JSON definition: %s", out.String())
+	}
+	single, err := NewDefinitionsFromJSON(&out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(single) != 1 || !bytes.Equal(single[0].Raw, defs[0].Raw) {
+		t.Errorf("got %s, want %s", single[0].Raw, defs[0].Raw)
+	}
+}
@@ -29,6 +29,8 @@ const (
 	OverlayFeature
 	// FuseFeature means the driver uses FUSE as its base.
 	FuseFeature
+	// LazyFeature means the driver handles lazily pulled OCI image mounts.
+	LazyFeature
 )
 
 // ImageFeature means the driver handles any of the image mount types
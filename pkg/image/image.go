@@ -39,6 +39,8 @@ const (
 	RAW
 	// GOCRYPTFS constant for encrypted gocryptfs format
 	GOCRYPTFSSQUASHFS
+	// LAZY constant for lazily pulled OCI image format
+	LAZY
 )
 
 type Usage uint8
@@ -108,6 +110,7 @@ var registeredFormats = []struct {
 	{"sif", &sifFormat{}},
 	{"squashfs", &squashfsFormat{}},
 	{"ext3", &ext3Format{}},
+	{"lazy", &lazyFormat{}},
 }
 
 // format describes the interface that an image format type must implement.
@@ -0,0 +1,89 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/ccoveille/go-safecast"
+)
+
+// LazyMediaType is the media type of the descriptor file of a lazily
+// pulled OCI image, the descriptor is a JSON document starting with
+// this media type. The root filesystem is assembled on demand from the
+// image layers by the lazy image driver.
+const LazyMediaType = "application/vnd.apptainer.lazy.image.v1+json"
+
+// lazyHeader is the required start of a lazy image descriptor.
+const lazyHeader = `{"mediaType":"` + LazyMediaType + `"`
+
+type lazyFormat struct{}
+
+func (f *lazyFormat) initializer(img *Image, fileinfo os.FileInfo) error {
+	if fileinfo.IsDir() {
+		return debugError("not a lazy image")
+	}
+	b := make([]byte, len(lazyHeader))
+	if n, err := img.File.Read(b); err != nil || n != len(b) {
+		return debugErrorf("can't read first %d bytes: %v", len(b), err)
+	}
+	if !bytes.Equal(b, []byte(lazyHeader)) {
+		return debugError("not a lazy image")
+	}
+	fSize, err := safecast.Convert[uint64](fileinfo.Size())
+	if err != nil {
+		return err
+	}
+	img.Type = LAZY
+	img.Partitions = []Section{
+		{
+			Offset:       0,
+			Size:         fSize,
+			ID:           1,
+			Type:         LAZY,
+			Name:         RootFs,
+			AllowedUsage: RootFsUsage,
+		},
+	}
+
+	if img.Writable {
+		img.Writable = false
+
+		return &readOnlyFilesystemError{
+			"could not set " + img.Path + " image writable: lazily pulled images are read-only",
+		}
+	}
+
+	return nil
+}
+
+func (f *lazyFormat) openMode(_ bool) int {
+	return os.O_RDONLY
+}
+
+func (f *lazyFormat) lock(_ *Image) error {
+	return nil
+}
+
+// IsLazy returns true if the file at path is a lazy image descriptor.
+func IsLazy(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	b := make([]byte, len(lazyHeader))
+	if _, err := io.ReadFull(f, b); err != nil {
+		return false
+	}
+	return bytes.Equal(b, []byte(lazyHeader))
+}
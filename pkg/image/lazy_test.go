@@ -0,0 +1,77 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLazyInitializer(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		expectErr bool
+	}{
+		{
+			name:    "Valid",
+			content: `{"mediaType":"` + LazyMediaType + `","reference":"docker.io/library/alpine"}`,
+		},
+		{
+			name:      "OtherJSON",
+			content:   `{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`,
+			expectErr: true,
+		},
+		{
+			name:      "Short",
+			content:   `{}`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "image.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if IsLazy(path) == tt.expectErr {
+				t.Errorf("IsLazy returned %v", !tt.expectErr)
+			}
+
+			var lazyfmt lazyFormat
+			img := &Image{Path: path, Name: "test"}
+			f, err := os.OpenFile(path, lazyfmt.openMode(false), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			img.File = f
+			fi, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = lazyfmt.initializer(img, fi)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if img.Type != LAZY || len(img.Partitions) != 1 || img.Partitions[0].Size != uint64(len(tt.content)) {
+				t.Errorf("unexpected image: %+v", img)
+			}
+		})
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"os"
 	"runtime"
 
+	"github.com/apptainer/apptainer/internal/pkg/ociplatform"
+	"github.com/apptainer/apptainer/internal/pkg/sifindex"
 	"github.com/apptainer/apptainer/internal/pkg/util/machine"
 	"github.com/apptainer/apptainer/pkg/sylog"
 	"github.com/apptainer/sif/v2/pkg/sif"
@@ -59,6 +61,28 @@ func checkPartitionType(img *Image, fstype sif.FSType, offset int64) (uint32, er
 	return 0, fmt.Errorf("unknown filesystem type %v", fstype)
 }
 
+// systemPartition returns the system partition matching the host platform
+// for multi-architecture images, or the primary system partition otherwise.
+func systemPartition(fimg *sif.FileImage) (sif.Descriptor, error) {
+	if !sifindex.IsIndex(fimg) {
+		return fimg.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	}
+
+	p, err := ociplatform.DefaultPlatform()
+	if err != nil {
+		return sif.Descriptor{}, err
+	}
+	desc, err := sifindex.Select(fimg, *p)
+	if err == nil {
+		sylog.Debugf("Selected system partition %d for platform %s", desc.ID(), p)
+		return desc, nil
+	}
+
+	platforms, _ := sifindex.Platforms(fimg)
+	sylog.Warningf("No system partition for platform %s in %s, using primary partition", p, sifindex.String(platforms))
+	return fimg.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+}
+
 func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 	if fi.IsDir() {
 		return debugError("not a sif file image")
@@ -88,8 +112,12 @@ func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 
 	var groupID uint32
 
+	// groups of the system partitions of other platforms, their
+	// objects are ignored for multi-architecture images
+	otherGroups := make(map[uint32]bool)
+
 	// Get the default system partition image
-	desc, err := fimg.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	desc, err := systemPartition(fimg)
 	if err == nil {
 		fstype, _, goArch, err := desc.PartitionMetadata()
 		if err != nil {
@@ -111,6 +139,14 @@ func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 
 		groupID = desc.GroupID()
 
+		if platforms, err := sifindex.Platforms(fimg); err == nil {
+			for _, p := range platforms {
+				if p.GroupID != groupID {
+					otherGroups[p.GroupID] = true
+				}
+			}
+		}
+
 		offset, err := safecast.Convert[uint64](desc.Offset())
 		if err != nil {
 			return err
@@ -132,6 +168,9 @@ func (f *sifFormat) initializer(img *Image, fi os.FileInfo) error {
 	}
 
 	fimg.WithDescriptors(func(desc sif.Descriptor) bool {
+		if otherGroups[desc.GroupID()] {
+			return false
+		}
 		offset, err := safecast.Convert[uint64](desc.Offset())
 		if err != nil {
 			sylog.Warningf("Invalid descriptor offset: %v", err)
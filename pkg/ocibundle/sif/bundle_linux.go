@@ -33,19 +33,22 @@ type sifBundle struct {
 	ocibundle.Bundle
 }
 
-func (s *sifBundle) writeConfig(img *image.Image, g *generate.Generator) error {
+// applyImageConfig applies the OCI image configuration stored in the SIF
+// image, if any, to the process of the OCI configuration. It returns the
+// image configuration, or nil if the image doesn't contain one.
+func applyImageConfig(img *image.Image, g *generate.Generator) (*imageSpecs.ImageConfig, error) {
 	// check if SIF file contain an OCI image configuration
 	reader, err := image.NewSectionReader(img, image.SIFDescOCIConfigJSON, -1)
 	if err != nil && err != image.ErrNoSection {
-		return fmt.Errorf("failed to read %s section: %s", image.SIFDescOCIConfigJSON, err)
+		return nil, fmt.Errorf("failed to read %s section: %s", image.SIFDescOCIConfigJSON, err)
 	} else if err == image.ErrNoSection {
-		return tools.SaveBundleConfig(s.bundlePath, g)
+		return nil, nil
 	}
 
 	var imgConfig imageSpecs.ImageConfig
 
 	if err := json.NewDecoder(reader).Decode(&imgConfig); err != nil {
-		return fmt.Errorf("failed to decode %s: %s", image.SIFDescOCIConfigJSON, err)
+		return nil, fmt.Errorf("failed to decode %s: %s", image.SIFDescOCIConfigJSON, err)
 	}
 
 	if len(g.Config.Process.Args) == 1 && g.Config.Process.Args[0] == tools.RunScript {
@@ -73,6 +76,17 @@ func (s *sifBundle) writeConfig(img *image.Image, g *generate.Generator) error {
 		}
 	}
 
+	return &imgConfig, nil
+}
+
+func (s *sifBundle) writeConfig(img *image.Image, g *generate.Generator) error {
+	imgConfig, err := applyImageConfig(img, g)
+	if err != nil {
+		return err
+	} else if imgConfig == nil {
+		return tools.SaveBundleConfig(s.bundlePath, g)
+	}
+
 	volumes := tools.Volumes(s.bundlePath).Path()
 	for dst := range imgConfig.Volumes {
 		replacer := strings.NewReplacer(string(os.PathSeparator), "_")
@@ -0,0 +1,135 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sifbundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apptainer/apptainer/internal/pkg/image/unpacker"
+	"github.com/apptainer/apptainer/internal/pkg/runtime/engine/config/oci/generate"
+	"github.com/apptainer/apptainer/pkg/image"
+	"github.com/apptainer/apptainer/pkg/ocibundle/tools"
+)
+
+func openSif(path string) (*image.Image, error) {
+	img, err := image.Init(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SIF image %s: %s", path, err)
+	}
+	if img.Type != image.SIF {
+		img.File.Close()
+		return nil, fmt.Errorf("%s is not a SIF image", path)
+	}
+	return img, nil
+}
+
+// Spec returns the default OCI configuration generated by
+// tools.GenerateDefaultConfig, with the root filesystem set to the rootfs
+// directory of the bundle. If imagePath is set, the process of the
+// configuration runs the entrypoint of the SIF image, with its environment
+// and working directory, when the image contains an OCI image
+// configuration. Otherwise the process runs the image runscript, with the
+// image environment.
+func Spec(imagePath string, rootless bool) (*generate.Generator, error) {
+	g, err := tools.GenerateDefaultConfig(rootless)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate OCI config: %s", err)
+	}
+	g.SetRootPath(filepath.Base(tools.RootFs("").Path()))
+
+	if imagePath == "" {
+		return g, nil
+	}
+
+	img, err := openSif(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer img.File.Close()
+
+	if _, err := applyImageConfig(img, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Export creates a standalone OCI bundle in bundle from the SIF imagePath, with
+// the configuration returned by Spec. Contrary to FromSif, the image root
+// filesystem is extracted in the bundle, so the bundle can be used by any
+// OCI runtime, without a mount of the image.
+func Export(imagePath, bundle string, rootless bool) (err error) {
+	bundlePath, err := filepath.Abs(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to determine bundle path: %s", err)
+	}
+	configPath := tools.Config(bundlePath).Path()
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("%s already exists", configPath)
+	}
+	rootFs := tools.RootFs(bundlePath).Path()
+	if _, err := os.Stat(rootFs); err == nil {
+		return fmt.Errorf("%s already exists", rootFs)
+	}
+
+	img, err := openSif(imagePath)
+	if err != nil {
+		return err
+	}
+	defer img.File.Close()
+
+	part, err := img.GetRootFsPartition()
+	if err != nil {
+		return fmt.Errorf("while getting root filesystem in SIF %s: %s", imagePath, err)
+	}
+	if part.Type != image.SQUASHFS {
+		return fmt.Errorf("unsupported image fs type: %v", part.Type)
+	}
+
+	s := unpacker.NewSquashfs()
+	if !s.HasUnsquashfs() {
+		return fmt.Errorf("could not extract root filesystem: unsquashfs not found")
+	}
+
+	spec, err := Spec("", rootless)
+	if err != nil {
+		return err
+	}
+	// generate OCI bundle directory
+	g, err := tools.GenerateBundleConfig(bundlePath, spec.Config)
+	if err != nil {
+		return fmt.Errorf("failed to generate OCI bundle: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(rootFs)
+			os.RemoveAll(tools.Volumes(bundlePath).Path())
+			os.Remove(configPath)
+			os.Remove(bundlePath)
+		}
+	}()
+	// GenerateBundleConfig sets an absolute root path, keep the bundle
+	// relocatable
+	g.SetRootPath(filepath.Base(rootFs))
+
+	reader, err := image.NewPartitionReader(img, "", 0)
+	if err != nil {
+		return fmt.Errorf("could not extract root filesystem: %s", err)
+	}
+	if err := s.ExtractAll(reader, rootFs); err != nil {
+		return fmt.Errorf("root filesystem extraction failed: %s", err)
+	}
+
+	sb := &sifBundle{image: img.Path, bundlePath: bundlePath}
+	if err := sb.writeConfig(img, g); err != nil {
+		return fmt.Errorf("failed to write OCI configuration: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sifbundle
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/apptainer/apptainer/internal/pkg/image/unpacker"
+	"github.com/apptainer/apptainer/pkg/ocibundle/tools"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-tools/validate"
+)
+
+func hasNamespace(s *specs.Spec, ns specs.LinuxNamespaceType) bool {
+	for _, n := range s.Linux.Namespaces {
+		if n.Type == ns {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSpec(t *testing.T) {
+	g, err := Spec("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Config.Root.Path != "rootfs" {
+		t.Errorf("unexpected root path %q", g.Config.Root.Path)
+	}
+	if !slices.Equal(g.Config.Process.Args, []string{tools.RunScript}) {
+		t.Errorf("unexpected process arguments %v", g.Config.Process.Args)
+	}
+	if !slices.Contains(g.Config.Linux.MaskedPaths, "/proc/kcore") {
+		t.Errorf("/proc/kcore is not masked")
+	}
+	if !hasNamespace(g.Config, specs.NetworkNamespace) || hasNamespace(g.Config, specs.UserNamespace) {
+		t.Errorf("unexpected namespaces %v", g.Config.Linux.Namespaces)
+	}
+
+	g, err = Spec("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasNamespace(g.Config, specs.NetworkNamespace) || !hasNamespace(g.Config, specs.UserNamespace) {
+		t.Errorf("unexpected rootless namespaces %v", g.Config.Linux.Namespaces)
+	}
+	if len(g.Config.Linux.UIDMappings) != 1 || int(g.Config.Linux.UIDMappings[0].HostID) != os.Getuid() {
+		t.Errorf("unexpected rootless uid mappings %v", g.Config.Linux.UIDMappings)
+	}
+	if g.Config.Linux.Resources != nil {
+		t.Errorf("unexpected rootless cgroups resources")
+	}
+
+	// the busybox image has an OCI configuration running sh
+	g, err = Spec(busyboxSIF, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(g.Config.Process.Args, []string{"sh"}) {
+		t.Errorf("unexpected process arguments %v", g.Config.Process.Args)
+	}
+
+	if _, err := Spec("/blah", false); err == nil {
+		t.Errorf("unexpected success with non existent image")
+	}
+}
+
+func TestExport(t *testing.T) {
+	if !unpacker.NewSquashfs().HasUnsquashfs() {
+		t.Skip("unsquashfs not found")
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle")
+
+	if err := Export("/blah", bundlePath, false); err == nil {
+		t.Errorf("unexpected success with non existent image")
+	}
+	if _, err := os.Stat(bundlePath); err == nil {
+		t.Errorf("bundle directory not cleaned up")
+	}
+
+	if err := Export(busyboxSIF, bundlePath, os.Getuid() != 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tools.RootFs(bundlePath).Path(), "bin", "sh")); err != nil {
+		t.Errorf("root filesystem not extracted: %s", err)
+	}
+
+	v, err := validate.NewValidatorFromPath(bundlePath, false, "linux")
+	if err != nil {
+		t.Fatalf("Could not create bundle validator: %v", err)
+	}
+	if err := v.CheckAll(); err != nil {
+		t.Errorf("Bundle not valid: %v", err)
+	}
+
+	if err := Export(busyboxSIF, bundlePath, false); err == nil {
+		t.Errorf("unexpected success with existing bundle")
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/apptainer/apptainer/internal/pkg/runtime/engine/config/oci"
 	"github.com/apptainer/apptainer/internal/pkg/runtime/engine/config/oci/generate"
+	"github.com/ccoveille/go-safecast"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -47,6 +48,96 @@ func (c Config) Path() string {
 // RunScript is the default process argument
 const RunScript = "/.singularity.d/actions/run"
 
+// DefaultMaskedPaths are the paths masked in the default OCI configuration.
+var DefaultMaskedPaths = []string{
+	"/proc/acpi",
+	"/proc/asound",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/sys/firmware",
+	"/sys/devices/virtual/powercap",
+}
+
+// DefaultReadonlyPaths are the paths set read-only in the default OCI
+// configuration.
+var DefaultReadonlyPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// GenerateDefaultConfig returns a default OCI configuration running the
+// container runscript, with masked and read-only paths. With rootless, the
+// configuration is adapted to run without privileges: the current user and
+// group are mapped to root in a user namespace, the host network is used
+// and no cgroups resources are set.
+func GenerateDefaultConfig(rootless bool) (*generate.Generator, error) {
+	g, err := oci.DefaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	g.SetProcessArgs([]string{RunScript})
+	g.Config.Linux.MaskedPaths = append([]string{}, DefaultMaskedPaths...)
+	g.Config.Linux.ReadonlyPaths = append([]string{}, DefaultReadonlyPaths...)
+
+	if !rootless {
+		return g, nil
+	}
+
+	namespaces := make([]specs.LinuxNamespace, 0, len(g.Config.Linux.Namespaces)+1)
+	for _, ns := range g.Config.Linux.Namespaces {
+		if ns.Type != specs.NetworkNamespace && ns.Type != specs.UserNamespace {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	g.Config.Linux.Namespaces = append(namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+	uid, err := safecast.Convert[uint32](os.Getuid())
+	if err != nil {
+		return nil, err
+	}
+	gid, err := safecast.Convert[uint32](os.Getgid())
+	if err != nil {
+		return nil, err
+	}
+	g.AddLinuxUIDMapping(uid, 0, 1)
+	g.AddLinuxGIDMapping(gid, 0, 1)
+
+	mounts := make([]specs.Mount, 0, len(g.Config.Mounts))
+	for _, m := range g.Config.Mounts {
+		switch m.Destination {
+		case "/sys":
+			// sysfs can't be mounted without a network namespace
+			m.Type = "none"
+			m.Source = "/sys"
+			m.Options = []string{"rbind", "nosuid", "noexec", "nodev", "ro"}
+		case "/sys/fs/cgroup":
+			// bind mounted with /sys
+			continue
+		case "/dev/pts":
+			// the tty group is not mapped in the user namespace
+			options := make([]string, 0, len(m.Options))
+			for _, o := range m.Options {
+				if o != "gid=5" {
+					options = append(options, o)
+				}
+			}
+			m.Options = options
+		}
+		mounts = append(mounts, m)
+	}
+	g.Config.Mounts = mounts
+	g.Config.Linux.Resources = nil
+
+	return g, nil
+}
+
 // GenerateBundleConfig generates a minimal OCI bundle directory
 // with the provided OCI configuration or a default one
 // if there is no configuration
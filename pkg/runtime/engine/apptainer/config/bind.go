@@ -17,18 +17,31 @@ type BindOption struct {
 	Value string `json:"value,omitempty"`
 }
 
+type bindOptionKind int
+
 const (
-	flagOption  = true
-	valueOption = false
+	flagOption bindOptionKind = iota
+	valueOption
+	// optionalValueOption is a flag option which may take a value.
+	optionalValueOption
 )
 
-// bindOptions is a map of option strings valid in bind specifications.
-// If true, the option is a flag. If false, the option takes a value.
-var bindOptions = map[string]bool{
+// bindOptions is a map of option strings valid in bind specifications
+// along with their kind.
+var bindOptions = map[string]bindOptionKind{
 	"ro":        flagOption,
 	"rw":        flagOption,
 	"image-src": valueOption,
 	"id":        valueOption,
+	"idmap":     optionalValueOption,
+}
+
+// matchBindOption returns whether s is the option name of the given kind.
+func matchBindOption(s, name string, kind bindOptionKind) bool {
+	if kind != valueOption && s == name {
+		return true
+	}
+	return kind != flagOption && strings.HasPrefix(s, name+"=")
 }
 
 // BindPath stores a parsed bind path specification. Source and Destination
@@ -66,6 +79,15 @@ func (b *BindPath) Readonly() bool {
 	return b.Options != nil && b.Options["ro"] != nil
 }
 
+// IDMap returns true if the idmap option was set for a BindPath, along
+// with the option value which is the container user to map if any.
+func (b *BindPath) IDMap() (string, bool) {
+	if b.Options != nil && b.Options["idmap"] != nil {
+		return b.Options["idmap"].Value, true
+	}
+	return "", false
+}
+
 // ParseBindPath parses a an array of strings each specifying one or
 // more (comma separated) bind paths in src[:dst[:options]] format, and
 // returns all encountered bind paths as a slice. Options may be simple
@@ -101,17 +123,10 @@ func ParseBindPath(paths []string) ([]BindPath, error) {
 
 			isOption := false
 
-			for option, flag := range bindOptions {
-				if flag {
-					if s == option {
-						isOption = true
-						break
-					}
-				} else {
-					if strings.HasPrefix(s, option+"=") {
-						isOption = true
-						break
-					}
+			for option, kind := range bindOptions {
+				if matchBindOption(s, option, kind) {
+					isOption = true
+					break
 				}
 			}
 
@@ -252,16 +267,13 @@ func newBindPath(bind string) (BindPath, error) {
 
 		for _, value := range strings.Split(splitted[2], ",") {
 			valid := false
-			for optName, isFlag := range bindOptions {
-				if isFlag && optName == value {
-					bp.Options[optName] = &BindOption{}
-					valid = true
-					break
-				} else if strings.HasPrefix(value, optName+"=") {
-					bp.Options[optName] = &BindOption{Value: value[len(optName+"="):]}
-					valid = true
-					break
+			for optName, kind := range bindOptions {
+				if !matchBindOption(value, optName, kind) {
+					continue
 				}
+				bp.Options[optName] = &BindOption{Value: strings.TrimPrefix(value[len(optName):], "=")}
+				valid = true
+				break
 			}
 			if !valid {
 				return bp, fmt.Errorf("%s is not a valid bind option", value)
@@ -174,6 +174,44 @@ func TestParseBindPath(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "srcDstIDMap",
+			bindpaths: []string{"/opt:/other:idmap"},
+			want: []BindPath{
+				{
+					Source:      "/opt",
+					Destination: "/other",
+					Options: map[string]*BindOption{
+						"idmap": {},
+					},
+				},
+			},
+		},
+		{
+			name:      "srcDstIDMapUser",
+			bindpaths: []string{"/opt:/other:ro,idmap=1000,/tmp"},
+			want: []BindPath{
+				{
+					Source:      "/opt",
+					Destination: "/other",
+					Options: map[string]*BindOption{
+						"ro":    {},
+						"idmap": {"1000"},
+					},
+				},
+				{
+					Source:      "/tmp",
+					Destination: "/tmp",
+				},
+			},
+		},
+		{
+			// Flag options don't take a value
+			name:      "srcDstROValue",
+			bindpaths: []string{"/opt:/other:ro=1"},
+			want:      []BindPath{},
+			wantErr:   true,
+		},
 		{
 			name:      "invalidOption",
 			bindpaths: []string{"/opt:/other:invalid"},
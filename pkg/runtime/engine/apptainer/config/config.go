@@ -69,6 +69,14 @@ type DMTCPConfig struct {
 	Args       []string `json:"args,omitempty"`
 }
 
+// CRIUConfig stores the CRIU-related information required to restore
+// container processes from a checkpoint.
+type CRIUConfig struct {
+	Enabled    bool     `json:"enabled,omitempty"`
+	Checkpoint string   `json:"checkpoint,omitempty"`
+	Args       []string `json:"args,omitempty"`
+}
+
 type UserInfo struct {
 	Username string         `json:"username,omitempty"`
 	Home     string         `json:"home,omitempty"`
@@ -90,6 +98,7 @@ type JSONConfig struct {
 	FuseMount             []FuseMount       `json:"fuseMount,omitempty"`
 	ImageList             []image.Image     `json:"imageList,omitempty"`
 	BindPath              []BindPath        `json:"bindpath,omitempty"`
+	Tmpfs                 []TmpfsMount      `json:"tmpfs,omitempty"`
 	ApptainerEnv          map[string]string `json:"apptainerEnv,omitempty"`
 	UnixSocketPair        [2]int            `json:"unixSocketPair,omitempty"`
 	OpenFd                []int             `json:"openFd,omitempty"`
@@ -99,6 +108,7 @@ type JSONConfig struct {
 	Workdir               string            `json:"workdir,omitempty"`
 	ConfigDir             string            `json:"configdir,omitempty"`
 	CgroupsJSON           string            `json:"cgroupsJSON,omitempty"`
+	StatsFile             string            `json:"statsFile,omitempty"`
 	HomeSource            string            `json:"homedir,omitempty"`
 	HomeDest              string            `json:"homeDest,omitempty"`
 	Command               string            `json:"command,omitempty"`
@@ -118,6 +128,7 @@ type JSONConfig struct {
 	TargetUID             int               `json:"targetUID,omitempty"`
 	WritableImage         bool              `json:"writableImage,omitempty"`
 	WritableTmpfs         bool              `json:"writableTmpfs,omitempty"`
+	ReadOnlyStrict        bool              `json:"readOnlyStrict,omitempty"`
 	Contain               bool              `json:"container,omitempty"`
 	NvLegacy              bool              `json:"nvLegacy,omitempty"`
 	NvCCLI                bool              `json:"nvCCLI,omitempty"`
@@ -147,6 +158,7 @@ type JSONConfig struct {
 	DeleteTempDir         string            `json:"deleteTempDir,omitempty"`
 	Umask                 int               `json:"umask,omitempty"`
 	DMTCPConfig           DMTCPConfig       `json:"dmtcpConfig,omitempty"`
+	CRIUConfig            CRIUConfig        `json:"criuConfig,omitempty"`
 	XdgRuntimeDir         string            `json:"xdgRuntimeDir,omitempty"`
 	DbusSessionBusAddress string            `json:"dbusSessionBusAddress,omitempty"`
 	NoEval                bool              `json:"noEval,omitempty"`
@@ -637,6 +649,27 @@ func (e *EngineConfig) GetWritableTmpfs() bool {
 	return e.JSON.WritableTmpfs
 }
 
+// SetReadOnlyStrict sets the strict read-only flag, mounting the container
+// read-only except for the tmpfs mounts.
+func (e *EngineConfig) SetReadOnlyStrict(strict bool) {
+	e.JSON.ReadOnlyStrict = strict
+}
+
+// GetReadOnlyStrict returns if strict read-only is set or no.
+func (e *EngineConfig) GetReadOnlyStrict() bool {
+	return e.JSON.ReadOnlyStrict
+}
+
+// SetTmpfs sets the tmpfs filesystems to mount into container.
+func (e *EngineConfig) SetTmpfs(tmpfs []TmpfsMount) {
+	e.JSON.Tmpfs = tmpfs
+}
+
+// GetTmpfs retrieves the tmpfs filesystems to mount into container.
+func (e *EngineConfig) GetTmpfs() []TmpfsMount {
+	return e.JSON.Tmpfs
+}
+
 // SetSecurity sets security feature arguments.
 func (e *EngineConfig) SetSecurity(security []string) {
 	e.JSON.Security = security
@@ -657,6 +690,18 @@ func (e *EngineConfig) GetCgroupsJSON() string {
 	return e.JSON.CgroupsJSON
 }
 
+// SetStatsFile sets the path of the file receiving the container resource
+// usage report when it exits.
+func (e *EngineConfig) SetStatsFile(path string) {
+	e.JSON.StatsFile = path
+}
+
+// GetStatsFile returns the path of the file receiving the container resource
+// usage report.
+func (e *EngineConfig) GetStatsFile() string {
+	return e.JSON.StatsFile
+}
+
 // SetTargetUID sets target UID to execute the container process as user ID.
 func (e *EngineConfig) SetTargetUID(uid int) {
 	e.JSON.TargetUID = uid
@@ -896,6 +941,16 @@ func (e *EngineConfig) GetDMTCPConfig() DMTCPConfig {
 	return e.JSON.DMTCPConfig
 }
 
+// SetCRIUConfig sets the criu configuration for the engine to restore the container processes.
+func (e *EngineConfig) SetCRIUConfig(config CRIUConfig) {
+	e.JSON.CRIUConfig = config
+}
+
+// GetCRIUConfig returns the criu configuration to restore the container processes.
+func (e *EngineConfig) GetCRIUConfig() CRIUConfig {
+	return e.JSON.CRIUConfig
+}
+
 // SetXdgRuntimeDir sets a XDG_RUNTIME_DIR value for rootless operations
 func (e *EngineConfig) SetXdgRuntimeDir(path string) {
 	e.JSON.XdgRuntimeDir = path
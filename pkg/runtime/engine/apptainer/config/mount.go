@@ -15,7 +15,7 @@ import (
 //
 // Our intention is to support common docker --mount strings, but have
 // additional fields for apptainer specific concepts (image-src, id when
-// binding out of an image file).
+// binding out of an image file, idmap for ID-mapped mounts).
 //
 // We use a CSV reader to parse the fields in a mount string according to CSV
 // escaping rules. This is the approach docker uses to allow special characters
@@ -80,6 +80,9 @@ func ParseMountString(mount string) (bindPaths []BindPath, err error) {
 					return []BindPath{}, fmt.Errorf("id cannot be empty")
 				}
 				bp.Options["id"] = &BindOption{Value: val}
+			// Apptainer only - ID-mapped mount, optionally of a specific container user
+			case "idmap":
+				bp.Options["idmap"] = &BindOption{Value: val}
 			case "bind-propagation":
 				return []BindPath{}, fmt.Errorf("bind-propagation not supported for individual mounts, check apptainer.conf for global setting")
 			default:
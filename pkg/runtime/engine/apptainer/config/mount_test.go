@@ -194,6 +194,34 @@ func TestParseMountString(t *testing.T) {
 			want:        []BindPath{},
 			wantErr:     true,
 		},
+		{
+			name:        "idmap",
+			mountString: "type=bind,source=/opt,destination=/opt,idmap",
+			want: []BindPath{
+				{
+					Source:      "/opt",
+					Destination: "/opt",
+					Options: map[string]*BindOption{
+						"idmap": {},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "idmapUser",
+			mountString: "type=bind,source=/opt,destination=/opt,idmap=1000",
+			want: []BindPath{
+				{
+					Source:      "/opt",
+					Destination: "/opt",
+					Options: map[string]*BindOption{
+						"idmap": {"1000"},
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name:        "bindpropagation",
 			mountString: "type=bind,source=/opt,destination=/opt,bind-propagation=shared",
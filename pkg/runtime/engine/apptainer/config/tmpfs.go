@@ -0,0 +1,73 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TmpfsMount stores a tmpfs filesystem to mount into the container.
+type TmpfsMount struct {
+	Destination string   `json:"destination"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// ParseTmpfsString converts a --tmpfs string into a TmpfsMount. The string
+// is in <destination>[:<options>] format, where options is a comma separated
+// list of tmpfs mount options, e.g.:
+//
+//	/var/run:size=64M,mode=755
+func ParseTmpfsString(tmpfs string) (TmpfsMount, error) {
+	t := TmpfsMount{}
+
+	dest, opts, _ := strings.Cut(tmpfs, ":")
+	if dest == "" {
+		return t, fmt.Errorf("tmpfs destination cannot be empty")
+	}
+	if !filepath.IsAbs(dest) {
+		return t, fmt.Errorf("tmpfs destination %s must be an absolute path", dest)
+	}
+	t.Destination = filepath.Clean(dest)
+	if t.Destination == "/" {
+		return t, fmt.Errorf("tmpfs cannot be mounted on the container root")
+	}
+
+	if opts == "" {
+		return t, nil
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		key, val, hasVal := strings.Cut(opt, "=")
+		switch key {
+		case "size", "nr_inodes":
+			if val == "" {
+				return t, fmt.Errorf("tmpfs %s option requires a value", key)
+			}
+		case "mode":
+			if _, err := strconv.ParseUint(val, 8, 32); err != nil {
+				return t, fmt.Errorf("tmpfs mode %q is not an octal value", val)
+			}
+		case "uid", "gid":
+			if _, err := strconv.ParseUint(val, 10, 32); err != nil {
+				return t, fmt.Errorf("tmpfs %s %q is not a numeric value", key, val)
+			}
+		case "noexec", "exec", "noatime":
+			if hasVal {
+				return t, fmt.Errorf("tmpfs %s option doesn't take a value", key)
+			}
+		default:
+			return t, fmt.Errorf("invalid tmpfs option %q", opt)
+		}
+		t.Options = append(t.Options, opt)
+	}
+
+	return t, nil
+}
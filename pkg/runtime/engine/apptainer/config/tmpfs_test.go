@@ -0,0 +1,96 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTmpfsString(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpfs   string
+		want    TmpfsMount
+		wantErr bool
+	}{
+		{
+			name:  "destinationOnly",
+			tmpfs: "/var/run",
+			want:  TmpfsMount{Destination: "/var/run"},
+		},
+		{
+			name:  "options",
+			tmpfs: "/var/run/:size=64M,mode=755,noexec",
+			want: TmpfsMount{
+				Destination: "/var/run",
+				Options:     []string{"size=64M", "mode=755", "noexec"},
+			},
+		},
+		{
+			name:  "ownership",
+			tmpfs: "/scratch:uid=1000,gid=1000,nr_inodes=1k",
+			want: TmpfsMount{
+				Destination: "/scratch",
+				Options:     []string{"uid=1000", "gid=1000", "nr_inodes=1k"},
+			},
+		},
+		{
+			name:    "emptyDestination",
+			tmpfs:   ":size=64M",
+			wantErr: true,
+		},
+		{
+			name:    "relativeDestination",
+			tmpfs:   "var/run",
+			wantErr: true,
+		},
+		{
+			name:    "rootDestination",
+			tmpfs:   "/",
+			wantErr: true,
+		},
+		{
+			name:    "emptySize",
+			tmpfs:   "/var/run:size=",
+			wantErr: true,
+		},
+		{
+			name:    "badMode",
+			tmpfs:   "/var/run:mode=999",
+			wantErr: true,
+		},
+		{
+			name:    "badUID",
+			tmpfs:   "/var/run:uid=user",
+			wantErr: true,
+		},
+		{
+			name:    "flagValue",
+			tmpfs:   "/var/run:noexec=1",
+			wantErr: true,
+		},
+		{
+			name:    "invalidOption",
+			tmpfs:   "/var/run:suid",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTmpfsString(tt.tmpfs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTmpfsString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTmpfsString() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -131,6 +131,7 @@ type File struct {
 	DownloadConcurrency uint   `default:"3" directive:"download concurrency"`
 	DownloadPartSize    uint   `default:"5242880" directive:"download part size"`
 	DownloadBufferSize  uint   `default:"32768" directive:"download buffer size"`
+	LazyPull            bool   `default:"no" authorized:"yes,no" directive:"lazy pull"`
 	SystemdCgroups      bool   `default:"yes" authorized:"yes,no" directive:"systemd cgroups"`
 	// apptheus unix socket
 	ApptheusSocketPath string `default:"/run/apptheus/gateway.sock" directive:"apptheus communication socket path"`
@@ -616,6 +617,15 @@ download part size = {{ .DownloadPartSize }}
 # are enabled.
 download buffer size = {{ .DownloadBufferSize }}
 
+# LAZY PULL: [BOOL]
+# DEFAULT: no
+# Run docker:// images without converting them to SIF, as with the --lazy
+# option of the action commands. The root filesystem is served on demand by
+# a FUSE image driver, eStargz layers are fetched by byte ranges from the
+# registry and other layers are downloaded into the cache. Running lazily
+# pulled images requires 'allow container dir' to be enabled.
+lazy pull = {{ if eq .LazyPull true }}yes{{ else }}no{{ end }}
+
 # SYSTEMD CGROUPS: [BOOL]
 # DEFAULT: yes
 # Whether to use systemd to manage container cgroups. Required for rootless cgroups
@@ -0,0 +1,198 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainerconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// DefaultOrigin is the origin reported for directives not set in any
+// configuration file.
+const DefaultOrigin = "default"
+
+// Origins maps configuration directives to the file each of their values
+// comes from, in the same order as the values of the corresponding
+// Directives.
+type Origins map[string][]string
+
+// DropInDir returns the directory holding the drop-in files of the
+// configuration file at path, e.g. /etc/apptainer/apptainer.conf.d.
+func DropInDir(path string) string {
+	return path + ".d"
+}
+
+// DropInFiles returns the drop-in files of the configuration file at path,
+// i.e. the *.conf files found in its drop-in directory, in lexical order.
+func DropInFiles(path string) ([]string, error) {
+	// filepath.Glob returns files in lexical order.
+	files, err := filepath.Glob(filepath.Join(DropInDir(path), "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dropins []string
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode().IsRegular() {
+			dropins = append(dropins, f)
+		}
+	}
+	return dropins, nil
+}
+
+// directive describes a configuration directive of File.
+type directive struct {
+	name         string
+	list         bool
+	defaultValue string
+}
+
+// getDirectives returns the directives of File, in declaration order.
+func getDirectives() []directive {
+	t := reflect.TypeOf(File{})
+
+	directives := make([]directive, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		directives = append(directives, directive{
+			name:         f.Tag.Get("directive"),
+			list:         f.Type.Kind() == reflect.Slice,
+			defaultValue: f.Tag.Get("default"),
+		})
+	}
+	return directives
+}
+
+// isListDirective returns true if the directive named name accepts a list
+// of values.
+func isListDirective(name string) bool {
+	for _, d := range getDirectives() {
+		if d.name == name {
+			return d.list
+		}
+	}
+	return false
+}
+
+// merge merges the directives src read from file into d, recording their
+// origin in o. Values of list directives are appended, values of other
+// directives override the existing ones.
+func (d Directives) merge(file string, src Directives, o Origins) {
+	for name, values := range src {
+		origins := make([]string, len(values))
+		for i := range origins {
+			origins[i] = file
+		}
+
+		if isListDirective(name) {
+			d[name] = append(d[name], values...)
+			o[name] = append(o[name], origins...)
+		} else {
+			d[name] = values
+			o[name] = origins
+		}
+	}
+}
+
+// readDirectives reads the directives of the configuration file at path.
+func readDirectives(path string) (Directives, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	directives, err := GetDirectives(f)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s: %s", path, err)
+	}
+	return directives, nil
+}
+
+// LoadDirectives reads the directives of the configuration file at path,
+// merged with those of its drop-in files in lexical order, and returns the
+// file each value comes from. Values of list directives, like "bind path",
+// are appended, values of other directives are overridden.
+func LoadDirectives(path string) (Directives, Origins, error) {
+	directives := make(Directives)
+	origins := make(Origins)
+
+	main, err := readDirectives(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	directives.merge(path, main, origins)
+
+	dropins, err := DropInFiles(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while listing drop-in files: %s", err)
+	}
+	for _, f := range dropins {
+		d, err := readDirectives(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		directives.merge(f, d, origins)
+	}
+
+	return directives, origins, nil
+}
+
+// OriginValue is an effective configuration value and the file it comes
+// from, or DefaultOrigin.
+type OriginValue struct {
+	Directive string
+	Value     string
+	Origin    string
+}
+
+// EffectiveValues returns the effective values of the configuration
+// directives, in the order of apptainer.conf, with their origin. Only the
+// first value of directives which don't accept a list of values is
+// effective. Directives without value are omitted.
+func EffectiveValues(directives Directives, origins Origins) []OriginValue {
+	var values []OriginValue
+
+	for _, d := range getDirectives() {
+		vals := directives[d.name]
+		if len(vals) == 0 {
+			if d.defaultValue == "" {
+				continue
+			}
+			vals = []string{d.defaultValue}
+			if d.list {
+				vals = strings.Split(d.defaultValue, ",")
+			}
+			for _, v := range vals {
+				values = append(values, OriginValue{Directive: d.name, Value: v, Origin: DefaultOrigin})
+			}
+			continue
+		}
+
+		if !d.list {
+			vals = vals[:1]
+		}
+		for i, v := range vals {
+			origin := DefaultOrigin
+			if i < len(origins[d.name]) {
+				origin = origins[d.name][i]
+			}
+			values = append(values, OriginValue{Directive: d.name, Value: v, Origin: origin})
+		}
+	}
+
+	return values
+}
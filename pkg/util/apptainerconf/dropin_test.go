@@ -0,0 +1,117 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainerconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDropIn(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "apptainer.conf")
+	dropins := DropInDir(conf)
+
+	writeFile(t, conf, "bind path = /etc/hosts\nmax loop devices = 128\nmount home = yes\n")
+
+	// No drop-in directory.
+	config, err := Parse(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.MaxLoopDevices != 128 {
+		t.Errorf("bad value for MaxLoopDevices: %v", config.MaxLoopDevices)
+	}
+
+	if err := os.Mkdir(dropins, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dropins, "20-site.conf"), "bind path = /scratch\nmax loop devices = 512\n")
+	writeFile(t, filepath.Join(dropins, "10-base.conf"), "bind path = /data\nmax loop devices = 256\nmount home = no\n")
+	writeFile(t, filepath.Join(dropins, "30-ignored.conf.bak"), "mount home = yes\n")
+	if err := os.Mkdir(filepath.Join(dropins, "40-dir.conf"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := DropInFiles(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	wantFiles := []string{filepath.Join(dropins, "10-base.conf"), filepath.Join(dropins, "20-site.conf")}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("got drop-in files %v, want %v", files, wantFiles)
+	}
+
+	config, err = Parse(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"/etc/hosts", "/data", "/scratch"}; !reflect.DeepEqual(config.BindPath, want) {
+		t.Errorf("bad value for BindPath: %v", config.BindPath)
+	}
+	if config.MaxLoopDevices != 512 {
+		t.Errorf("bad value for MaxLoopDevices: %v", config.MaxLoopDevices)
+	}
+	if config.MountHome {
+		t.Errorf("bad value for MountHome: %v", config.MountHome)
+	}
+
+	// ParseFile ignores drop-in files.
+	config, err = ParseFile(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.MaxLoopDevices != 128 {
+		t.Errorf("bad value for MaxLoopDevices: %v", config.MaxLoopDevices)
+	}
+
+	directives, origins, err := LoadDirectives(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := make(map[string][]OriginValue)
+	for _, v := range EffectiveValues(directives, origins) {
+		got[v.Directive] = append(got[v.Directive], v)
+	}
+	want := map[string][]OriginValue{
+		"bind path": {
+			{Directive: "bind path", Value: "/etc/hosts", Origin: conf},
+			{Directive: "bind path", Value: "/data", Origin: wantFiles[0]},
+			{Directive: "bind path", Value: "/scratch", Origin: wantFiles[1]},
+		},
+		"max loop devices": {{Directive: "max loop devices", Value: "512", Origin: wantFiles[1]}},
+		"mount home":       {{Directive: "mount home", Value: "no", Origin: wantFiles[0]}},
+		"mount proc":       {{Directive: "mount proc", Value: "yes", Origin: DefaultOrigin}},
+	}
+	for name, values := range want {
+		if !reflect.DeepEqual(got[name], values) {
+			t.Errorf("got %v for %q, want %v", got[name], name, values)
+		}
+	}
+	if _, ok := got["cni configuration path"]; ok {
+		t.Errorf("unexpected value for directive without value")
+	}
+
+	// Invalid values in drop-in files are reported.
+	writeFile(t, filepath.Join(dropins, "50-bad.conf"), "mount home = maybe\n")
+	if _, err := Parse(conf); err == nil {
+		t.Errorf("unexpected success with invalid drop-in value")
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainerconf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/apptainer/apptainer/pkg/util/namespaces"
+)
+
+const (
+	// OverrideUser is the kind of overrides scoped to Unix users.
+	OverrideUser = "user"
+	// OverrideGroup is the kind of overrides scoped to Unix groups.
+	OverrideGroup = "group"
+)
+
+var sectionReg = regexp.MustCompile(`^\s*\[\s*(\S+)\s+([^\]\s]+)\s*\]\s*$`)
+
+// Override holds configuration directives overriding those of
+// apptainer.conf for the Unix user or group Name, a name or a numeric ID.
+type Override struct {
+	Kind       string
+	Name       string
+	Directives Directives
+}
+
+// String returns the section header of the override, e.g. [group students].
+func (o Override) String() string {
+	return fmt.Sprintf("[%s %s]", o.Kind, o.Name)
+}
+
+// OverridesFile returns the path of the policy file holding the per-user
+// and per-group overrides of the configuration file at path, e.g.
+// /etc/apptainer/apptainer-overrides.conf.
+func OverridesFile(path string) string {
+	return strings.TrimSuffix(path, ".conf") + "-overrides.conf"
+}
+
+// ParseOverrides parses the sections of an overrides policy file from
+// reader. Each section starts with a [user NAME] or [group NAME] header,
+// followed by the directives applying to the user, or the members of the
+// group.
+func ParseOverrides(reader io.Reader) ([]Override, error) {
+	var overrides []Override
+
+	scanner := bufio.NewScanner(reader)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if match := sectionReg.FindStringSubmatch(line); match != nil {
+			kind := strings.ToLower(match[1])
+			if kind != OverrideUser && kind != OverrideGroup {
+				return nil, fmt.Errorf("line %d: unknown section kind %q, must be user or group", n, match[1])
+			}
+			overrides = append(overrides, Override{
+				Kind:       kind,
+				Name:       match[2],
+				Directives: make(Directives),
+			})
+			continue
+		}
+
+		match := parserReg.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: syntax error", n)
+		}
+		if len(overrides) == 0 {
+			return nil, fmt.Errorf("line %d: directive outside of a [user NAME] or [group NAME] section", n)
+		}
+		key := strings.TrimSpace(match[1])
+		if !HasDirective(key) {
+			return nil, fmt.Errorf("line %d: %q is not a valid configuration directive", n, key)
+		}
+		if val := strings.TrimSpace(match[2]); val != "" {
+			d := overrides[len(overrides)-1].Directives
+			d[key] = append(d[key], val)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("while reading data: %s", err)
+	}
+
+	return overrides, nil
+}
+
+// ReadOverrides reads the overrides policy file at path. A missing file
+// holds no overrides.
+func ReadOverrides(path string) ([]Override, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	overrides, err := ParseOverrides(f)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s: %s", path, err)
+	}
+	return overrides, nil
+}
+
+// membership functions, replaced by tests.
+var (
+	uidInList     = userInList
+	uidInAnyGroup = userInAnyGroup
+)
+
+// userInList returns true if the user with uid is in list, holding user
+// names, UIDs, or both.
+func userInList(uid int, list []string) (bool, error) {
+	uidStr := strconv.Itoa(uid)
+	if slices.Contains(list, uidStr) {
+		return true, nil
+	}
+	u, err := user.LookupId(uidStr)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(list, u.Username), nil
+}
+
+// userInAnyGroup returns true if the user with uid is a member of a group
+// in list, holding group names, GIDs, or both. An error is returned if the
+// user is not found to be a member while one of its groups can't be looked
+// up.
+func userInAnyGroup(uid int, list []string) (bool, error) {
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return false, err
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return false, err
+	}
+	var lookupErr error
+	for _, gid := range gids {
+		if slices.Contains(list, gid) {
+			return true, nil
+		}
+		g, err := user.LookupGroupId(gid)
+		if err != nil {
+			if lookupErr == nil {
+				lookupErr = fmt.Errorf("while looking up gid %s: %w", gid, err)
+			}
+			continue
+		}
+		if slices.Contains(list, g.Name) {
+			return true, nil
+		}
+	}
+	return false, lookupErr
+}
+
+// appliesTo returns true if the override applies to the user with uid.
+func (o Override) appliesTo(uid int) (bool, error) {
+	if o.Kind == OverrideGroup {
+		return uidInAnyGroup(uid, []string{o.Name})
+	}
+	return uidInList(uid, []string{o.Name})
+}
+
+// ApplyOverrides applies the overrides read from file applying to the user
+// with uid to d, recording their origin in o. Group overrides are applied
+// first, then user overrides, each in file order, so that user overrides
+// take precedence. Overridden values, including those of list directives,
+// replace the existing ones. As overrides may restrict the user, an error
+// is returned when the user or its groups can't be looked up to determine
+// whether an override applies.
+func (d Directives) ApplyOverrides(file string, overrides []Override, o Origins, uid int) error {
+	for _, kind := range []string{OverrideGroup, OverrideUser} {
+		for _, ov := range overrides {
+			if ov.Kind != kind || len(ov.Directives) == 0 {
+				continue
+			}
+			ok, err := ov.appliesTo(uid)
+			if err != nil {
+				return fmt.Errorf("while checking if %s of %s applies to uid %d: %w", ov, file, uid, err)
+			}
+			if !ok {
+				continue
+			}
+
+			origin := file + " " + ov.String()
+			for name, values := range ov.Directives {
+				origins := make([]string, len(values))
+				for i := range origins {
+					origins[i] = origin
+				}
+				d[name] = values
+				o[name] = origins
+			}
+		}
+	}
+	return nil
+}
+
+// originalUID returns the UID of the user running apptainer, outside of
+// any user namespace.
+func originalUID() int {
+	if uid, err := namespaces.HostUID(); err == nil {
+		return int(uid)
+	}
+	return os.Getuid()
+}
+
+// LoadUserDirectives returns the directives of the configuration file at
+// path merged with its drop-in files, see LoadDirectives, with the
+// overrides of its policy file, see OverridesFile, applying to the user
+// with uid.
+func LoadUserDirectives(path string, uid int) (Directives, Origins, error) {
+	directives, origins, err := LoadDirectives(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := OverridesFile(path)
+	overrides, err := ReadOverrides(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := directives.ApplyOverrides(file, overrides, origins, uid); err != nil {
+		return nil, nil, err
+	}
+
+	return directives, origins, nil
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Contributors to the Apptainer project, established as
+//   Apptainer a Series of LF Projects LLC.
+//   For website terms of use, trademark policy, privacy policy and other
+//   project policies see https://lfprojects.org/policies
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package apptainerconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Override
+		wantErr bool
+	}{
+		{
+			name: "Sections",
+			data: "# site policy\n[group gpu-users]\nalways use nv = yes\n\n[ user  1001 ]\nbind path = /a\nbind path = /b\n",
+			want: []Override{
+				{Kind: OverrideGroup, Name: "gpu-users", Directives: Directives{"always use nv": {"yes"}}},
+				{Kind: OverrideUser, Name: "1001", Directives: Directives{"bind path": {"/a", "/b"}}},
+			},
+		},
+		{
+			name:    "DirectiveOutsideSection",
+			data:    "always use nv = yes\n",
+			wantErr: true,
+		},
+		{
+			name:    "UnknownKind",
+			data:    "[host node1]\nalways use nv = yes\n",
+			wantErr: true,
+		},
+		{
+			name:    "UnknownDirective",
+			data:    "[user alice]\nunknown directive = yes\n",
+			wantErr: true,
+		},
+		{
+			name:    "SyntaxError",
+			data:    "[user alice]\nalways use nv\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOverrides(strings.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got overrides %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadUserDirectives(t *testing.T) {
+	// alice (1000) is a member of students and gpu-users, bob (1001) of
+	// students only.
+	// 1002 has no passwd entry, so overrides can't be evaluated.
+	uidInList = func(uid int, list []string) (bool, error) {
+		names := map[int]string{1000: "alice", 1001: "bob"}
+		if _, ok := names[uid]; !ok {
+			return false, fmt.Errorf("unknown uid %d", uid)
+		}
+		return list[0] == names[uid], nil
+	}
+	uidInAnyGroup = func(uid int, list []string) (bool, error) {
+		if uid == 1002 {
+			return false, fmt.Errorf("unknown uid %d", uid)
+		}
+		return list[0] == "students" || (uid == 1000 && list[0] == "gpu-users"), nil
+	}
+	t.Cleanup(func() {
+		uidInList = userInList
+		uidInAnyGroup = userInAnyGroup
+	})
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "apptainer.conf")
+	overrides := OverridesFile(conf)
+	if overrides != filepath.Join(dir, "apptainer-overrides.conf") {
+		t.Errorf("unexpected overrides file %s", overrides)
+	}
+
+	writeFile(t, conf, "limit container paths = /opt/images\nlimit container paths = /data/images\nmax loop devices = 256\n")
+
+	// No overrides file.
+	directives, _, err := LoadUserDirectives(conf, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := directives["max loop devices"]; !reflect.DeepEqual(got, []string{"256"}) {
+		t.Errorf("got max loop devices %v", got)
+	}
+
+	writeFile(t, overrides, `[user alice]
+max loop devices = 1024
+
+[group students]
+limit container paths = /shared/images
+max loop devices = 128
+
+[group gpu-users]
+always use nv = yes
+`)
+
+	tests := []struct {
+		uid     int
+		want    Directives
+		origins Origins
+		wantErr bool
+	}{
+		{
+			uid: 1000,
+			want: Directives{
+				"limit container paths": {"/shared/images"},
+				"max loop devices":      {"1024"},
+				"always use nv":         {"yes"},
+			},
+			origins: Origins{
+				"limit container paths": {overrides + " [group students]"},
+				"max loop devices":      {overrides + " [user alice]"},
+				"always use nv":         {overrides + " [group gpu-users]"},
+			},
+		},
+		{
+			uid: 1001,
+			want: Directives{
+				"limit container paths": {"/shared/images"},
+				"max loop devices":      {"128"},
+			},
+			origins: Origins{
+				"limit container paths": {overrides + " [group students]"},
+				"max loop devices":      {overrides + " [group students]"},
+			},
+		},
+		{
+			uid:     1002,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		directives, origins, err := LoadUserDirectives(conf, tt.uid)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("uid %d: unexpected error: %v", tt.uid, err)
+		}
+		if !reflect.DeepEqual(directives, tt.want) {
+			t.Errorf("uid %d: got directives %v, want %v", tt.uid, directives, tt.want)
+		}
+		if !reflect.DeepEqual(origins, tt.origins) {
+			t.Errorf("uid %d: got origins %v, want %v", tt.uid, origins, tt.origins)
+		}
+	}
+}
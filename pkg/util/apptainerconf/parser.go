@@ -172,13 +172,31 @@ func GetConfig(directives Directives) (*File, error) {
 	return file, nil
 }
 
-// Parse parses configuration file with the specified path.
+// Parse parses configuration file with the specified path, merged with
+// its drop-in files and the overrides applying to the current user, see
+// LoadUserDirectives.
 func Parse(filepath string) (*File, error) {
 	if filepath == "" {
 		// grab the default configuration
 		return GetConfig(nil)
 	}
 
+	directives, _, err := LoadUserDirectives(filepath, originalUID())
+	if err != nil {
+		return nil, err
+	}
+
+	return GetConfig(directives)
+}
+
+// ParseFile parses configuration file with the specified path alone,
+// ignoring its drop-in files.
+func ParseFile(filepath string) (*File, error) {
+	if filepath == "" {
+		// grab the default configuration
+		return GetConfig(nil)
+	}
+
 	c, err := os.Open(filepath)
 	if err != nil {
 		return nil, err
@@ -16,6 +16,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 // Listen wraps net.Listen to handle 108 characters issue
@@ -89,3 +91,26 @@ func WriteSocket(path string, data []byte) error {
 
 	return nil
 }
+
+// SendFile sends the file descriptor of f over the unix socket at path,
+// along with its name, as expected by the console socket of the OCI
+// runtime command line interface.
+func SendFile(path string, f *os.File) error {
+	c, err := Dial(path)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s socket: %s", path, err)
+	}
+	defer c.Close()
+
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("%s is not a unix socket", path)
+	}
+	rights := unix.UnixRights(int(f.Fd()))
+	if _, _, err := uc.WriteMsgUnix([]byte(f.Name()), rights, nil); err != nil {
+		return fmt.Errorf("failed to send file descriptor over socket: %s", err)
+	}
+	runtime.KeepAlive(f)
+
+	return nil
+}
@@ -11,11 +11,13 @@ package unix
 
 import (
 	"math/rand"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/apptainer/apptainer/internal/pkg/test"
+	"golang.org/x/sys/unix"
 )
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -90,3 +92,55 @@ func TestCreateSocket(t *testing.T) {
 		}
 	}
 }
+
+func TestSendFile(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	path := filepath.Join(t.TempDir(), "console.sock")
+	ln, err := CreateSocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- SendFile(path, f)
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	name := make([]byte, 64)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.(*net.UnixConn).ReadMsgUnix(name, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(name[:n]) != os.DevNull {
+		t.Errorf("got file name %q, want %q", name[:n], os.DevNull)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("unexpected control messages %v: %v", msgs, err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("unexpected file descriptors %v: %v", fds, err)
+	}
+	unix.Close(fds[0])
+}